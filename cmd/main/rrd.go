@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	// Embed time zone database, as container image has no tzdata.
+	_ "time/tzdata"
 
 	"aerospike.com/rrd/internal"
+	"aerospike.com/rrd/internal/config"
 )
 
 func main() {
-	app, err := internal.NewApp()
+	configPath := flag.String("config", "", "path to yaml or toml config file, "+config.EnvConfigFile+" env is used if empty")
+	flag.Parse()
+
+	app, err := internal.NewApp(config.Path(*configPath))
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err = app.Start(); err != nil {
+
+	// Kubernetes sends SIGTERM before killing the pod.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = app.Start(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Alert rules and silences created by API are kept in storage namespace, so they are shared by all instances.
+const (
+	setNameAlertRules = "alert_rules"
+	setNameSilences   = "alert_silences"
+
+	binNameTenant  = "tenant"
+	binNameRule    = "rule"
+	binNameSilence = "silence"
+)
+
+// CreateAlertRule saves new rule of the tenant, it returns models.ErrConflict if rule already exists.
+func (s *Storage) CreateAlertRule(ctx context.Context, tenant string, rule models.AlertRule) (err error) {
+	ctx, end := s.start(ctx, opSaveAlert, attribute.String("rrd.tenant", tenant))
+	defer end(&err)
+	policy := s.writePolicy(0, aerospike.TTLDontExpire)
+	policy.RecordExistsAction = aerospike.CREATE_ONLY
+	if err = s.putAlert(ctx, policy, setNameAlertRules, tenant, rule.Name, binNameRule, rule); err != nil {
+		return fmt.Errorf("failed to create rule %s: %w", rule.Name, err)
+	}
+	return nil
+}
+
+// UpdateAlertRule replaces rule of the tenant.
+func (s *Storage) UpdateAlertRule(ctx context.Context, tenant string, rule models.AlertRule) (err error) {
+	ctx, end := s.start(ctx, opSaveAlert, attribute.String("rrd.tenant", tenant))
+	defer end(&err)
+	policy := s.writePolicy(0, aerospike.TTLDontExpire)
+	if err = s.putAlert(ctx, policy, setNameAlertRules, tenant, rule.Name, binNameRule, rule); err != nil {
+		return fmt.Errorf("failed to update rule %s: %w", rule.Name, err)
+	}
+	return nil
+}
+
+// DeleteAlertRule deletes rule of the tenant, missing rule is not an error.
+func (s *Storage) DeleteAlertRule(ctx context.Context, tenant, name string) (err error) {
+	ctx, end := s.start(ctx, opDeleteAlert, attribute.String("rrd.tenant", tenant))
+	defer end(&err)
+	if err = s.deleteAlert(ctx, setNameAlertRules, tenant, name); err != nil {
+		return fmt.Errorf("failed to delete rule %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListAlertRules returns rules of all tenants keyed by tenant.
+func (s *Storage) ListAlertRules(ctx context.Context) (_ map[string][]models.AlertRule, err error) {
+	ctx, end := s.start(ctx, opListAlerts, semconv.DBNamespace(s.namespace))
+	defer end(&err)
+	rules := make(map[string][]models.AlertRule)
+	err = s.scanAlerts(ctx, setNameAlertRules, binNameRule, func(tenant string, data []byte) error {
+		var rule models.AlertRule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return err
+		}
+		rules[tenant] = append(rules[tenant], rule)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rules: %w", err)
+	}
+	return rules, nil
+}
+
+// CreateSilence saves new silence of the tenant.
+func (s *Storage) CreateSilence(ctx context.Context, tenant string, silence models.Silence) (err error) {
+	ctx, end := s.start(ctx, opSaveAlert, attribute.String("rrd.tenant", tenant))
+	defer end(&err)
+	policy := s.writePolicy(0, aerospike.TTLDontExpire)
+	policy.RecordExistsAction = aerospike.CREATE_ONLY
+	if err = s.putAlert(ctx, policy, setNameSilences, tenant, silence.ID, binNameSilence, silence); err != nil {
+		return fmt.Errorf("failed to create silence %s: %w", silence.ID, err)
+	}
+	return nil
+}
+
+// DeleteSilence deletes silence of the tenant, missing silence is not an error.
+func (s *Storage) DeleteSilence(ctx context.Context, tenant, id string) (err error) {
+	ctx, end := s.start(ctx, opDeleteAlert, attribute.String("rrd.tenant", tenant))
+	defer end(&err)
+	if err = s.deleteAlert(ctx, setNameSilences, tenant, id); err != nil {
+		return fmt.Errorf("failed to delete silence %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListSilences returns silences of all tenants keyed by tenant, expired silences are returned until deleted.
+func (s *Storage) ListSilences(ctx context.Context) (_ map[string][]models.Silence, err error) {
+	ctx, end := s.start(ctx, opListAlerts, semconv.DBNamespace(s.namespace))
+	defer end(&err)
+	silences := make(map[string][]models.Silence)
+	err = s.scanAlerts(ctx, setNameSilences, binNameSilence, func(tenant string, data []byte) error {
+		var silence models.Silence
+		if err := json.Unmarshal(data, &silence); err != nil {
+			return err
+		}
+		silences[tenant] = append(silences[tenant], silence)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list silences: %w", err)
+	}
+	return silences, nil
+}
+
+// alertKey returns key of a rule or silence of the tenant, names of rules and ids of silences have no
+// tenant separator, so keys of tenants never clash.
+func (s *Storage) alertKey(set, tenant, name string) (*aerospike.Key, error) {
+	key, err := aerospike.NewKey(s.namespace, set, tenant+models.TenantSeparator+name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aerospike key: %w", err)
+	}
+	return key, nil
+}
+
+// putAlert saves value encoded in json to a bin of the record of the tenant.
+func (s *Storage) putAlert(
+	ctx context.Context, policy *aerospike.WritePolicy, set, tenant, name, bin string, value any,
+) error {
+	key, err := s.alertKey(set, tenant, name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode: %w", err)
+	}
+	bins := aerospike.BinMap{
+		binNameTenant: tenant,
+		bin:           string(data),
+	}
+	return s.call(ctx, false, func() error { return s.client.Put(policy, key, bins) })
+}
+
+func (s *Storage) deleteAlert(ctx context.Context, set, tenant, name string) error {
+	key, err := s.alertKey(set, tenant, name)
+	if err != nil {
+		return err
+	}
+	// Delete is idempotent, so it is retried.
+	return s.call(ctx, true, func() error {
+		_, err := s.client.Delete(nil, key)
+		return err
+	})
+}
+
+// scanAlerts passes tenant and json value of the bin of each record of the set to fn.
+func (s *Storage) scanAlerts(ctx context.Context, set, bin string, fn func(tenant string, data []byte) error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+	var recordset *aerospike.Recordset
+	err := s.call(ctx, true, func() (err error) {
+		recordset, err = s.client.ScanAll(nil, s.namespace, set, binNameTenant, bin)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", set, err)
+	}
+	defer recordset.Close()
+
+	for res := range recordset.Results() {
+		if res.Err != nil {
+			return fmt.Errorf("failed to iterate over %s: %w", set, classifyError(res.Err))
+		}
+		tenant, _ := res.Record.Bins[binNameTenant].(string)
+		data, _ := res.Record.Bins[bin].(string)
+		if err := fn(tenant, []byte(data)); err != nil {
+			return fmt.Errorf("failed to decode record of %s: %w", set, err)
+		}
+	}
+	return nil
+}
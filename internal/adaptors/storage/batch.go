@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+	"github.com/aerospike/aerospike-client-go/v7/types"
+	"go.opentelemetry.io/otel/attribute"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// batchSeries contains writes of a series in a batch.
+type batchSeries struct {
+	retention models.Retention
+	// indexes are positions of writes in the batch.
+	indexes []int
+}
+
+// SetBatch saves records in a single batch request, keeping series within retention limits like Set.
+// Counter of each series is saved once per batch. It returns error of each write in the same order.
+func (s *Storage) SetBatch(ctx context.Context, writes []models.Write) []error {
+	ctx, end := s.start(ctx, opSetBatch, attribute.Int("rrd.writes", len(writes)))
+	s.pendingWrites.Add(int64(len(writes)))
+	defer s.pendingWrites.Add(-int64(len(writes)))
+
+	errs := make([]error, len(writes))
+	defer func() {
+		// The first error represents the batch in operation metrics.
+		var err error
+		for _, one := range errs {
+			if one != nil {
+				err = one
+				break
+			}
+		}
+		end(&err)
+	}()
+
+	if err := ctx.Err(); err != nil {
+		for i := range errs {
+			errs[i] = fmt.Errorf("context error: %w", err)
+		}
+		return errs
+	}
+
+	records := make([]aerospike.BatchRecordIfc, len(writes))
+	series := make(map[string]*batchSeries)
+	for i, write := range writes {
+		record, err := s.batchWrite(write)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		records[i] = record
+		one, ok := series[write.Record.Series]
+		if !ok {
+			one = &batchSeries{retention: write.Retention}
+			series[write.Record.Series] = one
+		}
+		one.indexes = append(one.indexes, i)
+	}
+
+	// Oldest records are evicted for all writes of a series that exceed its capacity.
+	for name, one := range series {
+		if err := s.evictForBatch(ctx, name, one); err != nil {
+			for _, i := range one.indexes {
+				errs[i] = err
+				records[i] = nil
+			}
+			delete(series, name)
+		}
+	}
+
+	batch := make([]aerospike.BatchRecordIfc, 0, len(records))
+	for _, record := range records {
+		if record != nil {
+			batch = append(batch, record)
+		}
+	}
+	var errBatch error
+	if len(batch) > 0 {
+		// Batch of puts is idempotent, so it is retried as a whole.
+		errBatch = s.call(ctx, true, func() error {
+			return s.client.BatchOperate(s.batchPolicy(), batch)
+		})
+	}
+	for i, record := range records {
+		if record == nil {
+			continue
+		}
+		errs[i] = batchRecordError(record.BatchRec(), errBatch)
+	}
+
+	for name, one := range series {
+		s.countBatch(ctx, name, one, errs)
+	}
+
+	return errs
+}
+
+// batchWrite returns batch put of a record.
+func (s *Storage) batchWrite(write models.Write) (*aerospike.BatchWrite, error) {
+	ttl, err := s.recordTTL(write.Record, write.Retention)
+	if err != nil {
+		return nil, err
+	}
+	key, errKey := s.placement(write.Record.Series).metricKey(write.Record.Series, write.Record.Timestamp)
+	if errKey != nil {
+		return nil, fmt.Errorf("failed to create aerospike key: %w", errKey)
+	}
+	bins, err := recordBins(write.Record)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]*aerospike.Operation, 0, len(bins))
+	for name, value := range bins {
+		ops = append(ops, aerospike.PutOp(aerospike.NewBin(name, value)))
+	}
+	policy := *s.client.DefaultBatchWritePolicy
+	policy.Expiration = ttl
+	return aerospike.NewBatchWrite(&policy, key, ops...), nil
+}
+
+// evictForBatch evicts oldest records of a series, so the batch writes fit into series capacity.
+func (s *Storage) evictForBatch(ctx context.Context, series string, batch *batchSeries) error {
+	counter, err := s.seriesCounter(ctx, series)
+	if err != nil {
+		return fmt.Errorf("failed to load counter: %w", err)
+	}
+	capacity := s.capacity(batch.retention)
+	total := counter.points.Load() + uint64(len(batch.indexes))
+	if total <= capacity {
+		return nil
+	}
+
+	evictions := min(total-capacity, uint64(len(batch.indexes)))
+	for i := uint64(0); i < evictions; i++ {
+		if err := s.evict(ctx, series); err != nil {
+			return fmt.Errorf("failed to evict records: %w", err)
+		}
+	}
+	return nil
+}
+
+// countBatch increases series counter by saved writes and saves it once.
+func (s *Storage) countBatch(ctx context.Context, series string, batch *batchSeries, errs []error) {
+	counter, err := s.seriesCounter(ctx, series)
+	if err != nil {
+		return
+	}
+	capacity := s.capacity(batch.retention)
+	changed := false
+	for _, i := range batch.indexes {
+		if errs[i] == nil && counter.points.Load() < capacity {
+			counter.points.Add(1)
+			changed = true
+		}
+	}
+	if changed {
+		s.SetCounter(ctx, series, int64(counter.points.Load()))
+	}
+}
+
+// batchRecordError returns error of a record in a batch, batch error is returned for records without result.
+func batchRecordError(record *aerospike.BatchRecord, errBatch error) error {
+	switch {
+	case record.ResultCode == types.OK:
+		return nil
+	case record.Err != nil:
+		return fmt.Errorf("failed to put bins: %w", classifyError(record.Err))
+	case errBatch != nil:
+		return fmt.Errorf("failed to put batch: %w", errBatch)
+	default:
+		err := &aerospike.AerospikeError{ResultCode: record.ResultCode}
+		return fmt.Errorf("failed to put bins: %w", classifyError(err))
+	}
+}
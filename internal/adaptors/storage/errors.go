@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+	"github.com/aerospike/aerospike-client-go/v7/types"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Result codes that mean the cluster is temporary unreachable or overloaded, so request can be retried.
+var unavailableCodes = []types.ResultCode{
+	types.NETWORK_ERROR,
+	types.TIMEOUT,
+	types.INVALID_NODE_ERROR,
+	types.SERVER_NOT_AVAILABLE,
+	types.NO_AVAILABLE_CONNECTIONS_TO_NODE,
+	types.MAX_RETRIES_EXCEEDED,
+	types.PARTITION_UNAVAILABLE,
+	types.DEVICE_OVERLOAD,
+	types.KEY_BUSY,
+	types.CLUSTER_KEY_MISMATCH,
+	types.SERVER_MEM_ERROR,
+	types.QUERY_TIMEOUT,
+}
+
+// Result codes that mean the request itself is invalid.
+var invalidArgumentCodes = []types.ResultCode{
+	types.PARAMETER_ERROR,
+	types.BIN_TYPE_ERROR,
+	types.RECORD_TOO_BIG,
+	types.BIN_NAME_TOO_LONG,
+}
+
+// classifyError wraps aerospike error with a matching sentinel error from models,
+// so upper layers can handle it without knowing about aerospike.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	ae := &aerospike.AerospikeError{}
+	if !errors.As(err, &ae) {
+		return err
+	}
+
+	switch {
+	case ae.Matches(types.KEY_NOT_FOUND_ERROR):
+		return fmt.Errorf("%w: %w", models.ErrNotFound, err)
+	case ae.Matches(types.KEY_EXISTS_ERROR, types.GENERATION_ERROR):
+		return fmt.Errorf("%w: %w", models.ErrConflict, err)
+	case ae.Matches(unavailableCodes...):
+		return fmt.Errorf("%w: %w", models.ErrUnavailable, err)
+	case ae.Matches(invalidArgumentCodes...):
+		return fmt.Errorf("%w: %w", models.ErrInvalidArgument, err)
+	default:
+		return err
+	}
+}
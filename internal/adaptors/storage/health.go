@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
+)
+
+const (
+	checkCluster = "aerospike_cluster"
+	checkIndex   = "aerospike_index"
+	checkUDF     = "aerospike_udf"
+	checkBreaker = "aerospike_breaker"
+
+	// indexStateReady is a state of secondary index that is ready for reads and writes.
+	indexStateReady = "RW"
+)
+
+// Check verifies cluster connectivity, secondary index state and udf registration,
+// that are set up by NewStorage. Checks are independent, so all of them are returned.
+func (s *Storage) Check(ctx context.Context) []models.Check {
+	return []models.Check{
+		newCheck(checkCluster, s.checkCluster(ctx)),
+		newCheck(checkIndex, s.checkIndex(ctx)),
+		newCheck(checkUDF, s.checkUDF(ctx)),
+		newCheck(checkBreaker, s.checkBreaker()),
+	}
+}
+
+// Capacity returns max points of a series with retention.
+func (s *Storage) Capacity(retention models.Retention) uint64 {
+	return s.capacity(retention)
+}
+
+func (s *Storage) checkCluster(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+	if !s.client.IsConnected() {
+		return models.NewDetailError(models.ErrUnavailable, "client is not connected")
+	}
+	return nil
+}
+
+// checkBreaker fails while circuit breaker is open, so traffic is routed to other instances.
+func (s *Storage) checkBreaker() error {
+	if state := s.breaker.State(); state == resilience.BreakerOpen {
+		return models.NewDetailError(models.ErrUnavailable, "circuit breaker is %s", state)
+	}
+	return nil
+}
+
+func (s *Storage) checkIndex(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	node, err := s.client.Cluster().GetRandomNode()
+	if err != nil {
+		return fmt.Errorf("failed to get node: %w", classifyError(err))
+	}
+	command := "sindex-list:ns=" + s.namespace
+	info, err := node.RequestInfo(aerospike.NewInfoPolicy(), command)
+	if err != nil {
+		return fmt.Errorf("failed to request index list: %w", classifyError(err))
+	}
+
+	state, ok := indexState(info[command], indexTimestamp)
+	if !ok {
+		return fmt.Errorf("index %s not found", indexTimestamp)
+	}
+	if state != indexStateReady {
+		return fmt.Errorf("index %s is in %s state", indexTimestamp, state)
+	}
+	return nil
+}
+
+func (s *Storage) checkUDF(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	udfs, err := s.client.ListUDF(nil)
+	if err != nil {
+		return fmt.Errorf("failed to list udf: %w", classifyError(err))
+	}
+	registered := make(map[string]struct{}, len(udfs))
+	for _, udf := range udfs {
+		registered[udf.Filename] = struct{}{}
+	}
+	for _, module := range udfModules {
+		if _, ok := registered[module+".lua"]; !ok {
+			return fmt.Errorf("udf %s is not registered", module)
+		}
+	}
+	return nil
+}
+
+// indexState returns state of an index from sindex-list info response.
+// Response is a list of indexes separated by ';', each index is a list of key=value separated by ':'.
+func indexState(response, name string) (string, bool) {
+	for _, index := range strings.Split(response, ";") {
+		fields := make(map[string]string)
+		for _, field := range strings.Split(index, ":") {
+			key, value, _ := strings.Cut(field, "=")
+			fields[key] = value
+		}
+		if fields["indexname"] == name {
+			return fields["state"], true
+		}
+	}
+	return "", false
+}
+
+func newCheck(name string, err error) models.Check {
+	if err != nil {
+		return models.Check{Name: name, Status: models.CheckStatusFailed, Error: err.Error()}
+	}
+	return models.Check{Name: name, Status: models.CheckStatusOK}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexState(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		response string
+		state    string
+		found    bool
+	}{
+		{
+			"ns=test:indexname=idx_other:set=metrics:bin=value:type=numeric:state=RW;" +
+				"ns=test:indexname=idx_timestamp:set=metrics:bin=timestamp:type=numeric:indextype=default:state=WO",
+			"WO",
+			true,
+		},
+		{
+			"ns=test:indexname=idx_timestamp:set=metrics:bin=timestamp:type=numeric:state=RW;",
+			"RW",
+			true,
+		},
+		{
+			"ns=test:indexname=idx_other:set=metrics:bin=value:type=numeric:state=RW",
+			"",
+			false,
+		},
+		{
+			"",
+			"",
+			false,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			state, found := indexState(tc.response, indexTimestamp)
+			require.Equal(t, tc.found, found)
+			require.Equal(t, tc.state, state)
+		})
+	}
+}
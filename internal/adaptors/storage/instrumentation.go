@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"aerospike.com/rrd/internal/tracing"
+)
+
+var tracer = otel.Tracer("aerospike.com/rrd/internal/adaptors/storage")
+
+// Names of storage operations for instrumentation.
+const (
+	opSet          = "set"
+	opSetBatch     = "set_batch"
+	opGetByRange   = "get_by_range"
+	opEvict        = "evict"
+	opGetCounter   = "get_counter"
+	opFindOldest   = "find_oldest"
+	opCreateSeries = "create_series"
+	opUpdateSeries = "update_series"
+	opGetSeries    = "get_series"
+	opListSeries   = "list_series"
+	opListTenants  = "list_tenants"
+	opSeriesStats  = "series_stats"
+	opExpire       = "expire"
+	opTrim         = "trim"
+	opSaveAlert    = "save_alert"
+	opDeleteAlert  = "delete_alert"
+	opListAlerts   = "list_alerts"
+)
+
+type observer interface {
+	ObserveStorage(operation string, duration time.Duration, err error)
+}
+
+// start starts span of an operation, returned function ends the span and records latency and error
+// of the operation. It is deferred with a pointer to the returned error.
+func (s *Storage) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, func(err *error)) {
+	started := time.Now()
+	ctx, span := tracer.Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemKey.String("aerospike"), semconv.DBOperationName(operation)),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, func(err *error) {
+		if s.observer != nil {
+			s.observer.ObserveStorage(operation, time.Since(started), *err)
+		}
+		tracing.End(span, *err)
+	}
+}
+
+// seriesAttributes returns span attributes of an operation on a series.
+func (s *Storage) seriesAttributes(series string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.DBNamespace(s.placement(series).namespace),
+		attribute.String("rrd.series", series),
+	}
+}
+
+// PendingWrites returns number of writes in progress.
+func (s *Storage) PendingWrites() int {
+	return int(s.pendingWrites.Load())
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+)
+
+// Options contains storage and aerospike client params.
+type Options struct {
+	// Hosts are seed nodes in `host:port` or `host:tls_name:port` format.
+	Hosts     []string
+	Namespace string
+	// TenantNamespaces are namespaces of tenants, other tenants use Namespace.
+	TenantNamespaces map[string]string
+	// MaxRecords is a default capacity of a series without max points.
+	MaxRecords uint64
+	// UseTTL enables aerospike ttl for series with max age.
+	UseTTL  bool
+	UDFPath string
+
+	// ClusterName is an expected cluster name, it is not checked if empty.
+	ClusterName string
+	User        string
+	Password    string
+	// AuthMode is one of `internal`, `external` or `pki`.
+	AuthMode string
+	TLS      TLSOptions
+
+	ConnectionQueueSize   int
+	MinConnectionsPerNode int
+	ConnectTimeout        time.Duration
+	IdleTimeout           time.Duration
+	LoginTimeout          time.Duration
+
+	Read  PolicyOptions
+	Write PolicyOptions
+	// Query policy is used for queries and scans.
+	Query PolicyOptions
+
+	// RetryAttempts is a total number of attempts of idempotent calls on transient errors, 1 disables retries.
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// BreakerThreshold is a number of consecutive transient errors that opens circuit breaker, 0 disables it.
+	BreakerThreshold int
+	// BreakerCooldown is a time before a probe call is allowed through open breaker.
+	BreakerCooldown time.Duration
+}
+
+// TLSOptions contains TLS params, TLS is used if enabled.
+type TLSOptions struct {
+	Enabled bool
+	// Name is a TLS name of hosts without it.
+	Name string
+	// CAFile is a path to CA certificates, system pool is used if empty.
+	CAFile string
+	// CertFile and KeyFile are client certificate and key, they are required for mutual TLS and pki auth.
+	CertFile string
+	KeyFile  string
+}
+
+// PolicyOptions contains params of an operation policy.
+type PolicyOptions struct {
+	TotalTimeout        time.Duration
+	SocketTimeout       time.Duration
+	MaxRetries          int
+	SleepBetweenRetries time.Duration
+	// Replica is one of `master`, `master_proles`, `sequence`, `prefer_rack` or `random`, it is ignored for writes.
+	Replica string
+}
+
+var authModes = map[string]aerospike.AuthMode{
+	"internal": aerospike.AuthModeInternal,
+	"external": aerospike.AuthModeExternal,
+	"pki":      aerospike.AuthModePKI,
+}
+
+var replicaPolicies = map[string]aerospike.ReplicaPolicy{
+	"master":        aerospike.MASTER,
+	"master_proles": aerospike.MASTER_PROLES,
+	"sequence":      aerospike.SEQUENCE,
+	"prefer_rack":   aerospike.PREFER_RACK,
+	"random":        aerospike.RANDOM,
+}
+
+// clientPolicy returns aerospike client policy from options.
+func (o Options) clientPolicy() (*aerospike.ClientPolicy, error) {
+	policy := aerospike.NewClientPolicy()
+	policy.ClusterName = o.ClusterName
+	policy.User = o.User
+	policy.Password = o.Password
+	if o.AuthMode != "" {
+		mode, ok := authModes[o.AuthMode]
+		if !ok {
+			return nil, fmt.Errorf("unknown auth mode %q", o.AuthMode)
+		}
+		policy.AuthMode = mode
+	}
+	if o.ConnectionQueueSize > 0 {
+		policy.ConnectionQueueSize = o.ConnectionQueueSize
+	}
+	policy.MinConnectionsPerNode = o.MinConnectionsPerNode
+	if o.ConnectTimeout > 0 {
+		policy.Timeout = o.ConnectTimeout
+	}
+	policy.IdleTimeout = o.IdleTimeout
+	if o.LoginTimeout > 0 {
+		policy.LoginTimeout = o.LoginTimeout
+	}
+
+	if o.TLS.Enabled {
+		tlsConfig, err := o.TLS.config()
+		if err != nil {
+			return nil, err
+		}
+		policy.TlsConfig = tlsConfig
+	}
+
+	return policy, nil
+}
+
+// hosts parses seed hosts.
+func (o Options) hosts() ([]*aerospike.Host, error) {
+	if len(o.Hosts) == 0 {
+		return nil, fmt.Errorf("no hosts")
+	}
+
+	hosts := make([]*aerospike.Host, 0, len(o.Hosts))
+	for _, address := range o.Hosts {
+		host, err := parseHost(address)
+		if err != nil {
+			return nil, err
+		}
+		if host.TLSName == "" && o.TLS.Enabled {
+			host.TLSName = o.TLS.Name
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// parseHost parses host in `host:port` or `host:tls_name:port` format.
+func parseHost(address string) (*aerospike.Host, error) {
+	parts := strings.Split(address, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid host %q, must be host:port or host:tls_name:port", address)
+	}
+	port, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port of host %q", address)
+	}
+
+	host := aerospike.NewHost(parts[0], port)
+	if len(parts) == 3 {
+		host.TLSName = parts[1]
+	}
+	return host, nil
+}
+
+// config returns TLS config with CA and client certificates.
+func (o TLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA file %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// applyPolicies sets default policies of the client, so operations without explicit policy use them.
+func (o Options) applyPolicies(client *aerospike.Client) error {
+	if err := o.Read.apply(client.DefaultPolicy); err != nil {
+		return fmt.Errorf("invalid read policy: %w", err)
+	}
+	if err := o.Write.apply(&client.DefaultWritePolicy.BasePolicy); err != nil {
+		return fmt.Errorf("invalid write policy: %w", err)
+	}
+	if err := o.Query.apply(&client.DefaultQueryPolicy.BasePolicy); err != nil {
+		return fmt.Errorf("invalid query policy: %w", err)
+	}
+	if err := o.Query.apply(&client.DefaultScanPolicy.BasePolicy); err != nil {
+		return fmt.Errorf("invalid scan policy: %w", err)
+	}
+	return nil
+}
+
+func (o PolicyOptions) apply(policy *aerospike.BasePolicy) error {
+	policy.TotalTimeout = o.TotalTimeout
+	policy.SocketTimeout = o.SocketTimeout
+	policy.MaxRetries = o.MaxRetries
+	policy.SleepBetweenRetries = o.SleepBetweenRetries
+	if o.Replica != "" {
+		replica, ok := replicaPolicies[o.Replica]
+		if !ok {
+			return fmt.Errorf("unknown replica policy %q", o.Replica)
+		}
+		policy.ReplicaPolicy = replica
+	}
+	return nil
+}
+
+// writePolicy returns copy of the client default write policy with generation and ttl.
+func (s *Storage) writePolicy(generation, ttl uint32) *aerospike.WritePolicy {
+	policy := *s.client.DefaultWritePolicy
+	policy.Generation = generation
+	policy.Expiration = ttl
+	return &policy
+}
+
+// batchPolicy returns copy of the client default batch policy with timeouts and retries of write policy,
+// as batches are used for writes only.
+func (s *Storage) batchPolicy() *aerospike.BatchPolicy {
+	policy := *s.client.DefaultBatchPolicy
+	policy.BasePolicy = s.client.DefaultWritePolicy.BasePolicy
+	return &policy
+}
+
+// queryPolicy returns copy of the client default query policy, so filter expression can be set.
+func (s *Storage) queryPolicy() *aerospike.QueryPolicy {
+	policy := *s.client.DefaultQueryPolicy
+	return &policy
+}
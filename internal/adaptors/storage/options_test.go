@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHost(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		address string
+		host    *aerospike.Host
+		isErr   bool
+	}{
+		{"localhost:3000", &aerospike.Host{Name: "localhost", Port: 3000}, false},
+		{"10.0.0.1:as-cluster:4333", &aerospike.Host{Name: "10.0.0.1", TLSName: "as-cluster", Port: 4333}, false},
+		{"localhost", nil, true},
+		{":3000", nil, true},
+		{"localhost:port", nil, true},
+		{"localhost:70000", nil, true},
+		{"a:b:c:3000", nil, true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			host, err := parseHost(tc.address)
+			if tc.isErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.host, host)
+		})
+	}
+}
+
+func TestOptions_Hosts(t *testing.T) {
+	t.Parallel()
+	opts := Options{
+		Hosts: []string{"node1:3000", "node2:custom:4333"},
+		TLS:   TLSOptions{Enabled: true, Name: "as-cluster"},
+	}
+
+	hosts, err := opts.hosts()
+	require.NoError(t, err)
+	// Default TLS name is used for hosts without it.
+	require.Equal(t, []*aerospike.Host{
+		{Name: "node1", TLSName: "as-cluster", Port: 3000},
+		{Name: "node2", TLSName: "custom", Port: 4333},
+	}, hosts)
+
+	_, err = Options{}.hosts()
+	require.Error(t, err)
+}
+
+func TestOptions_ClientPolicy(t *testing.T) {
+	t.Parallel()
+	invalidCA := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(invalidCA, []byte("not a certificate"), 0o600))
+
+	testCases := []struct {
+		opts  Options
+		isErr bool
+	}{
+		{Options{User: "rrd", Password: "secret", AuthMode: "external", ConnectTimeout: time.Second}, false},
+		{Options{AuthMode: "kerberos"}, true},
+		{Options{TLS: TLSOptions{Enabled: true}}, false},
+		{Options{TLS: TLSOptions{Enabled: true, CAFile: "missing.pem"}}, true},
+		{Options{TLS: TLSOptions{Enabled: true, CAFile: invalidCA}}, true},
+		{Options{TLS: TLSOptions{Enabled: true, CertFile: "missing.pem", KeyFile: "missing.key"}}, true},
+		// TLS files are not loaded if TLS is disabled.
+		{Options{TLS: TLSOptions{CAFile: "missing.pem"}}, false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			policy, err := tc.opts.clientPolicy()
+			if tc.isErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.opts.User, policy.User)
+			require.Equal(t, tc.opts.TLS.Enabled, policy.TlsConfig != nil)
+		})
+	}
+}
+
+func TestPolicyOptions_Apply(t *testing.T) {
+	t.Parallel()
+	policy := aerospike.NewPolicy()
+	opts := PolicyOptions{
+		TotalTimeout:        2 * time.Second,
+		SocketTimeout:       time.Second,
+		MaxRetries:          3,
+		SleepBetweenRetries: 10 * time.Millisecond,
+		Replica:             "master_proles",
+	}
+
+	require.NoError(t, opts.apply(policy))
+	require.Equal(t, 2*time.Second, policy.TotalTimeout)
+	require.Equal(t, time.Second, policy.SocketTimeout)
+	require.Equal(t, 3, policy.MaxRetries)
+	require.Equal(t, 10*time.Millisecond, policy.SleepBetweenRetries)
+	require.Equal(t, aerospike.MASTER_PROLES, policy.ReplicaPolicy)
+
+	require.Error(t, PolicyOptions{Replica: "nearest"}.apply(policy))
+}
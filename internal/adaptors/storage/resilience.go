@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
+)
+
+// call runs a single aerospike call through circuit breaker and classifies its error.
+// Idempotent calls are retried with backoff while cluster is unavailable.
+func (s *Storage) call(ctx context.Context, idempotent bool, fn func() error) error {
+	attempt := func() error {
+		if err := s.breaker.Allow(); err != nil {
+			return fmt.Errorf("%w: %w", models.NewDetailError(models.ErrUnavailable, "storage is unavailable"), err)
+		}
+		err := classifyError(fn())
+		if errors.Is(err, models.ErrUnavailable) {
+			s.breaker.Failure()
+		} else {
+			// Not found, conflict and invalid argument errors mean cluster responded.
+			s.breaker.Success()
+		}
+		return err
+	}
+	if !idempotent {
+		return attempt()
+	}
+	return s.retry.Do(ctx, retryable, attempt)
+}
+
+// retryable returns true for transient errors, calls rejected by open breaker are not retried.
+func retryable(err error) bool {
+	return errors.Is(err, models.ErrUnavailable) && !errors.Is(err, resilience.ErrOpen)
+}
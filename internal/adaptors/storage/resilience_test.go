@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+	"github.com/aerospike/aerospike-client-go/v7/types"
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
+)
+
+func TestStorage_Call(t *testing.T) {
+	t.Parallel()
+	s := &Storage{
+		breaker: resilience.NewBreaker(3, time.Minute),
+		retry:   resilience.Retry{Attempts: 2, Backoff: time.Millisecond},
+	}
+	calls := 0
+	fail := func(code types.ResultCode) func() error {
+		return func() error {
+			calls++
+			return &aerospike.AerospikeError{ResultCode: code}
+		}
+	}
+	ctx := context.Background()
+
+	// Idempotent calls are retried on transient errors only.
+	require.ErrorIs(t, s.call(ctx, true, fail(types.TIMEOUT)), models.ErrUnavailable)
+	require.Equal(t, 2, calls)
+	require.ErrorIs(t, s.call(ctx, true, fail(types.KEY_NOT_FOUND_ERROR)), models.ErrNotFound)
+	require.Equal(t, 3, calls)
+	require.ErrorIs(t, s.call(ctx, false, fail(types.TIMEOUT)), models.ErrUnavailable)
+	require.Equal(t, 4, calls)
+	require.Equal(t, resilience.BreakerClosed, s.breaker.State())
+
+	// Breaker opens after consecutive transient errors and rejects calls without retries.
+	require.ErrorIs(t, s.call(ctx, true, fail(types.TIMEOUT)), models.ErrUnavailable)
+	require.Equal(t, resilience.BreakerOpen, s.breaker.State())
+	calls = 0
+	err := s.call(ctx, true, fail(types.TIMEOUT))
+	require.ErrorIs(t, err, models.ErrUnavailable)
+	require.ErrorIs(t, err, resilience.ErrOpen)
+	require.Zero(t, calls)
+	require.Error(t, s.checkBreaker())
+}
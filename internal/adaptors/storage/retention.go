@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// seriesCounter contains in-memory counters of a series.
+type seriesCounter struct {
+	// points is a number of stored points, it is persisted in counter set.
+	points atomic.Uint64
+	// expired is a number of points deleted because of max age.
+	expired atomic.Uint64
+	// evicted is a number of points deleted because of max points.
+	evicted atomic.Uint64
+}
+
+// seriesCounter returns counters of a series, points counter is loaded from a database on the first call.
+func (s *Storage) seriesCounter(ctx context.Context, series string) (*seriesCounter, error) {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	if counter, ok := s.counters[series]; ok {
+		return counter, nil
+	}
+
+	counter := &seriesCounter{}
+	points, err := s.GetCounter(ctx, series)
+	switch {
+	case err == nil:
+		counter.points.Store(uint64(points))
+	case errors.Is(err, models.ErrNotFound):
+	default:
+		return nil, err
+	}
+	s.counters[series] = counter
+	s.logger.Debug("initialized counter",
+		slog.String(binNameSeries, series),
+		slog.Int64(binNameCounter, points),
+	)
+
+	return counter, nil
+}
+
+// capacity returns max points of a series.
+func (s *Storage) capacity(retention models.Retention) uint64 {
+	if retention.MaxPoints > 0 {
+		return retention.MaxPoints
+	}
+	return s.maxRecords.Load()
+}
+
+// ExpireOlderThan deletes records of a series with timestamp less than cutoff.
+// It returns number of deleted records.
+func (s *Storage) ExpireOlderThan(ctx context.Context, series string, cutoff int64) (_ uint64, err error) {
+	ctx, end := s.start(ctx, opExpire, s.seriesAttributes(series)...)
+	defer end(&err)
+
+	timestamps, err := s.timestamps(ctx, series, 0, cutoff-1)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := s.deleteRecords(ctx, series, timestamps)
+	if deleted > 0 {
+		counter, errCounter := s.seriesCounter(ctx, series)
+		if errCounter == nil {
+			counter.expired.Add(deleted)
+			s.SetCounter(ctx, series, int64(subtract(&counter.points, deleted)))
+		}
+	}
+
+	return deleted, err
+}
+
+// TrimToCapacity deletes the oldest records of a series above max points and reconciles points counter.
+// Counter may drift when records expire by ttl or after max points decrease, so it is synced with actual count.
+// It returns number of deleted records.
+func (s *Storage) TrimToCapacity(ctx context.Context, series string, retention models.Retention) (_ uint64, err error) {
+	ctx, end := s.start(ctx, opTrim, s.seriesAttributes(series)...)
+	defer end(&err)
+
+	timestamps, err := s.timestamps(ctx, series, 0, 1<<63-1)
+	if err != nil {
+		return 0, err
+	}
+
+	counter, err := s.seriesCounter(ctx, series)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load counter: %w", err)
+	}
+
+	capacity := s.capacity(retention)
+	var deleted uint64
+	if uint64(len(timestamps)) > capacity {
+		sort.Slice(timestamps, func(i, j int) bool {
+			return timestamps[i] < timestamps[j]
+		})
+		deleted, err = s.deleteRecords(ctx, series, timestamps[:uint64(len(timestamps))-capacity])
+		counter.evicted.Add(deleted)
+	}
+
+	points := uint64(len(timestamps)) - deleted
+	counter.points.Store(points)
+	s.SetCounter(ctx, series, int64(points))
+
+	return deleted, err
+}
+
+// RetentionStats returns number of expired and evicted points per series since start.
+func (s *Storage) RetentionStats() map[string]models.SeriesRetentionStats {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	result := make(map[string]models.SeriesRetentionStats, len(s.counters))
+	for series, counter := range s.counters {
+		result[series] = models.SeriesRetentionStats{
+			Points:  counter.points.Load(),
+			Expired: counter.expired.Load(),
+			Evicted: counter.evicted.Load(),
+		}
+	}
+
+	return result
+}
+
+// timestamps returns timestamps of series records in range.
+func (s *Storage) timestamps(ctx context.Context, series string, min, max int64) ([]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	p := s.placement(series)
+	stmt := aerospike.NewStatement(p.namespace, p.metrics, binNameTimestamp)
+	if err := stmt.SetFilter(aerospike.NewRangeFilter(binNameTimestamp, min, max)); err != nil {
+		return nil, fmt.Errorf("failed to set statement filter: %w", classifyError(err))
+	}
+
+	policy := s.queryPolicy()
+	policy.FilterExpression = seriesFilter(series)
+
+	var recordset *aerospike.Recordset
+	err := s.call(ctx, true, func() (err error) {
+		recordset, err = s.client.Query(policy, stmt)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer recordset.Close()
+
+	result := make([]int64, 0)
+	for res := range recordset.Results() {
+		if res.Err != nil {
+			return nil, fmt.Errorf("failed to iterate over result: %w", classifyError(res.Err))
+		}
+		timestamp, ok := res.Record.Bins[binNameTimestamp].(int)
+		if !ok {
+			return nil, fmt.Errorf("failed to cast timestamp to int64")
+		}
+		result = append(result, int64(timestamp))
+	}
+
+	return result, nil
+}
+
+// deleteRecords deletes series records by timestamps, it returns number of deleted records.
+func (s *Storage) deleteRecords(ctx context.Context, series string, timestamps []int64) (uint64, error) {
+	p := s.placement(series)
+	var deleted uint64
+	for _, timestamp := range timestamps {
+		if err := ctx.Err(); err != nil {
+			return deleted, fmt.Errorf("context error: %w", err)
+		}
+		key, err := p.metricKey(series, timestamp)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to create aerospike key: %w", err)
+		}
+		var existed bool
+		errDelete := s.call(ctx, true, func() (err error) {
+			existed, err = s.client.Delete(nil, key)
+			return err
+		})
+		if errDelete != nil {
+			return deleted, fmt.Errorf("failed to delete record: %w", errDelete)
+		}
+		if existed {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// subtract decreases counter without going below zero, it returns new value.
+func subtract(counter *atomic.Uint64, delta uint64) uint64 {
+	for {
+		current := counter.Load()
+		next := uint64(0)
+		if current > delta {
+			next = current - delta
+		}
+		if counter.CompareAndSwap(current, next) {
+			return next
+		}
+	}
+}
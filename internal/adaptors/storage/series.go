@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+	"go.opentelemetry.io/otel/attribute"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const (
+	setNameSeries = "series"
+
+	binNameName        = "name"
+	binNameLabels      = "labels"
+	binNameUnit        = "unit"
+	binNameDescription = "description"
+	binNameDataSource  = "data_source"
+	binNameStep        = "step"
+	binNameMaxPoints   = "max_points"
+	binNameMaxAge      = "max_age"
+	binNameValueEnum   = "value_enum"
+	binNameCreatedAt   = "created_at"
+	binNameUpdatedAt   = "updated_at"
+	binNameHoltWinters = "holt_winters"
+)
+
+// CreateSeries saves new series definition, it returns models.ErrConflict if series already exists.
+func (s *Storage) CreateSeries(ctx context.Context, series models.Series) (err error) {
+	ctx, end := s.start(ctx, opCreateSeries, s.seriesAttributes(series.Name)...)
+	defer end(&err)
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	tenant, _ := models.SplitSeriesName(series.Name)
+	if err = s.registerTenant(ctx, tenant); err != nil {
+		return err
+	}
+
+	key, err := s.placement(series.Name).seriesKey(series.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create aerospike key: %w", err)
+	}
+
+	writePolicy := s.writePolicy(0, aerospike.TTLDontExpire)
+	writePolicy.RecordExistsAction = aerospike.CREATE_ONLY
+
+	// Retry of create only put may fail with conflict if the first attempt succeeded, so it is not retried.
+	if err = s.call(ctx, false, func() error { return s.client.Put(writePolicy, key, seriesToBins(series)) }); err != nil {
+		return fmt.Errorf("failed to put series: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateSeries replaces series definition. It returns models.ErrConflict
+// if series was modified after it was read with series.Version.
+func (s *Storage) UpdateSeries(ctx context.Context, series models.Series) (err error) {
+	ctx, end := s.start(ctx, opUpdateSeries, s.seriesAttributes(series.Name)...)
+	defer end(&err)
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("context error: %w", err)
+	}
+
+	key, err := s.placement(series.Name).seriesKey(series.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create aerospike key: %w", err)
+	}
+
+	writePolicy := s.writePolicy(series.Version, aerospike.TTLDontExpire)
+	writePolicy.RecordExistsAction = aerospike.REPLACE_ONLY
+	writePolicy.GenerationPolicy = aerospike.EXPECT_GEN_EQUAL
+
+	// Put with expected generation is not retried for the same reason as in CreateSeries.
+	if err = s.call(ctx, false, func() error { return s.client.Put(writePolicy, key, seriesToBins(series)) }); err != nil {
+		return fmt.Errorf("failed to put series: %w", err)
+	}
+
+	return nil
+}
+
+// GetSeries returns series definition, it returns models.ErrNotFound if series doesn't exist.
+func (s *Storage) GetSeries(ctx context.Context, name string) (_ models.Series, err error) {
+	ctx, end := s.start(ctx, opGetSeries, s.seriesAttributes(name)...)
+	defer end(&err)
+
+	if err := ctx.Err(); err != nil {
+		return models.Series{}, fmt.Errorf("context error: %w", err)
+	}
+
+	key, err := s.placement(name).seriesKey(name)
+	if err != nil {
+		return models.Series{}, fmt.Errorf("failed to create aerospike key: %w", err)
+	}
+
+	var record *aerospike.Record
+	err = s.call(ctx, true, func() (err error) {
+		record, err = s.client.Get(nil, key)
+		return err
+	})
+	if err != nil {
+		return models.Series{}, fmt.Errorf("failed to get series %s: %w", name, err)
+	}
+
+	return seriesFromRecord(record), nil
+}
+
+// ListSeries returns series definitions of all tenants in undefined order.
+func (s *Storage) ListSeries(ctx context.Context) ([]models.Series, error) {
+	tenants, err := s.ListTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Series, 0)
+	for _, tenant := range tenants {
+		series, err := s.ListTenantSeries(ctx, tenant)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, series...)
+	}
+
+	return result, nil
+}
+
+// ListTenantSeries returns series definitions of the tenant in undefined order.
+func (s *Storage) ListTenantSeries(ctx context.Context, tenant string) (_ []models.Series, err error) {
+	ctx, end := s.start(ctx, opListSeries, attribute.String("rrd.tenant", tenant))
+	defer end(&err)
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	p := s.tenantPlacement(tenant)
+	var recordset *aerospike.Recordset
+	err = s.call(ctx, true, func() (err error) {
+		recordset, err = s.client.ScanAll(nil, p.namespace, p.series)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan series: %w", err)
+	}
+	defer recordset.Close()
+
+	result := make([]models.Series, 0)
+	for res := range recordset.Results() {
+		if res.Err != nil {
+			return nil, fmt.Errorf("failed to iterate over series: %w", classifyError(res.Err))
+		}
+		result = append(result, seriesFromRecord(res.Record))
+	}
+
+	return result, nil
+}
+
+// SeriesPoints returns number of stored points of a series from its counter.
+func (s *Storage) SeriesPoints(ctx context.Context, series string) (uint64, error) {
+	counter, err := s.seriesCounter(ctx, series)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load counter: %w", err)
+	}
+	return counter.points.Load(), nil
+}
+
+// SeriesStats returns number of points, oldest and newest timestamps of a series.
+func (s *Storage) SeriesStats(ctx context.Context, series string) (_ models.SeriesStats, err error) {
+	ctx, end := s.start(ctx, opSeriesStats, s.seriesAttributes(series)...)
+	defer end(&err)
+
+	if err := ctx.Err(); err != nil {
+		return models.SeriesStats{}, fmt.Errorf("context error: %w", err)
+	}
+
+	policy := s.queryPolicy()
+	policy.FilterExpression = seriesFilter(series)
+
+	p := s.placement(series)
+	stmt := aerospike.NewStatement(p.namespace, p.metrics)
+	var recordset *aerospike.Recordset
+	err = s.call(ctx, true, func() (err error) {
+		recordset, err = s.client.QueryAggregate(policy, stmt, udfSeriesStats, udfSeriesStats)
+		return err
+	})
+	if err != nil {
+		return models.SeriesStats{}, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer recordset.Close()
+
+	var stats models.SeriesStats
+	for res := range recordset.Results() {
+		if res.Err != nil {
+			return models.SeriesStats{}, classifyError(res.Err)
+		}
+		if result, ok := res.Record.Bins["SUCCESS"].(map[interface{}]interface{}); ok {
+			points, _ := result["points"].(int)
+			stats.Points = uint64(points)
+			oldest, _ := result["oldest"].(int)
+			stats.Oldest = int64(oldest)
+			newest, _ := result["newest"].(int)
+			stats.Newest = int64(newest)
+		}
+	}
+
+	return stats, nil
+}
+
+func seriesToBins(series models.Series) aerospike.BinMap {
+	labels := make(map[string]any, len(series.Labels))
+	for k, v := range series.Labels {
+		labels[k] = v
+	}
+	enum := make([]any, 0, len(series.ValueType.Enum))
+	for _, v := range series.ValueType.Enum {
+		enum = append(enum, v)
+	}
+
+	bins := aerospike.BinMap{
+		binNameName:        series.Name,
+		binNameLabels:      labels,
+		binNameUnit:        series.Unit,
+		binNameDescription: series.Description,
+		binNameDataSource:  string(series.DataSource),
+		binNameStep:        series.Step,
+		binNameMaxPoints:   int64(series.Retention.MaxPoints),
+		binNameMaxAge:      series.Retention.MaxAge,
+		binNameValueType:   string(series.ValueType.Type),
+		binNameValueEnum:   enum,
+		binNameCreatedAt:   series.CreatedAt,
+		binNameUpdatedAt:   series.UpdatedAt,
+	}
+	if hw := series.HoltWinters; hw != nil {
+		bins[binNameHoltWinters] = map[string]any{
+			"alpha":             hw.Alpha,
+			"beta":              hw.Beta,
+			"gamma":             hw.Gamma,
+			"season_length":     hw.SeasonLength,
+			"deviation_scale":   hw.DeviationScale,
+			"failure_threshold": hw.FailureThreshold,
+			"failure_window":    hw.FailureWindow,
+		}
+	}
+	return bins
+}
+
+func seriesFromRecord(record *aerospike.Record) models.Series {
+	bins := record.Bins
+	series := models.Series{
+		Version: record.Generation,
+	}
+	series.Name, _ = bins[binNameName].(string)
+	series.Unit, _ = bins[binNameUnit].(string)
+	series.Description, _ = bins[binNameDescription].(string)
+	dataSource, _ := bins[binNameDataSource].(string)
+	series.DataSource = models.DataSourceType(dataSource)
+	step, _ := bins[binNameStep].(int)
+	series.Step = int64(step)
+	maxPoints, _ := bins[binNameMaxPoints].(int)
+	series.Retention.MaxPoints = uint64(maxPoints)
+	maxAge, _ := bins[binNameMaxAge].(int)
+	series.Retention.MaxAge = int64(maxAge)
+	valueType, _ := bins[binNameValueType].(string)
+	series.ValueType.Type = models.ValueType(valueType)
+	createdAt, _ := bins[binNameCreatedAt].(int)
+	series.CreatedAt = int64(createdAt)
+	updatedAt, _ := bins[binNameUpdatedAt].(int)
+	series.UpdatedAt = int64(updatedAt)
+
+	if labels, ok := bins[binNameLabels].(map[interface{}]interface{}); ok && len(labels) > 0 {
+		series.Labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			key, _ := k.(string)
+			value, _ := v.(string)
+			series.Labels[key] = value
+		}
+	}
+	if hw, ok := bins[binNameHoltWinters].(map[interface{}]interface{}); ok {
+		series.HoltWinters = &models.HoltWinters{}
+		series.HoltWinters.Alpha, _ = hw["alpha"].(float64)
+		series.HoltWinters.Beta, _ = hw["beta"].(float64)
+		series.HoltWinters.Gamma, _ = hw["gamma"].(float64)
+		series.HoltWinters.SeasonLength, _ = hw["season_length"].(int)
+		series.HoltWinters.DeviationScale, _ = hw["deviation_scale"].(float64)
+		series.HoltWinters.FailureThreshold, _ = hw["failure_threshold"].(int)
+		series.HoltWinters.FailureWindow, _ = hw["failure_window"].(int)
+	}
+	if enum, ok := bins[binNameValueEnum].([]interface{}); ok {
+		for _, v := range enum {
+			value, _ := v.(string)
+			series.ValueType.Enum = append(series.ValueType.Enum, value)
+		}
+	}
+
+	return series
+}
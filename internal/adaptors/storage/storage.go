@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/aerospike/aerospike-client-go/v7"
 
 	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
 )
 
 const (
@@ -16,121 +19,249 @@ const (
 	setNameCounter     = "counter"
 	binNameTimestamp   = "timestamp"
 	binNameMetricValue = "metric_value"
+	binNameValueType   = "value_type"
+	binNameSeries      = "series"
 	binNameCounter     = "counter"
-	udfFileName        = "find_oldest.lua"
-)
+	udfFindOldest      = "find_oldest"
+	udfSeriesStats     = "series_stats"
+	indexTimestamp     = "idx_timestamp"
 
-// Storage contains database logic.
-type Storage struct {
-	namespace  string
-	maxRecords uint64
+	// maxRecordTTL is a max ttl of 10 years accepted by aerospike, larger values are rejected by the server and
+	// the largest ones are sentinels like aerospike.TTLDontUpdate.
+	maxRecordTTL = 10 * 365 * 24 * 60 * 60
+)
 
-	counter atomic.Uint64
-	client  *aerospike.Client
+// udfModules are registered on start, each module is a lua file with a function of the same name.
+var udfModules = []string{udfFindOldest, udfSeriesStats}
 
-	logger *slog.Logger
+// Storage contains database logic. Series names are qualified by tenant, see models.TenantSeriesName,
+// and series of each tenant are kept in separate sets.
+type Storage struct {
+	namespace string
+	// tenantNamespaces are namespaces of tenants that don't use storage namespace.
+	tenantNamespaces map[string]string
+	// registered are tenants known to be in the registry.
+	registered sync.Map
+	// maxRecords is a default capacity of a series without max points, it can be changed on config reload.
+	maxRecords atomic.Uint64
+	// useTTL enables aerospike ttl for series with max age, namespace must support expiration.
+	useTTL atomic.Bool
+
+	// countersMu guards counters, counters are loaded from a database on the first write to a series.
+	countersMu sync.Mutex
+	counters   map[string]*seriesCounter
+	client     *aerospike.Client
+	// breaker fails calls fast while cluster is down, retry repeats idempotent calls on transient errors.
+	breaker *resilience.Breaker
+	retry   resilience.Retry
+
+	// pendingWrites is a number of writes in progress.
+	pendingWrites atomic.Int64
+	// observer records operations latency and errors, it is optional.
+	observer observer
+	logger   *slog.Logger
 }
 
 // NewStorage returns new storage for processing time series data.
-func NewStorage(host string, port int, namespace string, maxRecords uint64, udfPath string, logger *slog.Logger,
-) (*Storage, error) {
-	aerospike.SetLuaPath(udfPath)
-	client, err := aerospike.NewClient(host, port)
-	// Why it returns custom error type!?
+func NewStorage(opts Options, observer observer, logger *slog.Logger) (*Storage, error) {
+	clientPolicy, err := opts.clientPolicy()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize aerospike client: %w", err)
+		return nil, fmt.Errorf("invalid client options: %w", err)
 	}
-
-	// create index.
-	indexTask, err := client.CreateIndex(nil,
-		namespace,
-		setNameMetrics,
-		"idx_timestamp",
-		binNameTimestamp,
-		aerospike.NUMERIC)
+	hosts, err := opts.hosts()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create index: %w", err)
+		return nil, fmt.Errorf("invalid hosts: %w", err)
 	}
-	<-indexTask.OnComplete()
 
-	task, err := client.RegisterUDFFromFile(nil, fmt.Sprintf("%s%s", udfPath, udfFileName), udfFileName, aerospike.LUA)
-	if err != nil {
-		return nil, fmt.Errorf("failed to register udf: %w", err)
+	aerospike.SetLuaPath(opts.UDFPath)
+	client, errClient := aerospike.NewClientWithPolicyAndHost(clientPolicy, hosts...)
+	// Why it returns custom error type!?
+	if errClient != nil {
+		return nil, fmt.Errorf("failed to initialize aerospike client: %w", classifyError(errClient))
+	}
+	if err = opts.applyPolicies(client); err != nil {
+		client.Close()
+		return nil, err
 	}
-	// Wait for the registration to complete
-	<-task.OnComplete()
 
 	storage := &Storage{
-		namespace:  namespace,
-		maxRecords: maxRecords,
-		client:     client,
-		logger:     logger,
+		namespace:        opts.Namespace,
+		tenantNamespaces: opts.TenantNamespaces,
+		counters:         make(map[string]*seriesCounter),
+		client:           client,
+		breaker:          resilience.NewBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+		retry: resilience.Retry{
+			Attempts:   opts.RetryAttempts,
+			Backoff:    opts.RetryBackoff,
+			MaxBackoff: opts.RetryMaxBackoff,
+		},
+		observer: observer,
+		logger:   logger,
 	}
+	storage.maxRecords.Store(opts.MaxRecords)
+	storage.useTTL.Store(opts.UseTTL)
 
-	// Initialize counter.
-	counter, errCounter := storage.GetCounter(context.Background())
-	if errCounter == nil {
-		storage.counter.Store(uint64(counter))
+	// Indexes of other tenants are created when they are registered.
+	if err = createIndex(client, storage.tenantPlacement(models.DefaultTenant)); err != nil {
+		return nil, err
+	}
+
+	udfPath := opts.UDFPath
+	for _, module := range udfModules {
+		fileName := module + ".lua"
+		task, err := client.RegisterUDFFromFile(nil, fmt.Sprintf("%s%s", udfPath, fileName), fileName, aerospike.LUA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register udf %s: %w", module, classifyError(err))
+		}
+		// Wait for the registration to complete
+		<-task.OnComplete()
 	}
-	logger.Debug("initialized counter", slog.Int64(binNameCounter, counter))
 
 	return storage, nil
 }
 
-// Close closes connection to aerospike instance.
+// SetCapacity changes default capacity of series without max points.
+// Series above capacity are trimmed by retention sweeper.
+func (s *Storage) SetCapacity(maxRecords uint64) {
+	s.maxRecords.Store(maxRecords)
+}
+
+// SetUseTTL enables or disables aerospike ttl for new points of series with max age.
+func (s *Storage) SetUseTTL(useTTL bool) {
+	s.useTTL.Store(useTTL)
+}
+
+// Close flushes series counters and closes connection to aerospike instance.
+// It must be called after all writes are completed.
 func (s *Storage) Close() {
+	s.Flush(context.Background())
 	s.client.Close()
 }
 
-// Set saves record to the database.
-func (s *Storage) Set(ctx context.Context, record models.Record) error {
+// Flush saves in-memory counters of all series to a database.
+// Concurrent writes may persist counters out of order, so the last values are saved once more.
+func (s *Storage) Flush(ctx context.Context) {
+	s.countersMu.Lock()
+	points := make(map[string]int64, len(s.counters))
+	for series, counter := range s.counters {
+		points[series] = int64(counter.points.Load())
+	}
+	s.countersMu.Unlock()
+
+	for series, val := range points {
+		s.SetCounter(ctx, series, val)
+	}
+}
+
+// Set saves record to the database, keeping series within retention limits.
+// Oldest record of the series is evicted when the series reaches max points.
+// Records older than series max age are rejected, and expire in aerospike if ttl is enabled.
+func (s *Storage) Set(ctx context.Context, record models.Record, retention models.Retention) (err error) {
+	ctx, end := s.start(ctx, opSet, s.seriesAttributes(record.Series)...)
+	defer end(&err)
+	s.pendingWrites.Add(1)
+	defer s.pendingWrites.Add(-1)
+
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("context error: %w", err)
 	}
-	if s.counter.Load() >= s.maxRecords {
-		if err := s.evict(ctx); err != nil {
+
+	ttl, err := s.recordTTL(record, retention)
+	if err != nil {
+		return err
+	}
+
+	counter, err := s.seriesCounter(ctx, record.Series)
+	if err != nil {
+		return fmt.Errorf("failed to load counter: %w", err)
+	}
+	capacity := s.capacity(retention)
+	if counter.points.Load() >= capacity {
+		if err := s.evict(ctx, record.Series); err != nil {
 			return fmt.Errorf("failed to evict records: %w", err)
 		}
 	}
 
-	key, err := aerospike.NewKey(s.namespace, setNameMetrics, record.Timestamp)
-	if err != nil {
-		return fmt.Errorf("failed to create aerospike key: %w", err)
+	key, errKey := s.placement(record.Series).metricKey(record.Series, record.Timestamp)
+	if errKey != nil {
+		return fmt.Errorf("failed to create aerospike key: %w", errKey)
 	}
 
-	bin := aerospike.BinMap{
-		binNameTimestamp:   record.Timestamp,
-		binNameMetricValue: record.MetricValue,
+	bin, err := recordBins(record)
+	if err != nil {
+		return err
 	}
 
-	writePolicy := aerospike.NewWritePolicy(0, aerospike.TTLDontExpire)
+	writePolicy := s.writePolicy(0, ttl)
 
-	err = s.client.Put(writePolicy, key, bin)
-	if err != nil {
+	// Put of the same key and bins is idempotent, so it is retried.
+	if err := s.call(ctx, true, func() error { return s.client.Put(writePolicy, key, bin) }); err != nil {
 		return fmt.Errorf("failed to put bins: %w", err)
 	}
 
-	// Increase counter only if we have less than `maxRecords`
-	if s.counter.Load() < s.maxRecords {
-		s.counter.Add(1)
-		s.SetCounter(ctx, int64(s.counter.Load()))
+	// Increase counter only if we have less than capacity.
+	if counter.points.Load() < capacity {
+		s.SetCounter(ctx, record.Series, int64(counter.points.Add(1)))
 	}
 
 	return nil
 }
 
-// GetByRange returns records from a database by range.
-func (s *Storage) GetByRange(ctx context.Context, min, max int64) ([]models.Record, error) {
+// recordTTL returns aerospike ttl of a record, it rejects records older than series max age.
+func (s *Storage) recordTTL(record models.Record, retention models.Retention) (uint32, error) {
+	ttl := uint32(aerospike.TTLDontExpire)
+	if retention.MaxAge > 0 {
+		// Points with future timestamps expire max age after their timestamp, age is limited as ttl is capped.
+		age := max((time.Now().UnixMicro()-record.Timestamp)/int64(time.Second/time.Microsecond), -maxRecordTTL)
+		remaining := retention.MaxAge - age
+		if remaining <= 0 {
+			return 0, models.NewValidationError("timestamp", "is older than series max age %ds", retention.MaxAge)
+		}
+		if s.useTTL.Load() {
+			ttl = uint32(min(remaining, maxRecordTTL))
+		}
+	}
+	return ttl, nil
+}
+
+// recordBins returns bins of a metric record.
+func recordBins(record models.Record) (aerospike.BinMap, error) {
+	value, valueType, err := encodeValue(record.MetricValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metric value: %w", err)
+	}
+
+	return aerospike.BinMap{
+		binNameTimestamp:   record.Timestamp,
+		binNameMetricValue: value,
+		binNameValueType:   string(valueType),
+		binNameSeries:      record.Series,
+	}, nil
+}
+
+// GetByRange returns records of a series from a database by range.
+func (s *Storage) GetByRange(ctx context.Context, series string, min, max int64) (_ []models.Record, err error) {
+	ctx, end := s.start(ctx, opGetByRange, s.seriesAttributes(series)...)
+	defer end(&err)
+
 	if err := ctx.Err(); err != nil {
 		return nil, fmt.Errorf("context error: %w", err)
 	}
 
-	stmt := aerospike.NewStatement(s.namespace, setNameMetrics)
+	p := s.placement(series)
+	stmt := aerospike.NewStatement(p.namespace, p.metrics)
 	if err := stmt.SetFilter(aerospike.NewRangeFilter(binNameTimestamp, min, max)); err != nil {
-		return nil, fmt.Errorf("failed to set statement filter: %w", err)
+		return nil, fmt.Errorf("failed to set statement filter: %w", classifyError(err))
 	}
 
-	recordset, err := s.client.Query(nil, stmt)
+	policy := s.queryPolicy()
+	policy.FilterExpression = seriesFilter(series)
+
+	var recordset *aerospike.Recordset
+	err = s.call(ctx, true, func() (err error) {
+		recordset, err = s.client.Query(policy, stmt)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -140,15 +271,20 @@ func (s *Storage) GetByRange(ctx context.Context, min, max int64) ([]models.Reco
 	results := make([]models.Record, 0)
 	for res := range recordset.Results() {
 		if res.Err != nil {
-			return nil, fmt.Errorf("failed to itterate over result: %w", res.Err)
+			return nil, fmt.Errorf("failed to itterate over result: %w", classifyError(res.Err))
 		}
 		timestamp, ok := res.Record.Bins[binNameTimestamp].(int)
 		if !ok {
 			return nil, fmt.Errorf("failed to cast timestamp to int64")
 		}
-		metricValue, ok := res.Record.Bins[binNameMetricValue]
+		rawValue, ok := res.Record.Bins[binNameMetricValue]
 		if !ok {
-			return nil, fmt.Errorf("failed to cast metric_value to float64")
+			return nil, fmt.Errorf("failed to get metric_value")
+		}
+		valueType, _ := res.Record.Bins[binNameValueType].(string)
+		metricValue, err := decodeValue(models.ValueType(valueType), rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode metric_value: %w", err)
 		}
 		one := models.Record{
 			Timestamp:   int64(timestamp),
@@ -160,55 +296,70 @@ func (s *Storage) GetByRange(ctx context.Context, min, max int64) ([]models.Reco
 	return results, nil
 }
 
-// evict finds oldest record in a database and delete it.
-func (s *Storage) evict(ctx context.Context) error {
-	oldestKey, errKey := s.FindOldestKey(ctx)
+// evict finds oldest record of a series in a database and delete it.
+func (s *Storage) evict(ctx context.Context, series string) (err error) {
+	ctx, end := s.start(ctx, opEvict, s.seriesAttributes(series)...)
+	defer end(&err)
+
+	oldestKey, errKey := s.FindOldestKey(ctx, series)
 	if errKey != nil {
 		return fmt.Errorf("failed to find oldest key: %w", errKey)
 	}
 	if oldestKey != nil {
-		_, err := s.client.Delete(nil, oldestKey)
+		err := s.call(ctx, true, func() error {
+			_, err := s.client.Delete(nil, oldestKey)
+			return err
+		})
 		if err != nil {
 			return fmt.Errorf("failed to delete oldest key: %w", err)
 		}
+		if counter, err := s.seriesCounter(ctx, series); err == nil {
+			counter.evicted.Add(1)
+		}
 	}
 
 	return nil
 }
 
-// SetCounter saves counter do db. As this function will be called in goroutine, we don't return errors here.
-func (s *Storage) SetCounter(ctx context.Context, val int64) {
+// SetCounter saves series counter do db. As this function will be called in goroutine, we don't return errors here.
+func (s *Storage) SetCounter(ctx context.Context, series string, val int64) {
 	if err := ctx.Err(); err != nil {
-		s.logger.Error("context error", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "context error", slog.Any("error", err))
 	}
 
-	key, err := aerospike.NewKey(s.namespace, setNameCounter, 0)
+	key, err := s.placement(series).counterKey(series)
 	if err != nil {
-		s.logger.Error("failed to create aerospike key", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "failed to create aerospike key", slog.Any("error", err))
 	}
 
 	bin := aerospike.BinMap{
 		binNameCounter: val,
 	}
 
-	err = s.client.Put(nil, key, bin)
-	if err != nil {
-		s.logger.Error("failed to put bins", slog.Any("error", err))
+	if err := s.call(ctx, true, func() error { return s.client.Put(nil, key, bin) }); err != nil {
+		s.logger.ErrorContext(ctx, "failed to put bins", slog.Any("error", err))
 	}
 }
 
-// GetCounter retrieves counter from a database for an initial load.
-func (s *Storage) GetCounter(ctx context.Context) (int64, error) {
+// GetCounter retrieves series counter from a database for an initial load.
+func (s *Storage) GetCounter(ctx context.Context, series string) (_ int64, err error) {
+	ctx, end := s.start(ctx, opGetCounter, s.seriesAttributes(series)...)
+	defer end(&err)
+
 	if err := ctx.Err(); err != nil {
 		return 0, fmt.Errorf("context error: %w", err)
 	}
 
-	key, err := aerospike.NewKey(s.namespace, setNameCounter, 0)
+	key, err := s.placement(series).counterKey(series)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create aerospike key: %w", err)
 	}
 
-	record, err := s.client.Get(nil, key)
+	var record *aerospike.Record
+	err = s.call(ctx, true, func() (err error) {
+		record, err = s.client.Get(nil, key)
+		return err
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to get counter: %w", err)
 	}
@@ -221,14 +372,25 @@ func (s *Storage) GetCounter(ctx context.Context) (int64, error) {
 	return int64(counter), nil
 }
 
-// FindOldestKey returns key of the oldest record for eviction.
-func (s *Storage) FindOldestKey(ctx context.Context) (*aerospike.Key, error) {
+// FindOldestKey returns key of the oldest record of a series for eviction.
+func (s *Storage) FindOldestKey(ctx context.Context, series string) (_ *aerospike.Key, err error) {
+	ctx, end := s.start(ctx, opFindOldest, s.seriesAttributes(series)...)
+	defer end(&err)
+
 	if err := ctx.Err(); err != nil {
 		return nil, fmt.Errorf("context error: %w", err)
 	}
 
-	stmt := aerospike.NewStatement(s.namespace, setNameMetrics)
-	recordset, err := s.client.QueryAggregate(nil, stmt, "find_oldest", "find_oldest")
+	policy := s.queryPolicy()
+	policy.FilterExpression = seriesFilter(series)
+
+	p := s.placement(series)
+	stmt := aerospike.NewStatement(p.namespace, p.metrics)
+	var recordset *aerospike.Recordset
+	err = s.call(ctx, true, func() (err error) {
+		recordset, err = s.client.QueryAggregate(policy, stmt, udfFindOldest, udfFindOldest)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -236,12 +398,12 @@ func (s *Storage) FindOldestKey(ctx context.Context) (*aerospike.Key, error) {
 
 	for res := range recordset.Results() {
 		if res.Err != nil {
-			return nil, res.Err
+			return nil, classifyError(res.Err)
 		}
 		var key *aerospike.Key
 		if record, ok := res.Record.Bins["SUCCESS"].(map[interface{}]interface{}); ok {
 			timestamp := int64(record[binNameTimestamp].(int))
-			key, err = aerospike.NewKey(s.namespace, setNameMetrics, timestamp)
+			key, err = p.metricKey(series, timestamp)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create aerospike key: %w", err)
 			}
@@ -251,3 +413,16 @@ func (s *Storage) FindOldestKey(ctx context.Context) (*aerospike.Key, error) {
 
 	return nil, nil
 }
+
+// seriesFilter returns expression that selects records of a series.
+// Records saved before series were introduced have no series bin and belong to the default series.
+func seriesFilter(series string) *aerospike.Expression {
+	sameSeries := aerospike.ExpEq(aerospike.ExpStringBin(binNameSeries), aerospike.ExpStringVal(series))
+	if series != models.DefaultSeriesName {
+		return sameSeries
+	}
+	return aerospike.ExpOr(
+		aerospike.ExpNot(aerospike.ExpBinExists(binNameSeries)),
+		sameSeries,
+	)
+}
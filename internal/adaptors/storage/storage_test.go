@@ -2,27 +2,42 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/aerospike/aerospike-client-go/v7"
 	"github.com/stretchr/testify/require"
 
 	"aerospike.com/rrd/internal/models"
 )
 
 const (
-	testHost       = "localhost"
-	testPort       = 3000
+	testHost       = "localhost:3000"
 	testNamespace  = "test"
 	testMaxRecords = 5
 	udfPath        = "../../../udf/"
 	testCounter    = int64(10)
 )
 
+func testOptions() Options {
+	return Options{
+		Hosts:      []string{testHost},
+		Namespace:  testNamespace,
+		MaxRecords: testMaxRecords,
+		UDFPath:    udfPath,
+		Read:       PolicyOptions{TotalTimeout: time.Second, SocketTimeout: time.Second, MaxRetries: 2},
+		Write:      PolicyOptions{TotalTimeout: time.Second, SocketTimeout: time.Second},
+		Query:      PolicyOptions{SocketTimeout: time.Second, MaxRetries: 5},
+	}
+}
+
 func testRecord(ts int64) models.Record {
 	return models.Record{
+		Series:      models.DefaultSeriesName,
 		Timestamp:   ts,
 		MetricValue: 3.5,
 	}
@@ -30,34 +45,239 @@ func testRecord(ts int64) models.Record {
 
 func TestStorage_Set(t *testing.T) {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	storage, err := NewStorage(testHost, testPort, testNamespace, testMaxRecords, udfPath, logger)
+	storage, err := NewStorage(testOptions(), nil, logger)
 	require.NoError(t, err)
 
-	err = storage.Set(context.Background(), testRecord(1))
+	err = storage.Set(context.Background(), testRecord(1), models.Retention{})
 	require.NoError(t, err)
 }
 
 func TestStorage_GetByRange(t *testing.T) {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	storage, err := NewStorage(testHost, testPort, testNamespace, testMaxRecords, udfPath, logger)
+	storage, err := NewStorage(testOptions(), nil, logger)
 	require.NoError(t, err)
 
 	for i := 0; i < 10; i++ {
-		err = storage.Set(context.Background(), testRecord(time.Now().UnixMicro()))
+		err = storage.Set(context.Background(), testRecord(time.Now().UnixMicro()), models.Retention{})
 		require.NoError(t, err)
 	}
-	result, err := storage.GetByRange(context.Background(), 0, time.Now().UnixMicro())
+	result, err := storage.GetByRange(context.Background(), models.DefaultSeriesName, 0, time.Now().UnixMicro())
 	require.NoError(t, err)
 	require.Equal(t, testMaxRecords, len(result))
 }
 
+func TestStorage_SetBatch(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	storage, err := NewStorage(testOptions(), nil, logger)
+	require.NoError(t, err)
+
+	series := fmt.Sprintf("batch_%d", time.Now().UnixNano())
+	require.NoError(t, storage.CreateSeries(context.Background(), models.Series{Name: series}))
+	retention := models.Retention{MaxPoints: 3, MaxAge: 3600}
+	now := time.Now().UnixMicro()
+	writes := make([]models.Write, 0, 5)
+	for i := int64(0); i < 4; i++ {
+		record := testRecord(now + i)
+		record.Series = series
+		writes = append(writes, models.Write{Record: record, Retention: retention})
+	}
+	old := testRecord(now - 2*3600*int64(time.Second/time.Microsecond))
+	old.Series = series
+	writes = append(writes, models.Write{Record: old, Retention: retention})
+
+	errs := storage.SetBatch(context.Background(), writes)
+	require.Len(t, errs, 5)
+	for _, err := range errs[:4] {
+		require.NoError(t, err)
+	}
+	require.ErrorIs(t, errs[4], models.ErrInvalidArgument)
+
+	// Counter is capped by capacity, extra points are evicted on the next batch.
+	counter, err := storage.GetCounter(context.Background(), series)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), counter)
+	require.Empty(t, failedWrites(storage.SetBatch(context.Background(), writes[:1])))
+	result, err := storage.GetByRange(context.Background(), series, 0, time.Now().UnixMicro())
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(result), 4)
+}
+
+// failedWrites returns errors of failed writes.
+func failedWrites(errs []error) []error {
+	var result []error
+	for _, err := range errs {
+		if err != nil {
+			result = append(result, err)
+		}
+	}
+	return result
+}
+
 func TestStorage_SetCounter(t *testing.T) {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	storage, err := NewStorage(testHost, testPort, testNamespace, testMaxRecords, udfPath, logger)
+	storage, err := NewStorage(testOptions(), nil, logger)
 	require.NoError(t, err)
 
-	storage.SetCounter(context.Background(), testCounter)
-	val, err := storage.GetCounter(context.Background())
+	storage.SetCounter(context.Background(), models.DefaultSeriesName, testCounter)
+	val, err := storage.GetCounter(context.Background(), models.DefaultSeriesName)
 	require.NoError(t, err)
 	require.Equal(t, testCounter, val)
 }
+
+func TestStorage_Series(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	storage, err := NewStorage(testOptions(), nil, logger)
+	require.NoError(t, err)
+
+	series := models.Series{
+		Name:       fmt.Sprintf("test_series_%d", time.Now().UnixNano()),
+		Labels:     map[string]string{"env": "test"},
+		DataSource: models.DataSourceGauge,
+		ValueType:  models.ValueSpec{Type: models.ValueTypeString, Enum: []string{"up", "down"}},
+	}
+	err = storage.CreateSeries(context.Background(), series)
+	require.NoError(t, err)
+	err = storage.CreateSeries(context.Background(), series)
+	require.ErrorIs(t, err, models.ErrConflict)
+
+	result, err := storage.GetSeries(context.Background(), series.Name)
+	require.NoError(t, err)
+	series.Version = 1
+	require.Equal(t, series, result)
+
+	result.Unit = "state"
+	err = storage.UpdateSeries(context.Background(), result)
+	require.NoError(t, err)
+	// Version is outdated now.
+	err = storage.UpdateSeries(context.Background(), result)
+	require.ErrorIs(t, err, models.ErrConflict)
+
+	hw := models.HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.1, SeasonLength: 24}.WithDefaults()
+	result.HoltWinters = &hw
+	result.Version++
+	err = storage.UpdateSeries(context.Background(), result)
+	require.NoError(t, err)
+	result, err = storage.GetSeries(context.Background(), series.Name)
+	require.NoError(t, err)
+	require.Equal(t, &hw, result.HoltWinters)
+
+	_, err = storage.GetSeries(context.Background(), "unknown_series")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	list, err := storage.ListSeries(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	// Newest records are not evicted.
+	start := time.Now().UnixMicro()
+	for i := int64(0); i < 3; i++ {
+		err = storage.Set(context.Background(),
+			models.Record{Series: series.Name, Timestamp: start + i, MetricValue: "up"},
+			models.Retention{},
+		)
+		require.NoError(t, err)
+	}
+	stats, err := storage.SeriesStats(context.Background(), series.Name)
+	require.NoError(t, err)
+	require.Equal(t, models.SeriesStats{Points: 3, Oldest: start, Newest: start + 2}, stats)
+}
+
+func TestStorage_Retention(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	storage, err := NewStorage(testOptions(), nil, logger)
+	require.NoError(t, err)
+
+	series := fmt.Sprintf("test_retention_%d", time.Now().UnixNano())
+	retention := models.Retention{MaxPoints: 3, MaxAge: 3600}
+	now := time.Now()
+
+	// Record older than max age is rejected.
+	old := models.Record{Series: series, Timestamp: now.Add(-2 * time.Hour).UnixMicro(), MetricValue: 1.0}
+	err = storage.Set(context.Background(), old, retention)
+	require.ErrorIs(t, err, models.ErrInvalidArgument)
+
+	for i := 0; i < 5; i++ {
+		record := models.Record{Series: series, Timestamp: now.Add(time.Duration(i) * time.Second).UnixMicro(), MetricValue: 1.0}
+		err = storage.Set(context.Background(), record, retention)
+		require.NoError(t, err)
+	}
+	result, err := storage.GetByRange(context.Background(), series, 0, now.Add(time.Minute).UnixMicro())
+	require.NoError(t, err)
+	require.Len(t, result, 3)
+
+	// Decrease capacity, sweeper trims the oldest points.
+	deleted, err := storage.TrimToCapacity(context.Background(), series, models.Retention{MaxPoints: 1})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), deleted)
+
+	deleted, err = storage.ExpireOlderThan(context.Background(), series, now.Add(time.Minute).UnixMicro())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), deleted)
+
+	stats := storage.RetentionStats()[series]
+	require.Equal(t, models.SeriesRetentionStats{Points: 0, Expired: 1, Evicted: 4}, stats)
+}
+
+func TestStorage_AlertRules(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	storage, err := NewStorage(testOptions(), nil, logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tenant := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	rule := models.AlertRule{
+		Name:        "high_cpu",
+		Selector:    models.AlertSelector{Series: "cpu"},
+		Aggregation: models.AggregationAvg,
+		Window:      60,
+		Comparison:  models.ComparisonGreater,
+		Threshold:   90,
+		Source:      models.AlertRuleSourceAPI,
+	}
+	require.NoError(t, storage.CreateAlertRule(ctx, tenant, rule))
+	require.ErrorIs(t, storage.CreateAlertRule(ctx, tenant, rule), models.ErrConflict)
+	rule.Threshold = 95
+	require.NoError(t, storage.UpdateAlertRule(ctx, tenant, rule))
+	rules, err := storage.ListAlertRules(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []models.AlertRule{rule}, rules[tenant])
+
+	silence := models.Silence{ID: "0011223344556677", Rule: "high_cpu", StartsAt: 1, EndsAt: 2, CreatedAt: 1}
+	require.NoError(t, storage.CreateSilence(ctx, tenant, silence))
+	silences, err := storage.ListSilences(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []models.Silence{silence}, silences[tenant])
+
+	require.NoError(t, storage.DeleteAlertRule(ctx, tenant, rule.Name))
+	require.NoError(t, storage.DeleteSilence(ctx, tenant, silence.ID))
+	rules, err = storage.ListAlertRules(ctx)
+	require.NoError(t, err)
+	require.Empty(t, rules[tenant])
+}
+
+func TestStorage_RecordTTL(t *testing.T) {
+	t.Parallel()
+	s := &Storage{}
+	s.SetUseTTL(true)
+	now := time.Now().UnixMicro()
+	hour := int64(time.Hour / time.Microsecond)
+	testCases := []struct {
+		timestamp int64
+		maxAge    int64
+		ttl       uint32
+	}{
+		{now, 0, aerospike.TTLDontExpire},
+		{now, 3600, 3600},
+		{now - hour, 7200, 3600},
+		// Points with future timestamps expire max age after their timestamp.
+		{now + hour, 3600, 7200},
+		{math.MaxInt64, models.MaxRetentionAge, maxRecordTTL},
+		// Max age beyond max ttl of aerospike is capped, so ttl is never a sentinel.
+		{now, models.MaxRetentionAge, maxRecordTTL},
+	}
+	for i, tc := range testCases {
+		ttl, err := s.recordTTL(models.Record{Timestamp: tc.timestamp}, models.Retention{MaxAge: tc.maxAge})
+		require.NoError(t, err, fmt.Sprintf("case %d", i))
+		require.InDelta(t, tc.ttl, ttl, 1, fmt.Sprintf("case %d", i))
+	}
+}
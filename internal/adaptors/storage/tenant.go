@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+	"github.com/aerospike/aerospike-client-go/v7/types"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// setNameTenants is a registry of tenants with series, it is kept in storage namespace.
+const setNameTenants = "tenants"
+
+// placement contains aerospike namespace, sets and index of a tenant.
+// Default tenant uses sets without prefix for backward compatibility, other tenants use sets prefixed with tenant.
+type placement struct {
+	namespace string
+	metrics   string
+	counter   string
+	series    string
+	index     string
+}
+
+// tenantPlacement returns placement of the tenant, namespace of a tenant is storage namespace if it is not set.
+func (s *Storage) tenantPlacement(tenant string) placement {
+	if tenant == models.DefaultTenant {
+		return placement{
+			namespace: s.namespace,
+			metrics:   setNameMetrics,
+			counter:   setNameCounter,
+			series:    setNameSeries,
+			index:     indexTimestamp,
+		}
+	}
+	namespace, ok := s.tenantNamespaces[tenant]
+	if !ok {
+		namespace = s.namespace
+	}
+	// Set names end with different words, so sets of different tenants never clash.
+	return placement{
+		namespace: namespace,
+		metrics:   tenant + "_" + setNameMetrics,
+		counter:   tenant + "_" + setNameCounter,
+		series:    tenant + "_" + setNameSeries,
+		index:     indexTimestamp + "_" + tenant,
+	}
+}
+
+// placement returns placement of the tenant that owns the series.
+func (s *Storage) placement(series string) placement {
+	tenant, _ := models.SplitSeriesName(series)
+	return s.tenantPlacement(tenant)
+}
+
+// metricKey returns key of a metric record. Default series uses timestamp as a key for backward compatibility,
+// other series use `series:timestamp` string keys.
+func (p placement) metricKey(series string, timestamp int64) (*aerospike.Key, aerospike.Error) {
+	if series == models.DefaultSeriesName {
+		return aerospike.NewKey(p.namespace, p.metrics, timestamp)
+	}
+	return aerospike.NewKey(p.namespace, p.metrics, fmt.Sprintf("%s:%d", series, timestamp))
+}
+
+// counterKey returns key of a series counter. Default series uses 0 key for backward compatibility.
+func (p placement) counterKey(series string) (*aerospike.Key, aerospike.Error) {
+	if series == models.DefaultSeriesName {
+		return aerospike.NewKey(p.namespace, p.counter, 0)
+	}
+	return aerospike.NewKey(p.namespace, p.counter, series)
+}
+
+// seriesKey returns key of a series definition.
+func (p placement) seriesKey(series string) (*aerospike.Key, aerospike.Error) {
+	return aerospike.NewKey(p.namespace, p.series, series)
+}
+
+// createIndex creates timestamp index of the metrics set and waits until it is built.
+func createIndex(client *aerospike.Client, p placement) error {
+	task, err := client.CreateIndex(nil, p.namespace, p.metrics, p.index, binNameTimestamp, aerospike.NUMERIC)
+	if err != nil {
+		if err.Matches(types.INDEX_FOUND) {
+			return nil
+		}
+		return fmt.Errorf("failed to create index %s: %w", p.index, classifyError(err))
+	}
+	return <-task.OnComplete()
+}
+
+// registerTenant creates index of the tenant metrics set and saves tenant to the registry, so series of the tenant
+// are listed. It is called before the first series of the tenant is created.
+func (s *Storage) registerTenant(ctx context.Context, tenant string) error {
+	if tenant == models.DefaultTenant {
+		return nil
+	}
+	if _, ok := s.registered.Load(tenant); ok {
+		return nil
+	}
+
+	if err := createIndex(s.client, s.tenantPlacement(tenant)); err != nil {
+		return err
+	}
+	key, errKey := aerospike.NewKey(s.namespace, setNameTenants, tenant)
+	if errKey != nil {
+		return fmt.Errorf("failed to create aerospike key: %w", errKey)
+	}
+	bins := aerospike.BinMap{
+		binNameName:      tenant,
+		binNameCreatedAt: time.Now().UnixMicro(),
+	}
+	policy := s.writePolicy(0, aerospike.TTLDontExpire)
+	policy.RecordExistsAction = aerospike.CREATE_ONLY
+	err := s.call(ctx, false, func() error { return s.client.Put(policy, key, bins) })
+	if err != nil && !errors.Is(err, models.ErrConflict) {
+		return fmt.Errorf("failed to register tenant %s: %w", tenant, err)
+	}
+	s.registered.Store(tenant, struct{}{})
+
+	return nil
+}
+
+// ListTenants returns all tenants with series, including default tenant.
+func (s *Storage) ListTenants(ctx context.Context) (_ []string, err error) {
+	ctx, end := s.start(ctx, opListTenants, semconv.DBNamespace(s.namespace))
+	defer end(&err)
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context error: %w", err)
+	}
+
+	var recordset *aerospike.Recordset
+	err = s.call(ctx, true, func() (err error) {
+		recordset, err = s.client.ScanAll(nil, s.namespace, setNameTenants, binNameName)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan tenants: %w", err)
+	}
+	defer recordset.Close()
+
+	result := []string{models.DefaultTenant}
+	for res := range recordset.Results() {
+		if res.Err != nil {
+			return nil, fmt.Errorf("failed to iterate over tenants: %w", classifyError(res.Err))
+		}
+		if name, ok := res.Record.Bins[binNameName].(string); ok {
+			result = append(result, name)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func TestTenantPlacement(t *testing.T) {
+	t.Parallel()
+	s := &Storage{
+		namespace:        "test",
+		tenantNamespaces: map[string]string{"globex": "globex"},
+	}
+	testCases := []struct {
+		series     string
+		namespace  string
+		metricsSet string
+		counterSet string
+		metricKey  any
+		counterKey any
+	}{
+		// Default tenant keeps legacy sets and keys.
+		{models.DefaultSeriesName, "test", "metrics", "counter", int64(100), 0},
+		{"cpu", "test", "metrics", "counter", "cpu:100", "cpu"},
+		{"acme/default", "test", "acme_metrics", "acme_counter", "acme/default:100", "acme/default"},
+		{"globex/cpu", "globex", "globex_metrics", "globex_counter", "globex/cpu:100", "globex/cpu"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			p := s.placement(tc.series)
+			metricKey, err := p.metricKey(tc.series, 100)
+			require.NoError(t, err)
+			require.Equal(t, tc.namespace, metricKey.Namespace())
+			require.Equal(t, tc.metricsSet, metricKey.SetName())
+			require.Equal(t, tc.metricKey, metricKey.Value().GetObject())
+
+			counterKey, err := p.counterKey(tc.series)
+			require.NoError(t, err)
+			require.Equal(t, tc.namespace, counterKey.Namespace())
+			require.Equal(t, tc.counterSet, counterKey.SetName())
+			require.Equal(t, tc.counterKey, counterKey.Value().GetObject())
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"fmt"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Keys of histogram map bin.
+const (
+	histogramKeyBounds = "le"
+	histogramKeyCounts = "counts"
+	histogramKeyCount  = "count"
+	histogramKeySum    = "sum"
+)
+
+// encodeValue converts normalized metric value to aerospike bin value.
+// Value type is saved to a separate bin, so decodeValue can restore exactly the same go type,
+// e.g. bool is stored as integer and integral float stays float.
+func encodeValue(v any) (any, models.ValueType, error) {
+	valueType, ok := models.ValueTypeOf(v)
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported metric value type %T", v)
+	}
+
+	switch val := v.(type) {
+	case bool:
+		if val {
+			return int64(1), valueType, nil
+		}
+		return int64(0), valueType, nil
+	case models.Histogram:
+		bounds := make([]any, 0, len(val.Buckets))
+		counts := make([]any, 0, len(val.Buckets))
+		for _, b := range val.Buckets {
+			bounds = append(bounds, b.UpperBound)
+			counts = append(counts, int64(b.Count))
+		}
+		return map[string]any{
+			histogramKeyBounds: bounds,
+			histogramKeyCounts: counts,
+			histogramKeyCount:  int64(val.Count),
+			histogramKeySum:    val.Sum,
+		}, valueType, nil
+	default:
+		return val, valueType, nil
+	}
+}
+
+// decodeValue restores metric value from aerospike bin value.
+// Records saved before value types were introduced have no type, they are returned as is.
+func decodeValue(valueType models.ValueType, raw any) (any, error) {
+	switch valueType {
+	case "":
+		if i, ok := raw.(int); ok {
+			return int64(i), nil
+		}
+		return raw, nil
+	case models.ValueTypeFloat:
+		return toFloat(raw)
+	case models.ValueTypeInt:
+		return toInt(raw)
+	case models.ValueTypeBool:
+		i, err := toInt(raw)
+		if err != nil {
+			return nil, err
+		}
+		return i != 0, nil
+	case models.ValueTypeString:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", raw)
+		}
+		return s, nil
+	case models.ValueTypeHistogram:
+		return decodeHistogram(raw)
+	default:
+		return nil, fmt.Errorf("unknown value type %q", valueType)
+	}
+}
+
+func decodeHistogram(raw any) (models.Histogram, error) {
+	m, ok := raw.(map[any]any)
+	if !ok {
+		return models.Histogram{}, fmt.Errorf("expected histogram map, got %T", raw)
+	}
+
+	bounds, okBounds := m[histogramKeyBounds].([]any)
+	counts, okCounts := m[histogramKeyCounts].([]any)
+	if !okBounds || !okCounts || len(bounds) != len(counts) {
+		return models.Histogram{}, fmt.Errorf("invalid histogram buckets")
+	}
+
+	var h models.Histogram
+	count, err := toInt(m[histogramKeyCount])
+	if err != nil {
+		return models.Histogram{}, fmt.Errorf("invalid histogram count: %w", err)
+	}
+	h.Count = uint64(count)
+	if h.Sum, err = toFloat(m[histogramKeySum]); err != nil {
+		return models.Histogram{}, fmt.Errorf("invalid histogram sum: %w", err)
+	}
+
+	h.Buckets = make([]models.Bucket, 0, len(bounds))
+	for i := range bounds {
+		bound, err := toFloat(bounds[i])
+		if err != nil {
+			return models.Histogram{}, fmt.Errorf("invalid histogram bucket %d bound: %w", i, err)
+		}
+		bucketCount, err := toInt(counts[i])
+		if err != nil {
+			return models.Histogram{}, fmt.Errorf("invalid histogram bucket %d count: %w", i, err)
+		}
+		h.Buckets = append(h.Buckets, models.Bucket{UpperBound: bound, Count: uint64(bucketCount)})
+	}
+
+	return h, nil
+}
+
+// toFloat converts aerospike number to float64, integers are returned by server for integral values of int bins.
+func toFloat(raw any) (float64, error) {
+	switch val := raw.(type) {
+	case float64:
+		return val, nil
+	case int:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	default:
+		return 0, fmt.Errorf("expected float, got %T", raw)
+	}
+}
+
+// toInt converts aerospike integer to int64.
+func toInt(raw any) (int64, error) {
+	switch val := raw.(type) {
+	case int:
+		return int64(val), nil
+	case int64:
+		return val, nil
+	default:
+		return 0, fmt.Errorf("expected integer, got %T", raw)
+	}
+}
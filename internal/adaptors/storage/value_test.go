@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func TestEncodeDecodeValue(t *testing.T) {
+	t.Parallel()
+	testCases := []any{
+		3.5,
+		3.0,
+		math.Inf(1),
+		int64(math.MaxInt64),
+		true,
+		false,
+		"up",
+		models.Histogram{
+			Buckets: []models.Bucket{{UpperBound: 0.5, Count: 1}, {UpperBound: math.Inf(1), Count: 3}},
+			Count:   3,
+			Sum:     4.5,
+		},
+	}
+
+	for i, value := range testCases {
+		raw, valueType, err := encodeValue(value)
+		require.NoError(t, err, fmt.Sprintf("case %d", i))
+		// Aerospike client returns integers as int and maps with interface keys.
+		raw = asReturnedByAerospike(raw)
+		result, err := decodeValue(valueType, raw)
+		require.NoError(t, err, fmt.Sprintf("case %d", i))
+		require.Equal(t, value, result, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestEncodeValue_Unsupported(t *testing.T) {
+	t.Parallel()
+	_, _, err := encodeValue(struct{}{})
+	require.Error(t, err)
+}
+
+func TestDecodeValue_Legacy(t *testing.T) {
+	t.Parallel()
+	result, err := decodeValue("", 42)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), result)
+
+	result, err = decodeValue("", 11.5)
+	require.NoError(t, err)
+	require.Equal(t, 11.5, result)
+}
+
+func asReturnedByAerospike(v any) any {
+	switch val := v.(type) {
+	case int64:
+		return int(val)
+	case []any:
+		result := make([]any, 0, len(val))
+		for _, item := range val {
+			result = append(result, asReturnedByAerospike(item))
+		}
+		return result
+	case map[string]any:
+		result := make(map[any]any, len(val))
+		for k, item := range val {
+			result[k] = asReturnedByAerospike(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -0,0 +1,70 @@
+package alerting
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Config contains alert rules that are defined by operators, it is loaded from yaml file.
+type Config struct {
+	Rules []RuleConfig `yaml:"rules"`
+}
+
+// RuleConfig is a rule of a tenant, see models.AlertRule.
+type RuleConfig struct {
+	// Tenant owns the rule and its series, default tenant is used if empty.
+	Tenant   string `yaml:"tenant"`
+	Name     string `yaml:"name"`
+	Selector struct {
+		Series string            `yaml:"series"`
+		Labels map[string]string `yaml:"labels"`
+	} `yaml:"selector"`
+	Aggregation string        `yaml:"aggregation"`
+	Window      time.Duration `yaml:"window"`
+	Comparison  string        `yaml:"comparison"`
+	Threshold   float64       `yaml:"threshold"`
+	For         time.Duration `yaml:"for"`
+	Description string        `yaml:"description"`
+}
+
+func (c RuleConfig) tenant() string {
+	if c.Tenant == "" {
+		return models.DefaultTenant
+	}
+	return c.Tenant
+}
+
+func (c RuleConfig) rule() models.AlertRule {
+	return models.AlertRule{
+		Name: c.Name,
+		Selector: models.AlertSelector{
+			Series: c.Selector.Series,
+			Labels: c.Selector.Labels,
+		},
+		Aggregation: models.AlertAggregation(c.Aggregation),
+		Window:      int64(c.Window / time.Second),
+		Comparison:  models.AlertComparison(c.Comparison),
+		Threshold:   c.Threshold,
+		For:         int64(c.For / time.Second),
+		Description: c.Description,
+		Source:      models.AlertRuleSourceConfig,
+	}
+}
+
+// LoadConfig reads alert rules from yaml file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read alert rules file: %w", err)
+	}
+	var cfg Config
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse alert rules file %s: %w", path, err)
+	}
+	return cfg, nil
+}
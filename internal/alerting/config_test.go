@@ -0,0 +1,58 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const testConfig = `
+rules:
+  - name: high_cpu
+    selector:
+      series: cpu
+    aggregation: avg
+    window: 5m
+    comparison: gt
+    threshold: 90
+    for: 1m30s
+  - tenant: acme
+    name: low_disk
+    selector:
+      labels:
+        env: prod
+    aggregation: min
+    window: 1h
+    comparison: lt
+    threshold: 0.1
+`
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "alerts.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig), 0o600))
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Rules, 2)
+
+	require.Equal(t, models.DefaultTenant, cfg.Rules[0].tenant())
+	require.Equal(t, models.AlertRule{
+		Name:        "high_cpu",
+		Selector:    models.AlertSelector{Series: "cpu"},
+		Aggregation: models.AggregationAvg,
+		Window:      300,
+		Comparison:  models.ComparisonGreater,
+		Threshold:   90,
+		For:         90,
+		Source:      models.AlertRuleSourceConfig,
+	}, cfg.Rules[0].rule())
+	require.Equal(t, "acme", cfg.Rules[1].tenant())
+	require.Equal(t, map[string]string{"env": "prod"}, cfg.Rules[1].rule().Selector.Labels)
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
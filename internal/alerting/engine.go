@@ -0,0 +1,445 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// evaluationSubject is a subject of the identity that queries series of rules.
+const evaluationSubject = "alerting"
+
+// seriesPageLimit is a page size of listing series selected by labels.
+const seriesPageLimit = 1000
+
+type querier interface {
+	GetByRange(ctx context.Context, series string, start, end int64) ([]models.Record, error)
+	ListSeries(ctx context.Context, filter models.SeriesFilter) (models.SeriesPage, error)
+}
+
+type notifier interface {
+	Notify(notification models.AlertNotification)
+}
+
+// Options contains params of rule evaluation.
+type Options struct {
+	// Interval is a duration between evaluations of all rules.
+	Interval time.Duration
+	// MaxRules is a max number of rules and of silences of a tenant created by API, zero means no limit.
+	MaxRules int
+	// RepeatInterval is a duration after which notification of a firing alert is sent again, zero disables repeats.
+	RepeatInterval time.Duration
+}
+
+// Engine evaluates alert rules of all tenants against their series and notifies about firing and resolved alerts.
+// Alerts are kept in memory of the instance, rules and silences created by API are saved to the store if it is set.
+type Engine struct {
+	querier  querier
+	notifier notifier
+	opts     Options
+	// interval is a duration between evaluations, it can be changed on config reload.
+	interval atomic.Int64
+	logger   *slog.Logger
+	now      func() time.Time
+	// store is optional, rules and silences of API are kept only in memory if it is nil.
+	store store
+
+	mu sync.Mutex
+	// rules are keyed by tenant and name, entry is replaced when rule is changed, so its alerts are reset.
+	rules map[string]map[string]*ruleEntry
+	// silences are keyed by tenant and id.
+	silences map[string]map[string]models.Silence
+
+	evaluationFailures atomic.Uint64
+}
+
+type ruleEntry struct {
+	rule models.AlertRule
+	// alerts are keyed by series name.
+	alerts map[string]*alertState
+}
+
+type alertState struct {
+	alert models.Alert
+	// notifiedAt is a time of the last firing notification, it is zero if firing wasn't notified.
+	notifiedAt time.Time
+}
+
+// NewEngine returns new rule engine.
+func NewEngine(querier querier, notifier notifier, opts Options, logger *slog.Logger) *Engine {
+	e := &Engine{
+		querier:  querier,
+		notifier: notifier,
+		opts:     opts,
+		logger:   logger,
+		now:      time.Now,
+		rules:    make(map[string]map[string]*ruleEntry),
+		silences: make(map[string]map[string]models.Silence),
+	}
+	e.interval.Store(int64(opts.Interval))
+	return e
+}
+
+// LoadRules adds rules of config, they can't be changed by API. It returns error if a rule is invalid or duplicated.
+func (e *Engine) LoadRules(cfg Config) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now().UnixMicro()
+	for i, one := range cfg.Rules {
+		tenant := one.tenant()
+		if err := models.ValidateTenant(tenant); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		rule := one.rule()
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		if _, ok := e.rules[tenant][rule.Name]; ok {
+			return fmt.Errorf("rule %d: %w: rule %s of tenant %s is duplicated", i, models.ErrConflict, rule.Name, tenant)
+		}
+		rule.CreatedAt = now
+		rule.UpdatedAt = now
+		e.setRule(tenant, rule)
+	}
+	return nil
+}
+
+// SetInterval changes interval between evaluations, it is applied when the current wait is over.
+func (e *Engine) SetInterval(interval time.Duration) {
+	e.interval.Store(int64(interval))
+}
+
+// Run evaluates rules with interval until context is canceled.
+func (e *Engine) Run(ctx context.Context) {
+	timer := time.NewTimer(time.Duration(e.interval.Load()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			e.Evaluate(ctx)
+			timer.Reset(time.Duration(e.interval.Load()))
+		}
+	}
+}
+
+// Evaluate evaluates all rules once. Alerts of a rule that failed to evaluate keep their state,
+// errors are logged, so other rules are still evaluated.
+func (e *Engine) Evaluate(ctx context.Context) {
+	started := e.now()
+	// Rules of the last sync are evaluated if store is unavailable.
+	if err := e.Sync(ctx); err != nil {
+		e.logger.Error("failed to sync alert rules", slog.Any("error", err))
+	}
+
+	type tenantRule struct {
+		tenant string
+		entry  *ruleEntry
+	}
+	e.mu.Lock()
+	var rules []tenantRule
+	for tenant, entries := range e.rules {
+		for _, entry := range entries {
+			rules = append(rules, tenantRule{tenant: tenant, entry: entry})
+		}
+	}
+	e.mu.Unlock()
+
+	failed := 0
+	for _, one := range rules {
+		results, err := e.evaluateRule(ctx, one.tenant, one.entry.rule, started)
+		if err != nil {
+			failed++
+			e.evaluationFailures.Add(1)
+			e.logger.Error("failed to evaluate alert rule",
+				slog.String("tenant", one.tenant),
+				slog.String("rule", one.entry.rule.Name),
+				slog.Any("error", err),
+			)
+			continue
+		}
+		e.apply(one.tenant, one.entry, results, started)
+	}
+	e.pruneSilences(started.UnixMicro())
+
+	e.logger.Debug("alert rules evaluated",
+		slog.Int("rules", len(rules)),
+		slog.Int("failed", failed),
+		slog.Duration("duration", time.Since(started)),
+	)
+}
+
+// result is an evaluation of a rule for one series.
+type result struct {
+	value float64
+	// matches is true if series has values in the window and their aggregation matches the condition.
+	matches bool
+}
+
+// evaluateRule queries points of selected series in the window of the rule as the tenant of the rule.
+func (e *Engine) evaluateRule(ctx context.Context, tenant string, rule models.AlertRule, now time.Time,
+) (map[string]result, error) {
+	ctx = models.WithIdentity(ctx, models.Identity{Subject: evaluationSubject, Tenant: tenant})
+	series, err := e.selectSeries(ctx, rule.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	// Window of valid rules in microseconds is within int64, the range starts at epoch at most.
+	end := now.UnixMicro()
+	start := max(end-rule.Window*int64(time.Second/time.Microsecond), 0)
+	results := make(map[string]result, len(series))
+	for _, name := range series {
+		records, err := e.querier.GetByRange(ctx, name, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query series %s: %w", name, err)
+		}
+		value, ok := aggregate(rule.Aggregation, records)
+		results[name] = result{
+			value:   value,
+			matches: ok && rule.Comparison.Compare(value, rule.Threshold),
+		}
+	}
+	return results, nil
+}
+
+// selectSeries returns names of series of the selector.
+func (e *Engine) selectSeries(ctx context.Context, selector models.AlertSelector) ([]string, error) {
+	if selector.Series != "" {
+		return []string{selector.Series}, nil
+	}
+
+	var names []string
+	filter := models.SeriesFilter{Labels: selector.Labels, Limit: seriesPageLimit}
+	for {
+		page, err := e.querier.ListSeries(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list series: %w", err)
+		}
+		for _, series := range page.Series {
+			names = append(names, series.Name)
+		}
+		if page.NextPageToken == "" {
+			return names, nil
+		}
+		filter.After = page.NextPageToken
+	}
+}
+
+// aggregate reduces numbers of records, bools are 1 and 0. Other values are only counted, non-finite floats
+// are skipped. It returns false if there are no values to aggregate.
+func aggregate(aggregation models.AlertAggregation, records []models.Record) (float64, bool) {
+	if aggregation == models.AggregationCount {
+		return float64(len(records)), true
+	}
+
+	var (
+		result float64
+		n      int
+		last   int64
+	)
+	for _, record := range records {
+		value, ok := numeric(record.MetricValue)
+		if !ok {
+			continue
+		}
+		n++
+		switch aggregation {
+		case models.AggregationAvg, models.AggregationSum:
+			result += value
+		case models.AggregationMin:
+			if n == 1 || value < result {
+				result = value
+			}
+		case models.AggregationMax:
+			if n == 1 || value > result {
+				result = value
+			}
+		case models.AggregationLast:
+			if n == 1 || record.Timestamp >= last {
+				result = value
+				last = record.Timestamp
+			}
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	if aggregation == models.AggregationAvg {
+		result /= float64(n)
+	}
+	return result, true
+}
+
+func numeric(v any) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, !math.IsNaN(value) && !math.IsInf(value, 0)
+	case int64:
+		return float64(value), true
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// apply changes states of alerts of the rule by evaluation results. Results are dropped if rule was changed
+// or deleted during evaluation.
+func (e *Engine) apply(tenant string, entry *ruleEntry, results map[string]result, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.rules[tenant][entry.rule.Name] != entry {
+		return
+	}
+	for series, res := range results {
+		e.transition(tenant, entry, series, res, now)
+	}
+	// Series that are not selected anymore don't match.
+	for series := range entry.alerts {
+		if _, ok := results[series]; !ok {
+			e.transition(tenant, entry, series, result{}, now)
+		}
+	}
+}
+
+// transition changes state of the alert of the series and sends notifications, it must be called with lock.
+// Firing is notified once, or again after repeat interval, resolved is notified only if firing was notified.
+func (e *Engine) transition(tenant string, entry *ruleEntry, series string, res result, now time.Time) {
+	state, ok := entry.alerts[series]
+	at := now.UnixMicro()
+	if !res.matches {
+		switch {
+		case !ok:
+		case state.alert.State == models.AlertStateFiring:
+			state.alert.State = models.AlertStateResolved
+			state.alert.ResolvedAt = at
+			state.alert.Silenced = e.silenced(tenant, entry.rule.Name, series, at)
+			if !state.notifiedAt.IsZero() {
+				e.notify(tenant, entry.rule, state.alert, at)
+				state.notifiedAt = time.Time{}
+			}
+		default:
+			// Pending alerts are dropped, resolved alerts are kept until the next evaluation.
+			delete(entry.alerts, series)
+		}
+		return
+	}
+
+	if !ok || state.alert.State == models.AlertStateResolved {
+		state = &alertState{alert: models.Alert{
+			Rule:     entry.rule.Name,
+			Series:   series,
+			State:    models.AlertStatePending,
+			ActiveAt: at,
+		}}
+		entry.alerts[series] = state
+	}
+	state.alert.Value = res.value
+	state.alert.Silenced = e.silenced(tenant, entry.rule.Name, series, at)
+	if state.alert.State == models.AlertStatePending && at-state.alert.ActiveAt >= entry.rule.For*int64(time.Second/time.Microsecond) {
+		state.alert.State = models.AlertStateFiring
+		state.alert.FiredAt = at
+	}
+	if state.alert.State != models.AlertStateFiring || state.alert.Silenced {
+		return
+	}
+	if state.notifiedAt.IsZero() || e.opts.RepeatInterval > 0 && now.Sub(state.notifiedAt) >= e.opts.RepeatInterval {
+		e.notify(tenant, entry.rule, state.alert, at)
+		state.notifiedAt = now
+	}
+}
+
+// resolveAll resolves notified alerts of the rule that is changed or deleted, it must be called with lock.
+func (e *Engine) resolveAll(tenant string, entry *ruleEntry, at int64) {
+	for _, state := range entry.alerts {
+		if state.alert.State != models.AlertStateFiring || state.notifiedAt.IsZero() {
+			continue
+		}
+		state.alert.State = models.AlertStateResolved
+		state.alert.ResolvedAt = at
+		e.notify(tenant, entry.rule, state.alert, at)
+	}
+}
+
+func (e *Engine) notify(tenant string, rule models.AlertRule, alert models.Alert, at int64) {
+	e.notifier.Notify(models.AlertNotification{
+		Status: alert.State,
+		Tenant: tenant,
+		Alert:  alert,
+		Rule:   rule,
+		SentAt: at,
+	})
+}
+
+// silenced reports whether an active silence matches the alert, it must be called with lock.
+func (e *Engine) silenced(tenant, rule, series string, at int64) bool {
+	for _, silence := range e.silences[tenant] {
+		if silence.Active(at) && silence.Matches(rule, series) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneSilences deletes expired silences.
+func (e *Engine) pruneSilences(at int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for tenant, silences := range e.silences {
+		for id, silence := range silences {
+			if silence.EndsAt <= at {
+				delete(silences, id)
+			}
+		}
+		if len(silences) == 0 {
+			delete(e.silences, tenant)
+		}
+	}
+}
+
+// Rules returns number of rules of all tenants.
+func (e *Engine) Rules() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for _, entries := range e.rules {
+		n += len(entries)
+	}
+	return n
+}
+
+// AlertStates returns number of alerts of all tenants by state.
+func (e *Engine) AlertStates() map[models.AlertState]int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	states := map[models.AlertState]int{
+		models.AlertStatePending:  0,
+		models.AlertStateFiring:   0,
+		models.AlertStateResolved: 0,
+	}
+	for _, entries := range e.rules {
+		for _, entry := range entries {
+			for _, state := range entry.alerts {
+				states[state.alert.State]++
+			}
+		}
+	}
+	return states
+}
+
+// EvaluationFailures returns number of rule evaluations that failed since start.
+func (e *Engine) EvaluationFailures() uint64 {
+	return e.evaluationFailures.Load()
+}
@@ -0,0 +1,420 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// querierMock returns records of series by tenant qualified name, series with labels are listed by pages of one.
+type querierMock struct {
+	mu      sync.Mutex
+	records map[string][]models.Record
+	labels  map[string]map[string]string
+	err     error
+}
+
+func (mock *querierMock) GetByRange(ctx context.Context, series string, start, end int64) ([]models.Record, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	name := models.TenantSeriesName(models.TenantFromContext(ctx), series)
+	records, ok := mock.records[name]
+	if !ok {
+		return nil, fmt.Errorf("series %s: %w", name, models.ErrNotFound)
+	}
+	var result []models.Record
+	for _, record := range records {
+		if record.Timestamp >= start && record.Timestamp <= end {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+func (mock *querierMock) ListSeries(ctx context.Context, filter models.SeriesFilter) (models.SeriesPage, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for _, name := range []string{"cpu", "memory", "web-1", "web-2"} {
+		series := models.Series{Name: name, Labels: mock.labels[name]}
+		if _, ok := mock.records[models.TenantSeriesName(models.TenantFromContext(ctx), name)]; !ok ||
+			name <= filter.After || !filter.Matches(&series) {
+			continue
+		}
+		return models.SeriesPage{Series: []models.Series{series}, NextPageToken: name}, nil
+	}
+	return models.SeriesPage{Series: []models.Series{}}, nil
+}
+
+func (mock *querierMock) set(series string, records ...models.Record) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.records[series] = records
+}
+
+type notifierMock struct {
+	mu            sync.Mutex
+	notifications []models.AlertNotification
+}
+
+func (mock *notifierMock) Notify(notification models.AlertNotification) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.notifications = append(mock.notifications, notification)
+}
+
+// take returns statuses and series of notifications since the previous call.
+func (mock *notifierMock) take() []string {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make([]string, 0, len(mock.notifications))
+	for _, notification := range mock.notifications {
+		result = append(result, fmt.Sprintf("%s %s/%s", notification.Status, notification.Tenant,
+			notification.Alert.Series))
+	}
+	mock.notifications = nil
+	return result
+}
+
+// testEngine is an engine with clock that is moved by tests.
+type testEngine struct {
+	*Engine
+	querier  *querierMock
+	notifier *notifierMock
+	clock    time.Time
+}
+
+func newTestEngine(opts Options) *testEngine {
+	te := &testEngine{
+		querier:  &querierMock{records: make(map[string][]models.Record), labels: make(map[string]map[string]string)},
+		notifier: &notifierMock{},
+		clock:    time.Date(2024, time.June, 7, 0, 0, 0, 0, time.UTC),
+	}
+	te.Engine = NewEngine(te.querier, te.notifier, opts, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	te.now = func() time.Time { return te.clock }
+	return te
+}
+
+// evaluate moves clock and evaluates rules.
+func (te *testEngine) evaluate(after time.Duration) {
+	te.clock = te.clock.Add(after)
+	te.Evaluate(context.Background())
+}
+
+// point returns record at the offset from the current time of the engine.
+func (te *testEngine) point(offset time.Duration, value any) models.Record {
+	return models.Record{Timestamp: te.clock.Add(offset).UnixMicro(), MetricValue: value}
+}
+
+func testRule(name string) models.AlertRule {
+	return models.AlertRule{
+		Name:        name,
+		Selector:    models.AlertSelector{Series: "cpu"},
+		Aggregation: models.AggregationAvg,
+		Window:      60,
+		Comparison:  models.ComparisonGreater,
+		Threshold:   90,
+		For:         60,
+	}
+}
+
+func tenantContext(tenant string) context.Context {
+	return models.WithIdentity(context.Background(), models.Identity{Subject: "test", Tenant: tenant})
+}
+
+func TestAggregate(t *testing.T) {
+	t.Parallel()
+	records := []models.Record{
+		{Timestamp: 3, MetricValue: 4.0},
+		{Timestamp: 1, MetricValue: int64(1)},
+		{Timestamp: 2, MetricValue: true},
+		{Timestamp: 4, MetricValue: math.NaN()},
+		{Timestamp: 5, MetricValue: "up"},
+	}
+	testCases := []struct {
+		aggregation models.AlertAggregation
+		records     []models.Record
+		value       float64
+		ok          bool
+	}{
+		{models.AggregationAvg, records, 2, true},
+		{models.AggregationMin, records, 1, true},
+		{models.AggregationMax, records, 4, true},
+		{models.AggregationSum, records, 6, true},
+		{models.AggregationLast, records, 4, true},
+		{models.AggregationCount, records, 5, true},
+		{models.AggregationCount, nil, 0, true},
+		{models.AggregationAvg, nil, 0, false},
+		{models.AggregationMax, []models.Record{{Timestamp: 1, MetricValue: "up"}}, 0, false},
+	}
+
+	for i, tc := range testCases {
+		value, ok := aggregate(tc.aggregation, tc.records)
+		require.Equal(t, tc.ok, ok, fmt.Sprintf("case %d", i))
+		require.InDelta(t, tc.value, value, 1e-9, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	t.Parallel()
+	te := newTestEngine(Options{})
+	_, err := te.CreateRule(context.Background(), testRule("high_cpu"))
+	require.NoError(t, err)
+
+	te.querier.set("cpu", te.point(0, 95.0))
+	te.evaluate(time.Second)
+	alerts, err := te.ListAlerts(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, models.AlertStatePending, alerts[0].State)
+	require.Equal(t, 95.0, alerts[0].Value)
+	require.Empty(t, te.notifier.take())
+
+	// Alert fires once condition holds for the duration, firing is notified once.
+	te.querier.set("cpu", te.point(0, 95.0), te.point(30*time.Second, 97.0))
+	te.evaluate(30 * time.Second)
+	require.Empty(t, te.notifier.take())
+	te.evaluate(30 * time.Second)
+	require.Equal(t, []string{"firing default/cpu"}, te.notifier.take())
+	te.evaluate(10 * time.Second)
+	require.Empty(t, te.notifier.take())
+	alerts, err = te.ListAlerts(context.Background(), models.AlertStateFiring)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, 97.0, alerts[0].Value)
+
+	// Failed evaluation keeps state.
+	te.querier.err = fmt.Errorf("storage: %w", models.ErrUnavailable)
+	te.evaluate(time.Minute)
+	require.Equal(t, uint64(1), te.EvaluationFailures())
+	require.Equal(t, 1, te.AlertStates()[models.AlertStateFiring])
+	te.querier.err = nil
+
+	// Points are out of the window, resolved alert is kept until the next evaluation.
+	te.evaluate(0)
+	require.Equal(t, []string{"resolved default/cpu"}, te.notifier.take())
+	alerts, err = te.ListAlerts(context.Background(), models.AlertStateResolved)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	te.evaluate(time.Minute)
+	require.Equal(t, map[models.AlertState]int{
+		models.AlertStatePending: 0, models.AlertStateFiring: 0, models.AlertStateResolved: 0,
+	}, te.AlertStates())
+}
+
+func TestEngine_EvaluateLongRule(t *testing.T) {
+	t.Parallel()
+	te := newTestEngine(Options{})
+	long := testRule("long_cpu")
+	long.Window = models.MaxAlertDuration
+	long.For = models.MaxAlertDuration
+	_, err := te.CreateRule(context.Background(), long)
+	require.NoError(t, err)
+	invalid := testRule("invalid")
+	invalid.Window = models.MaxAlertDuration + 1
+	_, err = te.CreateRule(context.Background(), invalid)
+	require.ErrorIs(t, err, models.ErrInvalidArgument)
+	invalid = testRule("invalid")
+	invalid.For = models.MaxAlertDuration + 1
+	_, err = te.CreateRule(context.Background(), invalid)
+	require.ErrorIs(t, err, models.ErrInvalidArgument)
+
+	// Window reaches old points and for doesn't wrap, so alert is pending.
+	te.querier.set("cpu", models.Record{Timestamp: 1, MetricValue: 95.0})
+	te.evaluate(time.Second)
+	require.Zero(t, te.EvaluationFailures())
+	alerts, err := te.ListAlerts(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, models.AlertStatePending, alerts[0].State)
+	te.evaluate(time.Hour)
+	require.Empty(t, te.notifier.take())
+}
+
+func TestEngine_EvaluateLabels(t *testing.T) {
+	t.Parallel()
+	te := newTestEngine(Options{RepeatInterval: time.Minute})
+	rule := testRule("web_down")
+	rule.Selector = models.AlertSelector{Labels: map[string]string{"role": "web"}}
+	rule.Aggregation = models.AggregationLast
+	rule.Comparison = models.ComparisonEqual
+	rule.Threshold = 0
+	rule.Window = 3600
+	rule.For = 0
+	_, err := te.CreateRule(tenantContext("acme"), rule)
+	require.NoError(t, err)
+
+	te.querier.labels["web-1"] = map[string]string{"role": "web"}
+	te.querier.labels["web-2"] = map[string]string{"role": "web"}
+	te.querier.set("acme/web-1", te.point(0, false))
+	te.querier.set("acme/web-2", te.point(0, true))
+	te.querier.set("acme/cpu", te.point(0, false))
+	// Series of other tenants are not selected.
+	te.querier.set("web-2", te.point(0, false))
+	te.evaluate(time.Second)
+	require.Equal(t, []string{"firing acme/web-1"}, te.notifier.take())
+
+	// Firing is notified again after repeat interval.
+	te.evaluate(30 * time.Second)
+	require.Empty(t, te.notifier.take())
+	te.evaluate(30 * time.Second)
+	require.Equal(t, []string{"firing acme/web-1"}, te.notifier.take())
+
+	// Series that isn't selected anymore is resolved.
+	te.querier.labels["web-1"] = nil
+	te.evaluate(time.Second)
+	require.Equal(t, []string{"resolved acme/web-1"}, te.notifier.take())
+
+	alerts, err := te.ListAlerts(tenantContext("acme"), "")
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, models.AlertStateResolved, alerts[0].State)
+	alerts, err = te.ListAlerts(context.Background(), "")
+	require.NoError(t, err)
+	require.Empty(t, alerts)
+}
+
+func TestEngine_Silences(t *testing.T) {
+	t.Parallel()
+	te := newTestEngine(Options{})
+	rule := testRule("high_cpu")
+	rule.For = 0
+	_, err := te.CreateRule(context.Background(), rule)
+	require.NoError(t, err)
+	silence, err := te.CreateSilence(context.Background(), models.Silence{
+		Rule:   "high_cpu",
+		EndsAt: te.clock.Add(time.Minute).UnixMicro(),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, silence.ID)
+	require.Equal(t, te.clock.UnixMicro(), silence.StartsAt)
+
+	te.querier.set("cpu", te.point(0, 95.0))
+	te.evaluate(time.Second)
+	require.Empty(t, te.notifier.take())
+	alerts, err := te.ListAlerts(context.Background(), models.AlertStateFiring)
+	require.NoError(t, err)
+	require.True(t, alerts[0].Silenced)
+
+	// Silenced alert that was not notified is resolved without notification.
+	te.querier.set("cpu")
+	te.evaluate(time.Second)
+	require.Empty(t, te.notifier.take())
+
+	// Firing is notified when silence expires, expired silences are deleted.
+	te.querier.set("cpu", te.point(0, 95.0))
+	te.evaluate(time.Second)
+	require.Empty(t, te.notifier.take())
+	te.querier.set("cpu", te.point(time.Minute, 95.0))
+	te.evaluate(time.Minute)
+	require.Equal(t, []string{"firing default/cpu"}, te.notifier.take())
+	silences, err := te.ListSilences(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, silences)
+	require.ErrorIs(t, te.DeleteSilence(context.Background(), silence.ID), models.ErrNotFound)
+
+	// Resolved is notified for notified alert even if it is silenced.
+	_, err = te.CreateSilence(context.Background(), models.Silence{EndsAt: te.clock.Add(time.Hour).UnixMicro()})
+	require.NoError(t, err)
+	te.querier.set("cpu")
+	te.evaluate(time.Second)
+	require.Equal(t, []string{"resolved default/cpu"}, te.notifier.take())
+}
+
+func TestEngine_Rules(t *testing.T) {
+	t.Parallel()
+	te := newTestEngine(Options{MaxRules: 2})
+	cfg := Config{Rules: []RuleConfig{{Name: "from_config", Aggregation: "max", Window: time.Minute,
+		Comparison: "lt", Threshold: 1}}}
+	cfg.Rules[0].Selector.Series = "cpu"
+	require.NoError(t, te.LoadRules(cfg))
+	require.ErrorIs(t, te.LoadRules(cfg), models.ErrConflict)
+
+	ctx := context.Background()
+	rule, err := te.CreateRule(ctx, testRule("high_cpu"))
+	require.NoError(t, err)
+	require.Equal(t, models.AlertRuleSourceAPI, rule.Source)
+	_, err = te.CreateRule(ctx, testRule("high_cpu"))
+	require.ErrorIs(t, err, models.ErrConflict)
+	_, err = te.CreateRule(ctx, models.AlertRule{Name: "invalid"})
+	require.ErrorIs(t, err, models.ErrInvalidArgument)
+	_, err = te.CreateRule(ctx, testRule("other_cpu"))
+	require.NoError(t, err)
+	// Rules of config are not limited.
+	_, err = te.CreateRule(ctx, testRule("third_cpu"))
+	require.ErrorIs(t, err, models.ErrQuotaExceeded)
+	// Rules are scoped by tenant.
+	_, err = te.CreateRule(tenantContext("acme"), testRule("high_cpu"))
+	require.NoError(t, err)
+
+	rules, err := te.ListRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	require.Equal(t, "from_config", rules[0].Name)
+	require.Equal(t, models.AlertRuleSourceConfig, rules[0].Source)
+	require.Equal(t, int64(60), rules[0].Window)
+	require.Equal(t, 4, te.Rules())
+
+	_, err = te.ReplaceRule(ctx, "from_config", testRule("from_config"))
+	require.ErrorIs(t, err, models.ErrConflict)
+	require.ErrorIs(t, te.DeleteRule(ctx, "from_config"), models.ErrConflict)
+	_, err = te.ReplaceRule(ctx, "high_cpu", testRule("other_name"))
+	require.ErrorIs(t, err, models.ErrInvalidArgument)
+	_, err = te.ReplaceRule(ctx, "unknown", testRule(""))
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// Firing alert is resolved when its rule is replaced or deleted.
+	te.querier.set("cpu", te.point(0, 95.0))
+	fast := testRule("")
+	fast.For = 0
+	replaced, err := te.ReplaceRule(ctx, "high_cpu", fast)
+	require.NoError(t, err)
+	require.Equal(t, "high_cpu", replaced.Name)
+	require.Equal(t, rule.CreatedAt, replaced.CreatedAt)
+	te.evaluate(time.Second)
+	require.Equal(t, []string{"firing default/cpu"}, te.notifier.take())
+	require.NoError(t, te.DeleteRule(ctx, "high_cpu"))
+	require.Equal(t, []string{"resolved default/cpu"}, te.notifier.take())
+	_, err = te.GetRule(ctx, "high_cpu")
+	require.ErrorIs(t, err, models.ErrNotFound)
+	got, err := te.GetRule(tenantContext("acme"), "high_cpu")
+	require.NoError(t, err)
+	require.Equal(t, "high_cpu", got.Name)
+}
+
+func TestEngine_CreateSilence(t *testing.T) {
+	t.Parallel()
+	te := newTestEngine(Options{MaxRules: 1})
+	now := te.clock.UnixMicro()
+	testCases := []struct {
+		silence models.Silence
+		err     error
+	}{
+		{models.Silence{EndsAt: now}, models.ErrInvalidArgument},
+		{models.Silence{StartsAt: now - 2, EndsAt: now - 1}, models.ErrInvalidArgument},
+		{models.Silence{StartsAt: now + 2, EndsAt: now + 1}, models.ErrInvalidArgument},
+		{models.Silence{EndsAt: now + 1}, nil},
+		{models.Silence{EndsAt: now + 1}, models.ErrQuotaExceeded},
+	}
+
+	for i, tc := range testCases {
+		silence, err := te.CreateSilence(tenantContext("acme"), tc.silence)
+		if tc.err != nil {
+			require.ErrorIs(t, err, tc.err, fmt.Sprintf("case %d", i))
+			continue
+		}
+		require.NoError(t, err, fmt.Sprintf("case %d", i))
+		require.Equal(t, "test", silence.CreatedBy, fmt.Sprintf("case %d", i))
+	}
+}
@@ -0,0 +1,188 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
+)
+
+// maxResponseSize limits response body of webhooks that is read, so connections can be reused.
+const maxResponseSize = 4096
+
+// NotifierOptions contains params of webhook notifications.
+type NotifierOptions struct {
+	// URLs are webhooks that receive each notification, notifications are not sent if it is empty.
+	URLs []string
+	// Timeout is a max duration of one request to a webhook.
+	Timeout time.Duration
+	// QueueSize is a number of notifications waiting for delivery to each webhook, new notifications are dropped
+	// for a webhook if its queue is full.
+	QueueSize int
+	// Retry retries requests that failed with network errors, 429 and 5xx statuses.
+	Retry resilience.Retry
+}
+
+// Notifier posts notifications to webhooks in background, so evaluation of rules is not blocked by webhooks.
+// Each webhook has own queue and worker, so retries of a failing webhook don't delay other webhooks.
+type Notifier struct {
+	opts   NotifierOptions
+	client *http.Client
+	// queues are queues of webhooks by index of url.
+	queues []chan models.AlertNotification
+	logger *slog.Logger
+
+	sent    atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// NewNotifier returns new notifier.
+func NewNotifier(opts NotifierOptions, logger *slog.Logger) *Notifier {
+	queues := make([]chan models.AlertNotification, len(opts.URLs))
+	for i := range queues {
+		queues[i] = make(chan models.AlertNotification, max(opts.QueueSize, 1))
+	}
+	return &Notifier{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		queues: queues,
+		logger: logger,
+	}
+}
+
+// Notify queues notification for delivery to each webhook, it never blocks.
+func (n *Notifier) Notify(notification models.AlertNotification) {
+	for i, queue := range n.queues {
+		select {
+		case queue <- notification:
+		default:
+			n.dropped.Add(1)
+			// Urls may contain secrets, so only index of the webhook is logged.
+			n.logger.Warn("alert notification is dropped, queue is full",
+				slog.Int("webhook", i),
+				slog.String("tenant", notification.Tenant),
+				slog.String("rule", notification.Alert.Rule),
+				slog.String("series", notification.Alert.Series),
+			)
+		}
+	}
+}
+
+// Run delivers queued notifications by a worker of each webhook until context is canceled, queued notifications
+// are dropped on cancel.
+func (n *Notifier) Run(ctx context.Context) {
+	var workers sync.WaitGroup
+	for i := range n.queues {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			n.run(ctx, i)
+		}()
+	}
+	workers.Wait()
+}
+
+// run delivers queued notifications to the webhook until context is canceled.
+func (n *Notifier) run(ctx context.Context, webhook int) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-n.queues[webhook]:
+			n.deliver(ctx, webhook, notification)
+		}
+	}
+}
+
+// deliver posts notification to the webhook with retries.
+func (n *Notifier) deliver(ctx context.Context, webhook int, notification models.AlertNotification) {
+	body, err := json.Marshal(notification)
+	if err == nil {
+		err = n.opts.Retry.Do(ctx, retryable, func() error {
+			return n.post(ctx, n.opts.URLs[webhook], body)
+		})
+	}
+	if err != nil {
+		n.failed.Add(1)
+		// Urls may contain secrets, so only index of the webhook is logged.
+		n.logger.Error("failed to send alert notification",
+			slog.Int("webhook", webhook),
+			slog.String("tenant", notification.Tenant),
+			slog.String("rule", notification.Alert.Rule),
+			slog.String("series", notification.Alert.Series),
+			slog.Any("error", err),
+		)
+		return
+	}
+	n.sent.Add(1)
+}
+
+func (n *Notifier) post(ctx context.Context, webhook string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// Error of client contains url.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &statusError{status: resp.StatusCode}
+	}
+	return nil
+}
+
+// statusError is returned if webhook responds with not successful status.
+type statusError struct {
+	status int
+}
+
+// Error implements error interface.
+func (e *statusError) Error() string {
+	return fmt.Sprintf("webhook responded with status %d", e.status)
+}
+
+// retryable reports whether request can be retried, client errors except 429 are not retried.
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status == http.StatusTooManyRequests || statusErr.status >= http.StatusInternalServerError
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// Sent returns number of notifications delivered to webhooks since start.
+func (n *Notifier) Sent() uint64 {
+	return n.sent.Load()
+}
+
+// Failed returns number of notifications that were not delivered to webhooks after retries since start.
+func (n *Notifier) Failed() uint64 {
+	return n.failed.Load()
+}
+
+// Dropped returns number of notifications dropped for webhooks because their queues were full since start.
+func (n *Notifier) Dropped() uint64 {
+	return n.dropped.Load()
+}
@@ -0,0 +1,127 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+	var (
+		calls    atomic.Int32
+		received = make(chan models.AlertNotification, 1)
+	)
+	// The first request fails and is retried.
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var notification models.AlertNotification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err == nil {
+			received <- notification
+		}
+	}))
+	defer flaky.Close()
+	var rejectedCalls atomic.Int32
+	// Client errors are not retried.
+	rejected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		rejectedCalls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejected.Close()
+
+	n := NewNotifier(NotifierOptions{
+		URLs:      []string{flaky.URL, rejected.URL},
+		Timeout:   time.Second,
+		QueueSize: 1,
+		Retry:     resilience.Retry{Attempts: 3, Backoff: time.Millisecond},
+	}, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	sent := models.AlertNotification{
+		Status: models.AlertStateFiring,
+		Tenant: models.DefaultTenant,
+		Alert:  models.Alert{Rule: "high_cpu", Series: "cpu", State: models.AlertStateFiring, Value: 95},
+		SentAt: 1,
+	}
+	n.Notify(sent)
+	// Queues are full until notifier runs.
+	n.Notify(sent)
+	require.Equal(t, uint64(2), n.Dropped())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.Run(ctx)
+	}()
+
+	require.Equal(t, sent, <-received)
+	require.Eventually(t, func() bool {
+		return n.Sent() == 1 && n.Failed() == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(2), calls.Load())
+	require.Equal(t, int32(1), rejectedCalls.Load())
+
+	cancel()
+	<-done
+}
+
+func TestNotifier_SlowWebhook(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	var fastCalls atomic.Int32
+	fast := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		fastCalls.Add(1)
+	}))
+	defer fast.Close()
+
+	n := NewNotifier(NotifierOptions{
+		URLs:      []string{slow.URL, fast.URL},
+		Timeout:   10 * time.Second,
+		QueueSize: 2,
+	}, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.Run(ctx)
+	}()
+
+	// Notifications are delivered to the fast webhook while the slow one doesn't respond.
+	n.Notify(models.AlertNotification{SentAt: 1})
+	n.Notify(models.AlertNotification{SentAt: 2})
+	require.Eventually(t, func() bool {
+		return fastCalls.Load() == 2
+	}, time.Second, time.Millisecond)
+	require.Equal(t, uint64(2), n.Sent())
+
+	cancel()
+	<-done
+}
+
+func TestNotifier_NoWebhooks(t *testing.T) {
+	t.Parallel()
+	n := NewNotifier(NotifierOptions{QueueSize: 1}, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	n.Notify(models.AlertNotification{})
+	n.Notify(models.AlertNotification{})
+	require.Equal(t, uint64(0), n.Dropped())
+}
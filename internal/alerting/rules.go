@@ -0,0 +1,273 @@
+package alerting
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// CreateRule validates and adds a rule of the request tenant.
+func (e *Engine) CreateRule(ctx context.Context, rule models.AlertRule) (models.AlertRule, error) {
+	rule.Source = models.AlertRuleSourceAPI
+	if err := rule.Validate(); err != nil {
+		return models.AlertRule{}, err
+	}
+	tenant := models.TenantFromContext(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.rules[tenant][rule.Name]; ok {
+		return models.AlertRule{}, models.NewDetailError(models.ErrConflict, "rule %s already exists", rule.Name)
+	}
+	if err := e.checkMaxRules(tenant); err != nil {
+		return models.AlertRule{}, err
+	}
+	now := e.now().UnixMicro()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	if e.store != nil {
+		if err := e.store.CreateAlertRule(ctx, tenant, rule); err != nil {
+			return models.AlertRule{}, fmt.Errorf("failed to save rule: %w", err)
+		}
+	}
+	e.setRule(tenant, rule)
+	return rule, nil
+}
+
+// ReplaceRule replaces definition of a rule of the request tenant, alerts of the rule are reset.
+func (e *Engine) ReplaceRule(ctx context.Context, name string, rule models.AlertRule) (models.AlertRule, error) {
+	if rule.Name == "" {
+		rule.Name = name
+	}
+	if rule.Name != name {
+		return models.AlertRule{}, models.NewValidationError("name", "must be equal to rule name in path")
+	}
+	rule.Source = models.AlertRuleSourceAPI
+	if err := rule.Validate(); err != nil {
+		return models.AlertRule{}, err
+	}
+	tenant := models.TenantFromContext(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entry, err := e.changeableRule(tenant, name)
+	if err != nil {
+		return models.AlertRule{}, err
+	}
+	now := e.now().UnixMicro()
+	rule.CreatedAt = entry.rule.CreatedAt
+	rule.UpdatedAt = now
+	if e.store != nil {
+		if err := e.store.UpdateAlertRule(ctx, tenant, rule); err != nil {
+			return models.AlertRule{}, fmt.Errorf("failed to save rule: %w", err)
+		}
+	}
+	e.resolveAll(tenant, entry, now)
+	e.setRule(tenant, rule)
+	return rule, nil
+}
+
+// DeleteRule deletes a rule of the request tenant, firing alerts that were notified are notified as resolved.
+func (e *Engine) DeleteRule(ctx context.Context, name string) error {
+	tenant := models.TenantFromContext(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entry, err := e.changeableRule(tenant, name)
+	if err != nil {
+		return err
+	}
+	if e.store != nil {
+		if err := e.store.DeleteAlertRule(ctx, tenant, name); err != nil {
+			return fmt.Errorf("failed to delete rule: %w", err)
+		}
+	}
+	e.resolveAll(tenant, entry, e.now().UnixMicro())
+	delete(e.rules[tenant], name)
+	if len(e.rules[tenant]) == 0 {
+		delete(e.rules, tenant)
+	}
+	return nil
+}
+
+// GetRule returns a rule of the request tenant.
+func (e *Engine) GetRule(ctx context.Context, name string) (models.AlertRule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entry, ok := e.rules[models.TenantFromContext(ctx)][name]
+	if !ok {
+		return models.AlertRule{}, models.NewDetailError(models.ErrNotFound, "rule %s", name)
+	}
+	return entry.rule, nil
+}
+
+// ListRules returns rules of the request tenant sorted by name.
+func (e *Engine) ListRules(ctx context.Context) ([]models.AlertRule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entries := e.rules[models.TenantFromContext(ctx)]
+	rules := make([]models.AlertRule, 0, len(entries))
+	for _, entry := range entries {
+		rules = append(rules, entry.rule)
+	}
+	slices.SortFunc(rules, func(a, b models.AlertRule) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return rules, nil
+}
+
+// ListAlerts returns alerts of the request tenant sorted by rule and series, empty state matches all alerts.
+func (e *Engine) ListAlerts(ctx context.Context, state models.AlertState) ([]models.Alert, error) {
+	switch state {
+	case "", models.AlertStatePending, models.AlertStateFiring, models.AlertStateResolved:
+	default:
+		return nil, models.NewValidationError("state", "unknown state %q", state)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]models.Alert, 0)
+	for _, entry := range e.rules[models.TenantFromContext(ctx)] {
+		for _, one := range entry.alerts {
+			if state == "" || one.alert.State == state {
+				alerts = append(alerts, one.alert)
+			}
+		}
+	}
+	slices.SortFunc(alerts, func(a, b models.Alert) int {
+		return cmp.Or(cmp.Compare(a.Rule, b.Rule), cmp.Compare(a.Series, b.Series))
+	})
+	return alerts, nil
+}
+
+// CreateSilence adds a silence of the request tenant, it starts now if start is not set.
+func (e *Engine) CreateSilence(ctx context.Context, silence models.Silence) (models.Silence, error) {
+	now := e.now().UnixMicro()
+	if silence.StartsAt == 0 {
+		silence.StartsAt = now
+	}
+	if err := silence.Validate(); err != nil {
+		return models.Silence{}, err
+	}
+	if silence.EndsAt <= now {
+		return models.Silence{}, models.NewValidationError("ends_at", "must be in the future")
+	}
+	id, err := newSilenceID()
+	if err != nil {
+		return models.Silence{}, err
+	}
+	silence.ID = id
+	silence.CreatedAt = now
+	silence.CreatedBy = ""
+	if identity, ok := models.IdentityFromContext(ctx); ok {
+		silence.CreatedBy = identity.Subject
+	}
+	tenant := models.TenantFromContext(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.opts.MaxRules > 0 && len(e.silences[tenant]) >= e.opts.MaxRules {
+		return models.Silence{}, models.NewDetailError(models.ErrQuotaExceeded,
+			"tenant %s has %d silences, limit is %d", tenant, len(e.silences[tenant]), e.opts.MaxRules)
+	}
+	if e.store != nil {
+		if err := e.store.CreateSilence(ctx, tenant, silence); err != nil {
+			return models.Silence{}, fmt.Errorf("failed to save silence: %w", err)
+		}
+	}
+	silences, ok := e.silences[tenant]
+	if !ok {
+		silences = make(map[string]models.Silence)
+		e.silences[tenant] = silences
+	}
+	silences[silence.ID] = silence
+	return silence, nil
+}
+
+// ListSilences returns silences of the request tenant that are not expired, sorted by start.
+func (e *Engine) ListSilences(ctx context.Context) ([]models.Silence, error) {
+	now := e.now().UnixMicro()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	silences := make([]models.Silence, 0)
+	for _, silence := range e.silences[models.TenantFromContext(ctx)] {
+		if silence.EndsAt > now {
+			silences = append(silences, silence)
+		}
+	}
+	slices.SortFunc(silences, func(a, b models.Silence) int {
+		return cmp.Or(cmp.Compare(a.StartsAt, b.StartsAt), cmp.Compare(a.ID, b.ID))
+	})
+	return silences, nil
+}
+
+// DeleteSilence deletes a silence of the request tenant, notifications of firing alerts are sent
+// on the next evaluation.
+func (e *Engine) DeleteSilence(ctx context.Context, id string) error {
+	tenant := models.TenantFromContext(ctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.silences[tenant][id]; !ok {
+		return models.NewDetailError(models.ErrNotFound, "silence %s", id)
+	}
+	if e.store != nil {
+		if err := e.store.DeleteSilence(ctx, tenant, id); err != nil {
+			return fmt.Errorf("failed to delete silence: %w", err)
+		}
+	}
+	delete(e.silences[tenant], id)
+	return nil
+}
+
+// setRule adds or replaces a rule without alerts, it must be called with lock.
+func (e *Engine) setRule(tenant string, rule models.AlertRule) {
+	entries, ok := e.rules[tenant]
+	if !ok {
+		entries = make(map[string]*ruleEntry)
+		e.rules[tenant] = entries
+	}
+	entries[rule.Name] = &ruleEntry{rule: rule, alerts: make(map[string]*alertState)}
+}
+
+// changeableRule returns a rule that can be changed by API, it must be called with lock.
+func (e *Engine) changeableRule(tenant, name string) (*ruleEntry, error) {
+	entry, ok := e.rules[tenant][name]
+	if !ok {
+		return nil, models.NewDetailError(models.ErrNotFound, "rule %s", name)
+	}
+	if entry.rule.Source == models.AlertRuleSourceConfig {
+		return nil, models.NewDetailError(models.ErrConflict, "rule %s is defined in alert rules file", name)
+	}
+	return entry, nil
+}
+
+// checkMaxRules returns error if tenant can't create more rules, it must be called with lock.
+func (e *Engine) checkMaxRules(tenant string) error {
+	if e.opts.MaxRules == 0 {
+		return nil
+	}
+	n := 0
+	for _, entry := range e.rules[tenant] {
+		if entry.rule.Source == models.AlertRuleSourceAPI {
+			n++
+		}
+	}
+	if n >= e.opts.MaxRules {
+		return models.NewDetailError(models.ErrQuotaExceeded, "tenant %s has %d rules, limit is %d",
+			tenant, n, e.opts.MaxRules)
+	}
+	return nil
+}
+
+func newSilenceID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate silence id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
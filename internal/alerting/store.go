@@ -0,0 +1,104 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// store keeps rules and silences created by API, so they survive restarts and are shared by instances.
+type store interface {
+	CreateAlertRule(ctx context.Context, tenant string, rule models.AlertRule) error
+	UpdateAlertRule(ctx context.Context, tenant string, rule models.AlertRule) error
+	DeleteAlertRule(ctx context.Context, tenant, name string) error
+	ListAlertRules(ctx context.Context) (map[string][]models.AlertRule, error)
+	CreateSilence(ctx context.Context, tenant string, silence models.Silence) error
+	DeleteSilence(ctx context.Context, tenant, id string) error
+	ListSilences(ctx context.Context) (map[string][]models.Silence, error)
+}
+
+// SetStore sets store of rules and silences created by API, they are kept only in memory of the instance
+// if it is not set.
+func (e *Engine) SetStore(store store) {
+	e.store = store
+}
+
+// Sync loads rules and silences of the store, so changes made by API of other instances are applied.
+// Alerts of changed and deleted rules are reset like on change by API of this instance. Rules of config
+// take precedence over stored rules with the same name, and rules and silences changed by this instance
+// while the store is read are kept.
+func (e *Engine) Sync(ctx context.Context) error {
+	if e.store == nil {
+		return nil
+	}
+	listed := e.now().UnixMicro()
+	rules, err := e.store.ListAlertRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	stored, err := e.store.ListSilences(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load silences: %w", err)
+	}
+	silences := make(map[string]map[string]models.Silence, len(stored))
+	for tenant, tenantSilences := range stored {
+		for _, silence := range tenantSilences {
+			if silence.EndsAt <= listed {
+				// Each instance deletes expired silences, so they are deleted while any instance runs.
+				if err := e.store.DeleteSilence(ctx, tenant, silence.ID); err != nil {
+					e.logger.Warn("failed to delete expired silence", slog.Any("error", err))
+				}
+				continue
+			}
+			if _, ok := silences[tenant]; !ok {
+				silences[tenant] = make(map[string]models.Silence)
+			}
+			silences[tenant][silence.ID] = silence
+		}
+	}
+
+	now := e.now().UnixMicro()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for tenant, entries := range e.rules {
+		for name, entry := range entries {
+			if entry.rule.Source == models.AlertRuleSourceAPI && entry.rule.UpdatedAt < listed &&
+				!slices.ContainsFunc(rules[tenant], func(rule models.AlertRule) bool { return rule.Name == name }) {
+				e.resolveAll(tenant, entry, now)
+				delete(entries, name)
+			}
+		}
+		if len(entries) == 0 {
+			delete(e.rules, tenant)
+		}
+	}
+	for tenant, tenantRules := range rules {
+		for _, rule := range tenantRules {
+			entry, ok := e.rules[tenant][rule.Name]
+			switch {
+			case !ok:
+				e.setRule(tenant, rule)
+			case entry.rule.Source == models.AlertRuleSourceConfig, entry.rule.UpdatedAt == rule.UpdatedAt,
+				entry.rule.UpdatedAt >= listed:
+			default:
+				e.resolveAll(tenant, entry, now)
+				e.setRule(tenant, rule)
+			}
+		}
+	}
+	for tenant, tenantSilences := range e.silences {
+		for id, silence := range tenantSilences {
+			if silence.CreatedAt >= listed && silence.EndsAt > now {
+				if _, ok := silences[tenant]; !ok {
+					silences[tenant] = make(map[string]models.Silence)
+				}
+				silences[tenant][id] = silence
+			}
+		}
+	}
+	e.silences = silences
+	return nil
+}
@@ -0,0 +1,153 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// storeMock keeps rules and silences in memory, it is shared by engines like storage by instances.
+type storeMock struct {
+	mu       sync.Mutex
+	rules    map[string]map[string]models.AlertRule
+	silences map[string]map[string]models.Silence
+}
+
+func newStoreMock() *storeMock {
+	return &storeMock{
+		rules:    make(map[string]map[string]models.AlertRule),
+		silences: make(map[string]map[string]models.Silence),
+	}
+}
+
+func (mock *storeMock) CreateAlertRule(_ context.Context, tenant string, rule models.AlertRule) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if _, ok := mock.rules[tenant][rule.Name]; ok {
+		return fmt.Errorf("failed to create: %w", models.ErrConflict)
+	}
+	if mock.rules[tenant] == nil {
+		mock.rules[tenant] = make(map[string]models.AlertRule)
+	}
+	mock.rules[tenant][rule.Name] = rule
+	return nil
+}
+
+func (mock *storeMock) UpdateAlertRule(_ context.Context, tenant string, rule models.AlertRule) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.rules[tenant][rule.Name] = rule
+	return nil
+}
+
+func (mock *storeMock) DeleteAlertRule(_ context.Context, tenant, name string) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	delete(mock.rules[tenant], name)
+	return nil
+}
+
+func (mock *storeMock) ListAlertRules(_ context.Context) (map[string][]models.AlertRule, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make(map[string][]models.AlertRule)
+	for tenant, rules := range mock.rules {
+		for _, rule := range rules {
+			result[tenant] = append(result[tenant], rule)
+		}
+	}
+	return result, nil
+}
+
+func (mock *storeMock) CreateSilence(_ context.Context, tenant string, silence models.Silence) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.silences[tenant] == nil {
+		mock.silences[tenant] = make(map[string]models.Silence)
+	}
+	mock.silences[tenant][silence.ID] = silence
+	return nil
+}
+
+func (mock *storeMock) DeleteSilence(_ context.Context, tenant, id string) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	delete(mock.silences[tenant], id)
+	return nil
+}
+
+func (mock *storeMock) ListSilences(_ context.Context) (map[string][]models.Silence, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make(map[string][]models.Silence)
+	for tenant, silences := range mock.silences {
+		for _, silence := range silences {
+			result[tenant] = append(result[tenant], silence)
+		}
+	}
+	return result, nil
+}
+
+func TestEngine_Sync(t *testing.T) {
+	t.Parallel()
+	store := newStoreMock()
+	first, second := newTestEngine(Options{}), newTestEngine(Options{})
+	first.SetStore(store)
+	second.SetStore(store)
+	cfg := Config{Rules: []RuleConfig{{Name: "from_config", Aggregation: "max", Window: time.Minute,
+		Comparison: "lt", Threshold: 1}}}
+	cfg.Rules[0].Selector.Series = "cpu"
+	require.NoError(t, second.LoadRules(cfg))
+	ctx := tenantContext("acme")
+
+	// Rules and silences created by API of one instance are applied by another one on sync.
+	_, err := first.CreateRule(ctx, testRule("high_cpu"))
+	require.NoError(t, err)
+	_, err = first.CreateRule(ctx, testRule("from_config"))
+	require.NoError(t, err)
+	silence, err := first.CreateSilence(ctx, models.Silence{Rule: "other_cpu",
+		EndsAt: first.clock.Add(time.Hour).UnixMicro()})
+	require.NoError(t, err)
+	second.clock = second.clock.Add(time.Second)
+	require.NoError(t, second.Sync(context.Background()))
+	rule, err := second.GetRule(ctx, "high_cpu")
+	require.NoError(t, err)
+	require.Equal(t, models.AlertRuleSourceAPI, rule.Source)
+	silences, err := second.ListSilences(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []models.Silence{silence}, silences)
+	// Rule of config takes precedence over stored rule.
+	rule, err = second.GetRule(context.Background(), "from_config")
+	require.NoError(t, err)
+	require.Equal(t, models.AlertRuleSourceConfig, rule.Source)
+
+	// Replaced and deleted rules are applied, firing alerts of them are resolved.
+	second.querier.set("acme/cpu", second.point(0, 95.0))
+	fast := testRule("high_cpu")
+	fast.For = 0
+	second.evaluate(time.Second)
+	_, err = second.ReplaceRule(ctx, "high_cpu", fast)
+	require.NoError(t, err)
+	second.evaluate(time.Second)
+	require.Equal(t, []string{"firing acme/cpu"}, second.notifier.take())
+	first.clock = second.clock
+	require.NoError(t, first.Sync(context.Background()))
+	rule, err = first.GetRule(ctx, "high_cpu")
+	require.NoError(t, err)
+	require.Zero(t, rule.For)
+	require.NoError(t, first.DeleteRule(ctx, "high_cpu"))
+	second.evaluate(time.Second)
+	require.Equal(t, []string{"resolved acme/cpu"}, second.notifier.take())
+	_, err = second.GetRule(ctx, "high_cpu")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// Expired silences are deleted from the store.
+	second.evaluate(time.Hour)
+	require.Empty(t, store.silences["acme"])
+}
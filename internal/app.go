@@ -1,79 +1,634 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"aerospike.com/rrd/internal/adaptors/storage"
+	"aerospike.com/rrd/internal/alerting"
 	"aerospike.com/rrd/internal/config"
+	"aerospike.com/rrd/internal/forecast"
+	"aerospike.com/rrd/internal/health"
 	"aerospike.com/rrd/internal/httpsrv"
+	"aerospike.com/rrd/internal/httpsrv/auth"
 	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/ingest"
+	"aerospike.com/rrd/internal/instrumentation"
+	"aerospike.com/rrd/internal/logging"
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
+	"aerospike.com/rrd/internal/retention"
 	"aerospike.com/rrd/internal/rrd"
+	"aerospike.com/rrd/internal/stream"
+	"aerospike.com/rrd/internal/tenant"
+	"aerospike.com/rrd/internal/timeexpr"
+	"aerospike.com/rrd/internal/tracing"
+	"aerospike.com/rrd/internal/wal"
 )
 
 const udfPath = "./udf/"
 
+// Version of the service, it is set on build with -ldflags.
+var Version = "dev"
+
 // App performs all services initializations.
 type App struct {
-	server *httpsrv.Server
-	logger *slog.Logger
+	server  *httpsrv.Server
+	sweeper *retention.Sweeper
+	storage *storage.Storage
+	// alerts evaluates alert rules, notifier sends their notifications to webhooks.
+	alerts   *alerting.Engine
+	notifier *alerting.Notifier
+	// queue saves writes in batches after response, it is nil if async ingestion is disabled.
+	queue *ingest.Queue
+	// hub streams created records, it is closed on shutdown, so streams don't block draining of requests.
+	hub *stream.Hub
+	// walWriter buffers writes while storage is unavailable, it is nil if write-ahead log is disabled.
+	walWriter *wal.Writer
+	walLog    *wal.Log
+	reloader  *config.Reloader
+	// shutdownTracing flushes spans that are not exported yet.
+	shutdownTracing func(context.Context) error
+	logger          *slog.Logger
+	// shutdownTimeout is a time to drain in-flight requests.
+	shutdownTimeout time.Duration
 }
 
-// NewApp returns new app instance.
-func NewApp() (*App, error) {
-	cfg, err := config.NewConfig()
+// NewApp returns new app instance, config is loaded from file if path is set, and from env.
+func NewApp(configPath string) (*App, error) {
+	cfg, err := config.NewConfig(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize config: %w", err)
 	}
 
-	lvl := slog.Level(0)
+	// Level is a var, so it can be changed on config reload.
+	lvl := &slog.LevelVar{}
 	if err = lvl.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
 		return nil, fmt.Errorf("failed to parse loglevel: %w", err)
 	}
+	// Context handler adds request id to logs of requests.
 	logger := slog.New(
-		slog.NewJSONHandler(
-			os.Stdout,
-			&slog.HandlerOptions{Level: lvl},
+		logging.NewContextHandler(
+			slog.NewJSONHandler(
+				os.Stdout,
+				&slog.HandlerOptions{Level: lvl},
+			),
 		),
 	)
 
-	db, err := storage.NewStorage(
-		cfg.StorageHost,
-		cfg.StoragePort,
-		cfg.StorageNamespace,
-		cfg.StorageCapacity,
-		udfPath,
-		logger,
-	)
+	location, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load time zone: %w", err)
+	}
+
+	valueSpec := cfg.ValueSpec()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+		ServiceName: cfg.TracingServiceName,
+		Version:     Version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+
+	metrics := instrumentation.NewMetrics()
+
+	tenants, err := newTenants(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize tenants: %w", err)
+	}
+
+	opts := storageOptions(cfg)
+	opts.TenantNamespaces = tenants.Namespaces()
+	db, err := storage.NewStorage(opts, metrics, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
+	var (
+		setter    recordSetter = db
+		walLog    *wal.Log
+		walWriter *wal.Writer
+		queue     *ingest.Queue
+	)
+	if cfg.WALPath != "" {
+		walLog, err = wal.Open(cfg.WALPath, cfg.WALMaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize write-ahead log: %w", err)
+		}
+		walWriter = wal.NewWriter(db, walLog, cfg.WALReplayInterval, logger)
+		setter = walWriter
+	}
+	pending := map[string]instrumentation.PendingSource{instrumentation.IngestStageStorage: db}
+	if walWriter != nil {
+		pending[instrumentation.IngestStageWAL] = walWriter
+	}
+
+	var serviceSetter rrdSetter = setter
+	if cfg.IngestAsync {
+		queue = ingest.NewQueue(setter, ingest.Options{
+			Size:      cfg.IngestQueueSize,
+			Workers:   cfg.IngestWorkers,
+			BatchSize: cfg.IngestBatchSize,
+			BatchWait: cfg.IngestBatchWait,
+			Ack:       cfg.IngestAck,
+		}, metrics, logger)
+		serviceSetter = queue
+		pending[instrumentation.IngestStageQueue] = queue
+	}
+
 	service := rrd.NewService(
 		db,
+		serviceSetter,
 		db,
+		tenants,
 	)
 
-	router := handlers.NewRRD(
+	hub := stream.NewHub(stream.Options{
+		BufferSize:     cfg.StreamBufferSize,
+		MaxSubscribers: cfg.StreamMaxSubscribers,
+	})
+	service.SetHub(hub)
+	service.SetForecaster(forecast.NewForecaster(db))
+
+	// Default series keeps legacy records without series name working.
+	defaultSeries, err := service.EnsureSeries(context.Background(), models.Series{
+		Name:        models.DefaultSeriesName,
+		Description: "Series for records without series name.",
+		DataSource:  models.DataSourceGauge,
+		Retention:   models.Retention{MaxPoints: cfg.StorageCapacity},
+		ValueType:   valueSpec,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize default series: %w", err)
+	}
+	if defaultSeries.ValueType.Type != valueSpec.Type {
+		logger.Warn("default series already exists with different value type, config is ignored",
+			slog.String("series_value_type", string(defaultSeries.ValueType.Type)),
+			slog.String("config_value_type", string(valueSpec.Type)),
+		)
+	}
+
+	rrdHandlers := handlers.NewRRD(
 		service,
 		service,
+		timeexpr.NewParser(location),
 		logger,
 	)
+	rrdHandlers.SetAsync(queue != nil && cfg.IngestAck == ingest.AckEnqueue)
+	rrdHandlers.SetLimits(rrdLimits(cfg))
 
-	httpServer := httpsrv.NewServer(
+	streamHandlers := handlers.NewStream(
+		service,
+		handlers.StreamOptions{
+			MaxBackfill: cfg.StreamMaxBackfill,
+			Heartbeat:   cfg.StreamHeartbeatInterval,
+		},
+		logger,
+	)
+
+	seriesHandlers := handlers.NewSeries(
+		service,
+		logger,
+	)
+
+	sweeper := retention.NewSweeper(
+		db,
+		cfg.RetentionSweepInterval,
+		logger,
+	)
+
+	retentionHandlers := handlers.NewRetention(
+		sweeper,
+		logger,
+	)
+
+	notifier := alerting.NewNotifier(alerting.NotifierOptions{
+		URLs:      cfg.AlertWebhookURLs,
+		Timeout:   cfg.AlertWebhookTimeout,
+		QueueSize: cfg.AlertWebhookQueueSize,
+		Retry: resilience.Retry{
+			Attempts:   cfg.AlertWebhookRetryAttempts,
+			Backoff:    cfg.AlertWebhookRetryBackoff,
+			MaxBackoff: cfg.AlertWebhookRetryMaxBackoff,
+		},
+	}, logger)
+	alerts, err := newAlerts(cfg, service, db, notifier, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alerting: %w", err)
+	}
+
+	alertHandlers := handlers.NewAlerts(
+		alerts,
+		logger,
+	)
+
+	forecastHandlers := handlers.NewForecast(
+		service,
+		logger,
+	)
+
+	tenantHandlers := handlers.NewTenants(
+		service,
+		logger,
+	)
+
+	healthHandlers := handlers.NewHealth(
+		health.NewService(db, Version),
+		logger,
+	)
+
+	if err = metrics.Register(instrumentation.NewSeriesCollector(db, logger)); err != nil {
+		return nil, fmt.Errorf("failed to register series collector: %w", err)
+	}
+	queueCapacity := 0
+	if queue != nil {
+		queueCapacity = queue.Capacity()
+	}
+	if err = metrics.Register(instrumentation.NewIngestCollector(queueCapacity, pending)); err != nil {
+		return nil, fmt.Errorf("failed to register ingest collector: %w", err)
+	}
+	if err = metrics.Register(instrumentation.NewStreamCollector(hub)); err != nil {
+		return nil, fmt.Errorf("failed to register stream collector: %w", err)
+	}
+	if err = metrics.Register(instrumentation.NewForecastCollector(service)); err != nil {
+		return nil, fmt.Errorf("failed to register forecast collector: %w", err)
+	}
+	if err = metrics.Register(instrumentation.NewAlertCollector(alerts, notifier)); err != nil {
+		return nil, fmt.Errorf("failed to register alert collector: %w", err)
+	}
+
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize authentication: %w", err)
+	}
+
+	limiter := httpsrv.NewLimiter(httpLimits(cfg))
+
+	httpServer, err := httpsrv.NewServer(
 		cfg.HttpPort,
-		router,
+		httpsrv.Handlers{
+			RRD:         rrdHandlers,
+			Series:      seriesHandlers,
+			Retention:   retentionHandlers,
+			Tenants:     tenantHandlers,
+			Health:      healthHandlers,
+			Stream:      streamHandlers,
+			Alerts:      alertHandlers,
+			Forecast:    forecastHandlers,
+			Metrics:     metrics.Handler(),
+			MetricsPath: cfg.MetricsPath,
+			Observer:    metrics,
+			Auth:        authenticator,
+			Limiter:     limiter,
+			Version:     Version,
+			Legacy: httpsrv.LegacyOptions{
+				Disabled: !cfg.HttpLegacyRoutes,
+				Sunset:   cfg.LegacySunset(),
+			},
+			Middleware: httpsrv.MiddlewareOptions{
+				AccessLog: cfg.HttpAccessLog,
+				CORS: httpsrv.CORSOptions{
+					AllowedOrigins: cfg.HttpCORSAllowedOrigins,
+					MaxAge:         cfg.HttpCORSMaxAge,
+				},
+				Compression:        cfg.HttpCompression,
+				CompressionMinSize: cfg.HttpCompressionMinSize,
+			},
+		},
+		httpsrv.TLSOptions{
+			CertFile:       cfg.HttpTLSCertFile,
+			KeyFile:        cfg.HttpTLSKeyFile,
+			ClientCAFile:   cfg.HttpTLSClientCAFile,
+			ClientAuth:     httpsrv.ClientAuth(cfg.HttpTLSClientAuth),
+			ReloadInterval: cfg.HttpTLSReloadInterval,
+			DisableHTTP2:   !cfg.HttpHTTP2Enabled,
+		},
+		logger,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize http server: %w", err)
+	}
+
+	reloader := config.NewReloader(configPath, cfg, logger)
+	// Capacity of default series is applied only when STORAGE_CAP changes, so reload of other params doesn't
+	// overwrite max points of default series that were changed by API.
+	var capacity atomic.Uint64
+	capacity.Store(cfg.StorageCapacity)
+	reloader.Subscribe(func(cfg *config.Config) {
+		if err := lvl.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+			logger.Error("failed to apply log level", slog.Any("error", err))
+		}
+		db.SetCapacity(cfg.StorageCapacity)
+		db.SetUseTTL(cfg.RetentionTTL)
+		sweeper.SetInterval(cfg.RetentionSweepInterval)
+		alerts.SetInterval(cfg.AlertEvaluationInterval)
+		limiter.SetLimits(httpLimits(cfg))
+		rrdHandlers.SetLimits(rrdLimits(cfg))
+		if capacity.Swap(cfg.StorageCapacity) == cfg.StorageCapacity {
+			return
+		}
+		if err := setDefaultCapacity(context.Background(), service, cfg.StorageCapacity); err != nil {
+			logger.Error("failed to apply capacity to default series", slog.Any("error", err))
+		}
+	})
 
 	return &App{
-		server: httpServer,
-		logger: logger,
+		server:          httpServer,
+		sweeper:         sweeper,
+		storage:         db,
+		alerts:          alerts,
+		notifier:        notifier,
+		queue:           queue,
+		hub:             hub,
+		walWriter:       walWriter,
+		walLog:          walLog,
+		reloader:        reloader,
+		shutdownTracing: shutdownTracing,
+		logger:          logger,
+		shutdownTimeout: cfg.HttpShutdownTimeout,
 	}, nil
 }
 
-// Start starts http server.
-func (app *App) Start() error {
-	app.logger.Info("starting server...")
-	return app.server.Start()
+// recordSetter saves records one by one or in batches, it is storage or write-ahead log writer on top of it.
+type recordSetter interface {
+	Set(ctx context.Context, record models.Record, retention models.Retention) error
+	SetBatch(ctx context.Context, writes []models.Write) []error
+}
+
+// rrdSetter saves records of rrd service, it is record setter or write queue on top of it.
+type rrdSetter interface {
+	Set(ctx context.Context, record models.Record, retention models.Retention) error
+}
+
+// httpLimits returns rate limits of clients and max body size.
+func httpLimits(cfg *config.Config) httpsrv.Limits {
+	return httpsrv.Limits{
+		Routes:         cfg.RouteRateLimits(),
+		Default:        cfg.RateLimit(),
+		Authentication: cfg.AuthRateLimit(),
+		MaxBodyBytes:   cfg.HttpMaxBodySize,
+	}
+}
+
+// rrdLimits returns limits of points per request and queried range.
+func rrdLimits(cfg *config.Config) handlers.RRDLimits {
+	return handlers.RRDLimits{
+		MaxBatchPoints: cfg.HttpMaxBatchPoints,
+		MaxRangeSpan:   cfg.HttpMaxQuerySpan,
+	}
+}
+
+// newTenants returns registry of tenants from TENANTS_FILE, tenants are not limited if it is not set.
+func newTenants(cfg *config.Config) (*tenant.Registry, error) {
+	var tenantsCfg tenant.Config
+	if cfg.TenantsFile != "" {
+		var err error
+		if tenantsCfg, err = tenant.LoadConfig(cfg.TenantsFile); err != nil {
+			return nil, err
+		}
+	}
+	return tenant.NewRegistry(tenantsCfg)
+}
+
+// newAlerts returns alerting engine with rules from ALERT_RULES_FILE if it is set.
+func newAlerts(cfg *config.Config, service *rrd.Service, db *storage.Storage, notifier *alerting.Notifier,
+	logger *slog.Logger,
+) (*alerting.Engine, error) {
+	engine := alerting.NewEngine(service, notifier, alerting.Options{
+		Interval:       cfg.AlertEvaluationInterval,
+		MaxRules:       cfg.AlertMaxRules,
+		RepeatInterval: cfg.AlertRepeatInterval,
+	}, logger)
+	if cfg.AlertRulesFile != "" {
+		rulesCfg, err := alerting.LoadConfig(cfg.AlertRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		if err = engine.LoadRules(rulesCfg); err != nil {
+			return nil, fmt.Errorf("invalid alert rules file %s: %w", cfg.AlertRulesFile, err)
+		}
+	}
+	// Rules of API are shared by instances through storage, they are loaded again before each evaluation,
+	// so failed load doesn't prevent start.
+	engine.SetStore(db)
+	if err := engine.Sync(context.Background()); err != nil {
+		logger.Error("failed to load alert rules from storage", slog.Any("error", err))
+	}
+	return engine, nil
+}
+
+// newAuthenticator returns chain of configured authenticators, it returns nil if auth is disabled.
+func newAuthenticator(cfg *config.Config) (httpsrv.Authenticator, error) {
+	if !cfg.AuthEnabled {
+		return nil, nil
+	}
+
+	var authenticators []auth.Authenticator
+	if cfg.AuthKeysFile != "" {
+		keys, err := auth.LoadKeys(cfg.AuthKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys, err := auth.NewAPIKeys(keys.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		hmacKeys, err := auth.NewHMAC(keys.HMACKeys, cfg.AuthClockSkew)
+		if err != nil {
+			return nil, err
+		}
+		// Client certificates are verified on handshake, so they are checked first.
+		if len(keys.ClientCerts) > 0 {
+			clientCerts, err := auth.NewClientCerts(keys.ClientCerts)
+			if err != nil {
+				return nil, err
+			}
+			authenticators = append(authenticators, clientCerts)
+		}
+		authenticators = append(authenticators, apiKeys, hmacKeys)
+	}
+	if cfg.AuthJWKSFile != "" {
+		jwt, err := auth.NewJWT(auth.JWTOptions{
+			JWKSPath:    cfg.AuthJWKSFile,
+			Issuer:      cfg.AuthJWTIssuer,
+			Audience:    cfg.AuthJWTAudience,
+			TenantClaim: cfg.AuthTenantClaim,
+			Leeway:      cfg.AuthClockSkew,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwt)
+	}
+	return auth.NewChain(authenticators...), nil
+}
+
+// storageOptions returns storage options from config.
+func storageOptions(cfg *config.Config) storage.Options {
+	return storage.Options{
+		Hosts:       cfg.Hosts(),
+		Namespace:   cfg.StorageNamespace,
+		MaxRecords:  cfg.StorageCapacity,
+		UseTTL:      cfg.RetentionTTL,
+		UDFPath:     udfPath,
+		ClusterName: cfg.StorageClusterName,
+		User:        cfg.StorageUser,
+		Password:    cfg.StoragePassword,
+		AuthMode:    cfg.StorageAuthMode,
+		TLS: storage.TLSOptions{
+			Enabled:  cfg.StorageTLSEnabled,
+			Name:     cfg.StorageTLSName,
+			CAFile:   cfg.StorageTLSCAFile,
+			CertFile: cfg.StorageTLSCertFile,
+			KeyFile:  cfg.StorageTLSKeyFile,
+		},
+		ConnectionQueueSize:   cfg.StorageConnectionQueueSize,
+		MinConnectionsPerNode: cfg.StorageMinConnectionsPerNode,
+		ConnectTimeout:        cfg.StorageConnectTimeout,
+		IdleTimeout:           cfg.StorageIdleTimeout,
+		LoginTimeout:          cfg.StorageLoginTimeout,
+		Read: storage.PolicyOptions{
+			TotalTimeout:        cfg.StorageReadTotalTimeout,
+			SocketTimeout:       cfg.StorageReadSocketTimeout,
+			MaxRetries:          cfg.StorageReadMaxRetries,
+			SleepBetweenRetries: cfg.StorageReadRetrySleep,
+			Replica:             cfg.StorageReadReplica,
+		},
+		Write: storage.PolicyOptions{
+			TotalTimeout:        cfg.StorageWriteTotalTimeout,
+			SocketTimeout:       cfg.StorageWriteSocketTimeout,
+			MaxRetries:          cfg.StorageWriteMaxRetries,
+			SleepBetweenRetries: cfg.StorageWriteRetrySleep,
+		},
+		Query: storage.PolicyOptions{
+			TotalTimeout:        cfg.StorageQueryTotalTimeout,
+			SocketTimeout:       cfg.StorageQuerySocketTimeout,
+			MaxRetries:          cfg.StorageQueryMaxRetries,
+			SleepBetweenRetries: cfg.StorageQueryRetrySleep,
+			Replica:             cfg.StorageQueryReplica,
+		},
+		RetryAttempts:    cfg.StorageRetryAttempts,
+		RetryBackoff:     cfg.StorageRetryBackoff,
+		RetryMaxBackoff:  cfg.StorageRetryMaxBackoff,
+		BreakerThreshold: cfg.StorageBreakerThreshold,
+		BreakerCooldown:  cfg.StorageBreakerCooldown,
+	}
+}
+
+// setDefaultCapacity updates max points of the default series, as it is created with STORAGE_CAP.
+func setDefaultCapacity(ctx context.Context, service *rrd.Service, capacity uint64) error {
+	series, err := service.DescribeSeries(ctx, models.DefaultSeriesName)
+	if err != nil {
+		return err
+	}
+	if series.Retention.MaxPoints == capacity {
+		return nil
+	}
+
+	retention := series.Retention
+	retention.MaxPoints = capacity
+	_, err = service.UpdateSeries(ctx, models.DefaultSeriesName, models.SeriesUpdate{
+		Retention: &retention,
+		Version:   &series.Version,
+	})
+	return err
+}
+
+// Start starts retention sweeper, alerting, write-ahead log replay and http server. It blocks until context is canceled
+// or server fails, then shuts down the app gracefully.
+func (app *App) Start(ctx context.Context) error {
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		app.sweeper.Run(backgroundCtx)
+	}()
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		app.alerts.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		app.notifier.Run(backgroundCtx)
+	}()
+	if app.walWriter != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			app.walWriter.Run(backgroundCtx)
+		}()
+	}
+	// Reloader is stopped with the app, it doesn't hold any resources.
+	go app.reloader.Run(ctx)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		app.logger.Info("starting server...")
+		serverErr <- app.server.Start()
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		app.logger.Info("shutting down server...")
+	case err = <-serverErr:
+		if err != nil {
+			err = fmt.Errorf("failed to start server: %w", err)
+		}
+	}
+
+	if errShutdown := app.shutdown(stopBackground, &background); errShutdown != nil {
+		err = errors.Join(err, errShutdown)
+	}
+	return err
+}
+
+// shutdown drains in-flight requests and write queue, then stops background jobs, replays write-ahead log
+// and closes storage, so no writes are dropped and storage is closed the last. Spans are flushed after all.
+// Not replayed entries are kept for the next start.
+func (app *App) shutdown(stopBackground context.CancelFunc, background *sync.WaitGroup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), app.shutdownTimeout)
+	defer cancel()
+
+	var err error
+	app.hub.Close()
+	if errShutdown := app.server.Shutdown(ctx); errShutdown != nil {
+		err = fmt.Errorf("failed to shutdown server: %w", errShutdown)
+	}
+	if app.queue != nil {
+		if errClose := app.queue.Close(ctx); errClose != nil {
+			err = errors.Join(err, errClose)
+		}
+	}
+
+	stopBackground()
+	background.Wait()
+
+	if app.walWriter != nil {
+		app.walWriter.Flush(ctx)
+		if errClose := app.walLog.Close(); errClose != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close write-ahead log: %w", errClose))
+		}
+	}
+
+	app.storage.Close()
+	if errShutdown := app.shutdownTracing(ctx); errShutdown != nil {
+		err = errors.Join(err, fmt.Errorf("failed to shutdown tracing: %w", errShutdown))
+	}
+	app.logger.Info("server stopped")
+
+	return err
 }
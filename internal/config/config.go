@@ -1,29 +1,532 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+
+	"aerospike.com/rrd/internal/models"
 )
 
+// EnvConfigFile is env variable with config file path, it is used when path is not set by flag.
+const EnvConfigFile = "CONFIG_FILE"
+
 // Config contains all configs for different services.
+// Fields with `reload` tag are applied on reload without restart, changes of other fields are ignored until restart.
 type Config struct {
 	// Logger
-	LogLevel string `env:"LOG_LEVEL" env-default:"info"`
+	LogLevel string `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" env-default:"info" reload:"true"`
 	// Http server params.
-	HttpPort int `env:"HTTP_PORT" env-default:"8080"`
+	HttpPort int `yaml:"http_port" toml:"http_port" env:"HTTP_PORT" env-default:"8080"`
+	// HttpShutdownTimeout is a time to wait for in-flight requests on shutdown.
+	HttpShutdownTimeout time.Duration `yaml:"http_shutdown_timeout" toml:"http_shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" env-default:"30s"`
+	// HTTPS params, server uses plain http if certificate is not set.
+	HttpTLSCertFile string `yaml:"http_tls_cert_file" toml:"http_tls_cert_file" env:"HTTP_TLS_CERT_FILE"`
+	HttpTLSKeyFile  string `yaml:"http_tls_key_file" toml:"http_tls_key_file" env:"HTTP_TLS_KEY_FILE"`
+	// HttpTLSClientCAFile is a path to CA certificates of clients, it is required if client certificates are verified.
+	HttpTLSClientCAFile string `yaml:"http_tls_client_ca_file" toml:"http_tls_client_ca_file" env:"HTTP_TLS_CLIENT_CA_FILE"`
+	// HttpTLSClientAuth is one of `none`, `optional` or `require`.
+	HttpTLSClientAuth string `yaml:"http_tls_client_auth" toml:"http_tls_client_auth" env:"HTTP_TLS_CLIENT_AUTH" env-default:"none"`
+	// HttpTLSReloadInterval is an interval of checking certificate files for changes, 0 disables reload.
+	HttpTLSReloadInterval time.Duration `yaml:"http_tls_reload_interval" toml:"http_tls_reload_interval" env:"HTTP_TLS_RELOAD_INTERVAL" env-default:"10s"`
+	HttpHTTP2Enabled      bool          `yaml:"http_http2_enabled" toml:"http_http2_enabled" env:"HTTP_HTTP2_ENABLED" env-default:"true"`
+	// HttpAccessLog enables logging of each request.
+	HttpAccessLog bool `yaml:"http_access_log" toml:"http_access_log" env:"HTTP_ACCESS_LOG" env-default:"true"`
+	// HttpCORSAllowedOrigins are origins allowed to call API from browsers, `*` allows any, CORS is disabled if empty.
+	HttpCORSAllowedOrigins []string      `yaml:"http_cors_allowed_origins" toml:"http_cors_allowed_origins" env:"HTTP_CORS_ALLOWED_ORIGINS" env-separator:","`
+	HttpCORSMaxAge         time.Duration `yaml:"http_cors_max_age" toml:"http_cors_max_age" env:"HTTP_CORS_MAX_AGE" env-default:"10m"`
+	// HttpCompression are response encodings in order of preference, empty disables compression of responses.
+	HttpCompression        []string `yaml:"http_compression" toml:"http_compression" env:"HTTP_COMPRESSION" env-separator:"," env-default:"zstd,gzip"`
+	HttpCompressionMinSize int      `yaml:"http_compression_min_size" toml:"http_compression_min_size" env:"HTTP_COMPRESSION_MIN_SIZE" env-default:"1024"`
+	// Request limits, zero means no limit.
+	HttpMaxBodySize    int64         `yaml:"http_max_body_size" toml:"http_max_body_size" env:"HTTP_MAX_BODY_SIZE" env-default:"1048576" reload:"true"`
+	HttpMaxBatchPoints int           `yaml:"http_max_batch_points" toml:"http_max_batch_points" env:"HTTP_MAX_BATCH_POINTS" env-default:"1000" reload:"true"`
+	HttpMaxQuerySpan   time.Duration `yaml:"http_max_query_span" toml:"http_max_query_span" env:"HTTP_MAX_QUERY_SPAN" env-default:"0s" reload:"true"`
+	// HttpRateLimit is a rate limit of each client in format rate[:burst] on routes without own limit.
+	HttpRateLimit string `yaml:"http_rate_limit" toml:"http_rate_limit" env:"HTTP_RATE_LIMIT" reload:"true"`
+	// HttpRateLimitRoutes are rate limits of each client on routes in format `METHOD /path=rate[:burst]`.
+	HttpRateLimitRoutes []string `yaml:"http_rate_limit_routes" toml:"http_rate_limit_routes" env:"HTTP_RATE_LIMIT_ROUTES" env-separator:"," reload:"true"`
+	// HttpAuthRateLimit is a rate limit of failed authentication of each remote ip in format rate[:burst].
+	HttpAuthRateLimit string `yaml:"http_auth_rate_limit" toml:"http_auth_rate_limit" env:"HTTP_AUTH_RATE_LIMIT" env-default:"100:200" reload:"true"`
+	// HttpLegacyRoutes serves deprecated routes without /api/v1 prefix, HttpLegacySunset is a date in format
+	// YYYY-MM-DD when they are removed, it is sent in Sunset header if it is set.
+	HttpLegacyRoutes bool   `yaml:"http_legacy_routes" toml:"http_legacy_routes" env:"HTTP_LEGACY_ROUTES" env-default:"true"`
+	HttpLegacySunset string `yaml:"http_legacy_sunset" toml:"http_legacy_sunset" env:"HTTP_LEGACY_SUNSET"`
+	// MetricsPath is a path of self-instrumentation in prometheus format, /metrics is used by data API.
+	MetricsPath string `yaml:"metrics_path" toml:"metrics_path" env:"METRICS_PATH" env-default:"/internal/metrics"`
+	// Tracing params, exporter is one of `none`, `stdout` or `otlp`, spans are not recorded with `none`.
+	TracingExporter string `yaml:"tracing_exporter" toml:"tracing_exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	// TracingEndpoint is host:port of OTLP HTTP collector, OTEL_EXPORTER_OTLP_* env variables are used if it is empty.
+	TracingEndpoint    string  `yaml:"tracing_endpoint" toml:"tracing_endpoint" env:"TRACING_ENDPOINT"`
+	TracingInsecure    bool    `yaml:"tracing_insecure" toml:"tracing_insecure" env:"TRACING_INSECURE" env-default:"false"`
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio" toml:"tracing_sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	TracingServiceName string  `yaml:"tracing_service_name" toml:"tracing_service_name" env:"TRACING_SERVICE_NAME" env-default:"rrd"`
+	// Time zone for resolving calendar anchors and dates without zone in query params.
+	TimeZone string `yaml:"time_zone" toml:"time_zone" env:"TIME_ZONE" env-default:"UTC"`
+	// ConfigWatchInterval is an interval of checking config file for changes, 0 disables watching. It is a string,
+	// as default is applied to zero values, so explicit 0 of a duration would be replaced by default.
+	ConfigWatchInterval string `yaml:"config_watch_interval" toml:"config_watch_interval" env:"CONFIG_WATCH_INTERVAL" env-default:"10s"`
 	// Storage paras
-	StorageCapacity  uint64 `env:"STORAGE_CAP" env-default:"1000"`
-	StorageHost      string `env:"STORAGE_HOST" env-default:"localhost"`
-	StoragePort      int    `env:"STORAGE_PORT" env-default:"3000"`
-	StorageNamespace string `env:"STORAGE_NAMESPACE" env-default:"test"`
+	StorageCapacity  uint64 `yaml:"storage_cap" toml:"storage_cap" env:"STORAGE_CAP" env-default:"1000" reload:"true"`
+	StorageHost      string `yaml:"storage_host" toml:"storage_host" env:"STORAGE_HOST" env-default:"localhost"`
+	StoragePort      int    `yaml:"storage_port" toml:"storage_port" env:"STORAGE_PORT" env-default:"3000"`
+	StorageNamespace string `yaml:"storage_namespace" toml:"storage_namespace" env:"STORAGE_NAMESPACE" env-default:"test"`
+	// StorageHosts are seed nodes in host:port or host:tls_name:port format, StorageHost and StoragePort are used if empty.
+	StorageHosts       []string `yaml:"storage_hosts" toml:"storage_hosts" env:"STORAGE_HOSTS" env-separator:","`
+	StorageClusterName string   `yaml:"storage_cluster_name" toml:"storage_cluster_name" env:"STORAGE_CLUSTER_NAME"`
+	// Storage auth params.
+	StorageUser     string `yaml:"storage_user" toml:"storage_user" env:"STORAGE_USER"`
+	StoragePassword string `yaml:"storage_password" toml:"storage_password" env:"STORAGE_PASSWORD"`
+	StorageAuthMode string `yaml:"storage_auth_mode" toml:"storage_auth_mode" env:"STORAGE_AUTH_MODE" env-default:"internal"`
+	// Storage TLS params.
+	StorageTLSEnabled  bool   `yaml:"storage_tls_enabled" toml:"storage_tls_enabled" env:"STORAGE_TLS_ENABLED" env-default:"false"`
+	StorageTLSName     string `yaml:"storage_tls_name" toml:"storage_tls_name" env:"STORAGE_TLS_NAME"`
+	StorageTLSCAFile   string `yaml:"storage_tls_ca_file" toml:"storage_tls_ca_file" env:"STORAGE_TLS_CA_FILE"`
+	StorageTLSCertFile string `yaml:"storage_tls_cert_file" toml:"storage_tls_cert_file" env:"STORAGE_TLS_CERT_FILE"`
+	StorageTLSKeyFile  string `yaml:"storage_tls_key_file" toml:"storage_tls_key_file" env:"STORAGE_TLS_KEY_FILE"`
+	// Storage connection params.
+	StorageConnectionQueueSize   int           `yaml:"storage_connection_queue_size" toml:"storage_connection_queue_size" env:"STORAGE_CONNECTION_QUEUE_SIZE" env-default:"100"`
+	StorageMinConnectionsPerNode int           `yaml:"storage_min_connections_per_node" toml:"storage_min_connections_per_node" env:"STORAGE_MIN_CONNECTIONS_PER_NODE" env-default:"0"`
+	StorageConnectTimeout        time.Duration `yaml:"storage_connect_timeout" toml:"storage_connect_timeout" env:"STORAGE_CONNECT_TIMEOUT" env-default:"30s"`
+	StorageIdleTimeout           time.Duration `yaml:"storage_idle_timeout" toml:"storage_idle_timeout" env:"STORAGE_IDLE_TIMEOUT" env-default:"55s"`
+	StorageLoginTimeout          time.Duration `yaml:"storage_login_timeout" toml:"storage_login_timeout" env:"STORAGE_LOGIN_TIMEOUT" env-default:"10s"`
+	// Storage read policy params.
+	StorageReadTotalTimeout  time.Duration `yaml:"storage_read_total_timeout" toml:"storage_read_total_timeout" env:"STORAGE_READ_TOTAL_TIMEOUT" env-default:"1s"`
+	StorageReadSocketTimeout time.Duration `yaml:"storage_read_socket_timeout" toml:"storage_read_socket_timeout" env:"STORAGE_READ_SOCKET_TIMEOUT" env-default:"30s"`
+	StorageReadMaxRetries    int           `yaml:"storage_read_max_retries" toml:"storage_read_max_retries" env:"STORAGE_READ_MAX_RETRIES" env-default:"2"`
+	StorageReadRetrySleep    time.Duration `yaml:"storage_read_retry_sleep" toml:"storage_read_retry_sleep" env:"STORAGE_READ_RETRY_SLEEP" env-default:"1ms"`
+	StorageReadReplica       string        `yaml:"storage_read_replica" toml:"storage_read_replica" env:"STORAGE_READ_REPLICA" env-default:"sequence"`
+	// Storage write policy params, writes are not retried by default, as they may be applied twice.
+	StorageWriteTotalTimeout  time.Duration `yaml:"storage_write_total_timeout" toml:"storage_write_total_timeout" env:"STORAGE_WRITE_TOTAL_TIMEOUT" env-default:"1s"`
+	StorageWriteSocketTimeout time.Duration `yaml:"storage_write_socket_timeout" toml:"storage_write_socket_timeout" env:"STORAGE_WRITE_SOCKET_TIMEOUT" env-default:"30s"`
+	StorageWriteMaxRetries    int           `yaml:"storage_write_max_retries" toml:"storage_write_max_retries" env:"STORAGE_WRITE_MAX_RETRIES" env-default:"0"`
+	StorageWriteRetrySleep    time.Duration `yaml:"storage_write_retry_sleep" toml:"storage_write_retry_sleep" env:"STORAGE_WRITE_RETRY_SLEEP" env-default:"1ms"`
+	// Storage query and scan policy params.
+	StorageQueryTotalTimeout  time.Duration `yaml:"storage_query_total_timeout" toml:"storage_query_total_timeout" env:"STORAGE_QUERY_TOTAL_TIMEOUT" env-default:"0s"`
+	StorageQuerySocketTimeout time.Duration `yaml:"storage_query_socket_timeout" toml:"storage_query_socket_timeout" env:"STORAGE_QUERY_SOCKET_TIMEOUT" env-default:"30s"`
+	StorageQueryMaxRetries    int           `yaml:"storage_query_max_retries" toml:"storage_query_max_retries" env:"STORAGE_QUERY_MAX_RETRIES" env-default:"5"`
+	StorageQueryRetrySleep    time.Duration `yaml:"storage_query_retry_sleep" toml:"storage_query_retry_sleep" env:"STORAGE_QUERY_RETRY_SLEEP" env-default:"1ms"`
+	StorageQueryReplica       string        `yaml:"storage_query_replica" toml:"storage_query_replica" env:"STORAGE_QUERY_REPLICA" env-default:"sequence"`
+	// Resilience params, retries are made on top of aerospike client retries and only for idempotent calls.
+	StorageRetryAttempts    int           `yaml:"storage_retry_attempts" toml:"storage_retry_attempts" env:"STORAGE_RETRY_ATTEMPTS" env-default:"3"`
+	StorageRetryBackoff     time.Duration `yaml:"storage_retry_backoff" toml:"storage_retry_backoff" env:"STORAGE_RETRY_BACKOFF" env-default:"50ms"`
+	StorageRetryMaxBackoff  time.Duration `yaml:"storage_retry_max_backoff" toml:"storage_retry_max_backoff" env:"STORAGE_RETRY_MAX_BACKOFF" env-default:"1s"`
+	StorageBreakerThreshold int           `yaml:"storage_breaker_threshold" toml:"storage_breaker_threshold" env:"STORAGE_BREAKER_THRESHOLD" env-default:"5"`
+	StorageBreakerCooldown  time.Duration `yaml:"storage_breaker_cooldown" toml:"storage_breaker_cooldown" env:"STORAGE_BREAKER_COOLDOWN" env-default:"10s"`
+	// Write-ahead log params, log is disabled if path is empty.
+	WALPath           string        `yaml:"wal_path" toml:"wal_path" env:"WAL_PATH" env-default:""`
+	WALMaxSize        int64         `yaml:"wal_max_size" toml:"wal_max_size" env:"WAL_MAX_SIZE" env-default:"67108864"`
+	WALReplayInterval time.Duration `yaml:"wal_replay_interval" toml:"wal_replay_interval" env:"WAL_REPLAY_INTERVAL" env-default:"5s"`
+	// Async ingestion params, writes are saved synchronously if async is disabled.
+	IngestAsync     bool          `yaml:"ingest_async" toml:"ingest_async" env:"INGEST_ASYNC" env-default:"false"`
+	IngestAck       string        `yaml:"ingest_ack" toml:"ingest_ack" env:"INGEST_ACK" env-default:"enqueue"`
+	IngestQueueSize int           `yaml:"ingest_queue_size" toml:"ingest_queue_size" env:"INGEST_QUEUE_SIZE" env-default:"10000"`
+	IngestWorkers   int           `yaml:"ingest_workers" toml:"ingest_workers" env:"INGEST_WORKERS" env-default:"4"`
+	IngestBatchSize int           `yaml:"ingest_batch_size" toml:"ingest_batch_size" env:"INGEST_BATCH_SIZE" env-default:"100"`
+	IngestBatchWait time.Duration `yaml:"ingest_batch_wait" toml:"ingest_batch_wait" env:"INGEST_BATCH_WAIT" env-default:"5ms"`
+	// Streaming params, subscriber is disconnected when its buffer is full, zero max subscribers means no limit.
+	StreamBufferSize        int           `yaml:"stream_buffer_size" toml:"stream_buffer_size" env:"STREAM_BUFFER_SIZE" env-default:"256"`
+	StreamMaxSubscribers    int           `yaml:"stream_max_subscribers" toml:"stream_max_subscribers" env:"STREAM_MAX_SUBSCRIBERS" env-default:"1000"`
+	StreamMaxBackfill       int           `yaml:"stream_max_backfill" toml:"stream_max_backfill" env:"STREAM_MAX_BACKFILL" env-default:"1000"`
+	StreamHeartbeatInterval time.Duration `yaml:"stream_heartbeat_interval" toml:"stream_heartbeat_interval" env:"STREAM_HEARTBEAT_INTERVAL" env-default:"15s"`
+	// Auth params, all routes except health checks require credentials if auth is enabled.
+	AuthEnabled     bool          `yaml:"auth_enabled" toml:"auth_enabled" env:"AUTH_ENABLED" env-default:"false"`
+	AuthKeysFile    string        `yaml:"auth_keys_file" toml:"auth_keys_file" env:"AUTH_KEYS_FILE"`
+	AuthJWKSFile    string        `yaml:"auth_jwks_file" toml:"auth_jwks_file" env:"AUTH_JWKS_FILE"`
+	AuthJWTIssuer   string        `yaml:"auth_jwt_issuer" toml:"auth_jwt_issuer" env:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience string        `yaml:"auth_jwt_audience" toml:"auth_jwt_audience" env:"AUTH_JWT_AUDIENCE"`
+	AuthTenantClaim string        `yaml:"auth_tenant_claim" toml:"auth_tenant_claim" env:"AUTH_TENANT_CLAIM" env-default:"tenant"`
+	AuthClockSkew   time.Duration `yaml:"auth_clock_skew" toml:"auth_clock_skew" env:"AUTH_CLOCK_SKEW" env-default:"5m"`
+	// TenantsFile contains placement and quotas of tenants, tenants are not limited if it is empty.
+	TenantsFile string `yaml:"tenants_file" toml:"tenants_file" env:"TENANTS_FILE"`
+	// Alerting params, rules of the file can't be changed by API, zero max rules means no limit.
+	AlertRulesFile          string        `yaml:"alert_rules_file" toml:"alert_rules_file" env:"ALERT_RULES_FILE"`
+	AlertEvaluationInterval time.Duration `yaml:"alert_evaluation_interval" toml:"alert_evaluation_interval" env:"ALERT_EVALUATION_INTERVAL" env-default:"30s" reload:"true"`
+	AlertMaxRules           int           `yaml:"alert_max_rules" toml:"alert_max_rules" env:"ALERT_MAX_RULES" env-default:"100"`
+	// AlertRepeatInterval is an interval of repeated notifications of firing alerts, they are sent once if it is 0.
+	AlertRepeatInterval time.Duration `yaml:"alert_repeat_interval" toml:"alert_repeat_interval" env:"ALERT_REPEAT_INTERVAL" env-default:"0s"`
+	// Webhook params, notifications are not sent if there are no urls.
+	AlertWebhookURLs            []string      `yaml:"alert_webhook_urls" toml:"alert_webhook_urls" env:"ALERT_WEBHOOK_URLS" env-separator:","`
+	AlertWebhookTimeout         time.Duration `yaml:"alert_webhook_timeout" toml:"alert_webhook_timeout" env:"ALERT_WEBHOOK_TIMEOUT" env-default:"5s"`
+	AlertWebhookQueueSize       int           `yaml:"alert_webhook_queue_size" toml:"alert_webhook_queue_size" env:"ALERT_WEBHOOK_QUEUE_SIZE" env-default:"1000"`
+	AlertWebhookRetryAttempts   int           `yaml:"alert_webhook_retry_attempts" toml:"alert_webhook_retry_attempts" env:"ALERT_WEBHOOK_RETRY_ATTEMPTS" env-default:"5"`
+	AlertWebhookRetryBackoff    time.Duration `yaml:"alert_webhook_retry_backoff" toml:"alert_webhook_retry_backoff" env:"ALERT_WEBHOOK_RETRY_BACKOFF" env-default:"500ms"`
+	AlertWebhookRetryMaxBackoff time.Duration `yaml:"alert_webhook_retry_max_backoff" toml:"alert_webhook_retry_max_backoff" env:"ALERT_WEBHOOK_RETRY_MAX_BACKOFF" env-default:"30s"`
+	// Retention params.
+	RetentionTTL           bool          `yaml:"retention_ttl" toml:"retention_ttl" env:"RETENTION_TTL" env-default:"false" reload:"true"`
+	RetentionSweepInterval time.Duration `yaml:"retention_sweep_interval" toml:"retention_sweep_interval" env:"RETENTION_SWEEP_INTERVAL" env-default:"1m" reload:"true"`
+	// Metric value params.
+	MetricValueType string   `yaml:"metric_value_type" toml:"metric_value_type" env:"METRIC_VALUE_TYPE" env-default:"float64"`
+	MetricValueEnum []string `yaml:"metric_value_enum" toml:"metric_value_enum" env:"METRIC_VALUE_ENUM" env-separator:","`
 }
 
-// NewConfig returns initialized app config.
-func NewConfig() (*Config, error) {
+// Path returns config file path from flag, or from env if flag is empty.
+func Path(flagPath string) string {
+	if flagPath != "" {
+		return flagPath
+	}
+	return os.Getenv(EnvConfigFile)
+}
+
+// NewConfig returns initialized and validated app config. Config file is optional,
+// it can be yaml or toml, env variables override its values.
+func NewConfig(path string) (*Config, error) {
 	var cfg Config
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to load config from env: %w", err)
+	if path == "" {
+		if err := cleanenv.ReadEnv(&cfg); err != nil {
+			return nil, fmt.Errorf("failed to load config from env: %w", err)
+		}
+	} else if err := cleanenv.ReadConfig(path, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to load config from file %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 	return &cfg, nil
 }
+
+// Validate checks all params and returns all found problems.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(env, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", env, fmt.Sprintf(format, args...)))
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		invalid("LOG_LEVEL", "unknown level %q, must be one of debug, info, warn, error", c.LogLevel)
+	}
+	if c.HttpPort < 0 || c.HttpPort > 65535 {
+		invalid("HTTP_PORT", "must be in range [0, 65535], got %d", c.HttpPort)
+	}
+	if c.HttpShutdownTimeout <= 0 {
+		invalid("HTTP_SHUTDOWN_TIMEOUT", "must be positive, got %s", c.HttpShutdownTimeout)
+	}
+	if (c.HttpTLSCertFile == "") != (c.HttpTLSKeyFile == "") {
+		invalid("HTTP_TLS_CERT_FILE", "must be set together with HTTP_TLS_KEY_FILE")
+	}
+	switch c.HttpTLSClientAuth {
+	case "none":
+	case "optional", "require":
+		if c.HttpTLSCertFile == "" {
+			invalid("HTTP_TLS_CLIENT_AUTH", "requires HTTP_TLS_CERT_FILE")
+		}
+		if c.HttpTLSClientCAFile == "" {
+			invalid("HTTP_TLS_CLIENT_CA_FILE", "must be set if client certificates are verified")
+		}
+	default:
+		invalid("HTTP_TLS_CLIENT_AUTH", "unknown mode %q, must be one of none, optional, require", c.HttpTLSClientAuth)
+	}
+	if c.HttpTLSReloadInterval < 0 {
+		invalid("HTTP_TLS_RELOAD_INTERVAL", "must not be negative, got %s", c.HttpTLSReloadInterval)
+	}
+	for _, origin := range c.HttpCORSAllowedOrigins {
+		if err := validateOrigin(origin); err != nil {
+			invalid("HTTP_CORS_ALLOWED_ORIGINS", "%s", err)
+		}
+	}
+	if c.HttpCORSMaxAge < 0 {
+		invalid("HTTP_CORS_MAX_AGE", "must not be negative, got %s", c.HttpCORSMaxAge)
+	}
+	for _, encoding := range c.HttpCompression {
+		if encoding != "zstd" && encoding != "gzip" {
+			invalid("HTTP_COMPRESSION", "unknown encoding %q, must be one of zstd, gzip", encoding)
+		}
+	}
+	if c.HttpCompressionMinSize < 0 {
+		invalid("HTTP_COMPRESSION_MIN_SIZE", "must not be negative, got %d", c.HttpCompressionMinSize)
+	}
+	if c.HttpMaxBodySize < 0 {
+		invalid("HTTP_MAX_BODY_SIZE", "must not be negative, got %d", c.HttpMaxBodySize)
+	}
+	if c.HttpMaxBatchPoints < 0 {
+		invalid("HTTP_MAX_BATCH_POINTS", "must not be negative, got %d", c.HttpMaxBatchPoints)
+	}
+	if c.HttpMaxQuerySpan < 0 {
+		invalid("HTTP_MAX_QUERY_SPAN", "must not be negative, got %s", c.HttpMaxQuerySpan)
+	}
+	if c.HttpRateLimit != "" {
+		if _, err := models.ParseRateLimit(c.HttpRateLimit); err != nil {
+			invalid("HTTP_RATE_LIMIT", "%s", err)
+		}
+	}
+	for _, limit := range c.HttpRateLimitRoutes {
+		if _, _, err := parseRouteRateLimit(limit); err != nil {
+			invalid("HTTP_RATE_LIMIT_ROUTES", "%s", err)
+		}
+	}
+	if c.HttpAuthRateLimit != "" {
+		if _, err := models.ParseRateLimit(c.HttpAuthRateLimit); err != nil {
+			invalid("HTTP_AUTH_RATE_LIMIT", "%s", err)
+		}
+	}
+	if c.HttpLegacySunset != "" {
+		if _, err := time.Parse(time.DateOnly, c.HttpLegacySunset); err != nil {
+			invalid("HTTP_LEGACY_SUNSET", "must be a date in format YYYY-MM-DD, got %q", c.HttpLegacySunset)
+		}
+	}
+	if !strings.HasPrefix(c.MetricsPath, "/") {
+		invalid("METRICS_PATH", "must start with /, got %q", c.MetricsPath)
+	}
+	switch c.TracingExporter {
+	case "none", "stdout", "otlp":
+	default:
+		invalid("TRACING_EXPORTER", "unknown exporter %q, must be one of none, stdout, otlp", c.TracingExporter)
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		invalid("TRACING_SAMPLE_RATIO", "must be in range [0, 1], got %g", c.TracingSampleRatio)
+	}
+	if c.TracingServiceName == "" {
+		invalid("TRACING_SERVICE_NAME", "must not be empty")
+	}
+	if _, err := time.LoadLocation(c.TimeZone); err != nil {
+		invalid("TIME_ZONE", "unknown time zone %q", c.TimeZone)
+	}
+	if interval, err := time.ParseDuration(c.ConfigWatchInterval); err != nil {
+		invalid("CONFIG_WATCH_INTERVAL", "invalid duration %q", c.ConfigWatchInterval)
+	} else if interval < 0 {
+		invalid("CONFIG_WATCH_INTERVAL", "must not be negative, got %s", interval)
+	}
+	if c.StorageCapacity == 0 {
+		invalid("STORAGE_CAP", "must be positive")
+	}
+	if c.StorageHost == "" {
+		invalid("STORAGE_HOST", "must not be empty")
+	}
+	if c.StoragePort <= 0 || c.StoragePort > 65535 {
+		invalid("STORAGE_PORT", "must be in range [1, 65535], got %d", c.StoragePort)
+	}
+	if c.StorageNamespace == "" {
+		invalid("STORAGE_NAMESPACE", "must not be empty")
+	}
+	switch c.StorageAuthMode {
+	case "internal", "external":
+		if c.StoragePassword != "" && c.StorageUser == "" {
+			invalid("STORAGE_USER", "must be set with STORAGE_PASSWORD")
+		}
+	case "pki":
+		if !c.StorageTLSEnabled || c.StorageTLSCertFile == "" {
+			invalid("STORAGE_AUTH_MODE", "pki requires STORAGE_TLS_ENABLED and client certificate")
+		}
+	default:
+		invalid("STORAGE_AUTH_MODE", "unknown mode %q, must be one of internal, external, pki", c.StorageAuthMode)
+	}
+	if (c.StorageTLSCertFile == "") != (c.StorageTLSKeyFile == "") {
+		invalid("STORAGE_TLS_CERT_FILE", "must be set together with STORAGE_TLS_KEY_FILE")
+	}
+	if c.StorageConnectionQueueSize <= 0 {
+		invalid("STORAGE_CONNECTION_QUEUE_SIZE", "must be positive, got %d", c.StorageConnectionQueueSize)
+	}
+	if c.StorageMinConnectionsPerNode < 0 || c.StorageMinConnectionsPerNode > c.StorageConnectionQueueSize {
+		invalid("STORAGE_MIN_CONNECTIONS_PER_NODE", "must be in range [0, STORAGE_CONNECTION_QUEUE_SIZE], got %d",
+			c.StorageMinConnectionsPerNode)
+	}
+	for _, replica := range []struct{ env, value string }{
+		{"STORAGE_READ_REPLICA", c.StorageReadReplica},
+		{"STORAGE_QUERY_REPLICA", c.StorageQueryReplica},
+	} {
+		switch replica.value {
+		case "master", "master_proles", "sequence", "prefer_rack", "random":
+		default:
+			invalid(replica.env, "unknown replica policy %q, must be one of master, master_proles, sequence, "+
+				"prefer_rack, random", replica.value)
+		}
+	}
+	for _, retries := range []struct {
+		env   string
+		value int
+	}{
+		{"STORAGE_READ_MAX_RETRIES", c.StorageReadMaxRetries},
+		{"STORAGE_WRITE_MAX_RETRIES", c.StorageWriteMaxRetries},
+		{"STORAGE_QUERY_MAX_RETRIES", c.StorageQueryMaxRetries},
+	} {
+		if retries.value < 0 {
+			invalid(retries.env, "must not be negative, got %d", retries.value)
+		}
+	}
+	if c.StorageRetryAttempts < 1 {
+		invalid("STORAGE_RETRY_ATTEMPTS", "must be positive, got %d", c.StorageRetryAttempts)
+	}
+	if c.StorageRetryBackoff < 0 || c.StorageRetryMaxBackoff < c.StorageRetryBackoff {
+		invalid("STORAGE_RETRY_BACKOFF", "must be in range [0, STORAGE_RETRY_MAX_BACKOFF], got %s", c.StorageRetryBackoff)
+	}
+	if c.StorageBreakerThreshold < 0 {
+		invalid("STORAGE_BREAKER_THRESHOLD", "must not be negative, got %d", c.StorageBreakerThreshold)
+	}
+	if c.StorageBreakerCooldown <= 0 {
+		invalid("STORAGE_BREAKER_COOLDOWN", "must be positive, got %s", c.StorageBreakerCooldown)
+	}
+	if c.WALMaxSize < 0 {
+		invalid("WAL_MAX_SIZE", "must not be negative, got %d", c.WALMaxSize)
+	}
+	if c.WALReplayInterval <= 0 {
+		invalid("WAL_REPLAY_INTERVAL", "must be positive, got %s", c.WALReplayInterval)
+	}
+	if c.IngestAck != "enqueue" && c.IngestAck != "durable" {
+		invalid("INGEST_ACK", "unknown mode %q, must be one of enqueue, durable", c.IngestAck)
+	}
+	for _, size := range []struct {
+		env   string
+		value int
+	}{
+		{"INGEST_QUEUE_SIZE", c.IngestQueueSize},
+		{"INGEST_WORKERS", c.IngestWorkers},
+		{"INGEST_BATCH_SIZE", c.IngestBatchSize},
+	} {
+		if size.value <= 0 {
+			invalid(size.env, "must be positive, got %d", size.value)
+		}
+	}
+	if c.IngestBatchWait < 0 {
+		invalid("INGEST_BATCH_WAIT", "must not be negative, got %s", c.IngestBatchWait)
+	}
+	if c.StreamBufferSize <= 0 {
+		invalid("STREAM_BUFFER_SIZE", "must be positive, got %d", c.StreamBufferSize)
+	}
+	if c.StreamMaxSubscribers < 0 {
+		invalid("STREAM_MAX_SUBSCRIBERS", "must not be negative, got %d", c.StreamMaxSubscribers)
+	}
+	if c.StreamMaxBackfill < 0 {
+		invalid("STREAM_MAX_BACKFILL", "must not be negative, got %d", c.StreamMaxBackfill)
+	}
+	if c.StreamHeartbeatInterval < 0 {
+		invalid("STREAM_HEARTBEAT_INTERVAL", "must not be negative, got %s", c.StreamHeartbeatInterval)
+	}
+	if c.AuthEnabled && c.AuthKeysFile == "" && c.AuthJWKSFile == "" {
+		invalid("AUTH_ENABLED", "requires AUTH_KEYS_FILE or AUTH_JWKS_FILE")
+	}
+	if c.AuthJWKSFile != "" && c.AuthTenantClaim == "" {
+		invalid("AUTH_TENANT_CLAIM", "must not be empty")
+	}
+	if c.AuthClockSkew < 0 {
+		invalid("AUTH_CLOCK_SKEW", "must not be negative, got %s", c.AuthClockSkew)
+	}
+	if c.AlertEvaluationInterval <= 0 {
+		invalid("ALERT_EVALUATION_INTERVAL", "must be positive, got %s", c.AlertEvaluationInterval)
+	}
+	if c.AlertMaxRules < 0 {
+		invalid("ALERT_MAX_RULES", "must not be negative, got %d", c.AlertMaxRules)
+	}
+	if c.AlertRepeatInterval < 0 {
+		invalid("ALERT_REPEAT_INTERVAL", "must not be negative, got %s", c.AlertRepeatInterval)
+	}
+	for i, webhook := range c.AlertWebhookURLs {
+		// Urls may contain secrets, so only index is reported.
+		if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("ALERT_WEBHOOK_URLS", "url %d must be absolute http or https url", i)
+		}
+	}
+	if c.AlertWebhookTimeout <= 0 {
+		invalid("ALERT_WEBHOOK_TIMEOUT", "must be positive, got %s", c.AlertWebhookTimeout)
+	}
+	if c.AlertWebhookQueueSize <= 0 {
+		invalid("ALERT_WEBHOOK_QUEUE_SIZE", "must be positive, got %d", c.AlertWebhookQueueSize)
+	}
+	if c.AlertWebhookRetryAttempts < 1 {
+		invalid("ALERT_WEBHOOK_RETRY_ATTEMPTS", "must be positive, got %d", c.AlertWebhookRetryAttempts)
+	}
+	if c.AlertWebhookRetryBackoff < 0 || c.AlertWebhookRetryMaxBackoff < c.AlertWebhookRetryBackoff {
+		invalid("ALERT_WEBHOOK_RETRY_BACKOFF", "must be in range [0, ALERT_WEBHOOK_RETRY_MAX_BACKOFF], got %s",
+			c.AlertWebhookRetryBackoff)
+	}
+	if c.RetentionSweepInterval <= 0 {
+		invalid("RETENTION_SWEEP_INTERVAL", "must be positive, got %s", c.RetentionSweepInterval)
+	}
+	if err := c.ValueSpec().Validate(); err != nil {
+		invalid("METRIC_VALUE_TYPE", "%s", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Hosts returns storage seed hosts, STORAGE_HOST and STORAGE_PORT are used if STORAGE_HOSTS is empty.
+func (c *Config) Hosts() []string {
+	if len(c.StorageHosts) > 0 {
+		return c.StorageHosts
+	}
+	return []string{fmt.Sprintf("%s:%d", c.StorageHost, c.StoragePort)}
+}
+
+// RateLimit returns rate limit of routes without own limit, it is valid after Validate.
+func (c *Config) RateLimit() models.RateLimit {
+	if c.HttpRateLimit == "" {
+		return models.RateLimit{}
+	}
+	limit, _ := models.ParseRateLimit(c.HttpRateLimit)
+	return limit
+}
+
+// AuthRateLimit returns rate limit of failed authentication of remote ips, it is valid after Validate.
+func (c *Config) AuthRateLimit() models.RateLimit {
+	if c.HttpAuthRateLimit == "" {
+		return models.RateLimit{}
+	}
+	limit, _ := models.ParseRateLimit(c.HttpAuthRateLimit)
+	return limit
+}
+
+// WatchInterval returns interval of checking config file for changes, it is zero if watching is disabled or
+// interval is invalid.
+func (c *Config) WatchInterval() time.Duration {
+	interval, _ := time.ParseDuration(c.ConfigWatchInterval)
+	return interval
+}
+
+// LegacySunset returns date when legacy routes are removed, it is zero if it is not set or invalid.
+func (c *Config) LegacySunset() time.Time {
+	sunset, _ := time.Parse(time.DateOnly, c.HttpLegacySunset)
+	return sunset
+}
+
+// RouteRateLimits returns rate limits keyed by method and route, they are valid after Validate.
+func (c *Config) RouteRateLimits() map[string]models.RateLimit {
+	limits := make(map[string]models.RateLimit, len(c.HttpRateLimitRoutes))
+	for _, limit := range c.HttpRateLimitRoutes {
+		if route, rateLimit, err := parseRouteRateLimit(limit); err == nil {
+			limits[route] = rateLimit
+		}
+	}
+	return limits
+}
+
+// validateOrigin returns error if origin is not `*` or `scheme://host[:port]`.
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" ||
+		u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("origin %q must be * or scheme://host[:port]", origin)
+	}
+	return nil
+}
+
+// parseRouteRateLimit parses rate limit of a route in format `METHOD /path=rate[:burst]`.
+func parseRouteRateLimit(s string) (string, models.RateLimit, error) {
+	route, limit, ok := strings.Cut(s, "=")
+	method, path, hasPath := strings.Cut(route, " ")
+	if !ok || !hasPath || method == "" || !strings.HasPrefix(path, "/") {
+		return "", models.RateLimit{}, fmt.Errorf("route limit %q must be in format METHOD /path=rate[:burst]", s)
+	}
+	rateLimit, err := models.ParseRateLimit(limit)
+	if err != nil {
+		return "", models.RateLimit{}, err
+	}
+	return strings.ToUpper(method) + " " + path, rateLimit, nil
+}
+
+// ValueSpec returns metric value spec of the default series.
+func (c *Config) ValueSpec() models.ValueSpec {
+	return models.ValueSpec{
+		Type: models.ValueType(c.MetricValueType),
+		Enum: c.MetricValueEnum,
+	}
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const testYAML = `
+log_level: debug
+http_port: 9090
+storage_cap: 500
+retention_sweep_interval: 30s
+metric_value_type: string
+metric_value_enum: [up, down]
+`
+
+const testTOML = `
+log_level = "debug"
+http_port = 9090
+storage_cap = 500
+retention_sweep_interval = "30s"
+metric_value_type = "string"
+metric_value_enum = ["up", "down"]
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// Tests with env are not parallel, as env is shared by the process.
+func TestNewConfig_File(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", testYAML},
+		{"config.toml", testTOML},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Setenv("HTTP_PORT", "8081")
+
+			cfg, err := NewConfig(writeFile(t, tc.name, tc.content))
+			require.NoError(t, err)
+			require.Equal(t, "debug", cfg.LogLevel)
+			// Env overrides file.
+			require.Equal(t, 8081, cfg.HttpPort)
+			require.Equal(t, uint64(500), cfg.StorageCapacity)
+			require.Equal(t, 30*time.Second, cfg.RetentionSweepInterval)
+			require.Equal(t, []string{"up", "down"}, cfg.MetricValueEnum)
+			// Defaults are used for params missing in file.
+			require.Equal(t, "localhost", cfg.StorageHost)
+			require.Equal(t, 30*time.Second, cfg.HttpShutdownTimeout)
+			require.Equal(t, []string{"zstd", "gzip"}, cfg.HttpCompression)
+			require.True(t, cfg.HttpAccessLog)
+			require.Equal(t, "none", cfg.TracingExporter)
+			require.InDelta(t, 1.0, cfg.TracingSampleRatio, 0)
+		})
+	}
+}
+
+func TestNewConfig_Env(t *testing.T) {
+	t.Setenv("STORAGE_CAP", "10")
+
+	cfg, err := NewConfig("")
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), cfg.StorageCapacity)
+	require.Equal(t, "info", cfg.LogLevel)
+	require.Equal(t, 10*time.Second, cfg.WatchInterval())
+}
+
+func TestNewConfig_WatchDisabled(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", "config_watch_interval: 0\n"},
+		{"config.toml", "config_watch_interval = \"0s\"\n"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			cfg, err := NewConfig(writeFile(t, tc.name, tc.content))
+			require.NoError(t, err)
+			require.Zero(t, cfg.WatchInterval())
+		})
+	}
+}
+
+func TestNewConfig_Invalid(t *testing.T) {
+	testCases := []struct {
+		content string
+		errs    []string
+	}{
+		{
+			"log_level: verbose\nretention_sweep_interval: -1s\nconfig_watch_interval: 10\n",
+			[]string{
+				`CONFIG_WATCH_INTERVAL: invalid duration "10"`,
+				`LOG_LEVEL: unknown level "verbose"`,
+				"RETENTION_SWEEP_INTERVAL: must be positive, got -1s",
+			},
+		},
+		{
+			"metrics_path: internal\ntime_zone: Mars/Olympus\nmetric_value_type: decimal\n" +
+				"http_legacy_sunset: 2027-13-01\n",
+			[]string{
+				`HTTP_LEGACY_SUNSET: must be a date in format YYYY-MM-DD, got "2027-13-01"`,
+				`METRICS_PATH: must start with /, got "internal"`,
+				`TIME_ZONE: unknown time zone "Mars/Olympus"`,
+				"METRIC_VALUE_TYPE:",
+			},
+		},
+		{
+			"storage_auth_mode: kerberos\nstorage_tls_cert_file: client.pem\nstorage_read_replica: nearest\n" +
+				"storage_query_max_retries: -1\n",
+			[]string{
+				`STORAGE_AUTH_MODE: unknown mode "kerberos"`,
+				"STORAGE_TLS_CERT_FILE: must be set together with STORAGE_TLS_KEY_FILE",
+				`STORAGE_READ_REPLICA: unknown replica policy "nearest"`,
+				"STORAGE_QUERY_MAX_RETRIES: must not be negative, got -1",
+			},
+		},
+		{
+			"storage_retry_attempts: -1\nstorage_retry_backoff: 2s\nstorage_breaker_threshold: -1\n" +
+				"wal_max_size: -1\nwal_replay_interval: -5s\n",
+			[]string{
+				"STORAGE_RETRY_ATTEMPTS: must be positive, got -1",
+				"STORAGE_RETRY_BACKOFF: must be in range [0, STORAGE_RETRY_MAX_BACKOFF], got 2s",
+				"STORAGE_BREAKER_THRESHOLD: must not be negative, got -1",
+				"WAL_MAX_SIZE: must not be negative, got -1",
+				"WAL_REPLAY_INTERVAL: must be positive, got -5s",
+			},
+		},
+		{
+			"ingest_ack: never\ningest_workers: -1\ningest_batch_wait: -1ms\n",
+			[]string{
+				`INGEST_ACK: unknown mode "never"`,
+				"INGEST_WORKERS: must be positive, got -1",
+				"INGEST_BATCH_WAIT: must not be negative, got -1ms",
+			},
+		},
+		{
+			"stream_buffer_size: -2\nstream_max_subscribers: -1\nstream_max_backfill: -1\n" +
+				"stream_heartbeat_interval: -1s\n",
+			[]string{
+				"STREAM_BUFFER_SIZE: must be positive, got -2",
+				"STREAM_MAX_SUBSCRIBERS: must not be negative, got -1",
+				"STREAM_MAX_BACKFILL: must not be negative, got -1",
+				"STREAM_HEARTBEAT_INTERVAL: must not be negative, got -1s",
+			},
+		},
+		{
+			"alert_evaluation_interval: -1s\nalert_max_rules: -1\nalert_repeat_interval: -1m\n" +
+				"alert_webhook_urls: ['https://example.com/hook', 'example.com/hook', 'ftp://example.com']\n" +
+				"alert_webhook_timeout: -1s\nalert_webhook_queue_size: -1\nalert_webhook_retry_attempts: -1\n" +
+				"alert_webhook_retry_backoff: 1m\n",
+			[]string{
+				"ALERT_EVALUATION_INTERVAL: must be positive, got -1s",
+				"ALERT_MAX_RULES: must not be negative, got -1",
+				"ALERT_REPEAT_INTERVAL: must not be negative, got -1m0s",
+				"ALERT_WEBHOOK_URLS: url 1 must be absolute http or https url",
+				"ALERT_WEBHOOK_URLS: url 2 must be absolute http or https url",
+				"ALERT_WEBHOOK_TIMEOUT: must be positive, got -1s",
+				"ALERT_WEBHOOK_QUEUE_SIZE: must be positive, got -1",
+				"ALERT_WEBHOOK_RETRY_ATTEMPTS: must be positive, got -1",
+				"ALERT_WEBHOOK_RETRY_BACKOFF: must be in range [0, ALERT_WEBHOOK_RETRY_MAX_BACKOFF], got 1m0s",
+			},
+		},
+		{
+			"auth_enabled: true\nauth_clock_skew: -1s\n",
+			[]string{
+				"AUTH_ENABLED: requires AUTH_KEYS_FILE or AUTH_JWKS_FILE",
+				"AUTH_CLOCK_SKEW: must not be negative, got -1s",
+			},
+		},
+		{
+			"http_max_body_size: -1\nhttp_rate_limit: fast\nhttp_rate_limit_routes: ['/metrics=10', 'PUT /metrics=1:x']\n" +
+				"http_auth_rate_limit: -1\n",
+			[]string{
+				"HTTP_MAX_BODY_SIZE: must not be negative, got -1",
+				`HTTP_RATE_LIMIT: invalid argument: rate limit "fast"`,
+				`HTTP_RATE_LIMIT_ROUTES: route limit "/metrics=10" must be in format METHOD /path=rate[:burst]`,
+				`HTTP_RATE_LIMIT_ROUTES: invalid argument: burst of rate limit "1:x"`,
+				`HTTP_AUTH_RATE_LIMIT: invalid argument: rate limit "-1"`,
+			},
+		},
+		{
+			"http_tls_key_file: server.key\nhttp_tls_client_auth: require\nhttp_tls_reload_interval: -1s\n",
+			[]string{
+				"HTTP_TLS_CERT_FILE: must be set together with HTTP_TLS_KEY_FILE",
+				"HTTP_TLS_CLIENT_AUTH: requires HTTP_TLS_CERT_FILE",
+				"HTTP_TLS_CLIENT_CA_FILE: must be set if client certificates are verified",
+				"HTTP_TLS_RELOAD_INTERVAL: must not be negative, got -1s",
+			},
+		},
+		{
+			"http_cors_allowed_origins: ['https://example.com', 'example.com', 'https://example.com/app']\n" +
+				"http_cors_max_age: -1s\nhttp_compression: [zstd, br]\nhttp_compression_min_size: -1\n",
+			[]string{
+				`HTTP_CORS_ALLOWED_ORIGINS: origin "example.com" must be * or scheme://host[:port]`,
+				`HTTP_CORS_ALLOWED_ORIGINS: origin "https://example.com/app" must be * or scheme://host[:port]`,
+				"HTTP_CORS_MAX_AGE: must not be negative, got -1s",
+				`HTTP_COMPRESSION: unknown encoding "br", must be one of zstd, gzip`,
+				"HTTP_COMPRESSION_MIN_SIZE: must not be negative, got -1",
+			},
+		},
+		{
+			"tracing_exporter: jaeger\ntracing_sample_ratio: 1.5\n",
+			[]string{
+				`TRACING_EXPORTER: unknown exporter "jaeger", must be one of none, stdout, otlp`,
+				"TRACING_SAMPLE_RATIO: must be in range [0, 1], got 1.5",
+			},
+		},
+		{
+			"http_tls_client_auth: always\n",
+			[]string{`HTTP_TLS_CLIENT_AUTH: unknown mode "always"`},
+		},
+		{
+			"storage_auth_mode: pki\nstorage_password: secret\n",
+			[]string{"STORAGE_AUTH_MODE: pki requires STORAGE_TLS_ENABLED and client certificate"},
+		},
+		{
+			"http_port: [8080\n",
+			[]string{"failed to load config from file"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			_, err := NewConfig(writeFile(t, "config.yaml", tc.content))
+			require.Error(t, err)
+			for _, msg := range tc.errs {
+				require.ErrorContains(t, err, msg)
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv(EnvConfigFile, "/etc/rrd/env.yaml")
+
+	require.Equal(t, "/etc/rrd/flag.yaml", Path("/etc/rrd/flag.yaml"))
+	require.Equal(t, "/etc/rrd/env.yaml", Path(""))
+}
+
+func TestConfig_Hosts(t *testing.T) {
+	t.Parallel()
+	cfg := Config{StorageHost: "localhost", StoragePort: 3000}
+	require.Equal(t, []string{"localhost:3000"}, cfg.Hosts())
+
+	cfg.StorageHosts = []string{"node1:3000", "node2:as-cluster:4333"}
+	require.Equal(t, []string{"node1:3000", "node2:as-cluster:4333"}, cfg.Hosts())
+}
+
+func TestConfig_RateLimits(t *testing.T) {
+	t.Parallel()
+	cfg := Config{
+		HttpRateLimit:       "10",
+		HttpRateLimitRoutes: []string{"put /metrics=100:200", "GET /series/{name}=0.5"},
+	}
+	require.Equal(t, models.RateLimit{Rate: 10}, cfg.RateLimit())
+	require.Equal(t, map[string]models.RateLimit{
+		"PUT /metrics":       {Rate: 100, Burst: 200},
+		"GET /series/{name}": {Rate: 0.5},
+	}, cfg.RouteRateLimits())
+}
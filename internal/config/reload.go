@@ -0,0 +1,166 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reloader reloads config on SIGHUP and on config file change, and passes new config to subscribers.
+// Invalid config is rejected, so the current config stays in use.
+type Reloader struct {
+	path   string
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	current     *Config
+	modTime     time.Time
+	subscribers []func(cfg *Config)
+}
+
+// NewReloader returns new reloader of config loaded from path, path may be empty if config is loaded from env only.
+func NewReloader(path string, current *Config, logger *slog.Logger) *Reloader {
+	r := &Reloader{
+		path:    path,
+		current: current,
+		logger:  logger,
+	}
+	r.modTime, _ = r.fileModTime()
+	return r
+}
+
+// Subscribe registers function that applies reloadable params of a new config.
+func (r *Reloader) Subscribe(fn func(cfg *Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Current returns config that is currently in use.
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Run reloads config on SIGHUP and checks config file for changes until context is canceled.
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var watch <-chan time.Time
+	if interval := r.Current().WatchInterval(); r.path != "" && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watch = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Info("reloading config on SIGHUP")
+		case <-watch:
+			if !r.fileChanged() {
+				continue
+			}
+			r.logger.Info("reloading config on file change", slog.String("path", r.path))
+		}
+		if err := r.Reload(); err != nil {
+			r.logger.Error("failed to reload config, current config is kept", slog.Any("error", err))
+		}
+	}
+}
+
+// Reload loads config and passes it to subscribers, if it is valid.
+func (r *Reloader) Reload() error {
+	cfg, err := NewConfig(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	reloaded, ignored := diff(r.current, cfg)
+	keepRestartOnly(r.current, cfg)
+	r.current = cfg
+	subscribers := r.subscribers
+	r.mu.Unlock()
+
+	if len(ignored) > 0 {
+		r.logger.Warn("config params can't be changed without restart, they are ignored",
+			slog.Any("params", ignored),
+		)
+	}
+	// Subscribers are called without lock, so they can read current config.
+	for _, fn := range subscribers {
+		fn(cfg)
+	}
+	r.logger.Info("config reloaded", slog.Any("changed", reloaded))
+
+	return nil
+}
+
+func (r *Reloader) fileModTime() (time.Time, error) {
+	if r.path == "" {
+		return time.Time{}, nil
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to stat config file: %w", err)
+	}
+	return info.ModTime(), nil
+}
+
+// fileChanged returns true if config file modification time changed since the last check.
+func (r *Reloader) fileChanged() bool {
+	modTime, err := r.fileModTime()
+	if err != nil {
+		r.logger.Error("failed to check config file", slog.Any("error", err))
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if modTime.Equal(r.modTime) {
+		return false
+	}
+	r.modTime = modTime
+	return true
+}
+
+// diff returns env names of changed reloadable params and changed params that require restart.
+func diff(old, cfg *Config) (reloaded, ignored []string) {
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+		if field.Tag.Get("reload") == "true" {
+			reloaded = append(reloaded, field.Tag.Get("env"))
+		} else {
+			ignored = append(ignored, field.Tag.Get("env"))
+		}
+	}
+	return reloaded, ignored
+}
+
+// keepRestartOnly copies params that require restart from old config, so current config reflects running state.
+func keepRestartOnly(old, cfg *Config) {
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		if oldValue.Type().Field(i).Tag.Get("reload") != "true" {
+			newValue.Field(i).Set(oldValue.Field(i))
+		}
+	}
+}
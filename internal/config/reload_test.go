@@ -0,0 +1,66 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	path := writeFile(t, "config.yaml", "log_level: info\nstorage_cap: 100\nhttp_port: 8080\n")
+	cfg, err := NewConfig(path)
+	require.NoError(t, err)
+
+	reloader := NewReloader(path, cfg, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	var applied []*Config
+	reloader.Subscribe(func(cfg *Config) {
+		applied = append(applied, cfg)
+	})
+
+	// Invalid config is rejected.
+	require.NoError(t, os.WriteFile(path, []byte("log_level: verbose\n"), 0o600))
+	require.Error(t, reloader.Reload())
+	require.Empty(t, applied)
+	require.Equal(t, cfg, reloader.Current())
+
+	// Reloadable params are applied, http port requires restart.
+	require.NoError(t, os.WriteFile(path, []byte("log_level: debug\nstorage_cap: 200\nhttp_port: 9090\n"), 0o600))
+	require.NoError(t, reloader.Reload())
+	require.Len(t, applied, 1)
+	require.Equal(t, "debug", applied[0].LogLevel)
+	require.Equal(t, uint64(200), applied[0].StorageCapacity)
+	require.Equal(t, 8080, applied[0].HttpPort)
+	require.Equal(t, applied[0], reloader.Current())
+}
+
+func TestReloader_Run(t *testing.T) {
+	path := writeFile(t, "config.yaml", "storage_cap: 100\nconfig_watch_interval: 10ms\n")
+	cfg, err := NewConfig(path)
+	require.NoError(t, err)
+
+	reloader := NewReloader(path, cfg, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	applied := make(chan *Config, 1)
+	reloader.Subscribe(func(cfg *Config) {
+		applied <- cfg
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Run(ctx)
+
+	// Modification time is changed explicitly, as file systems may have coarse resolution.
+	require.NoError(t, os.WriteFile(path, []byte("storage_cap: 200\nconfig_watch_interval: 10ms\n"), 0o600))
+	modTime := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+	select {
+	case cfg := <-applied:
+		require.Equal(t, uint64(200), cfg.StorageCapacity)
+	case <-time.After(time.Second):
+		t.Fatal("config was not reloaded on file change")
+	}
+}
@@ -0,0 +1,249 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// ErrInvalidExpression is returned when expression can't be parsed.
+var ErrInvalidExpression = errors.New("invalid expression")
+
+// Limits of an expression.
+const (
+	maxExpressionLength = 4096
+	maxSeries           = 16
+)
+
+type opKind int
+
+const (
+	// opConst pushes a constant.
+	opConst opKind = iota
+	// opSeries pushes values of a series.
+	opSeries
+	// opApply pops arguments of an operator and pushes its result.
+	opApply
+	// opWindow pops values and pushes their sliding average or shifted values.
+	opWindow
+	// opStack changes order of stack values.
+	opStack
+)
+
+// op is an instruction of the RPN program of an expression.
+type op struct {
+	kind   opKind
+	name   string
+	value  float64
+	series string
+	// seconds is a window of TREND and TRENDNAN, or an offset of SHIFT.
+	seconds int64
+}
+
+// operator is applied to values of the same step.
+type operator struct {
+	arity int
+	apply func(args []float64) float64
+}
+
+// operators are RPN operators of rrdtool CDEF, comparisons of unknown or infinite values are unknown.
+var operators = map[string]operator{
+	"+":     {2, func(a []float64) float64 { return a[0] + a[1] }},
+	"-":     {2, func(a []float64) float64 { return a[0] - a[1] }},
+	"*":     {2, func(a []float64) float64 { return a[0] * a[1] }},
+	"/":     {2, func(a []float64) float64 { return a[0] / a[1] }},
+	"%":     {2, func(a []float64) float64 { return math.Mod(a[0], a[1]) }},
+	"LT":    compare(func(a, b float64) bool { return a < b }),
+	"LE":    compare(func(a, b float64) bool { return a <= b }),
+	"GT":    compare(func(a, b float64) bool { return a > b }),
+	"GE":    compare(func(a, b float64) bool { return a >= b }),
+	"EQ":    compare(func(a, b float64) bool { return a == b }),
+	"NE":    compare(func(a, b float64) bool { return a != b }),
+	"UN":    {1, func(a []float64) float64 { return boolValue(math.IsNaN(a[0])) }},
+	"ISINF": {1, func(a []float64) float64 { return boolValue(math.IsInf(a[0], 0)) }},
+	"IF":    {3, ifValue},
+	"MIN":   {2, func(a []float64) float64 { return minMax(a, math.Min) }},
+	"MAX":   {2, func(a []float64) float64 { return minMax(a, math.Max) }},
+	"LIMIT": {3, limit},
+}
+
+// constants are pushed by name.
+var constants = map[string]float64{
+	"UNKN":   math.NaN(),
+	"INF":    math.Inf(1),
+	"NEGINF": math.Inf(-1),
+}
+
+// windows take a constant number of seconds, SHIFT is not in rrdtool CDEF.
+var windows = map[string]bool{
+	"TREND":    true,
+	"TRENDNAN": true,
+	"SHIFT":    true,
+}
+
+// stackOps change order of values on the stack, their arity is a number of used values.
+var stackOps = map[string]int{
+	"DUP": 1,
+	"POP": 1,
+	"EXC": 2,
+}
+
+// Expr is a compiled expression, values of series are arrays of steps and unknown values are NaN.
+type Expr struct {
+	program []op
+	series  []string
+}
+
+// Series returns sorted names of series referenced by the expression.
+func (e *Expr) Series() []string {
+	return e.series
+}
+
+// Lookback returns a number of steps before the first evaluated step that are used by TREND and SHIFT.
+func (e *Expr) Lookback(step int64) int {
+	lookback := 0
+	for _, o := range e.program {
+		if o.kind != opWindow {
+			continue
+		}
+		steps := int(o.seconds / step)
+		if o.name != "SHIFT" {
+			steps = max(steps, 1) - 1
+		}
+		lookback += steps
+	}
+	return lookback
+}
+
+// Eval returns values of n steps of step seconds, values of each referenced series must have n steps.
+// Missing series have unknown values.
+func (e *Expr) Eval(values map[string][]float64, n int, step int64) []float64 {
+	stack := make([][]float64, 0, len(e.program))
+	for _, o := range e.program {
+		switch o.kind {
+		case opConst:
+			stack = append(stack, filled(n, o.value))
+		case opSeries:
+			series, ok := values[o.series]
+			if !ok {
+				series = filled(n, math.NaN())
+			}
+			stack = append(stack, slices.Clone(series))
+		case opApply:
+			operator := operators[o.name]
+			args := stack[len(stack)-operator.arity:]
+			stack = stack[:len(stack)-operator.arity]
+			result := make([]float64, n)
+			scalars := make([]float64, operator.arity)
+			for i := range result {
+				for j, arg := range args {
+					scalars[j] = arg[i]
+				}
+				result[i] = operator.apply(scalars)
+			}
+			stack = append(stack, result)
+		case opWindow:
+			last := len(stack) - 1
+			stack[last] = window(o, stack[last], step)
+		case opStack:
+			last := len(stack) - 1
+			switch o.name {
+			case "DUP":
+				stack = append(stack, slices.Clone(stack[last]))
+			case "POP":
+				stack = stack[:last]
+			case "EXC":
+				stack[last], stack[last-1] = stack[last-1], stack[last]
+			}
+		}
+	}
+	return stack[0]
+}
+
+// window returns sliding average of values over window of TREND, or values shifted by SHIFT offset.
+func window(o op, values []float64, step int64) []float64 {
+	steps := int(o.seconds / step)
+	result := make([]float64, len(values))
+	if o.name == "SHIFT" {
+		for i := range result {
+			result[i] = math.NaN()
+			if i >= steps {
+				result[i] = values[i-steps]
+			}
+		}
+		return result
+	}
+
+	steps = max(steps, 1)
+	for i := range result {
+		sum, count := 0.0, 0
+		for _, v := range values[max(i-steps+1, 0) : i+1] {
+			if math.IsNaN(v) {
+				if o.name == "TREND" {
+					count = 0
+					break
+				}
+				continue
+			}
+			sum += v
+			count++
+		}
+		result[i] = math.NaN()
+		if count > 0 {
+			result[i] = sum / float64(count)
+		}
+	}
+	return result
+}
+
+func compare(cmp func(a, b float64) bool) operator {
+	return operator{2, func(a []float64) float64 {
+		if !isFinite(a[0]) || !isFinite(a[1]) {
+			return math.NaN()
+		}
+		return boolValue(cmp(a[0], a[1]))
+	}}
+}
+
+// ifValue returns the second value if the first is true, unknown is false.
+func ifValue(a []float64) float64 {
+	if a[0] != 0 && !math.IsNaN(a[0]) {
+		return a[1]
+	}
+	return a[2]
+}
+
+// minMax returns unknown if any value is unknown.
+func minMax(a []float64, f func(x, y float64) float64) float64 {
+	if math.IsNaN(a[0]) || math.IsNaN(a[1]) {
+		return math.NaN()
+	}
+	return f(a[0], a[1])
+}
+
+// limit returns the value if it is within bounds, and unknown otherwise.
+func limit(a []float64) float64 {
+	if math.IsNaN(a[0]) || math.IsNaN(a[1]) || math.IsNaN(a[2]) || a[0] < a[1] || a[0] > a[2] {
+		return math.NaN()
+	}
+	return a[0]
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func filled(n int, value float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
@@ -0,0 +1,115 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var nan = math.NaN()
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		expression string
+		series     []string
+		err        error
+	}{
+		{"bytes_in,8,*", []string{"bytes_in"}, nil},
+		{"bytes_in * 8", []string{"bytes_in"}, nil},
+		{"errors,requests,/,100,*", []string{"errors", "requests"}, nil},
+		{"errors / requests * 100", []string{"errors", "requests"}, nil},
+		{`"web-1" + "web-2"`, []string{"web-1", "web-2"}, nil},
+		{"web-1,web-2,+", []string{"web-1", "web-2"}, nil},
+		{"cpu:failures", []string{"cpu:failures"}, nil},
+		{"IF(cpu > 90, cpu, UNKN)", []string{"cpu"}, nil},
+		{"if(UN(cpu), 0, cpu)", []string{"cpu"}, nil},
+		{"cpu,1800,TREND", []string{"cpu"}, nil},
+		{"TREND(cpu, 1800) - SHIFT(cpu, 86400)", []string{"cpu"}, nil},
+		{"cpu,DUP,*", []string{"cpu"}, nil},
+		{"-5 * -cpu", []string{"cpu"}, nil},
+		{"1e3,2,EXC,-", nil, nil},
+		{"", nil, ErrInvalidExpression},
+		{"cpu,*", nil, ErrInvalidExpression},
+		{"cpu,mem", nil, ErrInvalidExpression},
+		{"cpu,,+", nil, ErrInvalidExpression},
+		{"cpu,-1,TREND", nil, ErrInvalidExpression},
+		{"cpu,mem,TREND", nil, ErrInvalidExpression},
+		{"TREND(cpu, mem)", nil, ErrInvalidExpression},
+		{"SHIFT(cpu, 1.5)", nil, ErrInvalidExpression},
+		{"cpu,1,$", nil, ErrInvalidExpression},
+		{"cpu +", nil, ErrInvalidExpression},
+		{"(cpu", nil, ErrInvalidExpression},
+		{"cpu)", nil, ErrInvalidExpression},
+		{"cpu = 1", nil, ErrInvalidExpression},
+		{"LOG(cpu)", nil, ErrInvalidExpression},
+		{"MIN(cpu)", nil, ErrInvalidExpression},
+		{`"web-1`, nil, ErrInvalidExpression},
+		{strings.Repeat("a", maxExpressionLength+1), nil, ErrInvalidExpression},
+		{"a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,+,+,+,+,+,+,+,+,+,+,+,+,+,+,+,+", nil, ErrInvalidExpression},
+	}
+
+	for i, tt := range testCases {
+		e, err := Parse(tt.expression)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+		if err == nil {
+			require.Equal(t, tt.series, e.Series(), fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+func TestExpr_Eval(t *testing.T) {
+	t.Parallel()
+	values := map[string][]float64{
+		"a": {1, 2, nan, 4, 5},
+		"b": {2, 2, 2, 0, math.Inf(1)},
+	}
+	testCases := []struct {
+		expression string
+		result     []float64
+		lookback   int
+	}{
+		{"a,b,+", []float64{3, 4, nan, 4, math.Inf(1)}, 0},
+		{"a + b", []float64{3, 4, nan, 4, math.Inf(1)}, 0},
+		{"a / b * 100", []float64{50, 100, nan, math.Inf(1), 0}, 0},
+		{"a - b - 1", []float64{-2, -1, nan, 3, math.Inf(-1)}, 0},
+		{"a % 2", []float64{1, 0, nan, 0, 1}, 0},
+		{"a,b,LT", []float64{1, 0, nan, 0, nan}, 0},
+		{"a >= b", []float64{0, 1, nan, 1, nan}, 0},
+		{"a == 2", []float64{0, 1, nan, 0, 0}, 0},
+		{"a,b,GT,a,b,IF", []float64{2, 2, 2, 4, math.Inf(1)}, 0},
+		{"IF(UN(a), 0, a)", []float64{1, 2, 0, 4, 5}, 0},
+		{"ISINF(b)", []float64{0, 0, 0, 0, 1}, 0},
+		{"MIN(a, b)", []float64{1, 2, nan, 0, 5}, 0},
+		{"a,b,MAX", []float64{2, 2, nan, 4, math.Inf(1)}, 0},
+		{"LIMIT(a, 2, 4)", []float64{nan, 2, nan, 4, nan}, 0},
+		{"a,UNKN,+", []float64{nan, nan, nan, nan, nan}, 0},
+		{"a,DUP,*", []float64{1, 4, nan, 16, 25}, 0},
+		{"a,b,EXC,-", []float64{1, 0, nan, -4, math.Inf(1)}, 0},
+		{"a,b,POP", []float64{1, 2, nan, 4, 5}, 0},
+		{"unknown + 1", []float64{nan, nan, nan, nan, nan}, 0},
+		{"TREND(a, 120)", []float64{1, 1.5, nan, nan, 4.5}, 1},
+		{"a,120,TRENDNAN", []float64{1, 1.5, 2, 4, 4.5}, 1},
+		{"TREND(a, 30)", []float64{1, 2, nan, 4, 5}, 0},
+		{"SHIFT(a, 120)", []float64{nan, nan, 1, 2, nan}, 2},
+		{"SHIFT(TREND(a, 120), 60)", []float64{nan, 1, 1.5, nan, nan}, 2},
+	}
+
+	for i, tt := range testCases {
+		e, err := Parse(tt.expression)
+		require.NoError(t, err, fmt.Sprintf("case %d", i))
+		require.Equal(t, tt.lookback, e.Lookback(60), fmt.Sprintf("case %d", i))
+		result := e.Eval(values, 5, 60)
+		require.Len(t, result, len(tt.result), fmt.Sprintf("case %d", i))
+		for j, expected := range tt.result {
+			if math.IsNaN(expected) {
+				require.True(t, math.IsNaN(result[j]), fmt.Sprintf("case %d: step %d: %v", i, j, result[j]))
+				continue
+			}
+			require.Equal(t, expected, result[j], fmt.Sprintf("case %d: step %d", i, j))
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// seriesNameRegexp is the same as of series names, names of infix expressions with `-` must be quoted.
+var (
+	seriesNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.:-]*$`)
+	identRegexp      = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.:]*`)
+	numberRegexp     = regexp.MustCompile(`^(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?`)
+)
+
+// comparisons maps infix comparisons to RPN operators.
+var comparisons = map[string]string{
+	"<": "LT", "<=": "LE", ">": "GT", ">=": "GE", "==": "EQ", "!=": "NE",
+}
+
+// infixFunctions are functions of infix expressions with their number of arguments.
+var infixFunctions = map[string]int{
+	"IF": 3, "MIN": 2, "MAX": 2, "LIMIT": 3, "UN": 1, "ISINF": 1, "TREND": 2, "TRENDNAN": 2, "SHIFT": 2,
+}
+
+// Parse compiles an expression in RPN of rrdtool CDEF, e.g. `errors,requests,/,100,*`, or in infix form,
+// e.g. `errors / requests * 100`. Expression is RPN if it has commas and no parentheses.
+func Parse(expression string) (*Expr, error) {
+	if len(expression) > maxExpressionLength {
+		return nil, fmt.Errorf("%w: longer than %d", ErrInvalidExpression, maxExpressionLength)
+	}
+	if strings.TrimSpace(expression) == "" {
+		return nil, fmt.Errorf("%w: empty expression", ErrInvalidExpression)
+	}
+
+	var (
+		b   builder
+		err error
+	)
+	if strings.Contains(expression, ",") && !strings.Contains(expression, "(") {
+		err = b.parseRPN(expression)
+	} else {
+		err = b.parseInfix(expression)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExpression, err)
+	}
+	return b.build()
+}
+
+// builder appends ops to the program and tracks depth of the stack.
+type builder struct {
+	program []op
+	depth   int
+}
+
+func (b *builder) build() (*Expr, error) {
+	if b.depth != 1 {
+		return nil, fmt.Errorf("%w: %d values are left on the stack, must be 1", ErrInvalidExpression, b.depth)
+	}
+	var series []string
+	for _, o := range b.program {
+		if o.kind == opSeries && !slices.Contains(series, o.series) {
+			series = append(series, o.series)
+		}
+	}
+	if len(series) > maxSeries {
+		return nil, fmt.Errorf("%w: references %d series, max is %d", ErrInvalidExpression, len(series), maxSeries)
+	}
+	slices.Sort(series)
+	return &Expr{program: b.program, series: series}, nil
+}
+
+func (b *builder) push(o op) {
+	b.program = append(b.program, o)
+	b.depth++
+}
+
+// apply appends operator that pops arity values and pushes one.
+func (b *builder) apply(name string) error {
+	arity := operators[name].arity
+	if b.depth < arity {
+		return fmt.Errorf("%s needs %d values", name, arity)
+	}
+	b.program = append(b.program, op{kind: opApply, name: name})
+	b.depth -= arity - 1
+	return nil
+}
+
+// window replaces the last constant with window op of its seconds.
+func (b *builder) window(name string) error {
+	if len(b.program) < 2 || b.depth < 2 || b.program[len(b.program)-1].kind != opConst {
+		return fmt.Errorf("%s needs a value and a constant number of seconds", name)
+	}
+	seconds := b.program[len(b.program)-1].value
+	if seconds < 0 || seconds > math.MaxInt32 || seconds != math.Trunc(seconds) {
+		return fmt.Errorf("%s seconds must be a non-negative integer", name)
+	}
+	b.program[len(b.program)-1] = op{kind: opWindow, name: name, seconds: int64(seconds)}
+	b.depth--
+	return nil
+}
+
+func (b *builder) stack(name string) error {
+	arity := stackOps[name]
+	if b.depth < arity {
+		return fmt.Errorf("%s needs %d values", name, arity)
+	}
+	b.program = append(b.program, op{kind: opStack, name: name})
+	switch name {
+	case "DUP":
+		b.depth++
+	case "POP":
+		b.depth--
+	}
+	return nil
+}
+
+func (b *builder) parseRPN(expression string) error {
+	for _, token := range strings.Split(expression, ",") {
+		token = strings.TrimSpace(token)
+		var err error
+		switch _, isOperator := operators[token]; {
+		case token == "":
+			err = fmt.Errorf("empty token")
+		case isNumber(token):
+			var value float64
+			if value, err = strconv.ParseFloat(token, 64); err == nil {
+				b.push(op{kind: opConst, value: value})
+			}
+		case isOperator:
+			err = b.apply(token)
+		case windows[token]:
+			err = b.window(token)
+		case stackOps[token] > 0:
+			err = b.stack(token)
+		default:
+			if value, ok := constants[token]; ok {
+				b.push(op{kind: opConst, value: value})
+			} else if seriesNameRegexp.MatchString(token) {
+				b.push(op{kind: opSeries, series: token})
+			} else {
+				err = fmt.Errorf("unknown token %q", token)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNumber reports whether token is a number, unlike strconv it doesn't accept names like INF and NaN.
+func isNumber(token string) bool {
+	token = strings.TrimLeft(token, "+-")
+	return token != "" && numberRegexp.FindString(token) == token
+}
+
+// infixParser is a recursive descent parser of infix expressions that appends ops in RPN order.
+//
+//	comparison = sum [("<" | "<=" | ">" | ">=" | "==" | "!=") sum]
+//	sum        = product {("+" | "-") product}
+//	product    = unary {("*" | "/" | "%") unary}
+//	unary      = "-" unary | primary
+//	primary    = number | name | '"' name '"' | constant | function "(" args ")" | "(" comparison ")"
+type infixParser struct {
+	*builder
+	input string
+	pos   int
+}
+
+func (b *builder) parseInfix(expression string) error {
+	p := &infixParser{builder: b, input: expression}
+	if err := p.comparison(); err != nil {
+		return err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return fmt.Errorf("unexpected %q at %d", p.input[p.pos:], p.pos)
+	}
+	return nil
+}
+
+func (p *infixParser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// operator consumes and returns the longest of operators at current position.
+func (p *infixParser) operator(candidates ...string) string {
+	p.skipSpace()
+	found := ""
+	for _, candidate := range candidates {
+		if strings.HasPrefix(p.input[p.pos:], candidate) && len(candidate) > len(found) {
+			found = candidate
+		}
+	}
+	p.pos += len(found)
+	return found
+}
+
+func (p *infixParser) expect(token string) error {
+	if p.operator(token) == "" {
+		return fmt.Errorf("expected %q at %d", token, p.pos)
+	}
+	return nil
+}
+
+func (p *infixParser) comparison() error {
+	if err := p.sum(); err != nil {
+		return err
+	}
+	if operator := p.operator("<", "<=", ">", ">=", "==", "!="); operator != "" {
+		if err := p.sum(); err != nil {
+			return err
+		}
+		return p.apply(comparisons[operator])
+	}
+	return nil
+}
+
+func (p *infixParser) sum() error {
+	if err := p.product(); err != nil {
+		return err
+	}
+	for {
+		operator := p.operator("+", "-")
+		if operator == "" {
+			return nil
+		}
+		if err := p.product(); err != nil {
+			return err
+		}
+		if err := p.apply(operator); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *infixParser) product() error {
+	if err := p.unary(); err != nil {
+		return err
+	}
+	for {
+		operator := p.operator("*", "/", "%")
+		if operator == "" {
+			return nil
+		}
+		if err := p.unary(); err != nil {
+			return err
+		}
+		if err := p.apply(operator); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *infixParser) unary() error {
+	if p.operator("-") == "" {
+		return p.primary()
+	}
+	if err := p.unary(); err != nil {
+		return err
+	}
+	// Negative constants are folded, so they can be seconds of windows.
+	if last := &p.program[len(p.program)-1]; last.kind == opConst {
+		last.value = -last.value
+		return nil
+	}
+	p.push(op{kind: opConst, value: -1})
+	return p.apply("*")
+}
+
+func (p *infixParser) primary() error {
+	p.skipSpace()
+	rest := p.input[p.pos:]
+	switch {
+	case rest == "":
+		return fmt.Errorf("unexpected end of expression")
+	case rest[0] == '(':
+		p.pos++
+		if err := p.comparison(); err != nil {
+			return err
+		}
+		return p.expect(")")
+	case rest[0] == '"':
+		end := strings.IndexByte(rest[1:], '"')
+		if end < 0 || !seriesNameRegexp.MatchString(rest[1:end+1]) {
+			return fmt.Errorf("invalid quoted series name at %d", p.pos)
+		}
+		p.push(op{kind: opSeries, series: rest[1 : end+1]})
+		p.pos += end + 2
+		return nil
+	}
+
+	if number := numberRegexp.FindString(rest); number != "" {
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return err
+		}
+		p.push(op{kind: opConst, value: value})
+		p.pos += len(number)
+		return nil
+	}
+
+	name := identRegexp.FindString(rest)
+	if name == "" {
+		return fmt.Errorf("unexpected %q at %d", rest, p.pos)
+	}
+	p.pos += len(name)
+	if p.skipSpace(); p.pos < len(p.input) && p.input[p.pos] == '(' {
+		return p.call(name)
+	}
+	if value, ok := constants[name]; ok {
+		p.push(op{kind: opConst, value: value})
+		return nil
+	}
+	p.push(op{kind: opSeries, series: name})
+	return nil
+}
+
+// call parses arguments of a function and appends the function, names of functions are case-insensitive.
+func (p *infixParser) call(name string) error {
+	name = strings.ToUpper(name)
+	arity, ok := infixFunctions[name]
+	if !ok {
+		return fmt.Errorf("unknown function %s", name)
+	}
+	p.pos++
+	for i := 0; i < arity; i++ {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return err
+			}
+		}
+		if err := p.comparison(); err != nil {
+			return err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return err
+	}
+	if windows[name] {
+		return p.window(name)
+	}
+	return p.apply(name)
+}
@@ -0,0 +1,130 @@
+package forecast
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type historyGetter interface {
+	GetByRange(ctx context.Context, series string, min, max int64) ([]models.Record, error)
+}
+
+// Forecaster keeps models of series in memory, a model is trained on stored points when it is used the first
+// time after start, or after params of its series are changed.
+type Forecaster struct {
+	history historyGetter
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is a model of a series, mu serializes training and updates of the model.
+type entry struct {
+	mu     sync.Mutex
+	params models.HoltWinters
+	step   int64
+	model  *Model
+}
+
+// NewForecaster returns new forecaster that trains models on points of history.
+func NewForecaster(history historyGetter) *Forecaster {
+	return &Forecaster{
+		history: history,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Observe updates model of the series with the record, series must have Holt-Winters params.
+// It returns records of prediction, deviation and failure series, or nothing if record is not used by model.
+func (f *Forecaster) Observe(ctx context.Context, series models.Series, record models.Record) ([]models.Record, error) {
+	value, ok := numeric(record.MetricValue)
+	if !ok {
+		return nil, nil
+	}
+	e := f.entry(series)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := f.train(ctx, e, series, record.Timestamp-1); err != nil {
+		return nil, err
+	}
+
+	point, ok := e.model.Update(record.Timestamp, value)
+	if !ok {
+		return nil, nil
+	}
+	failure := int64(0)
+	if point.Failure {
+		failure = 1
+	}
+	prediction, deviation, failures := models.ForecastSeriesNames(series.Name)
+	return []models.Record{
+		{Series: prediction, Timestamp: point.Timestamp, MetricValue: point.Prediction},
+		{Series: deviation, Timestamp: point.Timestamp, MetricValue: point.Deviation},
+		{Series: failures, Timestamp: point.Timestamp, MetricValue: failure},
+	}, nil
+}
+
+// Forecast returns predictions of the next horizon steps of the series, series must have Holt-Winters params.
+func (f *Forecaster) Forecast(ctx context.Context, series models.Series, horizon int) ([]models.ForecastPoint, error) {
+	e := f.entry(series)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := f.train(ctx, e, series, time.Now().UnixMicro()); err != nil {
+		return nil, err
+	}
+	return e.model.Forecast(horizon), nil
+}
+
+// entry returns entry of the series, entry of changed params is reset.
+func (f *Forecaster) entry(series models.Series) *entry {
+	params := series.HoltWinters.WithDefaults()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.entries[series.Name]
+	if !ok || e.params != params || e.step != series.Step {
+		e = &entry{params: params, step: series.Step}
+		f.entries[series.Name] = e
+	}
+	return e
+}
+
+// train creates model from points stored up to the time, it must be called with lock of the entry.
+func (f *Forecaster) train(ctx context.Context, e *entry, series models.Series, until int64) error {
+	if e.model != nil {
+		return nil
+	}
+	span := models.HoltWintersTrainingSeasons * int64(e.params.SeasonLength) * e.step * int64(time.Second/time.Microsecond)
+	records, err := f.history.GetByRange(ctx, series.Name, max(until-span, 0), until)
+	if err != nil {
+		return fmt.Errorf("failed to get history of series %s: %w", series.Name, err)
+	}
+	slices.SortFunc(records, func(a, b models.Record) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+
+	model := NewModel(e.params, e.step)
+	for _, record := range records {
+		if value, ok := numeric(record.MetricValue); ok {
+			model.Update(record.Timestamp, value)
+		}
+	}
+	e.model = model
+	return nil
+}
+
+func numeric(v any) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case int64:
+		return float64(value), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,133 @@
+package forecast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+var errTest = errors.New("test error")
+
+// historyMock returns stored records of the test pattern in reverse order, like unsorted storage scan.
+type historyMock struct {
+	records []models.Record
+	err     error
+}
+
+func newHistoryMock(seasons int) *historyMock {
+	mock := &historyMock{}
+	for i := 0; i < seasons*len(testPattern); i++ {
+		mock.records = append(mock.records, models.Record{
+			Series:      "cpu",
+			Timestamp:   testTimestamp(i),
+			MetricValue: testPattern[(testStart+i)%len(testPattern)],
+		})
+	}
+	slices.Reverse(mock.records)
+	return mock
+}
+
+func (mock *historyMock) GetByRange(_ context.Context, _ string, min, max int64) ([]models.Record, error) {
+	if mock.err != nil {
+		return nil, fmt.Errorf("failed to get by range: %w", mock.err)
+	}
+	var result []models.Record
+	for _, record := range mock.records {
+		if record.Timestamp >= min && record.Timestamp <= max {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+func testForecastSeries() models.Series {
+	params := testParams()
+	return models.Series{Name: "cpu", Step: testStep, HoltWinters: &params}
+}
+
+func TestForecaster_Observe(t *testing.T) {
+	t.Parallel()
+	forecaster := NewForecaster(newHistoryMock(testSeasons))
+	series := testForecastSeries()
+	steps := testSeasons * len(testPattern)
+
+	testCases := []struct {
+		record  models.Record
+		records int
+		failure int64
+	}{
+		{models.Record{Timestamp: testTimestamp(steps), MetricValue: 20.0}, 3, 0},
+		// Stored points are already observed.
+		{models.Record{Timestamp: testTimestamp(steps - 1), MetricValue: 20.0}, 0, 0},
+		{models.Record{Timestamp: testTimestamp(steps + 1), MetricValue: "up"}, 0, 0},
+		{models.Record{Timestamp: testTimestamp(steps + 1), MetricValue: int64(1000)}, 3, 0},
+	}
+
+	for i, tt := range testCases {
+		records, err := forecaster.Observe(context.Background(), series, tt.record)
+		require.NoError(t, err, fmt.Sprintf("case %d", i))
+		require.Len(t, records, tt.records, fmt.Sprintf("case %d", i))
+		if tt.records == 0 {
+			continue
+		}
+		require.Equal(t, []string{"cpu:hwpredict", "cpu:devpredict", "cpu:failures"},
+			[]string{records[0].Series, records[1].Series, records[2].Series}, fmt.Sprintf("case %d", i))
+		require.Equal(t, tt.record.Timestamp, records[0].Timestamp, fmt.Sprintf("case %d", i))
+		require.Equal(t, tt.failure, records[2].MetricValue, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestForecaster_MaxStep(t *testing.T) {
+	t.Parallel()
+	forecaster := NewForecaster(&historyMock{})
+	params := models.HoltWinters{Alpha: 0.1, Beta: 0.01, Gamma: 0.1, SeasonLength: 10000}.WithDefaults()
+	series := models.Series{
+		Name:        "cpu",
+		DataSource:  models.DataSourceGauge,
+		ValueType:   models.ValueSpec{Type: models.ValueTypeFloat},
+		Step:        models.MaxHoltWintersStep,
+		HoltWinters: &params,
+	}
+	require.NoError(t, series.Validate())
+
+	// Step and training seasons in microseconds don't overflow.
+	timestamp := time.Now().UnixMicro()
+	records, err := forecaster.Observe(context.Background(), series, models.Record{Timestamp: timestamp, MetricValue: 1.0})
+	require.NoError(t, err)
+	require.Empty(t, records)
+	records, err = forecaster.Observe(context.Background(), series, models.Record{
+		Timestamp: timestamp + series.Step*int64(time.Second/time.Microsecond), MetricValue: 2.0,
+	})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+}
+
+func TestForecaster_Forecast(t *testing.T) {
+	t.Parallel()
+	history := newHistoryMock(testSeasons)
+	forecaster := NewForecaster(history)
+	series := testForecastSeries()
+
+	// Model is trained on the last seasons of history.
+	points, err := forecaster.Forecast(context.Background(), series, 4)
+	require.NoError(t, err)
+	require.Len(t, points, 4)
+	for i, point := range points {
+		require.Equal(t, testTimestamp(testSeasons*len(testPattern)+i), point.Timestamp, fmt.Sprintf("case %d", i))
+	}
+
+	// Model of changed params is trained again.
+	history.err = errTest
+	params := series.HoltWinters.WithDefaults()
+	params.SeasonLength = 8
+	series.HoltWinters = &params
+	_, err = forecaster.Forecast(context.Background(), series, 4)
+	require.ErrorIs(t, err, errTest)
+}
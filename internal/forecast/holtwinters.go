@@ -0,0 +1,126 @@
+package forecast
+
+import (
+	"math"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Point is a result of an observed value: its prediction, predicted deviation and failure flag.
+type Point struct {
+	Timestamp  int64
+	Prediction float64
+	Deviation  float64
+	Failure    bool
+}
+
+// Model is an additive Holt-Winters model of a series with regular steps. Values are assigned to steps
+// by timestamp, only the first value of a step is used, and values of older steps are ignored.
+// Missing steps keep seasonal coefficients, and level follows the trend.
+type Model struct {
+	params models.HoltWinters
+	// step is an interval between points in microseconds.
+	step int64
+
+	// observed is a number of observed steps, last is an index of the last observed step.
+	observed int64
+	last     int64
+	level    float64
+	trend    float64
+	// seasonal and deviation are coefficients of steps of the season.
+	seasonal  []float64
+	deviation []float64
+	// violations is a ring of violations of the last failure window points.
+	violations []bool
+}
+
+// NewModel returns empty model of a series with step in seconds, params must be valid.
+func NewModel(params models.HoltWinters, step int64) *Model {
+	return &Model{
+		params:     params,
+		step:       step * int64(time.Second/time.Microsecond),
+		seasonal:   make([]float64, params.SeasonLength),
+		deviation:  make([]float64, params.SeasonLength),
+		violations: make([]bool, params.FailureWindow),
+	}
+}
+
+// Update observes value of the timestamp in unix microseconds. It returns false if value is not used,
+// or if it is the first value, as it only initializes level.
+func (m *Model) Update(timestamp int64, value float64) (Point, bool) {
+	if math.IsNaN(value) || math.IsInf(value, 0) || timestamp <= 0 {
+		return Point{}, false
+	}
+	index := timestamp / m.step
+	if m.observed == 0 {
+		m.level = value
+		m.last = index
+		m.observed = 1
+		return Point{}, false
+	}
+	if index <= m.last {
+		return Point{}, false
+	}
+
+	// Level of missing steps follows the trend.
+	m.level += float64(index-m.last-1) * m.trend
+	season := m.season(index)
+	prediction := m.level + m.trend + m.seasonal[season]
+	deviation := m.deviation[season]
+
+	// Deviations are not known during the first season, so violations are not counted.
+	violation := m.observed >= int64(m.params.SeasonLength) &&
+		math.Abs(value-prediction) > m.params.DeviationScale*deviation
+	m.violations[m.observed%int64(len(m.violations))] = violation
+
+	level := m.params.Alpha*(value-m.seasonal[season]) + (1-m.params.Alpha)*(m.level+m.trend)
+	m.trend = m.params.Beta*(level-m.level) + (1-m.params.Beta)*m.trend
+	m.level = level
+	m.seasonal[season] = m.params.Gamma*(value-level) + (1-m.params.Gamma)*m.seasonal[season]
+	m.deviation[season] = m.params.Gamma*math.Abs(value-prediction) + (1-m.params.Gamma)*deviation
+	m.last = index
+	m.observed++
+
+	return Point{
+		Timestamp:  timestamp,
+		Prediction: prediction,
+		Deviation:  deviation,
+		Failure:    m.failures() >= m.params.FailureThreshold,
+	}, true
+}
+
+// Forecast returns predictions of the next horizon steps after the last observed step.
+func (m *Model) Forecast(horizon int) []models.ForecastPoint {
+	points := make([]models.ForecastPoint, 0, horizon)
+	if m.observed == 0 {
+		return points
+	}
+	for h := 1; h <= horizon; h++ {
+		index := m.last + int64(h)
+		season := m.season(index)
+		value := m.level + float64(h)*m.trend + m.seasonal[season]
+		bound := m.params.DeviationScale * m.deviation[season]
+		points = append(points, models.ForecastPoint{
+			Timestamp: index * m.step,
+			Value:     value,
+			Lower:     value - bound,
+			Upper:     value + bound,
+		})
+	}
+	return points
+}
+
+func (m *Model) season(index int64) int {
+	return int(index % int64(m.params.SeasonLength))
+}
+
+func (m *Model) failures() int {
+	n := 0
+	for _, violation := range m.violations {
+		if violation {
+			n++
+		}
+	}
+	return n
+}
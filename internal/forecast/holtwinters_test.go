@@ -0,0 +1,104 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const (
+	testStep = 60
+	// testStepMicros is the test step in microseconds.
+	testStepMicros = testStep * 1000000
+	// testSeasons is a number of seasons of the test pattern that train the model.
+	testSeasons = 100
+)
+
+// testStart is the first step of the test series, its points end before now.
+var testStart = int(time.Now().Unix()/testStep) - testSeasons*len(testPattern)
+
+var testPattern = []float64{10, 20, 30, 20}
+
+func testParams() models.HoltWinters {
+	return models.HoltWinters{Alpha: 0.1, Beta: 0.01, Gamma: 0.1, SeasonLength: len(testPattern)}.WithDefaults()
+}
+
+// testTimestamp returns timestamp of the step of the test series.
+func testTimestamp(step int) int64 {
+	return int64(testStart+step) * testStepMicros
+}
+
+// trainedModel returns model that observed the test pattern for the number of seasons.
+func trainedModel(seasons int) *Model {
+	model := NewModel(testParams(), testStep)
+	for i := 0; i < seasons*len(testPattern); i++ {
+		model.Update(testTimestamp(i), testPattern[(testStart+i)%len(testPattern)])
+	}
+	return model
+}
+
+func TestModel_Forecast(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, NewModel(testParams(), testStep).Forecast(4))
+
+	model := trainedModel(testSeasons)
+	points := model.Forecast(8)
+	require.Len(t, points, 8)
+	for i, point := range points {
+		step := testSeasons*len(testPattern) + i
+		require.Equal(t, testTimestamp(step), point.Timestamp, fmt.Sprintf("case %d", i))
+		require.InDelta(t, testPattern[(testStart+step)%len(testPattern)], point.Value, 0.5, fmt.Sprintf("case %d", i))
+		require.LessOrEqual(t, point.Lower, point.Value, fmt.Sprintf("case %d", i))
+		require.GreaterOrEqual(t, point.Upper, point.Value, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestModel_Update(t *testing.T) {
+	t.Parallel()
+	model := NewModel(testParams(), testStep)
+	testCases := []struct {
+		timestamp int64
+		value     float64
+		ok        bool
+	}{
+		{testTimestamp(0), math.NaN(), false},
+		{testTimestamp(0), 10, false},
+		{testTimestamp(0) + 1, 20, false},
+		{testTimestamp(1), math.Inf(1), false},
+		{testTimestamp(2), 30, true},
+		{testTimestamp(1), 20, false},
+		{testTimestamp(5), 20, true},
+		{0, 20, false},
+	}
+
+	for i, tt := range testCases {
+		_, ok := model.Update(tt.timestamp, tt.value)
+		require.Equal(t, tt.ok, ok, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestModel_Failure(t *testing.T) {
+	t.Parallel()
+	model := trainedModel(testSeasons)
+	params := testParams()
+
+	// Points that follow the pattern are not failures, and failure needs threshold of violations in the window.
+	steps := testSeasons * len(testPattern)
+	for i := 0; i < params.FailureThreshold; i++ {
+		step := steps + i
+		point, ok := model.Update(testTimestamp(step), testPattern[(testStart+step)%len(testPattern)])
+		require.True(t, ok)
+		require.False(t, point.Failure, fmt.Sprintf("case %d", i))
+	}
+	steps += params.FailureThreshold
+	for i := 0; i < params.FailureThreshold; i++ {
+		point, ok := model.Update(testTimestamp(steps+i), 1000)
+		require.True(t, ok)
+		require.Equal(t, i == params.FailureThreshold-1, point.Failure, fmt.Sprintf("case %d", i))
+	}
+}
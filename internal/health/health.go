@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type storage interface {
+	Check(ctx context.Context) []models.Check
+	ListSeries(ctx context.Context) ([]models.Series, error)
+	GetCounter(ctx context.Context, series string) (int64, error)
+	Capacity(retention models.Retention) uint64
+}
+
+// Service reports service health and status.
+type Service struct {
+	storage   storage
+	version   string
+	startedAt time.Time
+}
+
+// NewService returns new health service, uptime is counted from the call.
+func NewService(storage storage, version string) *Service {
+	return &Service{
+		storage:   storage,
+		version:   version,
+		startedAt: time.Now(),
+	}
+}
+
+// Ready checks dependencies of the service.
+func (s *Service) Ready(ctx context.Context) models.Readiness {
+	return models.NewReadiness(s.storage.Check(ctx))
+}
+
+// Status returns version, uptime, dependency checks and counters with capacity of all series.
+func (s *Service) Status(ctx context.Context) (models.Status, error) {
+	status := models.Status{
+		Version:   s.version,
+		StartedAt: s.startedAt.UnixMicro(),
+		Uptime:    int64(time.Since(s.startedAt).Seconds()),
+		Readiness: s.Ready(ctx),
+		Series:    make([]models.SeriesStatus, 0),
+	}
+	if status.Readiness.Status != models.CheckStatusOK {
+		return status, nil
+	}
+
+	series, err := s.storage.ListSeries(ctx)
+	if err != nil {
+		return models.Status{}, fmt.Errorf("failed to list series: %w", err)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Name < series[j].Name
+	})
+	for _, one := range series {
+		points, err := s.storage.GetCounter(ctx, one.Name)
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			return models.Status{}, fmt.Errorf("failed to get counter of series %s: %w", one.Name, err)
+		}
+		status.Series = append(status.Series, models.SeriesStatus{
+			Name:     one.Name,
+			Points:   uint64(points),
+			Capacity: s.storage.Capacity(one.Retention),
+		})
+	}
+
+	return status, nil
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+var errTest = errors.New("test error")
+
+type storageMock struct {
+	checks  []models.Check
+	listErr error
+}
+
+func (mock storageMock) Check(_ context.Context) []models.Check {
+	return mock.checks
+}
+
+func (mock storageMock) ListSeries(_ context.Context) ([]models.Series, error) {
+	if mock.listErr != nil {
+		return nil, mock.listErr
+	}
+	return []models.Series{
+		{Name: "memory", Retention: models.Retention{MaxPoints: 10}},
+		{Name: "cpu"},
+	}, nil
+}
+
+func (mock storageMock) GetCounter(_ context.Context, series string) (int64, error) {
+	if series == "cpu" {
+		return 0, fmt.Errorf("failed to get counter: %w", models.ErrNotFound)
+	}
+	return 5, nil
+}
+
+func (mock storageMock) Capacity(retention models.Retention) uint64 {
+	if retention.MaxPoints > 0 {
+		return retention.MaxPoints
+	}
+	return 1000
+}
+
+func TestService_Status(t *testing.T) {
+	t.Parallel()
+	checkOK := models.Check{Name: "cluster", Status: models.CheckStatusOK}
+	checkFailed := models.Check{Name: "index", Status: models.CheckStatusFailed, Error: "not found"}
+
+	testCases := []struct {
+		storage storageMock
+		series  []models.SeriesStatus
+		status  models.CheckStatus
+		err     error
+	}{
+		{
+			storageMock{checks: []models.Check{checkOK}},
+			[]models.SeriesStatus{
+				{Name: "cpu", Points: 0, Capacity: 1000},
+				{Name: "memory", Points: 5, Capacity: 10},
+			},
+			models.CheckStatusOK,
+			nil,
+		},
+		// Series are not listed when storage is not ready.
+		{
+			storageMock{checks: []models.Check{checkOK, checkFailed}},
+			[]models.SeriesStatus{},
+			models.CheckStatusFailed,
+			nil,
+		},
+		{
+			storageMock{checks: []models.Check{checkOK}, listErr: errTest},
+			nil,
+			"",
+			errTest,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			service := NewService(tc.storage, "v1.0.0")
+			service.startedAt = time.Now().Add(-time.Minute)
+
+			status, err := service.Status(context.Background())
+			require.ErrorIs(t, err, tc.err)
+			if tc.err != nil {
+				return
+			}
+			require.Equal(t, "v1.0.0", status.Version)
+			require.Equal(t, int64(60), status.Uptime)
+			require.Equal(t, tc.status, status.Status)
+			require.Equal(t, tc.series, status.Series)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package httpsrv
+
+import (
+	"log/slog"
+	"net/http"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
+)
+
+// HeaderTenant allows admins to act on behalf of another tenant.
+const HeaderTenant = "X-Tenant"
+
+// authChallenge lists supported authentication schemes in 401 responses.
+const authChallenge = `Bearer realm="rrd", ApiKey realm="rrd", HMAC realm="rrd"`
+
+// Authenticator returns identity of the request. It returns models.ErrUnauthenticated if credentials
+// are missing or invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (models.Identity, error)
+}
+
+// authorize returns middleware that authenticates requests and checks that identity has the scope.
+// Identity is added to request context, so services can isolate tenants. Failed authentication is charged
+// to the remote ip on limiter, and requests of remote ips that exceeded the limit are rejected before
+// authentication, so clients sharing an ip are limited only by their own limits.
+func authorize(
+	authenticator Authenticator, scope models.Scope, limiter *Limiter, logger *slog.Logger,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if authenticator == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := clientKey(r)
+			if limiter != nil {
+				if allowed, wait := limiter.available(routeAuthentication, client); !allowed {
+					writeRateLimited(w, r, logger, routeAuthentication, client, wait)
+					return
+				}
+			}
+			identity, err := authenticator.Authenticate(r)
+			if err != nil {
+				if limiter != nil {
+					limiter.allow(routeAuthentication, client)
+				}
+				w.Header().Set("WWW-Authenticate", authChallenge)
+				handlers.WriteError(w, r, logger, "failed to authenticate", err)
+				return
+			}
+			attrs := []any{slog.String("subject", identity.Subject), slog.String("tenant", identity.Tenant)}
+
+			if !identity.HasScope(scope) {
+				handlers.WriteError(w, r, logger, "access denied",
+					models.NewDetailError(models.ErrForbidden, "%s scope is required", scope), attrs...)
+				return
+			}
+			if tenant := r.Header.Get(HeaderTenant); tenant != "" && tenant != identity.Tenant {
+				if !identity.HasScope(models.ScopeAdmin) {
+					handlers.WriteError(w, r, logger, "access denied",
+						models.NewDetailError(models.ErrForbidden, "admin scope is required to access other tenants"),
+						attrs...)
+					return
+				}
+				if err = models.ValidateTenant(tenant); err != nil {
+					handlers.WriteError(w, r, logger, "invalid tenant", err, attrs...)
+					return
+				}
+				identity.Tenant = tenant
+			}
+
+			next.ServeHTTP(w, r.WithContext(models.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
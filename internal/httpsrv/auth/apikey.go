@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// HeaderAPIKey contains static api key, it can also be sent as "Authorization: ApiKey <key>".
+const HeaderAPIKey = "X-API-Key"
+
+const schemeAPIKey = "ApiKey"
+
+// APIKeys authenticates requests with static keys.
+type APIKeys struct {
+	// identities are indexed by hex encoded sha256 hash of the key.
+	identities map[string]models.Identity
+}
+
+// NewAPIKeys returns api keys authenticator.
+func NewAPIKeys(keys []APIKey) (*APIKeys, error) {
+	identities := make(map[string]models.Identity, len(keys))
+	for i, key := range keys {
+		hash := strings.ToLower(key.SHA256)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("api key %d: sha256 must be hex encoded sha256 hash", i)
+		}
+		if _, ok := identities[hash]; ok {
+			return nil, fmt.Errorf("api key %d: duplicated key", i)
+		}
+		id, err := identity(key.Subject, key.Tenant, key.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("api key %d: %w", i, err)
+		}
+		identities[hash] = id
+	}
+	return &APIKeys{
+		identities: identities,
+	}, nil
+}
+
+// Authenticate returns identity of the api key.
+func (a *APIKeys) Authenticate(r *http.Request) (models.Identity, error) {
+	key := r.Header.Get(HeaderAPIKey)
+	if key == "" {
+		var ok bool
+		if key, ok = authorization(r, schemeAPIKey); !ok {
+			return models.Identity{}, ErrNoCredentials
+		}
+	}
+
+	// Hash lookup doesn't leak the key through timing, as hashes are compared instead of keys.
+	hash := sha256.Sum256([]byte(key))
+	id, ok := a.identities[hex.EncodeToString(hash[:])]
+	if !ok {
+		return models.Identity{}, unauthenticated("invalid api key")
+	}
+	return id, nil
+}
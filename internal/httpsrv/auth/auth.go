@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// ErrNoCredentials means that request has no credentials of the authenticator kind, so next one is tried.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator returns identity of the request, it returns ErrNoCredentials if request has no credentials
+// of its kind.
+type Authenticator interface {
+	Authenticate(r *http.Request) (models.Identity, error)
+}
+
+// Chain tries authenticators in order until one of them finds credentials in the request.
+type Chain struct {
+	authenticators []Authenticator
+}
+
+// NewChain returns new chain of authenticators.
+func NewChain(authenticators ...Authenticator) *Chain {
+	return &Chain{
+		authenticators: authenticators,
+	}
+}
+
+// Authenticate returns identity of the first authenticator that found credentials.
+// Invalid credentials are not passed to other authenticators.
+func (c *Chain) Authenticate(r *http.Request) (models.Identity, error) {
+	for _, one := range c.authenticators {
+		identity, err := one.Authenticate(r)
+		switch {
+		case err == nil:
+			return identity, nil
+		case errors.Is(err, ErrNoCredentials):
+			continue
+		default:
+			return models.Identity{}, err
+		}
+	}
+	return models.Identity{}, models.NewDetailError(models.ErrUnauthenticated, "credentials are required")
+}
+
+// authorization returns credentials of the scheme from Authorization header.
+func authorization(r *http.Request, scheme string) (string, bool) {
+	header := r.Header.Get("Authorization")
+	prefix, credentials, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(prefix, scheme) {
+		return "", false
+	}
+	return strings.TrimSpace(credentials), true
+}
+
+// unauthenticated returns error of invalid credentials.
+func unauthenticated(format string, args ...any) error {
+	return models.NewDetailError(models.ErrUnauthenticated, "%s", fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func keyHash(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+	apiKeys, err := NewAPIKeys([]APIKey{
+		{SHA256: keyHash("secret-key"), Subject: "collector", Tenant: "acme", Scopes: []models.Scope{models.ScopeWrite}},
+	})
+	require.NoError(t, err)
+	hmacKeys, err := NewHMAC(nil, 0)
+	require.NoError(t, err)
+	chain := NewChain(hmacKeys, apiKeys)
+
+	testCases := []struct {
+		header string
+		value  string
+		err    error
+	}{
+		{HeaderAPIKey, "secret-key", nil},
+		{"Authorization", "ApiKey secret-key", nil},
+		{HeaderAPIKey, "wrong-key", models.ErrUnauthenticated},
+		// Invalid credentials of the first authenticator are not passed to the next one.
+		{"Authorization", "HMAC unknown:00", models.ErrUnauthenticated},
+		{"", "", models.ErrUnauthenticated},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(http.MethodPut, "/metrics", nil)
+			if tc.header != "" {
+				r.Header.Set(tc.header, tc.value)
+			}
+			identity, err := chain.Authenticate(r)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, models.Identity{
+				Subject: "collector", Tenant: "acme", Scopes: []models.Scope{models.ScopeWrite},
+			}, identity)
+		})
+	}
+}
+
+func TestNewAPIKeys_Invalid(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		keys []APIKey
+		err  string
+	}{
+		{[]APIKey{{SHA256: "secret-key", Subject: "a"}}, "must be hex encoded sha256 hash"},
+		{[]APIKey{{SHA256: keyHash("a"), Subject: "a"}, {SHA256: keyHash("a"), Subject: "b"}}, "duplicated key"},
+		{[]APIKey{{SHA256: keyHash("a"), Subject: "a", Tenant: "Acme"}}, "tenant"},
+		{[]APIKey{{SHA256: keyHash("a"), Subject: "a", Scopes: []models.Scope{"delete"}}}, "unknown scope"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			_, err := NewAPIKeys(tc.keys)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	t.Parallel()
+	path := writeFile(t, "keys.yaml", `
+api_keys:
+  - sha256: `+keyHash("secret-key")+`
+    subject: collector
+    tenant: acme
+    scopes: [write]
+client_certs:
+  - cn: exporter
+    tenant: acme
+    scopes: [read]
+hmac_keys:
+  - id: agent
+    secret: 0123456789abcdef0123456789abcdef
+    subject: agent
+    scopes: [read, write]
+`)
+
+	keys, err := LoadKeys(path)
+	require.NoError(t, err)
+	require.Len(t, keys.APIKeys, 1)
+	require.Equal(t, []models.Scope{models.ScopeWrite}, keys.APIKeys[0].Scopes)
+	require.Len(t, keys.HMACKeys, 1)
+	require.Equal(t, "agent", keys.HMACKeys[0].ID)
+	require.Equal(t, []ClientCert{
+		{CommonName: "exporter", Tenant: "acme", Scopes: []models.Scope{models.ScopeRead}},
+	}, keys.ClientCerts)
+
+	_, err = LoadKeys(writeFile(t, "keys.yaml", "api_keys: {"))
+	require.ErrorContains(t, err, "failed to parse keys file")
+}
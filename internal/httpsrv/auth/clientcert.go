@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// ClientCerts authenticates requests with client certificates verified by mutual TLS.
+type ClientCerts struct {
+	// identities are indexed by common name of the certificate.
+	identities map[string]models.Identity
+}
+
+// NewClientCerts returns client certificates authenticator.
+func NewClientCerts(certs []ClientCert) (*ClientCerts, error) {
+	identities := make(map[string]models.Identity, len(certs))
+	for i, cert := range certs {
+		if cert.CommonName == "" {
+			return nil, fmt.Errorf("client cert %d: cn must not be empty", i)
+		}
+		if _, ok := identities[cert.CommonName]; ok {
+			return nil, fmt.Errorf("client cert %d: duplicated cn", i)
+		}
+		id, err := identity(cert.CommonName, cert.Tenant, cert.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("client cert %d: %w", i, err)
+		}
+		identities[cert.CommonName] = id
+	}
+	return &ClientCerts{
+		identities: identities,
+	}, nil
+}
+
+// Authenticate returns identity of the common name of verified client certificate.
+// Certificates without identity are not credentials, so such clients can use other ones.
+func (a *ClientCerts) Authenticate(r *http.Request) (models.Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return models.Identity{}, ErrNoCredentials
+	}
+	id, ok := a.identities[r.TLS.VerifiedChains[0][0].Subject.CommonName]
+	if !ok {
+		return models.Identity{}, ErrNoCredentials
+	}
+	return id, nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func TestClientCerts(t *testing.T) {
+	t.Parallel()
+	clientCerts, err := NewClientCerts([]ClientCert{
+		{CommonName: "exporter", Tenant: "acme", Scopes: []models.Scope{models.ScopeRead}},
+	})
+	require.NoError(t, err)
+
+	verified := func(cn string) *tls.ConnectionState {
+		return &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}},
+		}
+	}
+	testCases := []struct {
+		state *tls.ConnectionState
+		err   error
+	}{
+		{verified("exporter"), nil},
+		// Certificate without identity allows to use other credentials.
+		{verified("unknown"), ErrNoCredentials},
+		// Certificates are not sent or not verified.
+		{&tls.ConnectionState{}, ErrNoCredentials},
+		{nil, ErrNoCredentials},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			r.TLS = tc.state
+			identity, err := clientCerts.Authenticate(r)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, models.Identity{
+				Subject: "exporter", Tenant: "acme", Scopes: []models.Scope{models.ScopeRead},
+			}, identity)
+		})
+	}
+}
+
+func TestNewClientCerts_Invalid(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		certs []ClientCert
+		err   string
+	}{
+		{[]ClientCert{{Tenant: "acme"}}, "cn must not be empty"},
+		{[]ClientCert{{CommonName: "a"}, {CommonName: "a"}}, "duplicated cn"},
+		{[]ClientCert{{CommonName: "a", Scopes: []models.Scope{"delete"}}}, "unknown scope"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			_, err := NewClientCerts(tc.certs)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// HeaderTimestamp contains unix time in seconds when HMAC signed request was made.
+const HeaderTimestamp = "X-Auth-Timestamp"
+
+const schemeHMAC = "HMAC"
+
+type hmacKey struct {
+	secret   []byte
+	identity models.Identity
+}
+
+// HMAC authenticates requests signed with shared secret.
+// Header is "Authorization: HMAC <key id>:<hex signature>", signature is HMAC-SHA256 of StringToSign.
+type HMAC struct {
+	keys map[string]hmacKey
+	// maxSkew limits difference between request timestamp and server time, it limits replay of captured requests.
+	maxSkew time.Duration
+	now     func() time.Time
+}
+
+// NewHMAC returns HMAC authenticator.
+func NewHMAC(keys []HMACKey, maxSkew time.Duration) (*HMAC, error) {
+	byID := make(map[string]hmacKey, len(keys))
+	for i, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, fmt.Errorf("hmac key %d: id must be set and must not contain ':'", i)
+		}
+		if len(key.Secret) < sha256.Size {
+			return nil, fmt.Errorf("hmac key %s: secret must be at least %d bytes", key.ID, sha256.Size)
+		}
+		if _, ok := byID[key.ID]; ok {
+			return nil, fmt.Errorf("hmac key %s: duplicated id", key.ID)
+		}
+		id, err := identity(key.Subject, key.Tenant, key.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("hmac key %s: %w", key.ID, err)
+		}
+		byID[key.ID] = hmacKey{secret: []byte(key.Secret), identity: id}
+	}
+	return &HMAC{
+		keys:    byID,
+		maxSkew: maxSkew,
+		now:     time.Now,
+	}, nil
+}
+
+// Authenticate verifies signature of the request and returns identity of the key.
+func (a *HMAC) Authenticate(r *http.Request) (models.Identity, error) {
+	credentials, ok := authorization(r, schemeHMAC)
+	if !ok {
+		return models.Identity{}, ErrNoCredentials
+	}
+	keyID, signature, ok := strings.Cut(credentials, ":")
+	if !ok {
+		return models.Identity{}, unauthenticated("hmac credentials must be <key id>:<signature>")
+	}
+	key, ok := a.keys[keyID]
+	if !ok {
+		return models.Identity{}, unauthenticated("unknown hmac key %q", keyID)
+	}
+	mac, err := hex.DecodeString(signature)
+	if err != nil {
+		return models.Identity{}, unauthenticated("hmac signature must be hex encoded")
+	}
+
+	timestamp := r.Header.Get(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return models.Identity{}, unauthenticated("%s header must be unix time in seconds", HeaderTimestamp)
+	}
+	if skew := a.now().Sub(time.Unix(seconds, 0)).Abs(); skew > a.maxSkew {
+		return models.Identity{}, unauthenticated("request timestamp differs from server time by %s", skew)
+	}
+
+	// Body is read to verify its hash and then restored for handlers.
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(r.Body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return models.Identity{}, models.NewDetailError(models.ErrTooLarge, "body exceeds %d bytes",
+					tooLarge.Limit)
+			}
+			return models.Identity{}, fmt.Errorf("%w: %w",
+				models.NewDetailError(models.ErrInvalidArgument, "failed to read body"), err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := Sign(key.secret, StringToSign(r.Method, r.URL.RequestURI(), timestamp, body))
+	if !hmac.Equal(mac, expected) {
+		return models.Identity{}, unauthenticated("invalid hmac signature")
+	}
+	return key.identity, nil
+}
+
+// StringToSign returns canonical request, it is method, request uri, timestamp and
+// hex encoded sha256 hash of the body separated by new lines.
+func StringToSign(method, requestURI, timestamp string, body []byte) string {
+	hash := sha256.Sum256(body)
+	return strings.Join([]string{method, requestURI, timestamp, hex.EncodeToString(hash[:])}, "\n")
+}
+
+// Sign returns HMAC-SHA256 of the string.
+func Sign(secret []byte, stringToSign string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(stringToSign))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func signedRequest(method, target, body, keyID, secret string, timestamp time.Time) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	signature := Sign([]byte(secret), StringToSign(method, r.URL.RequestURI(), ts, []byte(body)))
+	r.Header.Set("Authorization", "HMAC "+keyID+":"+hex.EncodeToString(signature))
+	r.Header.Set(HeaderTimestamp, ts)
+	return r
+}
+
+func TestHMAC_Authenticate(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1700000000, 0)
+	body := `{"timestamp":1,"metric_value":1.5}`
+
+	tampered := signedRequest(http.MethodPut, "/metrics", body, "agent", testSecret, now)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"timestamp":1,"metric_value":100}`))
+	otherPath := signedRequest(http.MethodGet, "/metrics?series=cpu", "", "agent", testSecret, now)
+	otherPath.URL.RawQuery = "series=mem"
+
+	testCases := []struct {
+		request *http.Request
+		err     error
+	}{
+		{signedRequest(http.MethodPut, "/metrics", body, "agent", testSecret, now), nil},
+		{signedRequest(http.MethodGet, "/metrics?series=cpu", "", "agent", testSecret, now.Add(-time.Minute)), nil},
+		{tampered, models.ErrUnauthenticated},
+		{otherPath, models.ErrUnauthenticated},
+		{signedRequest(http.MethodPut, "/metrics", body, "agent", testSecret, now.Add(-10*time.Minute)),
+			models.ErrUnauthenticated},
+		{signedRequest(http.MethodPut, "/metrics", body, "agent", strings.Repeat("x", 32), now),
+			models.ErrUnauthenticated},
+		{signedRequest(http.MethodPut, "/metrics", body, "unknown", testSecret, now), models.ErrUnauthenticated},
+		{httptest.NewRequest(http.MethodPut, "/metrics", nil), ErrNoCredentials},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			a, err := NewHMAC([]HMACKey{
+				{ID: "agent", Secret: testSecret, Subject: "agent", Tenant: "acme", Scopes: []models.Scope{models.ScopeWrite}},
+			}, 5*time.Minute)
+			require.NoError(t, err)
+			a.now = func() time.Time { return now }
+
+			identity, err := a.Authenticate(tc.request)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "acme", identity.Tenant)
+
+			// Body is still readable by handlers.
+			restored, err := io.ReadAll(tc.request.Body)
+			require.NoError(t, err)
+			if tc.request.Method == http.MethodPut {
+				require.Equal(t, body, string(restored))
+			}
+		})
+	}
+}
+
+func TestNewHMAC_Invalid(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		keys []HMACKey
+		err  string
+	}{
+		{[]HMACKey{{ID: "a:b", Secret: testSecret, Subject: "a"}}, "must not contain ':'"},
+		{[]HMACKey{{ID: "a", Secret: "short", Subject: "a"}}, "secret must be at least 32 bytes"},
+		{[]HMACKey{{ID: "a", Secret: testSecret, Subject: "a"}, {ID: "a", Secret: testSecret, Subject: "b"}},
+			"duplicated id"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			_, err := NewHMAC(tc.keys, time.Minute)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256" // Hash functions are registered for crypto.Hash.
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const schemeBearer = "Bearer"
+
+// JWTOptions configures validation of bearer tokens.
+type JWTOptions struct {
+	// JWKSPath is a path of JSON Web Key Set file with public keys, it is reloaded on unknown key id.
+	JWKSPath string
+	// Issuer and Audience are checked if set.
+	Issuer   string
+	Audience string
+	// TenantClaim is a claim with tenant of the subject.
+	TenantClaim string
+	// Leeway is an allowed clock skew for exp and nbf claims.
+	Leeway time.Duration
+}
+
+// JWT authenticates requests with bearer tokens signed by keys from local JWKS file.
+type JWT struct {
+	opts JWTOptions
+	now  func() time.Time
+
+	mu      sync.RWMutex
+	keys    map[string]verificationKey
+	modTime time.Time
+}
+
+// NewJWT returns JWT authenticator, it fails if JWKS file can't be loaded.
+func NewJWT(opts JWTOptions) (*JWT, error) {
+	a := &JWT{
+		opts: opts,
+		now:  time.Now,
+	}
+	if err := a.loadKeys(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// claims are registered claims used for authentication, scope is a space separated string or scp array.
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	Scope     string   `json:"scope"`
+	Scp       []string `json:"scp"`
+}
+
+// audience is a single string or array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = many
+	return nil
+}
+
+// Authenticate validates bearer token and returns identity of its subject.
+func (a *JWT) Authenticate(r *http.Request) (models.Identity, error) {
+	token, ok := authorization(r, schemeBearer)
+	if !ok {
+		return models.Identity{}, ErrNoCredentials
+	}
+
+	payload, err := a.verify(token)
+	if err != nil {
+		return models.Identity{}, unauthenticated("invalid token: %s", err)
+	}
+
+	var c claims
+	if err = json.Unmarshal(payload, &c); err != nil {
+		return models.Identity{}, unauthenticated("invalid token claims: %s", err)
+	}
+	if err = a.validate(&c); err != nil {
+		return models.Identity{}, unauthenticated("invalid token: %s", err)
+	}
+
+	var custom map[string]any
+	if err = json.Unmarshal(payload, &custom); err != nil {
+		return models.Identity{}, unauthenticated("invalid token claims: %s", err)
+	}
+	tenant, _ := custom[a.opts.TenantClaim].(string)
+	if tenant == "" {
+		return models.Identity{}, unauthenticated("token has no %s claim", a.opts.TenantClaim)
+	}
+
+	scopes := c.Scp
+	if c.Scope != "" {
+		scopes = strings.Fields(c.Scope)
+	}
+	id := models.Identity{
+		Subject: c.Subject,
+		Tenant:  tenant,
+	}
+	// Scopes of other services may be in the same token, they are ignored.
+	for _, scope := range scopes {
+		switch models.Scope(scope) {
+		case models.ScopeRead, models.ScopeWrite, models.ScopeAdmin:
+			id.Scopes = append(id.Scopes, models.Scope(scope))
+		}
+	}
+	if err = id.Validate(); err != nil {
+		return models.Identity{}, unauthenticated("invalid token: %s", err)
+	}
+	return id, nil
+}
+
+// validate checks registered claims.
+func (a *JWT) validate(c *claims) error {
+	now := a.now()
+	if c.ExpiresAt == nil {
+		return errors.New("exp claim is required")
+	}
+	if now.After(unixTime(*c.ExpiresAt).Add(a.opts.Leeway)) {
+		return errors.New("token is expired")
+	}
+	if c.NotBefore != nil && now.Add(a.opts.Leeway).Before(unixTime(*c.NotBefore)) {
+		return errors.New("token is not valid yet")
+	}
+	if a.opts.Issuer != "" && c.Issuer != a.opts.Issuer {
+		return fmt.Errorf("unexpected issuer %q", c.Issuer)
+	}
+	if a.opts.Audience != "" && !contains(c.Audience, a.opts.Audience) {
+		return errors.New("token is not issued for this service")
+	}
+	return nil
+}
+
+// verify checks token signature and returns decoded payload.
+func (a *JWT) verify(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token must have 3 parts")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("invalid header encoding")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err = json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("invalid header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid signature encoding")
+	}
+
+	key, err := a.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("key %q is not allowed for %s", header.Kid, header.Alg)
+	}
+	if err = verifySignature(header.Alg, key.key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid payload encoding")
+	}
+	return payload, nil
+}
+
+// key returns verification key by id, the only key is used if token has no key id.
+// JWKS file is reloaded if it was changed, so keys can be rotated without restart.
+func (a *JWT) key(kid string) (verificationKey, error) {
+	if key, ok := a.findKey(kid); ok {
+		return key, nil
+	}
+	if err := a.loadKeys(); err != nil {
+		return verificationKey{}, err
+	}
+	if key, ok := a.findKey(kid); ok {
+		return key, nil
+	}
+	return verificationKey{}, fmt.Errorf("unknown key %q", kid)
+}
+
+func (a *JWT) findKey(kid string) (verificationKey, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	key, ok := a.keys[kid]
+	return key, ok
+}
+
+// loadKeys reads JWKS file if it was modified since last load.
+func (a *JWT) loadKeys() error {
+	info, err := os.Stat(a.opts.JWKSPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat jwks file: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.keys != nil && info.ModTime().Equal(a.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(a.opts.JWKSPath)
+	if err != nil {
+		return fmt.Errorf("failed to read jwks file: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse jwks file %s: %w", a.opts.JWKSPath, err)
+	}
+	a.keys = keys
+	a.modTime = info.ModTime()
+	return nil
+}
+
+// verifySignature checks signature of the signing input, only asymmetric algorithms are supported,
+// so the service never has secrets of the token issuer.
+func verifySignature(alg string, key crypto.PublicKey, input string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(edKey, []byte(input), signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(input))
+	digest := hasher.Sum(nil)
+
+	var valid bool
+	switch alg[:2] {
+	case "RS", "PS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type doesn't match %s", alg)
+		}
+		if alg[0] == 'R' {
+			valid = rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature) == nil
+		} else {
+			valid = rsa.VerifyPSS(rsaKey, hash, digest, signature, nil) == nil
+		}
+	default:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve.Params().BitSize != ecCurveBits[alg] {
+			return fmt.Errorf("key type doesn't match %s", alg)
+		}
+		// Signature is r and s of curve size each.
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(ecKey, digest, r, s)
+		}
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// ecCurveBits are curve sizes of ECDSA algorithms.
+var ecCurveBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+func contains(values []string, value string) bool {
+	for _, one := range values {
+		if one == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+	ed  ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return testKeys{rsa: rsaKey, ec: ecKey, ed: edKey}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (k testKeys) jwks() string {
+	set := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig",
+			"n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": b64(k.ec.X.FillBytes(make([]byte, 32))), "y": b64(k.ec.Y.FillBytes(make([]byte, 32))),
+		},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(k.ed.Public().(ed25519.PublicKey))},
+		// Encryption keys are skipped.
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}}
+	data, _ := json.Marshal(set)
+	return string(data)
+}
+
+// sign returns token signed with the key of the algorithm.
+func (k testKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "PS256":
+		signature, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA256, digest[:], nil)
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "EdDSA":
+		signature = ed25519.Sign(k.ed, []byte(input))
+	default:
+		signature = []byte("signature")
+	}
+	return input + "." + b64(signature)
+}
+
+func TestJWT_Authenticate(t *testing.T) {
+	t.Parallel()
+	keys := newTestKeys(t)
+	now := time.Unix(1700000000, 0)
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub":    "dashboard",
+			"iss":    "https://idp.example.com",
+			"aud":    []string{"rrd", "other"},
+			"exp":    now.Add(time.Hour).Unix(),
+			"nbf":    now.Add(-time.Hour).Unix(),
+			"scope":  "read openid",
+			"tenant": "acme",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	testCases := []struct {
+		token  string
+		scopes []models.Scope
+		err    string
+	}{
+		{keys.sign(t, "RS256", "rsa", claims(nil)), []models.Scope{models.ScopeRead}, ""},
+		{keys.sign(t, "ES256", "ec", claims(map[string]any{"scope": nil, "scp": []string{"read", "write"}})),
+			[]models.Scope{models.ScopeRead, models.ScopeWrite}, ""},
+		{keys.sign(t, "EdDSA", "ed", claims(map[string]any{"aud": "rrd"})), []models.Scope{models.ScopeRead}, ""},
+		{keys.sign(t, "PS256", "ec", claims(nil)), nil, "key type doesn't match"},
+		// Key is restricted to RS256.
+		{keys.sign(t, "PS256", "rsa", claims(nil)), nil, "not allowed for PS256"},
+		{keys.sign(t, "HS256", "rsa", claims(nil)), nil, "not allowed for HS256"},
+		{keys.sign(t, "none", "ec", claims(nil)), nil, `unsupported algorithm "none"`},
+		{keys.sign(t, "RS256", "unknown", claims(nil)), nil, `unknown key "unknown"`},
+		{keys.sign(t, "RS256", "rsa", claims(map[string]any{"exp": now.Add(-time.Hour).Unix()})), nil, "expired"},
+		{keys.sign(t, "RS256", "rsa", claims(map[string]any{"exp": nil})), nil, "exp claim is required"},
+		{keys.sign(t, "RS256", "rsa", claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})), nil, "not valid yet"},
+		{keys.sign(t, "RS256", "rsa", claims(map[string]any{"iss": "https://evil.example.com"})), nil, "issuer"},
+		{keys.sign(t, "RS256", "rsa", claims(map[string]any{"aud": "other"})), nil, "not issued for this service"},
+		{keys.sign(t, "RS256", "rsa", claims(map[string]any{"tenant": nil})), nil, "no tenant claim"},
+		{keys.sign(t, "RS256", "rsa", claims(nil))[:20], nil, "3 parts"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			a, err := NewJWT(JWTOptions{
+				JWKSPath:    writeFile(t, "jwks.json", keys.jwks()),
+				Issuer:      "https://idp.example.com",
+				Audience:    "rrd",
+				TenantClaim: "tenant",
+				Leeway:      time.Minute,
+			})
+			require.NoError(t, err)
+			a.now = func() time.Time { return now }
+
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+			identity, err := a.Authenticate(r)
+			if tc.err != "" {
+				require.ErrorIs(t, err, models.ErrUnauthenticated)
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, models.Identity{Subject: "dashboard", Tenant: "acme", Scopes: tc.scopes}, identity)
+		})
+	}
+}
+
+func TestJWT_ReloadsKeys(t *testing.T) {
+	t.Parallel()
+	oldKeys, newKeys := newTestKeys(t), newTestKeys(t)
+	path := writeFile(t, "jwks.json", oldKeys.jwks())
+	a, err := NewJWT(JWTOptions{JWKSPath: path, TenantClaim: "tenant"})
+	require.NoError(t, err)
+
+	claims := map[string]any{"sub": "dashboard", "tenant": "acme", "scope": "read",
+		"exp": time.Now().Add(time.Hour).Unix()}
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Authorization", "Bearer "+newKeys.sign(t, "ES256", "ec2", claims))
+	_, err = a.Authenticate(r)
+	require.ErrorContains(t, err, `unknown key "ec2"`)
+
+	// Issuer rotates keys with a new key id.
+	rotated := strings.ReplaceAll(newKeys.jwks(), `"kid":"ec"`, `"kid":"ec2"`)
+	require.NoError(t, os.WriteFile(path, []byte(rotated), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	identity, err := a.Authenticate(r)
+	require.NoError(t, err)
+	require.Equal(t, "acme", identity.Tenant)
+}
+
+func TestNewJWT_Invalid(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		jwks string
+		err  string
+	}{
+		{`{"keys": []}`, "no signature keys"},
+		{`{"keys": [{"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}]}`, "at least 2048 bits"},
+		{`{"keys": [{"kty": "EC", "kid": "a", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`, "invalid ec point"},
+		{`{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`, `unsupported key type "oct"`},
+		{`{"keys": `, "failed to decode jwks"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			_, err := NewJWT(JWTOptions{JWKSPath: writeFile(t, "jwks.json", tc.jwks)})
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
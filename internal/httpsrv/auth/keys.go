@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Keys contains static credentials, they are loaded from yaml file.
+type Keys struct {
+	APIKeys     []APIKey     `yaml:"api_keys"`
+	HMACKeys    []HMACKey    `yaml:"hmac_keys"`
+	ClientCerts []ClientCert `yaml:"client_certs"`
+}
+
+// APIKey is a static key, only its sha256 hash is stored, so the file doesn't leak keys.
+type APIKey struct {
+	// SHA256 is a hex encoded sha256 hash of the key.
+	SHA256  string         `yaml:"sha256"`
+	Subject string         `yaml:"subject"`
+	Tenant  string         `yaml:"tenant"`
+	Scopes  []models.Scope `yaml:"scopes"`
+}
+
+// HMACKey is a shared secret for signing requests.
+type HMACKey struct {
+	ID      string         `yaml:"id"`
+	Secret  string         `yaml:"secret"`
+	Subject string         `yaml:"subject"`
+	Tenant  string         `yaml:"tenant"`
+	Scopes  []models.Scope `yaml:"scopes"`
+}
+
+// ClientCert maps common name of verified client certificate to identity, common name is a subject.
+type ClientCert struct {
+	CommonName string         `yaml:"cn"`
+	Tenant     string         `yaml:"tenant"`
+	Scopes     []models.Scope `yaml:"scopes"`
+}
+
+// LoadKeys reads static credentials from yaml file.
+func LoadKeys(path string) (*Keys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys file: %w", err)
+	}
+	var keys Keys
+	if err = yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse keys file %s: %w", path, err)
+	}
+	return &keys, nil
+}
+
+// identity returns identity of the credentials, default tenant is used if tenant is not set.
+func identity(subject, tenant string, scopes []models.Scope) (models.Identity, error) {
+	if tenant == "" {
+		tenant = models.DefaultTenant
+	}
+	id := models.Identity{
+		Subject: subject,
+		Tenant:  tenant,
+		Scopes:  scopes,
+	}
+	if err := id.Validate(); err != nil {
+		return models.Identity{}, err
+	}
+	return id, nil
+}
@@ -0,0 +1,110 @@
+package httpsrv
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// authenticatorMock authenticates requests by api key header.
+type authenticatorMock map[string]models.Identity
+
+func (mock authenticatorMock) Authenticate(r *http.Request) (models.Identity, error) {
+	identity, ok := mock[r.Header.Get("X-API-Key")]
+	if !ok {
+		return models.Identity{}, fmt.Errorf("%w: invalid api key", models.ErrUnauthenticated)
+	}
+	return identity, nil
+}
+
+var testAuthenticator = authenticatorMock{
+	"reader": {Subject: "reader", Tenant: "acme", Scopes: []models.Scope{models.ScopeRead}},
+	"admin":  {Subject: "admin", Tenant: "ops", Scopes: []models.Scope{models.ScopeAdmin}},
+}
+
+func TestRouter_Auth(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		method     string
+		path       string
+		key        string
+		statusCode int
+	}{
+		// Probes are public.
+		{http.MethodGet, "/healthz", "", http.StatusOK},
+		{http.MethodGet, "/internal/metrics", "", http.StatusUnauthorized},
+		{http.MethodGet, "/internal/metrics", "unknown", http.StatusUnauthorized},
+		{http.MethodGet, "/internal/metrics", "reader", http.StatusForbidden},
+		{http.MethodGet, "/internal/metrics", "admin", http.StatusOK},
+		{http.MethodPut, "/metrics", "reader", http.StatusForbidden},
+		{http.MethodPatch, "/series/cpu", "reader", http.StatusForbidden},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			h := testHandlers(nil, "/internal/metrics")
+			h.Auth = testAuthenticator
+			router, err := NewRouter(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tc.statusCode, rec.Code)
+			if tc.statusCode == http.StatusUnauthorized {
+				require.Equal(t, authChallenge, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAuthorize_Tenant(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		key        string
+		tenant     string
+		statusCode int
+		expected   string
+	}{
+		{"reader", "", http.StatusOK, "acme"},
+		{"reader", "acme", http.StatusOK, "acme"},
+		// Only admins can access other tenants.
+		{"reader", "ops", http.StatusForbidden, ""},
+		{"admin", "", http.StatusOK, "ops"},
+		{"admin", "acme", http.StatusOK, "acme"},
+		{"admin", "Invalid Tenant", http.StatusBadRequest, ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			var tenant string
+			handler := authorize(testAuthenticator, models.ScopeRead, nil, slog.New(slog.NewJSONHandler(os.Stdout, nil)))(
+				http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+					tenant = models.TenantFromContext(r.Context())
+				}),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("X-API-Key", tc.key)
+			if tc.tenant != "" {
+				req.Header.Set(HeaderTenant, tc.tenant)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tc.statusCode, rec.Code)
+			require.Equal(t, tc.expected, tenant)
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package httpsrv
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
+)
+
+// Supported content encodings of requests and responses.
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// maxDecoderWindow limits memory of zstd decoder of request bodies.
+const maxDecoderWindow = 8 << 20
+
+// encoder compresses response body, encoders are reused with Reset.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+	EncodingZstd: {New: func() any {
+		// Options are valid, so error is not possible.
+		enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+		return enc
+	}},
+}
+
+// compress returns middleware that compresses responses with encoding accepted by the client.
+// Encodings are in order of server preference, it is used if client accepts them equally.
+func compress(encodings []string, minSize int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the best encoding accepted by Accept-Encoding header, or empty string if none is accepted.
+func negotiateEncoding(header string, encodings []string) string {
+	if header == "" {
+		return ""
+	}
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		accepted[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := accepted[encoding]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers response until it reaches min size, then compresses it. Smaller responses,
+// responses without body and already encoded ones are written as is.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder encoder
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.status != 0 || cw.decided {
+		return
+	}
+	if code < http.StatusOK {
+		// Informational responses are sent as is.
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+	if !compressible(code, cw.Header()) {
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 && !cw.decided {
+		if cw.Header().Get("Content-Type") == "" {
+			// Compressed body can't be sniffed by the server.
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.startEncoding(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends buffered body, streamed responses are compressed regardless of size.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.WriteHeader(http.StatusOK)
+		}
+		if !cw.decided {
+			if err := cw.startEncoding(); err != nil {
+				return
+			}
+		}
+	}
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the original writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Hijack allows websocket handlers to take over the connection, response of hijacked connection isn't compressed.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.decided = true
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// startEncoding sends headers of compressed response and writes buffered body to encoder.
+func (cw *compressWriter) startEncoding() error {
+	cw.decided = true
+	cw.Header().Set("Content-Encoding", cw.encoding)
+	cw.Header().Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	cw.encoder = encoderPools[cw.encoding].Get().(encoder)
+	cw.encoder.Reset(cw.ResponseWriter)
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.encoder.Write(buf)
+	return err
+}
+
+// close finishes compressed body, or writes buffered body as is if it is smaller than min size.
+func (cw *compressWriter) close() {
+	if cw.encoder != nil {
+		_ = cw.encoder.Close()
+		cw.encoder.Reset(io.Discard)
+		encoderPools[cw.encoding].Put(cw.encoder)
+		cw.encoder = nil
+		return
+	}
+	if cw.decided || cw.status == 0 {
+		return
+	}
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) > 0 {
+		_, _ = cw.ResponseWriter.Write(cw.buf)
+	}
+}
+
+// compressible returns true if response may have body that is worth compressing.
+func compressible(code int, header http.Header) bool {
+	if code == http.StatusNoContent || code == http.StatusNotModified || header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, prefix := range []string{"image/", "video/", "audio/", "application/zip", "application/gzip", "application/zstd"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// decompress returns middleware that decodes request bodies with gzip or zstd Content-Encoding. Body limit is
+// applied to decoded body, so small compressed bodies can't expand beyond it.
+func decompress(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			var decoder io.ReadCloser
+			switch encoding {
+			case "", "identity":
+				next.ServeHTTP(w, r)
+				return
+			case EncodingGzip:
+				gz, err := gzip.NewReader(r.Body)
+				if err != nil {
+					handlers.WriteError(w, r, logger, "failed to decode body",
+						models.NewValidationError("body", "invalid gzip body: %s", err))
+					return
+				}
+				decoder = gz
+			case EncodingZstd:
+				zr, err := zstd.NewReader(r.Body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxDecoderWindow))
+				if err != nil {
+					handlers.WriteError(w, r, logger, "failed to decode body",
+						models.NewValidationError("body", "invalid zstd body: %s", err))
+					return
+				}
+				decoder = zr.IOReadCloser()
+			default:
+				handlers.WriteError(w, r, logger, "failed to decode body",
+					models.NewDetailError(models.ErrUnsupportedMediaType, "content encoding %q, must be one of %s, %s",
+						encoding, EncodingZstd, EncodingGzip))
+				return
+			}
+			defer decoder.Close()
+
+			// Decoder is closed by middleware and original body by server.
+			r.Body = io.NopCloser(decoder)
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
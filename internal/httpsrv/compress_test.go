@@ -0,0 +1,212 @@
+package httpsrv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	t.Parallel()
+	encodings := []string{EncodingZstd, EncodingGzip}
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{"gzip, deflate, br, zstd", EncodingZstd},
+		{"gzip", EncodingGzip},
+		{"zstd;q=0.5, gzip", EncodingGzip},
+		{"GZIP;q=0.8", EncodingGzip},
+		{"*", EncodingZstd},
+		{"*, zstd;q=0", EncodingGzip},
+		{"br, identity", ""},
+		{"gzip;q=0", ""},
+		{"gzip;q=x", ""},
+		{"", ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, negotiateEncoding(tc.header, encodings))
+		})
+	}
+}
+
+func decodeBody(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var reader io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		reader = gz
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		defer zr.Close()
+		reader = zr
+	default:
+		return string(body)
+	}
+	decoded, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(decoded)
+}
+
+func encodeBody(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch encoding {
+	case EncodingGzip:
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write([]byte(body))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+	case EncodingZstd:
+		enc, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		_, err = enc.Write([]byte(body))
+		require.NoError(t, err)
+		require.NoError(t, enc.Close())
+	default:
+		buf.WriteString(body)
+	}
+	return buf.Bytes()
+}
+
+func TestMiddleware_Compress(t *testing.T) {
+	t.Parallel()
+	large := `{"values": [` + strings.Repeat(`1.5, `, 500) + `1.5]}`
+	testCases := []struct {
+		acceptEncoding string
+		status         int
+		contentType    string
+		body           string
+		encoding       string
+	}{
+		{"gzip", http.StatusOK, "application/json", large, EncodingGzip},
+		{"gzip, zstd", http.StatusOK, "application/json", large, EncodingZstd},
+		{"", http.StatusOK, "application/json", large, ""},
+		// Small, empty and binary responses are not compressed.
+		{"gzip", http.StatusOK, "application/json", `{"a": 1}`, ""},
+		{"gzip", http.StatusNoContent, "", "", ""},
+		{"gzip", http.StatusOK, "image/png", large, ""},
+		{"gzip", http.StatusBadRequest, "application/problem+json", large, EncodingGzip},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			handler := compress([]string{EncodingZstd, EncodingGzip}, 1024)(http.HandlerFunc(
+				func(w http.ResponseWriter, _ *http.Request) {
+					if tc.contentType != "" {
+						w.Header().Set("Content-Type", tc.contentType)
+					}
+					w.WriteHeader(tc.status)
+					if tc.body == "" {
+						return
+					}
+					// Body is written in parts to check buffering.
+					for _, part := range strings.SplitAfter(tc.body, ",") {
+						_, err := w.Write([]byte(part))
+						require.NoError(t, err)
+					}
+				}))
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code)
+			require.Equal(t, tc.encoding, rec.Header().Get("Content-Encoding"))
+			require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			require.Equal(t, tc.body, decodeBody(t, tc.encoding, rec.Body.Bytes()))
+		})
+	}
+}
+
+func TestMiddleware_CompressFlush(t *testing.T) {
+	t.Parallel()
+	flushed := make(chan struct{})
+	handler := compress([]string{EncodingGzip}, 1024)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: 1\n\n"))
+		require.NoError(t, http.NewResponseController(w).Flush())
+		close(flushed)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	<-flushed
+	require.True(t, rec.Flushed)
+	require.Equal(t, EncodingGzip, rec.Header().Get("Content-Encoding"))
+	require.Equal(t, "data: 1\n\n", decodeBody(t, EncodingGzip, rec.Body.Bytes()))
+}
+
+func TestMiddleware_Decompress(t *testing.T) {
+	t.Parallel()
+	body := `{"timestamp": 1, "metric_value": 1}`
+	testCases := []struct {
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{EncodingGzip, encodeBody(t, EncodingGzip, body), http.StatusOK},
+		{EncodingZstd, encodeBody(t, EncodingZstd, body), http.StatusOK},
+		{"", []byte(body), http.StatusOK},
+		{EncodingGzip, []byte(body), http.StatusBadRequest},
+		{EncodingZstd, []byte(body), http.StatusBadRequest},
+		{"br", []byte(body), http.StatusUnsupportedMediaType},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			handler := decompress(slog.New(slog.NewJSONHandler(os.Stdout, nil)))(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					require.Empty(t, r.Header.Get("Content-Encoding"))
+					decoded, err := io.ReadAll(r.Body)
+					if err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					require.Equal(t, body, string(decoded))
+				}))
+			req := httptest.NewRequest(http.MethodPut, "/metrics", bytes.NewReader(tc.body))
+			req.Header.Set("Content-Encoding", tc.encoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
+
+func TestHandler_DecompressedBodyLimit(t *testing.T) {
+	t.Parallel()
+	h := testHandlers(nil, "/internal/metrics")
+	h.Limiter = NewLimiter(Limits{MaxBodyBytes: 100})
+	handler, err := NewHandler(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+
+	// Compressed body is small, but decoded one exceeds the limit.
+	body := encodeBody(t, EncodingGzip, `[`+strings.Repeat(`{"timestamp": 1, "metric_value": 1},`, 100)+`]`)
+	require.Less(t, len(body), 100)
+	req := httptest.NewRequest(http.MethodPut, "/metrics", bytes.NewReader(body))
+	req.Header.Set("Content-Encoding", EncodingGzip)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
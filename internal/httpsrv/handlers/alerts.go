@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const (
+	// PathParamRule is a name of route variable with alert rule name.
+	PathParamRule = "rule"
+	// PathParamSilence is a name of route variable with silence id.
+	PathParamSilence = "id"
+)
+
+type AlertService interface {
+	CreateRule(ctx context.Context, rule models.AlertRule) (models.AlertRule, error)
+	ReplaceRule(ctx context.Context, name string, rule models.AlertRule) (models.AlertRule, error)
+	DeleteRule(ctx context.Context, name string) error
+	GetRule(ctx context.Context, name string) (models.AlertRule, error)
+	ListRules(ctx context.Context) ([]models.AlertRule, error)
+	ListAlerts(ctx context.Context, state models.AlertState) ([]models.Alert, error)
+	CreateSilence(ctx context.Context, silence models.Silence) (models.Silence, error)
+	ListSilences(ctx context.Context) ([]models.Silence, error)
+	DeleteSilence(ctx context.Context, id string) error
+}
+
+// Alerts contains handlers for alert rules, alerts and silences.
+type Alerts struct {
+	service AlertService
+	logger  *slog.Logger
+}
+
+// NewAlerts returns new alerts handlers struct.
+func NewAlerts(service AlertService, logger *slog.Logger) *Alerts {
+	return &Alerts{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// rulesResult is a response of ListRules.
+type rulesResult struct {
+	Rules []models.AlertRule `json:"rules"`
+}
+
+// alertsResult is a response of List.
+type alertsResult struct {
+	Alerts []models.Alert `json:"alerts"`
+}
+
+// silencesResult is a response of ListSilences.
+type silencesResult struct {
+	Silences []models.Silence `json:"silences"`
+}
+
+// CreateRule validates request and adds new alert rule.
+func (h *Alerts) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeError(w, r, h.logger, "failed to create alert rule, failed to decode request", decodeError(err))
+		return
+	}
+
+	result, err := h.service.CreateRule(r.Context(), rule)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to create alert rule", err,
+			slog.String("rule", rule.Name),
+		)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusCreated, result)
+}
+
+// ReplaceRule replaces definition of the alert rule.
+func (h *Alerts) ReplaceRule(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)[PathParamRule]
+
+	var rule models.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeError(w, r, h.logger, "failed to replace alert rule, failed to decode request", decodeError(err))
+		return
+	}
+
+	result, err := h.service.ReplaceRule(r.Context(), name, rule)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to replace alert rule", err,
+			slog.String("rule", name),
+		)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, result)
+}
+
+// DeleteRule deletes the alert rule.
+func (h *Alerts) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)[PathParamRule]
+
+	if err := h.service.DeleteRule(r.Context(), name); err != nil {
+		writeError(w, r, h.logger, "failed to delete alert rule", err,
+			slog.String("rule", name),
+		)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRule returns definition of the alert rule.
+func (h *Alerts) GetRule(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)[PathParamRule]
+
+	result, err := h.service.GetRule(r.Context(), name)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to get alert rule", err,
+			slog.String("rule", name),
+		)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, result)
+}
+
+// ListRules returns alert rules of the tenant.
+func (h *Alerts) ListRules(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.ListRules(r.Context())
+	if err != nil {
+		writeError(w, r, h.logger, "failed to list alert rules", err)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, rulesResult{Rules: result})
+}
+
+// List returns alerts of the tenant.
+// Query params: `state` (pending, firing or resolved).
+func (h *Alerts) List(w http.ResponseWriter, r *http.Request) {
+	state := models.AlertState(r.URL.Query().Get("state"))
+
+	result, err := h.service.ListAlerts(r.Context(), state)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to list alerts", err)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, alertsResult{Alerts: result})
+}
+
+// CreateSilence validates request and adds new silence.
+func (h *Alerts) CreateSilence(w http.ResponseWriter, r *http.Request) {
+	var silence models.Silence
+	if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+		writeError(w, r, h.logger, "failed to create silence, failed to decode request", decodeError(err))
+		return
+	}
+
+	result, err := h.service.CreateSilence(r.Context(), silence)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to create silence", err)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusCreated, result)
+}
+
+// ListSilences returns silences of the tenant that are not expired.
+func (h *Alerts) ListSilences(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.ListSilences(r.Context())
+	if err != nil {
+		writeError(w, r, h.logger, "failed to list silences", err)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, silencesResult{Silences: result})
+}
+
+// DeleteSilence deletes the silence.
+func (h *Alerts) DeleteSilence(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)[PathParamSilence]
+
+	if err := h.service.DeleteSilence(r.Context(), id); err != nil {
+		writeError(w, r, h.logger, "failed to delete silence", err,
+			slog.String("silence", id),
+		)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
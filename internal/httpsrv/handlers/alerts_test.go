@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/steinfletcher/apitest"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const (
+	testRuleName  = "high_cpu"
+	testSilenceID = "0011223344556677"
+)
+
+type alertServiceMock struct{}
+
+func (mock alertServiceMock) CreateRule(_ context.Context, rule models.AlertRule) (models.AlertRule, error) {
+	if rule.Name == testRuleName {
+		return models.AlertRule{}, fmt.Errorf("failed to create: %w", models.ErrConflict)
+	}
+	if err := rule.Validate(); err != nil {
+		return models.AlertRule{}, err
+	}
+	rule.Source = models.AlertRuleSourceAPI
+	return rule, nil
+}
+
+func (mock alertServiceMock) ReplaceRule(_ context.Context, name string, rule models.AlertRule,
+) (models.AlertRule, error) {
+	if name != testRuleName {
+		return models.AlertRule{}, fmt.Errorf("failed to get: %w", models.ErrNotFound)
+	}
+	rule.Name = name
+	if err := rule.Validate(); err != nil {
+		return models.AlertRule{}, err
+	}
+	rule.Source = models.AlertRuleSourceAPI
+	return rule, nil
+}
+
+func (mock alertServiceMock) DeleteRule(_ context.Context, name string) error {
+	if name != testRuleName {
+		return fmt.Errorf("failed to get: %w", models.ErrNotFound)
+	}
+	return nil
+}
+
+func (mock alertServiceMock) GetRule(_ context.Context, name string) (models.AlertRule, error) {
+	if name != testRuleName {
+		return models.AlertRule{}, fmt.Errorf("failed to get: %w", models.ErrNotFound)
+	}
+	return testAlertRule(), nil
+}
+
+func (mock alertServiceMock) ListRules(context.Context) ([]models.AlertRule, error) {
+	return []models.AlertRule{testAlertRule()}, nil
+}
+
+func (mock alertServiceMock) ListAlerts(_ context.Context, state models.AlertState) ([]models.Alert, error) {
+	switch state {
+	case "", models.AlertStateFiring:
+		return []models.Alert{{Rule: testRuleName, Series: "cpu", State: models.AlertStateFiring, Value: 95}}, nil
+	case models.AlertStatePending, models.AlertStateResolved:
+		return []models.Alert{}, nil
+	default:
+		return nil, models.NewValidationError("state", "unknown state %q", state)
+	}
+}
+
+func (mock alertServiceMock) CreateSilence(_ context.Context, silence models.Silence) (models.Silence, error) {
+	if err := silence.Validate(); err != nil {
+		return models.Silence{}, err
+	}
+	silence.ID = testSilenceID
+	return silence, nil
+}
+
+func (mock alertServiceMock) ListSilences(context.Context) ([]models.Silence, error) {
+	return []models.Silence{{ID: testSilenceID, Rule: testRuleName, StartsAt: 1, EndsAt: 2}}, nil
+}
+
+func (mock alertServiceMock) DeleteSilence(_ context.Context, id string) error {
+	if id != testSilenceID {
+		return fmt.Errorf("failed to get: %w", models.ErrNotFound)
+	}
+	return nil
+}
+
+func testAlertRule() models.AlertRule {
+	return models.AlertRule{
+		Name:        testRuleName,
+		Selector:    models.AlertSelector{Series: "cpu"},
+		Aggregation: models.AggregationAvg,
+		Window:      60,
+		Comparison:  models.ComparisonGreater,
+		Threshold:   90,
+		Source:      models.AlertRuleSourceAPI,
+	}
+}
+
+func newAlertsRouter() *mux.Router {
+	h := NewAlerts(alertServiceMock{}, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	router := mux.NewRouter()
+	router.HandleFunc("/alerts", h.List).Methods(http.MethodGet)
+	router.HandleFunc("/alerts/rules", h.CreateRule).Methods(http.MethodPost)
+	router.HandleFunc("/alerts/rules", h.ListRules).Methods(http.MethodGet)
+	router.HandleFunc("/alerts/rules/{rule}", h.GetRule).Methods(http.MethodGet)
+	router.HandleFunc("/alerts/rules/{rule}", h.ReplaceRule).Methods(http.MethodPut)
+	router.HandleFunc("/alerts/rules/{rule}", h.DeleteRule).Methods(http.MethodDelete)
+	router.HandleFunc("/alerts/silences", h.CreateSilence).Methods(http.MethodPost)
+	router.HandleFunc("/alerts/silences", h.ListSilences).Methods(http.MethodGet)
+	router.HandleFunc("/alerts/silences/{id}", h.DeleteSilence).Methods(http.MethodDelete)
+	return router
+}
+
+func TestAlerts(t *testing.T) {
+	t.Parallel()
+	const rule = `{"name":"high_cpu","selector":{"series":"cpu"},"aggregation":"avg","window":60,` +
+		`"comparison":"gt","threshold":90,"for":0,"source":"api","created_at":0,"updated_at":0}`
+
+	testCases := []struct {
+		method     string
+		path       string
+		query      map[string]string
+		body       string
+		statusCode int
+		response   string
+	}{
+		{http.MethodGet, "/alerts", nil, "", http.StatusOK,
+			`{"alerts":[{"rule":"high_cpu","series":"cpu","state":"firing","value":95,"active_at":0,"silenced":false}]}`},
+		{http.MethodGet, "/alerts", map[string]string{"state": "pending"}, "", http.StatusOK, `{"alerts":[]}`},
+		{http.MethodGet, "/alerts", map[string]string{"state": "unknown"}, "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/alerts/rules", nil, "", http.StatusOK, `{"rules":[` + rule + `]}`},
+		{http.MethodPost, "/alerts/rules", nil,
+			`{"name":"low_memory","selector":{"labels":{"env":"prod"}},"aggregation":"min","window":60,` +
+				`"comparison":"lt","threshold":10}`,
+			http.StatusCreated,
+			`{"name":"low_memory","selector":{"labels":{"env":"prod"}},"aggregation":"min","window":60,` +
+				`"comparison":"lt","threshold":10,"for":0,"source":"api","created_at":0,"updated_at":0}`},
+		{http.MethodPost, "/alerts/rules", nil, rule, http.StatusConflict, ""},
+		{http.MethodPost, "/alerts/rules", nil, `{"name":"low_memory"}`, http.StatusBadRequest, ""},
+		{http.MethodPost, "/alerts/rules", nil, `{"name":1}`, http.StatusBadRequest, ""},
+		{http.MethodGet, "/alerts/rules/high_cpu", nil, "", http.StatusOK, rule},
+		{http.MethodGet, "/alerts/rules/unknown", nil, "", http.StatusNotFound, ""},
+		{http.MethodPut, "/alerts/rules/high_cpu", nil, rule, http.StatusOK, rule},
+		{http.MethodPut, "/alerts/rules/unknown", nil, rule, http.StatusNotFound, ""},
+		{http.MethodPut, "/alerts/rules/high_cpu", nil, "", http.StatusBadRequest, ""},
+		{http.MethodDelete, "/alerts/rules/high_cpu", nil, "", http.StatusNoContent, ""},
+		{http.MethodDelete, "/alerts/rules/unknown", nil, "", http.StatusNotFound, ""},
+		{http.MethodGet, "/alerts/silences", nil, "", http.StatusOK,
+			`{"silences":[{"id":"0011223344556677","rule":"high_cpu","starts_at":1,"ends_at":2,"created_at":0}]}`},
+		{http.MethodPost, "/alerts/silences", nil, `{"series":"cpu","starts_at":1,"ends_at":2}`, http.StatusCreated,
+			`{"id":"0011223344556677","series":"cpu","starts_at":1,"ends_at":2,"created_at":0}`},
+		{http.MethodPost, "/alerts/silences", nil, `{"starts_at":2,"ends_at":1}`, http.StatusBadRequest, ""},
+		{http.MethodDelete, "/alerts/silences/" + testSilenceID, nil, "", http.StatusNoContent, ""},
+		{http.MethodDelete, "/alerts/silences/unknown", nil, "", http.StatusNotFound, ""},
+	}
+
+	router := newAlertsRouter()
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			test := apitest.New().
+				Handler(router).
+				Method(tc.method).
+				URL(tc.path).
+				QueryParams(tc.query).
+				Body(tc.body).
+				Expect(t).
+				Status(tc.statusCode)
+			if tc.response != "" {
+				test = test.Body(tc.response)
+			}
+			test.End()
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type ForecastService interface {
+	Forecast(ctx context.Context, series string, horizon int) (models.Forecast, error)
+}
+
+// Forecast contains handlers for Holt-Winters forecasts of series.
+type Forecast struct {
+	service ForecastService
+	logger  *slog.Logger
+}
+
+// NewForecast returns new forecast handlers struct.
+func NewForecast(service ForecastService, logger *slog.Logger) *Forecast {
+	return &Forecast{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Get returns predictions of the next steps of the series.
+// Query params: `horizon` - number of predicted steps, one season by default.
+func (h *Forecast) Get(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)[PathParamSeries]
+	horizon := 0
+	if value := r.URL.Query().Get("horizon"); value != "" {
+		var err error
+		if horizon, err = strconv.Atoi(value); err != nil {
+			writeError(w, r, h.logger, "failed to forecast series, invalid params",
+				models.NewValidationError("horizon", "must be integer"))
+			return
+		}
+	}
+
+	result, err := h.service.Forecast(r.Context(), name, horizon)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to forecast series", err,
+			slog.String("series", name),
+		)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, result)
+}
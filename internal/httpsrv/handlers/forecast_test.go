@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/steinfletcher/apitest"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type forecastServiceMock struct{}
+
+func (mock forecastServiceMock) Forecast(_ context.Context, series string, horizon int) (models.Forecast, error) {
+	switch {
+	case series != testSeriesName:
+		return models.Forecast{}, fmt.Errorf("failed to get: %w", models.ErrNotFound)
+	case horizon < 0:
+		return models.Forecast{}, models.NewValidationError("horizon", "must not be negative")
+	case horizon == 0:
+		horizon = 2
+	}
+	points := make([]models.ForecastPoint, horizon)
+	for i := range points {
+		points[i] = models.ForecastPoint{Timestamp: int64(i+1) * 60000000, Value: 10, Lower: 8, Upper: 12}
+	}
+	return models.Forecast{Series: series, Step: 60, Points: points}, nil
+}
+
+func TestForecast_Get(t *testing.T) {
+	t.Parallel()
+	h := NewForecast(forecastServiceMock{}, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	router := mux.NewRouter()
+	router.HandleFunc("/series/{name}/forecast", h.Get).Methods(http.MethodGet)
+
+	testCases := []struct {
+		series     string
+		query      map[string]string
+		statusCode int
+		response   string
+	}{
+		{testSeriesName, nil, http.StatusOK, `{"series":"cpu_usage","step":60,"points":[` +
+			`{"timestamp":60000000,"value":10,"lower":8,"upper":12},` +
+			`{"timestamp":120000000,"value":10,"lower":8,"upper":12}]}`},
+		{testSeriesName, map[string]string{"horizon": "1"}, http.StatusOK, `{"series":"cpu_usage","step":60,"points":[` +
+			`{"timestamp":60000000,"value":10,"lower":8,"upper":12}]}`},
+		{testSeriesName, map[string]string{"horizon": "x"}, http.StatusBadRequest, ""},
+		{testSeriesName, map[string]string{"horizon": "-1"}, http.StatusBadRequest, ""},
+		{"unknown", nil, http.StatusNotFound, ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			test := apitest.New().
+				Handler(router).
+				Get("/series/" + tc.series + "/forecast").
+				QueryParams(tc.query).
+				Expect(t).
+				Status(tc.statusCode)
+			if tc.response != "" {
+				test = test.Body(tc.response)
+			}
+			test.End()
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Formats of records in requests and responses.
+const (
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatCSV      = "csv"
+	FormatMsgPack  = "msgpack"
+	FormatProtobuf = "protobuf"
+)
+
+// QueryParamFormat selects format of records, it takes precedence over Accept and Content-Type headers.
+const QueryParamFormat = "format"
+
+// Columns of records in csv format.
+const (
+	csvColumnSeries    = "series"
+	csvColumnTimestamp = "timestamp"
+	csvColumnValue     = "metric_value"
+)
+
+// recordFormat encodes and decodes records in one format.
+type recordFormat struct {
+	name string
+	// contentType is sent in responses.
+	contentType string
+	// mediaTypes are accepted in Accept and Content-Type headers.
+	mediaTypes []string
+	encode     func(w io.Writer, records []models.Record) error
+	// decode returns true if body is a batch of records.
+	decode func(body io.Reader) ([]models.Record, bool, error)
+}
+
+// recordFormats are in order of server preference, it is used if client accepts several formats equally.
+var recordFormats = []recordFormat{
+	{
+		name:        FormatJSON,
+		contentType: "application/json",
+		mediaTypes:  []string{"application/json"},
+		encode:      encodeJSON,
+		decode:      decodeRecords,
+	},
+	{
+		name:        FormatNDJSON,
+		contentType: "application/x-ndjson",
+		mediaTypes:  []string{"application/x-ndjson", "application/jsonl"},
+		encode:      encodeNDJSON,
+		decode:      decodeNDJSON,
+	},
+	{
+		name:        FormatCSV,
+		contentType: "text/csv; charset=utf-8",
+		mediaTypes:  []string{"text/csv"},
+		encode:      encodeCSV,
+		decode:      decodeCSV,
+	},
+	{
+		name:        FormatMsgPack,
+		contentType: "application/msgpack",
+		mediaTypes:  []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		encode:      encodeMsgPack,
+		decode:      decodeMsgPack,
+	},
+	{
+		name:        FormatProtobuf,
+		contentType: "application/x-protobuf",
+		mediaTypes:  []string{"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf"},
+		encode:      encodeProtobuf,
+		decode:      decodeProtobuf,
+	},
+}
+
+// legacyMediaTypes were sent by clients with json bodies before formats were negotiated, so they are decoded as json.
+var legacyMediaTypes = []string{"application/x-www-form-urlencoded", "text/plain"}
+
+// formatByName returns format from format query param.
+func formatByName(name string) (recordFormat, error) {
+	for _, format := range recordFormats {
+		if format.name == strings.ToLower(name) {
+			return format, nil
+		}
+	}
+	return recordFormat{}, models.NewValidationError(QueryParamFormat, "unknown format %q, must be one of %s",
+		name, strings.Join(formatNames(), ", "))
+}
+
+func formatNames() []string {
+	names := make([]string, 0, len(recordFormats))
+	for _, format := range recordFormats {
+		names = append(names, format.name)
+	}
+	return names
+}
+
+// responseFormat returns format of response from format query param or Accept header, json is used by default.
+func responseFormat(r *http.Request) (recordFormat, error) {
+	if name := r.URL.Query().Get(QueryParamFormat); name != "" {
+		return formatByName(name)
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return recordFormats[0], nil
+	}
+	if format, ok := negotiateFormat(accept); ok {
+		return format, nil
+	}
+	return recordFormat{}, models.NewDetailError(models.ErrNotAcceptable, "accept %q, supported formats are %s",
+		accept, strings.Join(formatNames(), ", "))
+}
+
+// requestFormat returns format of request body from format query param or Content-Type header,
+// body without content type is json.
+func requestFormat(r *http.Request) (recordFormat, error) {
+	if name := r.URL.Query().Get(QueryParamFormat); name != "" {
+		return formatByName(name)
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return recordFormats[0], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return recordFormat{}, models.NewDetailError(models.ErrUnsupportedMediaType, "invalid content type %q",
+			contentType)
+	}
+	if slices.Contains(legacyMediaTypes, mediaType) {
+		return recordFormats[0], nil
+	}
+	for _, format := range recordFormats {
+		if slices.Contains(format.mediaTypes, mediaType) {
+			return format, nil
+		}
+	}
+	return recordFormat{}, models.NewDetailError(models.ErrUnsupportedMediaType,
+		"content type %q, supported formats are %s", mediaType, strings.Join(formatNames(), ", "))
+}
+
+// negotiateFormat returns the best format accepted by Accept header. Quality of a format is taken from
+// the most specific matching media range.
+func negotiateFormat(accept string) (recordFormat, bool) {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+
+	var (
+		best  recordFormat
+		bestQ float64
+	)
+	for _, format := range recordFormats {
+		q, specificity := 0.0, -1
+		for _, mediaType := range format.mediaTypes {
+			group, _, _ := strings.Cut(mediaType, "/")
+			for _, one := range ranges {
+				matched := -1
+				switch one.mediaType {
+				case mediaType:
+					matched = 2
+				case group + "/*":
+					matched = 1
+				case "*/*":
+					matched = 0
+				}
+				if matched > specificity {
+					q, specificity = one.q, matched
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+func encodeJSON(w io.Writer, records []models.Record) error {
+	return json.NewEncoder(w).Encode(records)
+}
+
+// encodeNDJSON writes a record per line.
+func encodeNDJSON(w io.Writer, records []models.Record) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeNDJSON reads a record per line, body is always a batch.
+func decodeNDJSON(body io.Reader) ([]models.Record, bool, error) {
+	decoder := json.NewDecoder(body)
+	var records []models.Record
+	for {
+		var record models.Record
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return records, true, nil
+		}
+		if err != nil {
+			return nil, true, fmt.Errorf("record %d: %w", len(records), err)
+		}
+		records = append(records, record)
+	}
+}
+
+// encodeCSV writes header and a record per row. Histograms are written as json.
+func encodeCSV(w io.Writer, records []models.Record) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{csvColumnTimestamp, csvColumnValue}); err != nil {
+		return err
+	}
+	for _, record := range records {
+		value, err := csvValue(record.MetricValue)
+		if err != nil {
+			return err
+		}
+		if err = writer.Write([]string{strconv.FormatInt(record.Timestamp, 10), value}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvValue(v any) (string, error) {
+	switch value := v.(type) {
+	case float64:
+		// Non-finite values are written as NaN, +Inf and -Inf.
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case string:
+		return value, nil
+	default:
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	}
+}
+
+// decodeCSV reads records from rows, the first row is a header with timestamp, metric_value and optional series
+// columns. Values are decoded like json literals, other values are strings. Body is always a batch.
+func decodeCSV(body io.Reader) ([]models.Record, bool, error) {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != csvColumnSeries && name != csvColumnTimestamp && name != csvColumnValue {
+			return nil, true, fmt.Errorf("unknown column %q, columns are %s, %s, %s",
+				name, csvColumnSeries, csvColumnTimestamp, csvColumnValue)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{csvColumnTimestamp, csvColumnValue} {
+		if _, ok := columns[name]; !ok {
+			return nil, true, fmt.Errorf("column %q is required", name)
+		}
+	}
+
+	var records []models.Record
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, true, nil
+		}
+		if err != nil {
+			return nil, true, err
+		}
+		var record models.Record
+		if i, ok := columns[csvColumnSeries]; ok {
+			record.Series = row[i]
+		}
+		if record.Timestamp, err = strconv.ParseInt(strings.TrimSpace(row[columns[csvColumnTimestamp]]), 10, 64); err != nil {
+			return nil, true, fmt.Errorf("row %d: invalid timestamp %q", len(records)+1, row[columns[csvColumnTimestamp]])
+		}
+		record.MetricValue = parseCSVValue(row[columns[csvColumnValue]])
+		records = append(records, record)
+	}
+}
+
+// parseCSVValue decodes numbers, booleans and objects like json, other values are strings.
+// Strings that look like json literals must be quoted, e.g. "\"true\"".
+func parseCSVValue(raw string) any {
+	if raw == "" {
+		return nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return raw
+	}
+	return value
+}
+
+// encodeMsgPack writes records as an array of maps with the same keys as json.
+func encodeMsgPack(w io.Writer, records []models.Record) error {
+	encoder := msgpack.NewEncoder(w)
+	encoder.SetCustomStructTag("json")
+	return encoder.Encode(records)
+}
+
+// decodeMsgPack reads a record or an array of records, it returns true if body is an array.
+func decodeMsgPack(body io.Reader) ([]models.Record, bool, error) {
+	decoder := msgpack.NewDecoder(body)
+	decoder.SetCustomStructTag("json")
+	// Integers are decoded as int64 and uint64, so values are normalized like json numbers.
+	decoder.UseLooseInterfaceDecoding(true)
+	code, err := decoder.PeekCode()
+	if err != nil {
+		return nil, false, err
+	}
+	if msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32 {
+		var records []models.Record
+		if err = decoder.Decode(&records); err != nil {
+			return nil, true, err
+		}
+		return records, true, nil
+	}
+	var record models.Record
+	if err = decoder.Decode(&record); err != nil {
+		return nil, false, err
+	}
+	return []models.Record{record}, false, nil
+}
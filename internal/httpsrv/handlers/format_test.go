@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/timeexpr"
+)
+
+// recordingSetter remembers created records.
+type recordingSetter struct {
+	mu      sync.Mutex
+	records []models.Record
+}
+
+func (mock *recordingSetter) Create(_ context.Context, record models.Record) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.records = append(mock.records, record)
+	return nil
+}
+
+// Validate rejects records without value.
+func (mock *recordingSetter) Validate(_ context.Context, record models.Record) error {
+	if record.MetricValue == nil {
+		return models.NewValidationError("metric_value", "must be set")
+	}
+	return nil
+}
+
+func (mock *recordingSetter) Admit(int) error {
+	return nil
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		accept   string
+		expected string
+	}{
+		{"text/csv", FormatCSV},
+		{"application/x-ndjson, application/json", FormatJSON},
+		{"application/json;q=0.5, application/x-protobuf", FormatProtobuf},
+		{"application/x-msgpack", FormatMsgPack},
+		{"application/*", FormatJSON},
+		{"text/*, application/json;q=0.1", FormatCSV},
+		{"*/*", FormatJSON},
+		// The most specific range wins.
+		{"*/*, application/json;q=0", FormatNDJSON},
+		{"TEXT/CSV; charset=utf-8", FormatCSV},
+		{"image/png", ""},
+		{"text/csv;q=0", ""},
+		{"text/csv;q=x", ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			format, ok := negotiateFormat(tc.accept)
+			require.Equal(t, tc.expected != "", ok)
+			require.Equal(t, tc.expected, format.name)
+		})
+	}
+}
+
+func TestRequestFormat(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		url         string
+		contentType string
+		expected    string
+		err         error
+	}{
+		{"/metrics", "", FormatJSON, nil},
+		{"/metrics", "application/json; charset=utf-8", FormatJSON, nil},
+		// Clients sent json with default content types before formats were negotiated.
+		{"/metrics", "application/x-www-form-urlencoded", FormatJSON, nil},
+		{"/metrics", "text/csv", FormatCSV, nil},
+		{"/metrics", "application/x-ndjson", FormatNDJSON, nil},
+		{"/metrics", "application/vnd.msgpack", FormatMsgPack, nil},
+		{"/metrics", "application/protobuf", FormatProtobuf, nil},
+		{"/metrics?format=csv", "application/json", FormatCSV, nil},
+		{"/metrics?format=xml", "", "", models.ErrInvalidArgument},
+		{"/metrics", "application/xml", "", models.ErrUnsupportedMediaType},
+		{"/metrics", "text/csv; =", "", models.ErrUnsupportedMediaType},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodPut, tc.url, nil)
+			req.Header.Set("Content-Type", tc.contentType)
+			format, err := requestFormat(req)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, format.name)
+		})
+	}
+}
+
+func TestFormats_RoundTrip(t *testing.T) {
+	t.Parallel()
+	histogram := models.Histogram{
+		Buckets: []models.Bucket{{UpperBound: 0.5, Count: 1}, {UpperBound: math.Inf(1), Count: 3}},
+		Count:   3,
+		Sum:     4.5,
+	}
+	testCases := []struct {
+		spec   models.ValueSpec
+		values []any
+	}{
+		{models.ValueSpec{Type: models.ValueTypeFloat}, []any{1.5, -2.0, math.Inf(-1)}},
+		{models.ValueSpec{Type: models.ValueTypeInt}, []any{int64(math.MaxInt64), int64(-7)}},
+		{models.ValueSpec{Type: models.ValueTypeBool}, []any{true, false}},
+		{models.ValueSpec{Type: models.ValueTypeString}, []any{"up", "a,b \"c\""}},
+		{models.ValueSpec{Type: models.ValueTypeHistogram}, []any{histogram}},
+	}
+
+	for _, format := range recordFormats {
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("%s case %d", format.name, i), func(t *testing.T) {
+				t.Parallel()
+				records := make([]models.Record, 0, len(tc.values))
+				for j, value := range tc.values {
+					records = append(records, models.Record{Timestamp: int64(j + 1), MetricValue: value})
+				}
+
+				var buf bytes.Buffer
+				require.NoError(t, format.encode(&buf, records))
+				decoded, batch, err := format.decode(&buf)
+				require.NoError(t, err)
+				require.True(t, batch)
+				require.Len(t, decoded, len(records))
+				for j := range decoded {
+					require.Equal(t, records[j].Timestamp, decoded[j].Timestamp)
+					value, err := tc.spec.Normalize(decoded[j].MetricValue)
+					require.NoError(t, err)
+					require.Equal(t, records[j].MetricValue, value)
+				}
+			})
+		}
+	}
+}
+
+func TestFormats_NaN(t *testing.T) {
+	t.Parallel()
+	for _, format := range recordFormats {
+		t.Run(format.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			require.NoError(t, format.encode(&buf, []models.Record{{Timestamp: 1, MetricValue: math.NaN()}}))
+			decoded, _, err := format.decode(&buf)
+			require.NoError(t, err)
+			value, err := models.ValueSpec{Type: models.ValueTypeFloat}.Normalize(decoded[0].MetricValue)
+			require.NoError(t, err)
+			require.True(t, math.IsNaN(value.(float64)))
+		})
+	}
+}
+
+func TestDecodeCSV(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		body     string
+		expected []models.Record
+		err      string
+	}{
+		{
+			"series,timestamp,metric_value\ncpu,1,1.5\nstatus,2,up\nstatus,3,\"\"\"1\"\"\"\n",
+			[]models.Record{
+				{Series: "cpu", Timestamp: 1, MetricValue: "1.5"},
+				{Series: "status", Timestamp: 2, MetricValue: "up"},
+				{Series: "status", Timestamp: 3, MetricValue: "1"},
+			},
+			"",
+		},
+		{"metric_value, Timestamp\ntrue,1\n", []models.Record{{Timestamp: 1, MetricValue: true}}, ""},
+		{"", nil, ""},
+		{"timestamp,value\n1,1\n", nil, `unknown column "value"`},
+		{"timestamp\n1\n", nil, `column "metric_value" is required`},
+		{"timestamp,metric_value\nnow,1\n", nil, `row 1: invalid timestamp "now"`},
+		{"timestamp,metric_value\n1,1,1\n", nil, "wrong number of fields"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			records, batch, err := decodeCSV(strings.NewReader(tc.body))
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, batch)
+			require.Len(t, records, len(tc.expected))
+			for j := range records {
+				require.Equal(t, tc.expected[j].Series, records[j].Series)
+				require.Equal(t, tc.expected[j].Timestamp, records[j].Timestamp)
+				require.Equal(t, fmt.Sprint(tc.expected[j].MetricValue), fmt.Sprint(records[j].MetricValue))
+			}
+		})
+	}
+}
+
+func TestDecodeNDJSON_Invalid(t *testing.T) {
+	t.Parallel()
+	// Records are counted from zero like in errors of other formats, a record may span lines.
+	_, _, err := decodeNDJSON(strings.NewReader("{\"timestamp\":1,\n\"metric_value\":1}\n{\"timestamp\":}\n"))
+	require.ErrorContains(t, err, "record 1: ")
+}
+
+func TestDecodeProtobuf_Invalid(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		body []byte
+		err  string
+	}{
+		// Truncated tag.
+		{[]byte{0x0a, 0x05, 0x10}, "unexpected EOF"},
+		// Timestamp with bytes wire type.
+		{[]byte{0x0a, 0x02, 0x12, 0x00}, "record 0: field 2: invalid wire type"},
+		{[]byte{0x08, 0x01}, "records: invalid wire type"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			_, _, err := decodeProtobuf(bytes.NewReader(tc.body))
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	// Unknown fields of newer schema are skipped.
+	records, _, err := decodeProtobuf(bytes.NewReader([]byte{0x0a, 0x04, 0x10, 0x01, 0x40, 0x01, 0x10, 0x02}))
+	require.NoError(t, err)
+	require.Equal(t, []models.Record{{Timestamp: 1}}, records)
+}
+
+func TestRRD_Formats(t *testing.T) {
+	t.Parallel()
+	setter := &recordingSetter{}
+	h := NewRRD(getterMock{}, setter, timeexpr.NewParser(time.UTC), slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	testCases := []struct {
+		method      string
+		url         string
+		header      string
+		value       string
+		body        string
+		status      int
+		contentType string
+	}{
+		{http.MethodGet, "/metrics", "Accept", "text/csv", "", http.StatusOK, "text/csv; charset=utf-8"},
+		{http.MethodGet, "/metrics?format=ndjson", "Accept", "text/csv", "", http.StatusOK, "application/x-ndjson"},
+		{http.MethodGet, "/metrics", "Accept", "application/msgpack", "", http.StatusOK, "application/msgpack"},
+		{http.MethodGet, "/metrics", "Accept", "image/png", "", http.StatusNotAcceptable, contentTypeProblem},
+		{http.MethodGet, "/metrics?format=xml", "", "", "", http.StatusBadRequest, contentTypeProblem},
+		{http.MethodGet, "/metrics?series=unencodable&format=protobuf", "", "", "", http.StatusInternalServerError, contentTypeProblem},
+		{http.MethodPut, "/metrics", "Content-Type", "text/csv", "timestamp,metric_value\n1,1.5\n2,2.5\n", http.StatusOK, ""},
+		{http.MethodPut, "/metrics", "Content-Type", "application/x-ndjson", "{\"timestamp\":3,\"metric_value\":1}\n", http.StatusOK, ""},
+		{http.MethodPut, "/metrics", "Content-Type", "text/csv", "timestamp,metric_value\n", http.StatusBadRequest, contentTypeProblem},
+		{http.MethodPut, "/metrics", "Content-Type", "application/xml", "<record/>", http.StatusUnsupportedMediaType, contentTypeProblem},
+	}
+
+	for i, tc := range testCases {
+		req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		rec := httptest.NewRecorder()
+		if tc.method == http.MethodGet {
+			h.GetByRange(rec, req)
+			require.Equal(t, "Accept", rec.Header().Get("Vary"), fmt.Sprintf("case %d", i))
+		} else {
+			h.Create(rec, req)
+		}
+		require.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		require.Equal(t, tc.contentType, rec.Header().Get("Content-Type"), fmt.Sprintf("case %d", i))
+	}
+	require.Len(t, setter.records, 3)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type HealthChecker interface {
+	Ready(ctx context.Context) models.Readiness
+	Status(ctx context.Context) (models.Status, error)
+}
+
+// Health contains handlers for orchestrator probes and service status.
+type Health struct {
+	checker HealthChecker
+	logger  *slog.Logger
+}
+
+// NewHealth returns new health handlers struct.
+func NewHealth(checker HealthChecker, logger *slog.Logger) *Health {
+	return &Health{
+		checker: checker,
+		logger:  logger,
+	}
+}
+
+// Live reports that process is alive, it doesn't check dependencies,
+// so orchestrator doesn't restart the service when storage is down.
+func (h *Health) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, h.logger, http.StatusOK, models.NewReadiness(make([]models.Check, 0)))
+}
+
+// Ready reports whether service can serve requests, it returns 503 if any dependency check fails.
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	readiness := h.checker.Ready(r.Context())
+	code := http.StatusOK
+	if readiness.Status != models.CheckStatusOK {
+		code = http.StatusServiceUnavailable
+		h.logger.WarnContext(r.Context(), "service is not ready", slog.Any("checks", readiness.Checks))
+	}
+	writeJSON(w, r, h.logger, code, readiness)
+}
+
+// Status returns detailed service status.
+func (h *Health) Status(w http.ResponseWriter, r *http.Request) {
+	status, err := h.checker.Status(r.Context())
+	if err != nil {
+		writeError(w, r, h.logger, "failed to get status", err)
+		return
+	}
+	writeJSON(w, r, h.logger, http.StatusOK, status)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type healthCheckerMock struct {
+	checks []models.Check
+	err    error
+}
+
+func (mock healthCheckerMock) Ready(_ context.Context) models.Readiness {
+	return models.NewReadiness(mock.checks)
+}
+
+func (mock healthCheckerMock) Status(ctx context.Context) (models.Status, error) {
+	if mock.err != nil {
+		return models.Status{}, mock.err
+	}
+	return models.Status{
+		Version:   "v1.0.0",
+		StartedAt: 1717745157997559,
+		Uptime:    60,
+		Readiness: mock.Ready(ctx),
+		Series:    []models.SeriesStatus{{Name: models.DefaultSeriesName, Points: 10, Capacity: 1000}},
+	}, nil
+}
+
+var (
+	checkOK     = models.Check{Name: "aerospike_cluster", Status: models.CheckStatusOK}
+	checkFailed = models.Check{Name: "aerospike_index", Status: models.CheckStatusFailed, Error: "index not found"}
+)
+
+func TestHealth_Live(t *testing.T) {
+	t.Parallel()
+	h := NewHealth(healthCheckerMock{checks: []models.Check{checkFailed}}, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	// Live doesn't depend on storage checks.
+	apitest.New().
+		HandlerFunc(h.Live).
+		Get("/healthz").
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{"status":"ok","checks":[]}`).
+		End()
+}
+
+func TestHealth_Ready(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		checks []models.Check
+		status int
+		body   string
+	}{
+		{
+			[]models.Check{checkOK},
+			http.StatusOK,
+			`{"status":"ok","checks":[{"name":"aerospike_cluster","status":"ok"}]}`,
+		},
+		{
+			[]models.Check{checkOK, checkFailed},
+			http.StatusServiceUnavailable,
+			`{"status":"failed","checks":[{"name":"aerospike_cluster","status":"ok"},` +
+				`{"name":"aerospike_index","status":"failed","error":"index not found"}]}`,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			h := NewHealth(healthCheckerMock{checks: tc.checks}, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+			apitest.New().
+				HandlerFunc(h.Ready).
+				Get("/readyz").
+				Expect(t).
+				Status(tc.status).
+				Body(tc.body).
+				End()
+		})
+	}
+}
+
+func TestHealth_Status(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		err    error
+		status int
+		body   string
+	}{
+		{
+			nil,
+			http.StatusOK,
+			`{"version":"v1.0.0","started_at":1717745157997559,"uptime_seconds":60,"status":"ok",` +
+				`"checks":[{"name":"aerospike_cluster","status":"ok"}],` +
+				`"series":[{"name":"default","points":10,"capacity":1000}]}`,
+		},
+		{
+			errTest,
+			http.StatusInternalServerError,
+			"",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			h := NewHealth(healthCheckerMock{checks: []models.Check{checkOK}, err: tc.err},
+				slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+			result := apitest.New().
+				HandlerFunc(h.Status).
+				Get("/status").
+				Expect(t).
+				Status(tc.status)
+			if tc.body != "" {
+				result = result.Body(tc.body)
+			}
+			result.End()
+		})
+	}
+}
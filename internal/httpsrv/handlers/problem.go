@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const (
+	// HeaderRequestID contains request id, it is taken from request or generated.
+	HeaderRequestID = "X-Request-ID"
+
+	contentTypeProblem = "application/problem+json"
+	// maxRequestIDLength limits request id from client, longer ids are replaced.
+	maxRequestIDLength = 128
+
+	problemTypeValidation       = "/problems/validation-error"
+	problemTypeNotFound         = "/problems/not-found"
+	problemTypeConflict         = "/problems/conflict"
+	problemTypeUnavailable      = "/problems/unavailable"
+	problemTypeOverloaded       = "/problems/overloaded"
+	problemTypeUnauthenticated  = "/problems/unauthenticated"
+	problemTypeForbidden        = "/problems/forbidden"
+	problemTypeQuotaExceeded    = "/problems/quota-exceeded"
+	problemTypeRateLimited      = "/problems/rate-limited"
+	problemTypeTooLarge         = "/problems/too-large"
+	problemTypeUnsupportedMedia = "/problems/unsupported-media-type"
+	problemTypeNotAcceptable    = "/problems/not-acceptable"
+	problemTypeMethodNotAllowed = "/problems/method-not-allowed"
+	problemTypeInternal         = "/problems/internal-error"
+)
+
+// Problem is an error response body according to RFC 7807.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []models.FieldError `json:"errors,omitempty"`
+	// Accepted is a number of saved records of a batch that failed, records before the failed one are saved.
+	Accepted *int `json:"accepted,omitempty"`
+	// retryAfter is sent in Retry-After header of 429 and 503 responses, at least one second.
+	retryAfter time.Duration
+}
+
+// newProblem maps error to problem details. Details of internal errors are not exposed to clients.
+func newProblem(err error) *Problem {
+	problem := problemOf(err)
+	var retryErr *models.RetryError
+	if errors.As(err, &retryErr) {
+		problem.retryAfter = retryErr.After
+	}
+	return problem
+}
+
+func problemOf(err error) *Problem {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return &Problem{
+			Type:   problemTypeValidation,
+			Title:  "Request validation failed",
+			Status: http.StatusBadRequest,
+			Detail: validationErr.Error(),
+			Errors: validationErr.Fields,
+		}
+	case errors.Is(err, models.ErrTooLarge):
+		return &Problem{
+			Type:   problemTypeTooLarge,
+			Title:  "Request too large",
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: detailOf(err, models.ErrTooLarge),
+		}
+	case errors.Is(err, models.ErrUnsupportedMediaType):
+		return &Problem{
+			Type:   problemTypeUnsupportedMedia,
+			Title:  "Unsupported media type",
+			Status: http.StatusUnsupportedMediaType,
+			Detail: detailOf(err, models.ErrUnsupportedMediaType),
+		}
+	case errors.Is(err, models.ErrNotAcceptable):
+		return &Problem{
+			Type:   problemTypeNotAcceptable,
+			Title:  "Not acceptable",
+			Status: http.StatusNotAcceptable,
+			Detail: detailOf(err, models.ErrNotAcceptable),
+		}
+	case errors.Is(err, models.ErrInvalidArgument):
+		return &Problem{
+			Type:   problemTypeValidation,
+			Title:  "Request validation failed",
+			Status: http.StatusBadRequest,
+			Detail: detailOf(err, models.ErrInvalidArgument),
+		}
+	case errors.Is(err, models.ErrNotFound):
+		return &Problem{
+			Type:   problemTypeNotFound,
+			Title:  "Not found",
+			Status: http.StatusNotFound,
+			Detail: detailOf(err, models.ErrNotFound),
+		}
+	case errors.Is(err, models.ErrConflict):
+		return &Problem{
+			Type:   problemTypeConflict,
+			Title:  "Conflict",
+			Status: http.StatusConflict,
+			Detail: detailOf(err, models.ErrConflict),
+		}
+	case errors.Is(err, models.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return &Problem{
+			Type:   problemTypeUnavailable,
+			Title:  "Service unavailable",
+			Status: http.StatusServiceUnavailable,
+			Detail: "storage is temporary unavailable, retry later",
+		}
+	case errors.Is(err, models.ErrOverloaded):
+		return &Problem{
+			Type:   problemTypeOverloaded,
+			Title:  "Too many requests",
+			Status: http.StatusTooManyRequests,
+			Detail: "write queue is full, retry later",
+		}
+	case errors.Is(err, models.ErrUnauthenticated):
+		return &Problem{
+			Type:   problemTypeUnauthenticated,
+			Title:  "Unauthenticated",
+			Status: http.StatusUnauthorized,
+			Detail: detailOf(err, models.ErrUnauthenticated),
+		}
+	case errors.Is(err, models.ErrForbidden):
+		return &Problem{
+			Type:   problemTypeForbidden,
+			Title:  "Forbidden",
+			Status: http.StatusForbidden,
+			Detail: detailOf(err, models.ErrForbidden),
+		}
+	case errors.Is(err, models.ErrQuotaExceeded):
+		return &Problem{
+			Type:   problemTypeQuotaExceeded,
+			Title:  "Quota exceeded",
+			Status: http.StatusForbidden,
+			Detail: detailOf(err, models.ErrQuotaExceeded),
+		}
+	case errors.Is(err, models.ErrRateLimited):
+		return &Problem{
+			Type:   problemTypeRateLimited,
+			Title:  "Too many requests",
+			Status: http.StatusTooManyRequests,
+			Detail: detailOf(err, models.ErrRateLimited),
+		}
+	default:
+		return &Problem{
+			Type:   problemTypeInternal,
+			Title:  "Internal server error",
+			Status: http.StatusInternalServerError,
+		}
+	}
+}
+
+// detailOf returns a message of the error for clients. Messages of wrapping errors may have internal details like
+// storage names and errors, so only a message of DetailError or the sentinel error is returned.
+func detailOf(err, sentinel error) string {
+	var detailErr *models.DetailError
+	if errors.As(err, &detailErr) {
+		return detailErr.Error()
+	}
+	return sentinel.Error()
+}
+
+// writeError logs error, records it in the span of the request and writes it as problem details.
+func writeError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, msg string, err error, attrs ...any) {
+	trace.SpanFromContext(r.Context()).RecordError(err)
+	writeProblem(w, r, logger, msg, newProblem(err), append(attrs, slog.Any("error", err))...)
+}
+
+// WriteError logs error and writes it as problem details, it is used by middlewares.
+func WriteError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, msg string, err error, attrs ...any) {
+	writeError(w, r, logger, msg, err, attrs...)
+}
+
+// writeProblem logs problem and writes it to response.
+func writeProblem(w http.ResponseWriter, r *http.Request, logger *slog.Logger, msg string, problem *Problem,
+	attrs ...any,
+) {
+	problem.Instance = r.URL.Path
+	problem.RequestID = RequestID(w, r)
+
+	// Request id is added to the log by logger handler from context.
+	ctx := r.Context()
+	if models.RequestIDFromContext(ctx) == "" {
+		ctx = models.WithRequestID(ctx, problem.RequestID)
+	}
+	attrs = append(attrs, slog.Int("status", problem.Status))
+	if problem.Status >= http.StatusInternalServerError {
+		trace.SpanFromContext(ctx).SetStatus(codes.Error, msg)
+		logger.ErrorContext(ctx, msg, attrs...)
+	} else {
+		logger.WarnContext(ctx, msg, attrs...)
+	}
+
+	w.Header().Set("Content-Type", contentTypeProblem)
+	if problem.Status == http.StatusServiceUnavailable || problem.Status == http.StatusTooManyRequests {
+		seconds := max(int64(math.Ceil(problem.retryAfter.Seconds())), 1)
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		logger.ErrorContext(ctx, "failed to encode problem", slog.Any("error", err))
+	}
+}
+
+// decodeError converts json decoding error to validation error, or to models.ErrTooLarge if body exceeds limit.
+func decodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return models.NewDetailError(models.ErrTooLarge, "body exceeds %d bytes", tooLarge.Limit)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return models.NewValidationError(typeErr.Field, "must be of type %s", typeErr.Type)
+	}
+	return models.NewValidationError("body", "failed to decode request: %s", err)
+}
+
+// RequestID returns request id from context or request header, or generates a new one.
+// Request id is also set to response headers, so clients can report it.
+func RequestID(w http.ResponseWriter, r *http.Request) string {
+	if id := models.RequestIDFromContext(r.Context()); id != "" {
+		return id
+	}
+	if id := w.Header().Get(HeaderRequestID); id != "" {
+		return id
+	}
+	id := r.Header.Get(HeaderRequestID)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(HeaderRequestID, id)
+	return id
+}
+
+// validRequestID checks that request id from client is safe to log and to return in headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	// rand.Read never returns an error on supported platforms.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NotFound writes problem for unknown routes.
+func NotFound(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, logger, "route not found", &Problem{
+			Type:   problemTypeNotFound,
+			Title:  "Not found",
+			Status: http.StatusNotFound,
+			Detail: "route not found",
+		}, slog.String("path", r.URL.Path))
+	}
+}
+
+// MethodNotAllowed writes problem for known routes with unsupported method.
+func MethodNotAllowed(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, logger, "method not allowed", methodNotAllowedProblem(r.Method),
+			slog.String("method", r.Method))
+	}
+}
+
+func methodNotAllowedProblem(method string) *Problem {
+	return &Problem{
+		Type:   problemTypeMethodNotAllowed,
+		Title:  "Method not allowed",
+		Status: http.StatusMethodNotAllowed,
+		Detail: "method " + method + " is not allowed",
+	}
+}
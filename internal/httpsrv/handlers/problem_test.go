@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func TestNewProblem(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		err        error
+		statusCode int
+		fields     int
+	}{
+		{models.NewValidationError("start", "must not be negative"), http.StatusBadRequest, 1},
+		{fmt.Errorf("wrapped: %w", models.NewValidationError("end", "invalid")), http.StatusBadRequest, 1},
+		{fmt.Errorf("%w: bad range", models.ErrInvalidArgument), http.StatusBadRequest, 0},
+		{fmt.Errorf("%w: key", models.ErrNotFound), http.StatusNotFound, 0},
+		{fmt.Errorf("%w: key", models.ErrConflict), http.StatusConflict, 0},
+		{fmt.Errorf("%w: timeout", models.ErrUnavailable), http.StatusServiceUnavailable, 0},
+		{fmt.Errorf("%w: queue is full", models.ErrOverloaded), http.StatusTooManyRequests, 0},
+		{fmt.Errorf("%w: invalid api key", models.ErrUnauthenticated), http.StatusUnauthorized, 0},
+		{fmt.Errorf("%w: write scope is required", models.ErrForbidden), http.StatusForbidden, 0},
+		{fmt.Errorf("%w: max series", models.ErrQuotaExceeded), http.StatusForbidden, 0},
+		{fmt.Errorf("%w: ingest rate", models.ErrRateLimited), http.StatusTooManyRequests, 0},
+		{fmt.Errorf("%w: body", models.ErrTooLarge), http.StatusRequestEntityTooLarge, 0},
+		{decodeError(&http.MaxBytesError{Limit: 10}), http.StatusRequestEntityTooLarge, 0},
+		{fmt.Errorf("%w: content type", models.ErrUnsupportedMediaType), http.StatusUnsupportedMediaType, 0},
+		{fmt.Errorf("%w: accept", models.ErrNotAcceptable), http.StatusNotAcceptable, 0},
+		{errTest, http.StatusInternalServerError, 0},
+	}
+
+	for i, tt := range testCases {
+		problem := newProblem(tt.err)
+		require.Equal(t, tt.statusCode, problem.Status, fmt.Sprintf("case %d", i))
+		require.Len(t, problem.Errors, tt.fields, fmt.Sprintf("case %d", i))
+		require.NotEmpty(t, problem.Type, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	var validationErr models.ValidationError
+	validationErr.Add("start", "must not be negative")
+	validationErr.Add("end", "must not be negative")
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set(HeaderRequestID, "test-request-id")
+	w := httptest.NewRecorder()
+	writeError(w, r, logger, "test", &validationErr)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, contentTypeProblem, w.Header().Get("Content-Type"))
+	require.Equal(t, "test-request-id", w.Header().Get(HeaderRequestID))
+
+	var problem Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	require.Equal(t, problemTypeValidation, problem.Type)
+	require.Equal(t, "/metrics", problem.Instance)
+	require.Equal(t, "test-request-id", problem.RequestID)
+	require.Equal(t, validationErr.Fields, problem.Errors)
+}
+
+func TestWriteError_HidesInternalDetails(t *testing.T) {
+	t.Parallel()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	r := httptest.NewRequest(http.MethodPut, "/metrics", nil)
+	w := httptest.NewRecorder()
+	writeError(w, r, logger, "test", errTest)
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NotEmpty(t, w.Header().Get(HeaderRequestID))
+
+	var problem Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	require.Empty(t, problem.Detail)
+	require.Equal(t, w.Header().Get(HeaderRequestID), problem.RequestID)
+}
+
+func TestNewProblem_Detail(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		err    error
+		detail string
+	}{
+		{fmt.Errorf("failed to get series acme/cpu: %w: key not found", models.ErrNotFound), "not found"},
+		{fmt.Errorf("failed to get series acme/cpu: %w", models.NewDetailError(models.ErrNotFound, "rule cpu")),
+			"not found: rule cpu"},
+		{fmt.Errorf("wrapped: %w", models.NewValidationError("end", "invalid")), "invalid argument: end: invalid"},
+		{models.NewRetryError(time.Second, models.NewDetailError(models.ErrRateLimited, "rate limit of put")),
+			"rate limited: rate limit of put"},
+	}
+
+	for i, tt := range testCases {
+		require.Equal(t, tt.detail, newProblem(tt.err).Detail, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestWriteError_RetryAfter(t *testing.T) {
+	t.Parallel()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	testCases := []struct {
+		err        error
+		retryAfter string
+	}{
+		{fmt.Errorf("%w: timeout", models.ErrUnavailable), "1"},
+		{models.NewRetryError(2500*time.Millisecond, models.ErrRateLimited), "3"},
+		{models.NewRetryError(time.Millisecond, models.ErrRateLimited), "1"},
+		{fmt.Errorf("%w: body", models.ErrTooLarge), ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			w := httptest.NewRecorder()
+			writeError(w, httptest.NewRequest(http.MethodPut, "/metrics", nil), logger, "test", tc.err)
+			require.Equal(t, tc.retryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
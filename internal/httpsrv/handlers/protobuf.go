@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Field numbers of messages of api/proto/rrd/v1/records.proto. Messages are small, so they are encoded
+// with protowire instead of generated code.
+const (
+	fieldRecords = 1
+
+	fieldRecordSeries    = 1
+	fieldRecordTimestamp = 2
+	fieldRecordFloat     = 3
+	fieldRecordInt       = 4
+	fieldRecordBool      = 5
+	fieldRecordString    = 6
+	fieldRecordHistogram = 7
+
+	fieldHistogramBuckets = 1
+	fieldHistogramCount   = 2
+	fieldHistogramSum     = 3
+
+	fieldBucketUpperBound = 1
+	fieldBucketCount      = 2
+)
+
+var errInvalidWireType = errors.New("invalid wire type")
+
+// encodeProtobuf writes records as rrd.v1.Records message.
+func encodeProtobuf(w io.Writer, records []models.Record) error {
+	var buf []byte
+	for _, record := range records {
+		message, err := appendRecord(nil, record)
+		if err != nil {
+			return err
+		}
+		buf = protowire.AppendTag(buf, fieldRecords, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, message)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func appendRecord(buf []byte, record models.Record) ([]byte, error) {
+	if record.Series != "" {
+		buf = protowire.AppendTag(buf, fieldRecordSeries, protowire.BytesType)
+		buf = protowire.AppendString(buf, record.Series)
+	}
+	buf = protowire.AppendTag(buf, fieldRecordTimestamp, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, uint64(record.Timestamp))
+
+	switch value := record.MetricValue.(type) {
+	case float64:
+		buf = protowire.AppendTag(buf, fieldRecordFloat, protowire.Fixed64Type)
+		buf = protowire.AppendFixed64(buf, math.Float64bits(value))
+	case int64:
+		buf = protowire.AppendTag(buf, fieldRecordInt, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, uint64(value))
+	case bool:
+		buf = protowire.AppendTag(buf, fieldRecordBool, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, protowire.EncodeBool(value))
+	case string:
+		buf = protowire.AppendTag(buf, fieldRecordString, protowire.BytesType)
+		buf = protowire.AppendString(buf, value)
+	case models.Histogram:
+		buf = protowire.AppendTag(buf, fieldRecordHistogram, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, appendHistogram(nil, value))
+	default:
+		return nil, fmt.Errorf("unsupported metric value %T", record.MetricValue)
+	}
+	return buf, nil
+}
+
+func appendHistogram(buf []byte, h models.Histogram) []byte {
+	for _, bucket := range h.Buckets {
+		var message []byte
+		message = protowire.AppendTag(message, fieldBucketUpperBound, protowire.Fixed64Type)
+		message = protowire.AppendFixed64(message, math.Float64bits(bucket.UpperBound))
+		message = protowire.AppendTag(message, fieldBucketCount, protowire.VarintType)
+		message = protowire.AppendVarint(message, bucket.Count)
+
+		buf = protowire.AppendTag(buf, fieldHistogramBuckets, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, message)
+	}
+	buf = protowire.AppendTag(buf, fieldHistogramCount, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, h.Count)
+	buf = protowire.AppendTag(buf, fieldHistogramSum, protowire.Fixed64Type)
+	return protowire.AppendFixed64(buf, math.Float64bits(h.Sum))
+}
+
+// decodeProtobuf reads rrd.v1.Records message, body is always a batch.
+func decodeProtobuf(body io.Reader) ([]models.Record, bool, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, true, err
+	}
+	var records []models.Record
+	err = consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+		if num != fieldRecords {
+			return skipField(num, typ, value)
+		}
+		if typ != protowire.BytesType {
+			return 0, fmt.Errorf("records: %w %d", errInvalidWireType, typ)
+		}
+		message, n := protowire.ConsumeBytes(value)
+		if n < 0 {
+			return n, nil
+		}
+		record, err := decodeRecord(message)
+		if err != nil {
+			return 0, fmt.Errorf("record %d: %w", len(records), err)
+		}
+		records = append(records, record)
+		return n, nil
+	})
+	if err != nil {
+		return nil, true, err
+	}
+	return records, true, nil
+}
+
+func decodeRecord(data []byte) (models.Record, error) {
+	var record models.Record
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+		switch {
+		case num == fieldRecordSeries && typ == protowire.BytesType:
+			series, n := protowire.ConsumeString(value)
+			record.Series = series
+			return n, nil
+		case num == fieldRecordTimestamp && typ == protowire.VarintType:
+			timestamp, n := protowire.ConsumeVarint(value)
+			record.Timestamp = int64(timestamp)
+			return n, nil
+		case num == fieldRecordFloat && typ == protowire.Fixed64Type:
+			bits, n := protowire.ConsumeFixed64(value)
+			record.MetricValue = math.Float64frombits(bits)
+			return n, nil
+		case num == fieldRecordInt && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(value)
+			record.MetricValue = int64(v)
+			return n, nil
+		case num == fieldRecordBool && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(value)
+			record.MetricValue = protowire.DecodeBool(v)
+			return n, nil
+		case num == fieldRecordString && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(value)
+			record.MetricValue = v
+			return n, nil
+		case num == fieldRecordHistogram && typ == protowire.BytesType:
+			message, n := protowire.ConsumeBytes(value)
+			if n < 0 {
+				return n, nil
+			}
+			h, err := decodeHistogram(message)
+			record.MetricValue = h
+			return n, err
+		case num <= fieldRecordHistogram:
+			return 0, fmt.Errorf("field %d: %w %d", num, errInvalidWireType, typ)
+		default:
+			return skipField(num, typ, value)
+		}
+	})
+	return record, err
+}
+
+func decodeHistogram(data []byte) (models.Histogram, error) {
+	var h models.Histogram
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+		switch {
+		case num == fieldHistogramBuckets && typ == protowire.BytesType:
+			message, n := protowire.ConsumeBytes(value)
+			if n < 0 {
+				return n, nil
+			}
+			bucket, err := decodeBucket(message)
+			h.Buckets = append(h.Buckets, bucket)
+			return n, err
+		case num == fieldHistogramCount && typ == protowire.VarintType:
+			count, n := protowire.ConsumeVarint(value)
+			h.Count = count
+			return n, nil
+		case num == fieldHistogramSum && typ == protowire.Fixed64Type:
+			bits, n := protowire.ConsumeFixed64(value)
+			h.Sum = math.Float64frombits(bits)
+			return n, nil
+		case num <= fieldHistogramSum:
+			return 0, fmt.Errorf("histogram field %d: %w %d", num, errInvalidWireType, typ)
+		default:
+			return skipField(num, typ, value)
+		}
+	})
+	return h, err
+}
+
+func decodeBucket(data []byte) (models.Bucket, error) {
+	var bucket models.Bucket
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+		switch {
+		case num == fieldBucketUpperBound && typ == protowire.Fixed64Type:
+			bits, n := protowire.ConsumeFixed64(value)
+			bucket.UpperBound = math.Float64frombits(bits)
+			return n, nil
+		case num == fieldBucketCount && typ == protowire.VarintType:
+			count, n := protowire.ConsumeVarint(value)
+			bucket.Count = count
+			return n, nil
+		case num <= fieldBucketCount:
+			return 0, fmt.Errorf("bucket field %d: %w %d", num, errInvalidWireType, typ)
+		default:
+			return skipField(num, typ, value)
+		}
+	})
+	return bucket, err
+}
+
+// consumeFields calls fn for each field of a message, fn returns length of consumed value or negative
+// protowire error code.
+func consumeFields(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		m, err := fn(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		data = data[m:]
+	}
+	return nil
+}
+
+// skipField consumes value of unknown field, so messages of newer schema versions can be decoded.
+func skipField(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+	return protowire.ConsumeFieldValue(num, typ, value), nil
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const recordsProto = "../../../api/proto/rrd/v1/records.proto"
+
+var (
+	protoMessage = regexp.MustCompile(`^message (\w+) \{$`)
+	protoField   = regexp.MustCompile(`^(repeated )?(\w+) (\w+) = (\d+);$`)
+)
+
+func TestProtobuf_Schema(t *testing.T) {
+	t.Parallel()
+	file, err := os.Open(recordsProto)
+	require.NoError(t, err)
+	defer file.Close()
+
+	// Fields are declared one per line, so they are compared without a proto parser.
+	var expected []string
+	message := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := protoMessage.FindStringSubmatch(line); match != nil {
+			message = match[1]
+		} else if match := protoField.FindStringSubmatch(line); match != nil {
+			expected = append(expected, fmt.Sprintf("%s.%s %s%s = %s", message, match[3], match[1], match[2], match[4]))
+		}
+	}
+	require.NoError(t, scanner.Err())
+
+	var actual []string
+	messages := recordsDescriptor(t).Messages()
+	for i := range messages.Len() {
+		fields := messages.Get(i).Fields()
+		for j := range fields.Len() {
+			field := fields.Get(j)
+			label := ""
+			if field.Cardinality() == protoreflect.Repeated {
+				label = "repeated "
+			}
+			typ := field.Kind().String()
+			if field.Kind() == protoreflect.MessageKind {
+				typ = string(field.Message().Name())
+			}
+			actual = append(actual, fmt.Sprintf("%s.%s %s%s = %d", messages.Get(i).Name(), field.Name(), label, typ,
+				field.Number()))
+		}
+	}
+	require.ElementsMatch(t, expected, actual)
+}
+
+func TestProtobuf_RoundTrip(t *testing.T) {
+	t.Parallel()
+	records := []models.Record{
+		{Series: "cpu", Timestamp: 1, MetricValue: 0.5},
+		{Timestamp: -1, MetricValue: math.Inf(-1)},
+		{Series: "requests", Timestamp: math.MaxInt64, MetricValue: int64(-5)},
+		{Series: "up", Timestamp: 3, MetricValue: false},
+		{Series: "up", Timestamp: 4, MetricValue: true},
+		{Series: "status", Timestamp: 5, MetricValue: ""},
+		{Series: "status", Timestamp: 6, MetricValue: "ok"},
+		{Series: "latency", Timestamp: 7, MetricValue: models.Histogram{
+			Buckets: []models.Bucket{{UpperBound: 0.1, Count: 0}, {UpperBound: math.Inf(1), Count: 3}},
+			Count:   3,
+			Sum:     1.5,
+		}},
+	}
+	expected := recordsMessage(t, records)
+
+	// Encoded records are decoded by protobuf runtime.
+	var buf bytes.Buffer
+	require.NoError(t, encodeProtobuf(&buf, records))
+	message := dynamicpb.NewMessage(expected.Descriptor())
+	require.NoError(t, proto.Unmarshal(buf.Bytes(), message))
+	require.True(t, proto.Equal(expected, message), "decoded %v, expected %v", message, expected)
+
+	// Records encoded by protobuf runtime are decoded.
+	body, err := proto.Marshal(expected)
+	require.NoError(t, err)
+	decoded, batch, err := decodeProtobuf(bytes.NewReader(body))
+	require.NoError(t, err)
+	require.True(t, batch)
+	require.Equal(t, records, decoded)
+}
+
+// recordsDescriptor returns descriptor of api/proto/rrd/v1/records.proto.
+func recordsDescriptor(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, message string,
+	) *descriptorpb.FieldDescriptorProto {
+		field := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+		if message != "" {
+			field.TypeName = proto.String(".rrd.v1." + message)
+		}
+		return field
+	}
+	repeated := func(field *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return field
+	}
+	oneof := func(field *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		field.OneofIndex = proto.Int32(0)
+		return field
+	}
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("rrd/v1/records.proto"),
+		Package: proto.String("rrd.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Records"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				repeated(field("records", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, "Record")),
+			},
+		}, {
+			Name: proto.String("Record"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("series", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("timestamp", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+				oneof(field("float_value", 3, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, "")),
+				oneof(field("int_value", 4, descriptorpb.FieldDescriptorProto_TYPE_INT64, "")),
+				oneof(field("bool_value", 5, descriptorpb.FieldDescriptorProto_TYPE_BOOL, "")),
+				oneof(field("string_value", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")),
+				oneof(field("histogram_value", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, "Histogram")),
+			},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("metric_value")}},
+		}, {
+			Name: proto.String("Histogram"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				repeated(field("buckets", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, "Bucket")),
+				field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64, ""),
+				field("sum", 3, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, ""),
+			},
+		}, {
+			Name: proto.String("Bucket"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("le", 1, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, ""),
+				field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64, ""),
+			},
+		}},
+	}, nil)
+	require.NoError(t, err)
+	return file
+}
+
+// recordsMessage returns records as dynamic rrd.v1.Records message.
+func recordsMessage(t *testing.T, records []models.Record) *dynamicpb.Message {
+	t.Helper()
+	message := dynamicpb.NewMessage(recordsDescriptor(t).Messages().ByName("Records"))
+	list := message.Mutable(message.Descriptor().Fields().ByName("records")).List()
+	for _, record := range records {
+		element := list.NewElement().Message()
+		fields := element.Descriptor().Fields()
+		element.Set(fields.ByName("series"), protoreflect.ValueOfString(record.Series))
+		element.Set(fields.ByName("timestamp"), protoreflect.ValueOfInt64(record.Timestamp))
+		switch value := record.MetricValue.(type) {
+		case float64:
+			element.Set(fields.ByName("float_value"), protoreflect.ValueOfFloat64(value))
+		case int64:
+			element.Set(fields.ByName("int_value"), protoreflect.ValueOfInt64(value))
+		case bool:
+			element.Set(fields.ByName("bool_value"), protoreflect.ValueOfBool(value))
+		case string:
+			element.Set(fields.ByName("string_value"), protoreflect.ValueOfString(value))
+		case models.Histogram:
+			h := element.Mutable(fields.ByName("histogram_value")).Message()
+			hFields := h.Descriptor().Fields()
+			buckets := h.Mutable(hFields.ByName("buckets")).List()
+			for _, bucket := range value.Buckets {
+				b := buckets.NewElement().Message()
+				b.Set(b.Descriptor().Fields().ByName("le"), protoreflect.ValueOfFloat64(bucket.UpperBound))
+				b.Set(b.Descriptor().Fields().ByName("count"), protoreflect.ValueOfUint64(bucket.Count))
+				buckets.Append(protoreflect.ValueOfMessage(b))
+			}
+			h.Set(hFields.ByName("count"), protoreflect.ValueOfUint64(value.Count))
+			h.Set(hFields.ByName("sum"), protoreflect.ValueOfFloat64(value.Sum))
+		default:
+			t.Fatalf("unsupported metric value %T", record.MetricValue)
+		}
+		list.Append(protoreflect.ValueOfMessage(element))
+	}
+	return message
+}
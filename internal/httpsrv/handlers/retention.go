@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type RetentionStatsGetter interface {
+	Stats() models.RetentionStats
+}
+
+// Retention contains handlers for retention statistics.
+type Retention struct {
+	getter RetentionStatsGetter
+	logger *slog.Logger
+}
+
+// NewRetention returns new retention handlers struct.
+func NewRetention(getter RetentionStatsGetter, logger *slog.Logger) *Retention {
+	return &Retention{
+		getter: getter,
+		logger: logger,
+	}
+}
+
+// Stats returns number of points expired and evicted by retention policies.
+func (h *Retention) Stats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.getter.Stats()); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode retention stats", slog.Any("error", err))
+	}
+}
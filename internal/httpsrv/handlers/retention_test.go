@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type retentionStatsMock struct{}
+
+func (mock retentionStatsMock) Stats() models.RetentionStats {
+	return models.RetentionStats{
+		Sweeps:  1,
+		Expired: 2,
+		Evicted: 3,
+		Series: map[string]models.SeriesRetentionStats{
+			models.DefaultSeriesName: {Points: 10, Expired: 2, Evicted: 3},
+		},
+	}
+}
+
+func TestRetention_Stats(t *testing.T) {
+	t.Parallel()
+	h := NewRetention(retentionStatsMock{}, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	apitest.New().
+		HandlerFunc(h.Stats).
+		Get("/retention").
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{"sweeps":1,"last_sweep_at":0,"last_sweep_duration_ms":0,"expired":2,"evicted":3,` +
+			`"series":{"default":{"points":10,"expired":2,"evicted":3}}}`).
+		End()
+}
@@ -1,141 +1,574 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/timeexpr"
+)
+
+var tracer = otel.Tracer("aerospike.com/rrd/internal/httpsrv/handlers")
+
+// HeaderNextPageToken contains token of the next page of records, it is set if there are more records.
+const HeaderNextPageToken = "X-Next-Page-Token"
+
+// Limits of a page of records of API v1.
+const (
+	defaultRecordPageLimit = 1000
+	maxRecordPageLimit     = 10000
 )
 
+// maxExprStep keeps step of expressions in microseconds within int64.
+const maxExprStep = math.MaxInt64 / int64(time.Second/time.Microsecond)
+
 type RRDGetter interface {
-	GetByRange(ctx context.Context, start, end int64) ([]models.Record, error)
+	GetByRange(ctx context.Context, series string, start, end int64) ([]models.Record, error)
+	ListRecords(ctx context.Context, series string, start, end int64, limit int) ([]models.Record, error)
+	Evaluate(ctx context.Context, query models.ExprQuery) ([]models.Record, error)
 }
 
 type RRDSetter interface {
 	Create(ctx context.Context, record models.Record) error
+	Validate(ctx context.Context, record models.Record) error
+	Admit(n int) error
+}
+
+// RRDLimits limit size of requests, zero means no limit.
+type RRDLimits struct {
+	// MaxBatchPoints is a max number of records in a request.
+	MaxBatchPoints int
+	// MaxRangeSpan is a max time between start and end of a query.
+	MaxRangeSpan time.Duration
 }
 
 // RRD contains handlers for processing http requests.
 type RRD struct {
-	getter RRDGetter
-	setter RRDSetter
-	logger *slog.Logger
+	getter     RRDGetter
+	setter     RRDSetter
+	timeParser *timeexpr.Parser
+	logger     *slog.Logger
+	// async means that records are saved after response, so Create responds with 202 Accepted.
+	async bool
+	// Limits can be changed on config reload.
+	maxBatchPoints atomic.Int64
+	maxRangeSpan   atomic.Int64
 }
 
 // NewRRD returns new handlers struct.
-func NewRRD(getter RRDGetter, setter RRDSetter, logger *slog.Logger) *RRD {
+func NewRRD(getter RRDGetter, setter RRDSetter, timeParser *timeexpr.Parser, logger *slog.Logger) *RRD {
 	return &RRD{
-		getter: getter,
-		setter: setter,
-		logger: logger,
+		getter:     getter,
+		setter:     setter,
+		timeParser: timeParser,
+		logger:     logger,
 	}
 }
 
-// Create validates request and creates record in database.
+// SetAsync sets whether records are saved after Create responds.
+func (h *RRD) SetAsync(async bool) {
+	h.async = async
+}
+
+// SetLimits changes limits of requests.
+func (h *RRD) SetLimits(limits RRDLimits) {
+	h.maxBatchPoints.Store(int64(limits.MaxBatchPoints))
+	h.maxRangeSpan.Store(int64(limits.MaxRangeSpan))
+}
+
+// Create validates request and creates a record, or a batch of records, in database. Records of a batch
+// are validated before any of them is saved, then they are saved in order until the first error, so records
+// before the failed one are saved and their number is returned in the problem.
+// It is a handler of legacy PUT /metrics, see CreateRecords for API v1.
 func (h *RRD) Create(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		h.logger.Error("failed to create record, wrong method",
+		writeProblem(w, r, h.logger, "failed to create record, wrong method", methodNotAllowedProblem(r.Method),
 			slog.String("method", r.Method),
 		)
-		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	var record models.Record
-	err := json.NewDecoder(r.Body).Decode(&record)
-	if err != nil {
-		h.logger.Error("failed to create record, failed to decode request", slog.Any("error", err))
-		w.WriteHeader(http.StatusBadRequest)
+	ctx, span := tracer.Start(r.Context(), "RRD.Create")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	if _, ok := h.save(w, r); !ok {
 		return
 	}
-
-	if err = h.setter.Create(r.Context(), record); err != nil {
-		h.logger.Error("failed to create record",
-			slog.Any("record", record),
-			slog.Any("error", err))
-		w.WriteHeader(http.StatusInternalServerError)
+	if h.async {
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 	// Here must be http.StatusCreated, but requirements say http.StatusOK.
 	w.WriteHeader(http.StatusOK)
 }
 
+// CreateRecords creates records like Create, it responds 201 Created, or 202 Accepted if records are saved
+// asynchronously, with a number of accepted records.
+func (h *RRD) CreateRecords(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "RRD.CreateRecords")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	accepted, ok := h.save(w, r)
+	if !ok {
+		return
+	}
+	status := http.StatusCreated
+	if h.async {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, r, h.logger, status, createResult{Accepted: accepted})
+}
+
+// createResult is a response of CreateRecords.
+type createResult struct {
+	Accepted int `json:"accepted"`
+}
+
+// save decodes records of the request and saves them, it writes error and returns false if records are not saved.
+func (h *RRD) save(w http.ResponseWriter, r *http.Request) (int, bool) {
+	span := trace.SpanFromContext(r.Context())
+
+	format, err := requestFormat(r)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to create record, unsupported format", err)
+		return 0, false
+	}
+	span.SetAttributes(attribute.String("rrd.format", format.name))
+
+	records, batch, err := format.decode(r.Body)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to create record, failed to decode request", decodeError(err))
+		return 0, false
+	}
+	span.SetAttributes(attribute.Int("rrd.records", len(records)))
+	if batch && len(records) == 0 {
+		writeError(w, r, h.logger, "failed to create records, empty batch",
+			models.NewValidationError("body", "batch must not be empty"))
+		return 0, false
+	}
+	if maxPoints := h.maxBatchPoints.Load(); maxPoints > 0 && int64(len(records)) > maxPoints {
+		writeError(w, r, h.logger, "failed to create records, batch is too large",
+			models.NewDetailError(models.ErrTooLarge, "batch has %d points, limit is %d", len(records), maxPoints))
+		return 0, false
+	}
+
+	if batch {
+		if err = h.validate(r.Context(), records); err != nil {
+			writeError(w, r, h.logger, "failed to create records, invalid batch", err)
+			return 0, false
+		}
+		if err = h.setter.Admit(len(records)); err != nil {
+			writeError(w, r, h.logger, "failed to create records, batch is not admitted", err)
+			return 0, false
+		}
+	}
+	for i, record := range records {
+		if err = h.setter.Create(r.Context(), record); err != nil {
+			problem := newProblem(err)
+			if batch {
+				err = fmt.Errorf("record %d: %w", i, err)
+				accepted := i
+				problem.Accepted = &accepted
+			}
+			trace.SpanFromContext(r.Context()).RecordError(err)
+			writeProblem(w, r, h.logger, "failed to create record", problem,
+				slog.Any("record", record),
+				slog.Any("error", err),
+			)
+			return 0, false
+		}
+	}
+	return len(records), true
+}
+
+// validate checks all records of a batch, fields of invalid records are prefixed with their index.
+func (h *RRD) validate(ctx context.Context, records []models.Record) error {
+	var batchErr models.ValidationError
+	for i, record := range records {
+		err := h.setter.Validate(ctx, record)
+		var validationErr *models.ValidationError
+		switch {
+		case err == nil:
+		case errors.As(err, &validationErr):
+			for _, field := range validationErr.Fields {
+				batchErr.Add(fmt.Sprintf("%d.%s", i, field.Field), "%s", field.Message)
+			}
+		default:
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	if len(batchErr.Fields) > 0 {
+		return &batchErr
+	}
+	return nil
+}
+
 // GetByRange validates request and returns records from database by range.
+// It is a handler of legacy GET /metrics, see ListRecords for API v1.
 func (h *RRD) GetByRange(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		h.logger.Error("failed to get records, wrong method",
+		writeProblem(w, r, h.logger, "failed to get records, wrong method", methodNotAllowedProblem(r.Method),
 			slog.String("method", r.Method),
 		)
-		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
+	ctx, span := tracer.Start(r.Context(), "RRD.GetByRange")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	// Format is negotiated before the query, so not acceptable requests don't load storage.
+	w.Header().Add("Vary", "Accept")
+	format, err := responseFormat(r)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to get records, unsupported format", err)
+		return
+	}
+	span.SetAttributes(attribute.String("rrd.format", format.name))
+
+	query, ok := h.parseRange(w, r, time.Now())
+	if !ok {
+		return
+	}
+	result, ok := h.get(w, r, query)
+	if !ok {
+		return
+	}
+
+	if len(result) == 0 {
+		h.logger.DebugContext(r.Context(), "records not found",
+			slog.String("series", query.series),
+			slog.Int64("start", query.start),
+			slog.Int64("end", query.end),
+		)
+		// I think that it must be http.StatusNotFound.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.encode(w, r, format, result)
+}
+
+// ListRecords returns a page of records by range sorted by timestamp. Unlike GetByRange it responds 200 OK with
+// an empty page if there are no records, and range without end ends now.
+// Query params: `series` or `expr` with `step`, `start`, `end`, `limit`, `page_token` and `format`.
+func (h *RRD) ListRecords(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "RRD.ListRecords")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	w.Header().Add("Vary", "Accept")
+	format, err := responseFormat(r)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to list records, unsupported format", err)
+		return
+	}
+	span.SetAttributes(attribute.String("rrd.format", format.name))
+
+	limit, after, err := parseRecordPage(r)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to list records, invalid params", err)
+		return
+	}
+	now := time.Now()
+	query, ok := h.parseRange(w, r, now)
+	if !ok {
+		return
+	}
+	if query.end == 0 {
+		query.end = now.UnixMicro()
+	}
+	// Page token is a timestamp of the last record of the previous page. One more record than the limit tells
+	// that there is the next page.
+	query.limit = limit + 1
+	switch {
+	case query.expr != "":
+		// Steps of an expression depend on the range, so the page is selected by the last step.
+		query.after = after
+	case after >= query.start:
+		query.start = after + 1
+	}
+
+	var records []models.Record
+	if query.start <= query.end {
+		if records, ok = h.get(w, r, query); !ok {
+			return
+		}
+	}
+	if records == nil {
+		// Empty page is encoded as an empty array.
+		records = make([]models.Record, 0)
+	}
+	page := models.RecordPage{Records: records}
+	if len(records) > limit {
+		page.Records = records[:limit]
+		page.NextPageToken = strconv.FormatInt(page.Records[limit-1].Timestamp, 10)
+		w.Header().Set(HeaderNextPageToken, page.NextPageToken)
+	}
+
+	if format.name == FormatJSON {
+		span.SetAttributes(attribute.Int("rrd.records", len(page.Records)))
+		writeJSON(w, r, h.logger, http.StatusOK, page)
+		return
+	}
+	h.encode(w, r, format, page.Records)
+}
+
+// rangeQuery is a query of records of a series, or of values of an expression, from start to end.
+type rangeQuery struct {
+	series     string
+	start, end int64
+	expr       string
+	// step is an interval between values of the expression in seconds.
+	step int64
+	// after selects a page of values of the expression.
+	after int64
+	// limit is a max number of sorted records or values, zero means all records of the range.
+	limit int
+}
+
+// parseRange parses and validates range params, it writes error and returns false if params are invalid.
+func (h *RRD) parseRange(w http.ResponseWriter, r *http.Request, now time.Time) (rangeQuery, bool) {
+	query := rangeQuery{series: r.URL.Query().Get("series"), expr: r.URL.Query().Get("expr")}
 	startString := r.URL.Query().Get("start")
 	endString := r.URL.Query().Get("end")
 
-	var (
-		start, end int64
-		err        error
-	)
+	if err := parseExprParams(r, &query); err != nil {
+		writeError(w, r, h.logger, "failed to get records, invalid params", err)
+		return rangeQuery{}, false
+	}
 
+	var err error
+	// All relative expressions of one request are resolved against the same moment.
 	if startString != "" {
-		start, err = strconv.ParseInt(startString, 10, 64)
+		query.start, err = h.timeParser.Parse(startString, now)
 		if err != nil {
-			h.logger.Error("failed to get records, failed to parse int64",
+			writeError(w, r, h.logger, "failed to get records, failed to parse start",
+				models.NewValidationError("start", "%s", err),
 				slog.String("startString", startString),
 			)
-			w.WriteHeader(http.StatusBadRequest)
-			return
+			return rangeQuery{}, false
 		}
 	}
 
-	if endString != "" {
-		end, err = strconv.ParseInt(endString, 10, 64)
+	switch {
+	case endString == "" && startString != "":
+		// Open range like `start=-1d` ends now.
+		query.end = now.UnixMicro()
+	case endString != "":
+		query.end, err = h.timeParser.Parse(endString, now)
 		if err != nil {
-			h.logger.Error("failed to get records, failed to parse int64",
+			writeError(w, r, h.logger, "failed to get records, failed to parse end",
+				models.NewValidationError("end", "%s", err),
 				slog.String("endString", endString),
 			)
-			w.WriteHeader(http.StatusBadRequest)
-			return
+			return rangeQuery{}, false
 		}
 	}
 
-	if start > end || start < 0 || end < 0 {
-		h.logger.Error("failed to get records, invalid range",
-			slog.Int64("start", start),
-			slog.Int64("end", end),
+	if err = validateRange(query.start, query.end); err != nil {
+		writeError(w, r, h.logger, "failed to get records, invalid range", err,
+			slog.Int64("start", query.start),
+			slog.Int64("end", query.end),
 		)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return rangeQuery{}, false
 	}
 
-	result, err := h.getter.GetByRange(r.Context(), start, end)
-	if err != nil {
-		h.logger.Error("failed to get records",
-			slog.Int64("start", start),
-			slog.Int64("end", end),
-			slog.Any("error", err))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	if err = h.checkSpan(query.start, query.end, now); err != nil {
+		writeError(w, r, h.logger, "failed to get records, range is too large", err,
+			slog.Int64("start", query.start),
+			slog.Int64("end", query.end),
+		)
+		return rangeQuery{}, false
+	}
+	return query, true
+}
+
+// parseExprParams parses `expr` and `step` params, series can't be queried with expression.
+func parseExprParams(r *http.Request, query *rangeQuery) error {
+	var validationErr models.ValidationError
+	if query.expr != "" && query.series != "" {
+		validationErr.Add("series", "must not be set with expr")
+	}
+	if value := r.URL.Query().Get("step"); value != "" {
+		step, err := strconv.ParseInt(value, 10, 64)
+		switch {
+		case err != nil:
+			validationErr.Add("step", "must be integer")
+		case step < 0:
+			validationErr.Add("step", "must not be negative")
+		case step > maxExprStep:
+			validationErr.Add("step", "must be at most %d", maxExprStep)
+		case query.expr == "":
+			validationErr.Add("step", "must be set only with expr")
+		}
+		query.step = step
+	}
+	if len(validationErr.Fields) > 0 {
+		return &validationErr
 	}
+	return nil
+}
 
-	if len(result) == 0 {
-		h.logger.Error("failed to get records, not found",
-			slog.Int("len(result)", len(result)),
+// get returns records of the query, it writes error and returns false if records are not loaded.
+func (h *RRD) get(w http.ResponseWriter, r *http.Request, query rangeQuery) ([]models.Record, bool) {
+	trace.SpanFromContext(r.Context()).SetAttributes(
+		attribute.String("rrd.series", query.series),
+		attribute.String("rrd.expr", query.expr),
+		attribute.Int64("rrd.start", query.start),
+		attribute.Int64("rrd.end", query.end),
+	)
+	var (
+		result []models.Record
+		err    error
+	)
+	switch {
+	case query.expr != "":
+		result, err = h.getter.Evaluate(r.Context(), models.ExprQuery{
+			Expr:  query.expr,
+			Start: query.start,
+			End:   query.end,
+			Step:  query.step,
+			After: query.after,
+			Limit: query.limit,
+		})
+	case query.limit > 0:
+		result, err = h.getter.ListRecords(r.Context(), query.series, query.start, query.end, query.limit)
+	default:
+		result, err = h.getter.GetByRange(r.Context(), query.series, query.start, query.end)
+	}
+	if err != nil {
+		writeError(w, r, h.logger, "failed to get records", err,
+			slog.String("series", query.series),
+			slog.String("expr", query.expr),
+			slog.Int64("start", query.start),
+			slog.Int64("end", query.end),
 		)
-		// I think that it must be http.StatusNotFound.
-		w.WriteHeader(http.StatusNoContent)
-		return
+		return nil, false
 	}
+	return result, true
+}
 
-	if err = json.NewEncoder(w).Encode(result); err != nil {
-		h.logger.Error("failed to get records, failed to encode", slog.Any("error", err))
-		w.WriteHeader(http.StatusInternalServerError)
+// encode writes records in the format with status 200 OK. Records are encoded before the header is written,
+// so records which can't be encoded in the format get an error response instead of a truncated body.
+func (h *RRD) encode(w http.ResponseWriter, r *http.Request, format recordFormat, records []models.Record) {
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.Int("rrd.records", len(records)))
+	_, encodeSpan := tracer.Start(r.Context(), "RRD.encode")
+	defer encodeSpan.End()
+	var buf bytes.Buffer
+	if err := format.encode(&buf, records); err != nil {
+		encodeSpan.RecordError(err)
+		writeError(w, r, h.logger, "failed to get records, failed to encode", err)
 		return
 	}
+	w.Header().Set("Content-Type", format.contentType)
+	if _, err := buf.WriteTo(w); err != nil {
+		// Headers are already sent, so we can only log the error.
+		h.logger.ErrorContext(r.Context(), "failed to get records, failed to write", slog.Any("error", err))
+	}
+}
+
+// parseRecordPage returns limit of a page of records and timestamp of the last record of the previous page,
+// it is -1 for the first page.
+func parseRecordPage(r *http.Request) (int, int64, error) {
+	query := r.URL.Query()
+	var validationErr models.ValidationError
+
+	limit := defaultRecordPageLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		switch {
+		case err != nil:
+			validationErr.Add("limit", "must be integer")
+		case parsed < 0:
+			validationErr.Add("limit", "must not be negative")
+		case parsed > maxRecordPageLimit:
+			limit = maxRecordPageLimit
+		case parsed > 0:
+			limit = parsed
+		}
+	}
 
-	w.WriteHeader(http.StatusOK)
+	after := int64(-1)
+	if token := query.Get("page_token"); token != "" {
+		parsed, err := strconv.ParseInt(token, 10, 64)
+		if err != nil || parsed < 0 {
+			validationErr.Add("page_token", "invalid page token %q", token)
+		}
+		after = parsed
+	}
+
+	if len(validationErr.Fields) > 0 {
+		return 0, 0, &validationErr
+	}
+	return limit, after, nil
+}
+
+// checkSpan returns models.ErrTooLarge if range is longer than max range span, range without bounds ends now.
+func (h *RRD) checkSpan(start, end int64, now time.Time) error {
+	maxSpan := time.Duration(h.maxRangeSpan.Load())
+	if maxSpan <= 0 {
+		return nil
+	}
+	if start == 0 && end == 0 {
+		end = now.UnixMicro()
+	}
+	if end-start > maxSpan.Microseconds() {
+		return models.NewDetailError(models.ErrTooLarge, "range is longer than %s", maxSpan)
+	}
+	return nil
+}
+
+// decodeRecords decodes a record or an array of records, it returns true if body is an array.
+func decodeRecords(body io.Reader) ([]models.Record, bool, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, false, err
+	}
+	if len(raw) > 0 && raw[0] == '[' {
+		var records []models.Record
+		if err := json.Unmarshal(raw, &records); err != nil {
+			return nil, true, err
+		}
+		return records, true, nil
+	}
+	var record models.Record
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, false, err
+	}
+	return []models.Record{record}, false, nil
+}
+
+// validateRange checks that range params are not negative and start is not after end.
+func validateRange(start, end int64) error {
+	var validationErr models.ValidationError
+	if start < 0 {
+		validationErr.Add("start", "must not be negative")
+	}
+	if end < 0 {
+		validationErr.Add("end", "must not be negative")
+	}
+	if start > end {
+		validationErr.Add("start", "must not be after end")
+	}
+	if len(validationErr.Fields) > 0 {
+		return &validationErr
+	}
+	return nil
 }
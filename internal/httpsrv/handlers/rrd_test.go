@@ -1,25 +1,40 @@
 package handlers
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/steinfletcher/apitest"
+	"github.com/stretchr/testify/require"
 
 	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/rrd"
+	"aerospike.com/rrd/internal/timeexpr"
 )
 
 const (
-	testMetric  = 3.5
-	errorMetric = 0
+	testMetric        = 3.5
+	errorMetric       = 0
+	unavailableMetric = 503
+	overloadedMetric  = 429
+	admittedRecords   = 5
+	unknownSeries     = "unknown"
+	// unencodableSeries has a value which binary formats can't encode.
+	unencodableSeries = "unencodable"
 )
 
 var errTest = errors.New("test error")
@@ -48,29 +63,89 @@ func errorBody() string {
 	return string(body)
 }
 
+func unavailableBody() string {
+	body, _ := json.Marshal(models.Record{Timestamp: time.Now().UnixMicro(), MetricValue: unavailableMetric})
+	return string(body)
+}
+
+func overloadedBody() string {
+	body, _ := json.Marshal(models.Record{Timestamp: time.Now().UnixMicro(), MetricValue: overloadedMetric})
+	return string(body)
+}
+
 type getterMock struct{}
 
-func (mock getterMock) GetByRange(_ context.Context, min, max int64) ([]models.Record, error) {
+func (mock getterMock) GetByRange(_ context.Context, series string, min, max int64) ([]models.Record, error) {
+	if series == unknownSeries {
+		return nil, fmt.Errorf("failed to get series: %w", models.ErrNotFound)
+	}
+	if series == unencodableSeries {
+		return []models.Record{{Timestamp: 1, MetricValue: []int{1}}}, nil
+	}
 	if min < 0 || max < 0 {
 		return nil, fmt.Errorf("failed to get by range: %w", errTest)
 	}
 	return []models.Record{testRecord()}, nil
 }
 
+func (mock getterMock) ListRecords(ctx context.Context, series string, start, end int64, _ int,
+) ([]models.Record, error) {
+	return mock.GetByRange(ctx, series, start, end)
+}
+
+// Evaluate returns the step and unknown value at steps of a second after the page, up to the limit.
+func (mock getterMock) Evaluate(_ context.Context, query models.ExprQuery) ([]models.Record, error) {
+	if query.Expr == "bad," {
+		return nil, models.NewValidationError("expr", "invalid expression")
+	}
+	records := []models.Record{
+		{Timestamp: query.Start, MetricValue: float64(query.Step)},
+		{Timestamp: query.Start + 1, MetricValue: math.NaN()},
+		{Timestamp: query.Start + 2, MetricValue: 1.0},
+	}
+	for len(records) > 0 && records[0].Timestamp <= query.After {
+		records = records[1:]
+	}
+	if query.Limit > 0 && len(records) > query.Limit {
+		records = records[:query.Limit]
+	}
+	return records, nil
+}
+
 type setterMock struct{}
 
 func (mock setterMock) Create(_ context.Context, record models.Record) error {
-	if record.MetricValue != testMetric {
+	number, _ := record.MetricValue.(json.Number)
+	value, _ := number.Float64()
+	if value == unavailableMetric {
+		return fmt.Errorf("failed to set: %w", models.ErrUnavailable)
+	}
+	if value == overloadedMetric {
+		return fmt.Errorf("failed to enqueue: %w", models.ErrOverloaded)
+	}
+	if value != testMetric {
 		return fmt.Errorf("failed to set: %w", errTest)
 	}
 	return nil
 }
 
+func (mock setterMock) Validate(context.Context, models.Record) error {
+	return nil
+}
+
+func (mock setterMock) Admit(n int) error {
+	if n > admittedRecords {
+		return models.NewDetailError(models.ErrOverloaded, "batch exceeds %d records", admittedRecords)
+	}
+	return nil
+}
+
 func newRRDMock() *RRD {
 	return &RRD{
-		getter: getterMock{},
-		setter: setterMock{},
-		logger: slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		getter:     getterMock{},
+		setter:     setterMock{},
+		timeParser: timeexpr.NewParser(time.UTC),
+		logger:     slog.New(slog.NewJSONHandler(os.Stdout, nil)),
 	}
 }
 
@@ -91,6 +166,8 @@ func TestRRD_Create(t *testing.T) {
 		{http.MethodPut, http.StatusOK, testBody()},
 		{http.MethodPut, http.StatusBadRequest, ""},
 		{http.MethodPut, http.StatusInternalServerError, errorBody()},
+		{http.MethodPut, http.StatusServiceUnavailable, unavailableBody()},
+		{http.MethodPut, http.StatusBadRequest, `{"timestamp":"abc","metric_value":1}`},
 		{http.MethodPost, http.StatusMethodNotAllowed, testBody()},
 		{http.MethodConnect, http.StatusMethodNotAllowed, testBody()},
 		{http.MethodDelete, http.StatusMethodNotAllowed, testBody()},
@@ -111,6 +188,105 @@ func TestRRD_Create(t *testing.T) {
 	}
 }
 
+func TestRRD_CreateAsync(t *testing.T) {
+	t.Parallel()
+	h := newRRDMock()
+	h.SetAsync(true)
+
+	testCases := []struct {
+		statusCode int
+		body       string
+	}{
+		{http.StatusAccepted, testBody()},
+		{http.StatusTooManyRequests, overloadedBody()},
+		{http.StatusServiceUnavailable, unavailableBody()},
+	}
+
+	for _, tt := range testCases {
+		apitest.New().
+			HandlerFunc(h.Create).
+			Method(http.MethodPut).
+			URL("/metrics").
+			Body(tt.body).
+			Expect(t).
+			Status(tt.statusCode).
+			End()
+	}
+}
+
+func TestRRD_CreateBatch(t *testing.T) {
+	t.Parallel()
+	h := newRRDMock()
+	h.SetLimits(RRDLimits{MaxBatchPoints: 2})
+
+	testCases := []struct {
+		statusCode int
+		body       string
+	}{
+		{http.StatusOK, "[" + testBody() + "]"},
+		{http.StatusOK, "[" + testBody() + "," + testBody() + "]"},
+		{http.StatusRequestEntityTooLarge, "[" + testBody() + "," + testBody() + "," + testBody() + "]"},
+		{http.StatusBadRequest, "[]"},
+		{http.StatusBadRequest, `[{"timestamp":"abc","metric_value":1}]`},
+		{http.StatusServiceUnavailable, "[" + testBody() + "," + unavailableBody() + "]"},
+	}
+
+	for _, tt := range testCases {
+		apitest.New().
+			HandlerFunc(h.Create).
+			Method(http.MethodPut).
+			URL("/metrics").
+			Body(tt.body).
+			Expect(t).
+			Status(tt.statusCode).
+			End()
+	}
+}
+
+func TestRRD_CreateBatchErrors(t *testing.T) {
+	t.Parallel()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	setter := &recordingSetter{}
+	h := NewRRD(getterMock{}, setter, timeexpr.NewParser(time.UTC), logger)
+
+	// Invalid records are reported by index and no record of the batch is saved.
+	rec := httptest.NewRecorder()
+	h.CreateRecords(rec, httptest.NewRequest(http.MethodPut, "/api/v1/metrics",
+		strings.NewReader(`[{"timestamp":1,"metric_value":1},{"timestamp":2},{"timestamp":3}]`)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	require.Equal(t, []models.FieldError{
+		{Field: "1.metric_value", Message: "must be set"},
+		{Field: "2.metric_value", Message: "must be set"},
+	}, problem.Errors)
+	require.Empty(t, setter.records)
+
+	// Records before a failed one are saved, their number is returned.
+	h = newRRDMock()
+	rec = httptest.NewRecorder()
+	h.CreateRecords(rec, httptest.NewRequest(http.MethodPut, "/api/v1/metrics",
+		strings.NewReader("["+testBody()+","+testBody()+","+unavailableBody()+"]")))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	problem = Problem{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	require.NotNil(t, problem.Accepted)
+	require.Equal(t, 2, *problem.Accepted)
+
+	// Batch which doesn't fit is rejected before any record is saved.
+	bodies := make([]string, admittedRecords+1)
+	for i := range bodies {
+		bodies[i] = unavailableBody()
+	}
+	rec = httptest.NewRecorder()
+	h.CreateRecords(rec, httptest.NewRequest(http.MethodPut, "/api/v1/metrics",
+		strings.NewReader("["+strings.Join(bodies, ",")+"]")))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	problem = Problem{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	require.Nil(t, problem.Accepted)
+}
+
 func TestRRD_GetByRange(t *testing.T) {
 	t.Parallel()
 	h := newRRDMock()
@@ -127,7 +303,14 @@ func TestRRD_GetByRange(t *testing.T) {
 		end        string
 	}{
 		{http.MethodGet, http.StatusOK, "0", "10"},
+		{http.MethodGet, http.StatusOK, "now-6h", "now"},
+		{http.MethodGet, http.StatusOK, "-1d", ""},
+		{http.MethodGet, http.StatusOK, "2024-06-07T00:00:00Z", "2024-06-08"},
+		{http.MethodGet, http.StatusOK, "startofweek", "today+8h"},
+		{http.MethodGet, http.StatusOK, "1717745157s", "1717745157997ms"},
 		{http.MethodGet, http.StatusBadRequest, "a", "b"},
+		{http.MethodGet, http.StatusBadRequest, "now", "now-1h"},
+		{http.MethodGet, http.StatusBadRequest, "now-1x", "now"},
 		{http.MethodGet, http.StatusBadRequest, "-1", "0"},
 		{http.MethodGet, http.StatusBadRequest, "5", "1"},
 		{http.MethodGet, http.StatusBadRequest, "0", "-1"},
@@ -150,3 +333,312 @@ func TestRRD_GetByRange(t *testing.T) {
 			End()
 	}
 }
+
+func TestRRD_GetByRangeSpan(t *testing.T) {
+	t.Parallel()
+	h := newRRDMock()
+	h.SetLimits(RRDLimits{MaxRangeSpan: 24 * time.Hour})
+
+	testCases := []struct {
+		statusCode int
+		start      string
+		end        string
+	}{
+		{http.StatusOK, "now-6h", "now"},
+		{http.StatusOK, "-1d", ""},
+		{http.StatusRequestEntityTooLarge, "-2d", ""},
+		// Range without bounds selects all records.
+		{http.StatusRequestEntityTooLarge, "", ""},
+	}
+
+	for _, tt := range testCases {
+		apitest.New().
+			HandlerFunc(h.GetByRange).
+			Get("/metrics").
+			QueryParams(map[string]string{"start": tt.start, "end": tt.end}).
+			Expect(t).
+			Status(tt.statusCode).
+			End()
+	}
+}
+
+func TestRRD_GetByRangeSeries(t *testing.T) {
+	t.Parallel()
+	h := newRRDMock()
+	router := mux.NewRouter()
+	router.HandleFunc(
+		"/metrics",
+		h.GetByRange,
+	).Methods(http.MethodGet)
+
+	testCases := []struct {
+		series     string
+		statusCode int
+	}{
+		{"", http.StatusOK},
+		{models.DefaultSeriesName, http.StatusOK},
+		{"cpu_usage", http.StatusOK},
+		{unknownSeries, http.StatusNotFound},
+	}
+
+	for _, tt := range testCases {
+		apitest.New().
+			Handler(router).
+			Get("/metrics").
+			QueryParams(map[string]string{"series": tt.series, "start": "0", "end": "10"}).
+			Expect(t).
+			Status(tt.statusCode).
+			End()
+	}
+}
+
+func TestRRD_ListRecordsExpr(t *testing.T) {
+	t.Parallel()
+	h := newRRDMock()
+
+	testCases := []struct {
+		query      map[string]string
+		statusCode int
+		body       string
+	}{
+		{map[string]string{"expr": "errors / requests", "start": "10", "end": "20", "step": "60"}, http.StatusOK,
+			`{"records":[{"timestamp":10,"metric_value":60},{"timestamp":11,"metric_value":"NaN"},` +
+				`{"timestamp":12,"metric_value":1}]}`},
+		{map[string]string{"expr": "errors,requests,/", "start": "10", "end": "20", "limit": "1"}, http.StatusOK,
+			`{"records":[{"timestamp":10,"metric_value":0}],"next_page_token":"10"}`},
+		{map[string]string{"expr": "errors,requests,/", "start": "10", "end": "20", "limit": "1", "page_token": "10"},
+			http.StatusOK, `{"records":[{"timestamp":11,"metric_value":"NaN"}],"next_page_token":"11"}`},
+		{map[string]string{"expr": "errors,requests,/", "start": "10", "end": "20", "limit": "1", "page_token": "11"},
+			http.StatusOK, `{"records":[{"timestamp":12,"metric_value":1}]}`},
+		{map[string]string{"expr": "bad,", "start": "10"}, http.StatusBadRequest, ""},
+		{map[string]string{"expr": "errors", "series": "errors", "start": "10"}, http.StatusBadRequest, ""},
+		{map[string]string{"expr": "errors", "start": "10", "step": "-1"}, http.StatusBadRequest, ""},
+		{map[string]string{"expr": "errors", "start": "10", "step": "288230376151711744"}, http.StatusBadRequest, ""},
+		{map[string]string{"expr": "errors", "start": "10", "step": "1m"}, http.StatusBadRequest, ""},
+		{map[string]string{"start": "10", "step": "60"}, http.StatusBadRequest, ""},
+	}
+
+	for i, tt := range testCases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics", nil)
+		query := req.URL.Query()
+		for key, value := range tt.query {
+			query.Set(key, value)
+		}
+		req.URL.RawQuery = query.Encode()
+		h.ListRecords(rec, req)
+
+		require.Equal(t, tt.statusCode, rec.Code, fmt.Sprintf("case %d", i))
+		if tt.statusCode == http.StatusOK {
+			require.JSONEq(t, tt.body, rec.Body.String(), fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+func TestRRD_CreateRecords(t *testing.T) {
+	t.Parallel()
+	h := newRRDMock()
+	asyncHandlers := newRRDMock()
+	asyncHandlers.SetAsync(true)
+
+	testCases := []struct {
+		handlers   *RRD
+		statusCode int
+		body       string
+		expected   string
+	}{
+		{h, http.StatusCreated, testBody(), `{"accepted":1}`},
+		{h, http.StatusCreated, "[" + testBody() + "," + testBody() + "]", `{"accepted":2}`},
+		{asyncHandlers, http.StatusAccepted, testBody(), `{"accepted":1}`},
+		{h, http.StatusBadRequest, "[]", ""},
+		{h, http.StatusServiceUnavailable, unavailableBody(), ""},
+	}
+
+	for _, tt := range testCases {
+		test := apitest.New().
+			HandlerFunc(tt.handlers.CreateRecords).
+			Put("/api/v1/metrics").
+			Body(tt.body).
+			Expect(t).
+			Status(tt.statusCode)
+		if tt.expected != "" {
+			test = test.Body(tt.expected)
+		}
+		test.End()
+	}
+}
+
+// rangeGetterMock returns records of timestamps in range in reverse order, as storage doesn't sort them.
+type rangeGetterMock []int64
+
+func (mock rangeGetterMock) GetByRange(_ context.Context, _ string, min, max int64) ([]models.Record, error) {
+	var records []models.Record
+	for i := len(mock) - 1; i >= 0; i-- {
+		if mock[i] >= min && mock[i] <= max {
+			records = append(records, models.Record{Timestamp: mock[i], MetricValue: 1})
+		}
+	}
+	return records, nil
+}
+
+// ListRecords returns records in range sorted by timestamp up to the limit.
+func (mock rangeGetterMock) ListRecords(ctx context.Context, series string, start, end int64, limit int,
+) ([]models.Record, error) {
+	records, err := mock.GetByRange(ctx, series, start, end)
+	slices.SortFunc(records, func(a, b models.Record) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, err
+}
+
+func (mock rangeGetterMock) Evaluate(context.Context, models.ExprQuery) ([]models.Record, error) {
+	return nil, nil
+}
+
+func TestRRD_ListRecords(t *testing.T) {
+	t.Parallel()
+	h := newRRDMock()
+	h.getter = rangeGetterMock{1, 2, 3, 4, 5}
+
+	testCases := []struct {
+		query      map[string]string
+		statusCode int
+		body       string
+		nextPage   string
+	}{
+		{
+			map[string]string{"limit": "2"}, http.StatusOK,
+			`{"records":[{"timestamp":1,"metric_value":1},{"timestamp":2,"metric_value":1}],"next_page_token":"2"}`,
+			"2",
+		},
+		{
+			map[string]string{"limit": "2", "page_token": "2"}, http.StatusOK,
+			`{"records":[{"timestamp":3,"metric_value":1},{"timestamp":4,"metric_value":1}],"next_page_token":"4"}`,
+			"4",
+		},
+		{
+			map[string]string{"limit": "2", "page_token": "4"}, http.StatusOK,
+			`{"records":[{"timestamp":5,"metric_value":1}]}`, "",
+		},
+		{map[string]string{"start": "2", "end": "3"}, http.StatusOK,
+			`{"records":[{"timestamp":2,"metric_value":1},{"timestamp":3,"metric_value":1}]}`, ""},
+		{map[string]string{"page_token": "10", "end": "5"}, http.StatusOK, `{"records":[]}`, ""},
+		{map[string]string{"start": "6", "end": "10"}, http.StatusOK, `{"records":[]}`, ""},
+		{map[string]string{"limit": "2", "format": "csv"}, http.StatusOK,
+			"timestamp,metric_value\n1,1\n2,1\n", "2"},
+		{map[string]string{"limit": "-1"}, http.StatusBadRequest, "", ""},
+		{map[string]string{"page_token": "abc"}, http.StatusBadRequest, "", ""},
+		{map[string]string{"start": "5", "end": "1"}, http.StatusBadRequest, "", ""},
+	}
+
+	for i, tt := range testCases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics", nil)
+		query := req.URL.Query()
+		for key, value := range tt.query {
+			query.Set(key, value)
+		}
+		req.URL.RawQuery = query.Encode()
+		h.ListRecords(rec, req)
+
+		require.Equal(t, tt.statusCode, rec.Code, fmt.Sprintf("case %d", i))
+		require.Equal(t, tt.nextPage, rec.Header().Get(HeaderNextPageToken), fmt.Sprintf("case %d", i))
+		if tt.statusCode == http.StatusOK {
+			if tt.query["format"] == "" {
+				require.JSONEq(t, tt.body, rec.Body.String(), fmt.Sprintf("case %d", i))
+				continue
+			}
+			require.Equal(t, tt.body, rec.Body.String(), fmt.Sprintf("case %d", i))
+		}
+	}
+}
+
+// tenantStorageStub has a series of any name and counts reads and writes of records, so only validation of the
+// service rejects names of series of other tenants.
+type tenantStorageStub struct {
+	calls *atomic.Int64
+}
+
+func (stub tenantStorageStub) GetByRange(context.Context, string, int64, int64) ([]models.Record, error) {
+	stub.calls.Add(1)
+	return []models.Record{testRecord()}, nil
+}
+
+func (stub tenantStorageStub) Set(context.Context, models.Record, models.Retention) error {
+	stub.calls.Add(1)
+	return nil
+}
+
+func (stub tenantStorageStub) CreateSeries(context.Context, models.Series) error { return nil }
+
+func (stub tenantStorageStub) UpdateSeries(context.Context, models.Series) error { return nil }
+
+func (stub tenantStorageStub) GetSeries(_ context.Context, name string) (models.Series, error) {
+	return models.Series{Name: name, ValueType: models.ValueSpec{Type: models.ValueTypeFloat}}, nil
+}
+
+func (stub tenantStorageStub) ListTenantSeries(context.Context, string) ([]models.Series, error) {
+	return nil, nil
+}
+
+func (stub tenantStorageStub) ListTenants(context.Context) ([]string, error) { return nil, nil }
+
+func (stub tenantStorageStub) SeriesStats(context.Context, string) (models.SeriesStats, error) {
+	return models.SeriesStats{}, nil
+}
+
+func (stub tenantStorageStub) SeriesPoints(context.Context, string) (uint64, error) { return 1, nil }
+
+func (stub tenantStorageStub) Capacity(models.Retention) uint64 { return 0 }
+
+func (stub tenantStorageStub) Limits(string) models.TenantLimits { return models.TenantLimits{} }
+
+func (stub tenantStorageStub) AllowIngest(string, int) error { return nil }
+
+func (stub tenantStorageStub) RefundIngest(string, int) {}
+
+func (stub tenantStorageStub) IngestUsage(string) models.IngestUsage { return models.IngestUsage{} }
+
+func TestRRD_OtherTenantSeries(t *testing.T) {
+	t.Parallel()
+	stub := tenantStorageStub{calls: new(atomic.Int64)}
+	service := rrd.NewService(stub, stub, stub, stub)
+	h := NewRRD(service, service, timeexpr.NewParser(time.UTC), slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	router := mux.NewRouter()
+	router.HandleFunc("/metrics", h.GetByRange).Methods(http.MethodGet)
+	router.HandleFunc("/metrics", h.Create).Methods(http.MethodPut)
+	// Default tenant identity has no tenant prefix of its series, so names with separator are series of tenants.
+	ctx := models.WithIdentity(context.Background(), models.Identity{
+		Subject: "client", Tenant: models.DefaultTenant, Scopes: []models.Scope{models.ScopeRead, models.ScopeWrite},
+	})
+	body, _ := json.Marshal(models.Record{Series: "acme/cpu", Timestamp: 1, MetricValue: testMetric})
+
+	apitest.New().
+		Handler(router).
+		Get("/metrics").
+		WithContext(ctx).
+		QueryParams(map[string]string{"series": "acme/cpu", "start": "0", "end": "10"}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	apitest.New().
+		Handler(router).
+		Put("/metrics").
+		WithContext(ctx).
+		JSON(string(body)).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	apitest.New().
+		Handler(router).
+		Get("/metrics").
+		WithContext(ctx).
+		QueryParams(map[string]string{"series": "cpu", "start": "0", "end": "10"}).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	require.Equal(t, int64(1), stub.calls.Load())
+}
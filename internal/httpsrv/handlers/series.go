@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// PathParamSeries is a name of route variable with series name.
+const PathParamSeries = "name"
+
+type SeriesService interface {
+	CreateSeries(ctx context.Context, series models.Series) (models.Series, error)
+	UpdateSeries(ctx context.Context, name string, update models.SeriesUpdate) (models.Series, error)
+	DescribeSeries(ctx context.Context, name string) (models.SeriesDescription, error)
+	ListSeries(ctx context.Context, filter models.SeriesFilter) (models.SeriesPage, error)
+}
+
+// Series contains handlers for managing series definitions.
+type Series struct {
+	service SeriesService
+	logger  *slog.Logger
+}
+
+// NewSeries returns new series handlers struct.
+func NewSeries(service SeriesService, logger *slog.Logger) *Series {
+	return &Series{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Create validates request and registers new series.
+func (h *Series) Create(w http.ResponseWriter, r *http.Request) {
+	var series models.Series
+	if err := json.NewDecoder(r.Body).Decode(&series); err != nil {
+		writeError(w, r, h.logger, "failed to create series, failed to decode request", decodeError(err))
+		return
+	}
+
+	result, err := h.service.CreateSeries(r.Context(), series)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to create series", err,
+			slog.String("series", series.Name),
+		)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusCreated, result)
+}
+
+// Update applies partial update to the series.
+func (h *Series) Update(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)[PathParamSeries]
+
+	var update models.SeriesUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, r, h.logger, "failed to update series, failed to decode request", decodeError(err))
+		return
+	}
+
+	result, err := h.service.UpdateSeries(r.Context(), name, update)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to update series", err,
+			slog.String("series", name),
+		)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, result)
+}
+
+// Describe returns series definition with statistics.
+func (h *Series) Describe(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)[PathParamSeries]
+
+	result, err := h.service.DescribeSeries(r.Context(), name)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to describe series", err,
+			slog.String("series", name),
+		)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, result)
+}
+
+// List returns page of series filtered by labels.
+// Query params: `label=key:value` (can be repeated), `limit`, `page_token`.
+func (h *Series) List(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSeriesFilter(r)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to list series, invalid params", err)
+		return
+	}
+
+	result, err := h.service.ListSeries(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to list series", err)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, result)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, logger *slog.Logger, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		// Headers are already sent, so we can only log the error.
+		logger.ErrorContext(r.Context(), "failed to encode response", slog.Any("error", err))
+	}
+}
+
+func parseSeriesFilter(r *http.Request) (models.SeriesFilter, error) {
+	query := r.URL.Query()
+	filter := models.SeriesFilter{
+		After: query.Get("page_token"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return models.SeriesFilter{}, models.NewValidationError("limit", "must be integer")
+		}
+		filter.Limit = value
+	}
+
+	for _, label := range query["label"] {
+		key, value, ok := strings.Cut(label, ":")
+		if !ok || key == "" {
+			return models.SeriesFilter{}, models.NewValidationError("label", "must be in format key:value, got %q", label)
+		}
+		if filter.Labels == nil {
+			filter.Labels = make(map[string]string)
+		}
+		filter.Labels[key] = value
+	}
+
+	return filter, nil
+}
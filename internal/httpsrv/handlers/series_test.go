@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/steinfletcher/apitest"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const testSeriesName = "cpu_usage"
+
+type seriesServiceMock struct{}
+
+func (mock seriesServiceMock) CreateSeries(_ context.Context, series models.Series) (models.Series, error) {
+	switch series.Name {
+	case "":
+		return models.Series{}, models.NewValidationError("name", "must be set")
+	case testSeriesName:
+		return models.Series{}, fmt.Errorf("failed to create: %w", models.ErrConflict)
+	}
+	series.Version = 1
+	return series, nil
+}
+
+func (mock seriesServiceMock) UpdateSeries(_ context.Context, name string, _ models.SeriesUpdate,
+) (models.Series, error) {
+	if name != testSeriesName {
+		return models.Series{}, fmt.Errorf("failed to get: %w", models.ErrNotFound)
+	}
+	return models.Series{Name: name, Version: 2}, nil
+}
+
+func (mock seriesServiceMock) DescribeSeries(_ context.Context, name string) (models.SeriesDescription, error) {
+	if name != testSeriesName {
+		return models.SeriesDescription{}, fmt.Errorf("failed to get: %w", models.ErrNotFound)
+	}
+	return models.SeriesDescription{
+		Series: models.Series{Name: name, Version: 1},
+		Stats:  models.SeriesStats{Points: 1, Oldest: 1, Newest: 1},
+	}, nil
+}
+
+func (mock seriesServiceMock) ListSeries(_ context.Context, filter models.SeriesFilter) (models.SeriesPage, error) {
+	if filter.Limit < 0 {
+		return models.SeriesPage{}, models.NewValidationError("limit", "must not be negative")
+	}
+	series := models.Series{Name: testSeriesName, Labels: map[string]string{"env": "prod"}}
+	if !filter.Matches(&series) {
+		return models.SeriesPage{Series: []models.Series{}}, nil
+	}
+	return models.SeriesPage{Series: []models.Series{series}}, nil
+}
+
+func newSeriesRouter() *mux.Router {
+	h := &Series{
+		service: seriesServiceMock{},
+		logger:  slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/series", h.Create).Methods(http.MethodPost)
+	router.HandleFunc("/series", h.List).Methods(http.MethodGet)
+	router.HandleFunc("/series/{name}", h.Describe).Methods(http.MethodGet)
+	router.HandleFunc("/series/{name}", h.Update).Methods(http.MethodPatch)
+	return router
+}
+
+func TestSeries_Create(t *testing.T) {
+	t.Parallel()
+	router := newSeriesRouter()
+
+	testCases := []struct {
+		statusCode int
+		body       string
+	}{
+		{http.StatusCreated, `{"name":"memory_usage","value_type":{"type":"float64"}}`},
+		{http.StatusConflict, `{"name":"cpu_usage"}`},
+		{http.StatusBadRequest, `{"name":""}`},
+		{http.StatusBadRequest, `{"name":1}`},
+		{http.StatusBadRequest, ``},
+	}
+
+	for _, tt := range testCases {
+		apitest.New().
+			Handler(router).
+			Post("/series").
+			Body(tt.body).
+			Expect(t).
+			Status(tt.statusCode).
+			End()
+	}
+}
+
+func TestSeries_Update(t *testing.T) {
+	t.Parallel()
+	router := newSeriesRouter()
+
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+	}{
+		{testSeriesName, http.StatusOK, `{"unit":"percent"}`},
+		{"unknown", http.StatusNotFound, `{"unit":"percent"}`},
+		{testSeriesName, http.StatusBadRequest, `{"unit":1}`},
+	}
+
+	for _, tt := range testCases {
+		apitest.New().
+			Handler(router).
+			Patch("/series/" + tt.name).
+			Body(tt.body).
+			Expect(t).
+			Status(tt.statusCode).
+			End()
+	}
+}
+
+func TestSeries_Describe(t *testing.T) {
+	t.Parallel()
+	router := newSeriesRouter()
+
+	apitest.New().
+		Handler(router).
+		Get("/series/" + testSeriesName).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{"name":"cpu_usage","data_source":"","step":0,"retention":{},"value_type":{"type":""},` +
+			`"version":1,"created_at":0,"updated_at":0,"stats":{"points":1,"oldest":1,"newest":1}}`).
+		End()
+
+	apitest.New().
+		Handler(router).
+		Get("/series/unknown").
+		Expect(t).
+		Status(http.StatusNotFound).
+		End()
+}
+
+func TestSeries_List(t *testing.T) {
+	t.Parallel()
+	router := newSeriesRouter()
+
+	testCases := []struct {
+		query      map[string]string
+		statusCode int
+		body       string
+	}{
+		{map[string]string{}, http.StatusOK, `{"series":[{"name":"cpu_usage","labels":{"env":"prod"},` +
+			`"data_source":"","step":0,"retention":{},"value_type":{"type":""},"version":0,"created_at":0,"updated_at":0}]}`},
+		{map[string]string{"label": "env:dev"}, http.StatusOK, `{"series":[]}`},
+		{map[string]string{"label": "env"}, http.StatusBadRequest, ""},
+		{map[string]string{"limit": "a"}, http.StatusBadRequest, ""},
+		{map[string]string{"limit": "-1"}, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range testCases {
+		test := apitest.New().
+			Handler(router).
+			Get("/series").
+			QueryParams(tt.query).
+			Expect(t).
+			Status(tt.statusCode)
+		if tt.body != "" {
+			test = test.Body(tt.body)
+		}
+		test.End()
+	}
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/stream"
+)
+
+// streamWriteTimeout is a max time of writing one message to a subscriber.
+const streamWriteTimeout = 10 * time.Second
+
+// StreamService subscribes to new records of series.
+type StreamService interface {
+	Subscribe(ctx context.Context, series string, backfill int) ([]models.Record, *stream.Subscription, error)
+}
+
+// StreamOptions contains params of streams.
+type StreamOptions struct {
+	// MaxBackfill is a max number of last records that are sent before new ones.
+	MaxBackfill int
+	// Heartbeat is an interval of SSE comments and WebSocket pings that keep idle connections open, zero disables them.
+	Heartbeat time.Duration
+}
+
+// Stream contains handlers streaming new records.
+type Stream struct {
+	service  StreamService
+	opts     StreamOptions
+	upgrader websocket.Upgrader
+	logger   *slog.Logger
+}
+
+// NewStream returns new handlers struct.
+func NewStream(service StreamService, opts StreamOptions, logger *slog.Logger) *Stream {
+	h := &Stream{
+		service: service,
+		opts:    opts,
+		logger:  logger,
+	}
+	// Origin of browsers must match the host, as default CheckOrigin of the upgrader allows.
+	h.upgrader = websocket.Upgrader{
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			writeError(w, r, h.logger, "failed to upgrade to websocket", upgradeError(status, reason))
+		},
+	}
+	return h
+}
+
+// Subscribe streams records of a series created after subscription, it uses WebSocket if request is an upgrade
+// and Server-Sent Events otherwise. Query params: `series` and `backfill` - number of last records sent first.
+func (h *Stream) Subscribe(w http.ResponseWriter, r *http.Request) {
+	series := r.URL.Query().Get("series")
+	backfill, err := h.parseBackfill(r.URL.Query().Get("backfill"))
+	if err != nil {
+		writeError(w, r, h.logger, "failed to subscribe, invalid params", err)
+		return
+	}
+
+	// Subscription starts before upgrade, so errors are returned as problems.
+	records, sub, err := h.service.Subscribe(r.Context(), series, backfill)
+	if err != nil {
+		writeError(w, r, h.logger, "failed to subscribe", err,
+			slog.String("series", series),
+		)
+		return
+	}
+	defer sub.Close()
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r, records, sub)
+		return
+	}
+	h.serveSSE(w, r, records, sub)
+}
+
+func (h *Stream) parseBackfill(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	backfill, err := strconv.Atoi(value)
+	switch {
+	case err != nil:
+		return 0, models.NewValidationError("backfill", "must be integer")
+	case backfill < 0:
+		return 0, models.NewValidationError("backfill", "must not be negative")
+	case backfill > h.opts.MaxBackfill:
+		return 0, models.NewValidationError("backfill", "must not exceed %d", h.opts.MaxBackfill)
+	}
+	return backfill, nil
+}
+
+// streamWriter writes messages of a stream in its protocol.
+type streamWriter interface {
+	record(record models.Record) error
+	heartbeat() error
+	// end tells subscriber why the stream ends.
+	end(err error)
+}
+
+// stream sends backfill and then new records until subscription or context ends.
+func (h *Stream) stream(ctx context.Context, out streamWriter, backfill []models.Record, sub *stream.Subscription) {
+	// Records created while backfill was loaded may be both in backfill and in subscription. Only the first record
+	// of a backfilled timestamp may be such duplicate, and it is skipped only if it has the same value, so
+	// overwrite of a backfilled record is sent.
+	sent := make(map[int64]any, len(backfill))
+	for _, record := range backfill {
+		sent[record.Timestamp] = record.MetricValue
+		if err := out.record(record); err != nil {
+			return
+		}
+	}
+
+	var heartbeat <-chan time.Time
+	if h.opts.Heartbeat > 0 {
+		ticker := time.NewTicker(h.opts.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	for {
+		select {
+		case record := <-sub.Records():
+			if value, ok := sent[record.Timestamp]; ok {
+				delete(sent, record.Timestamp)
+				if reflect.DeepEqual(value, record.MetricValue) {
+					continue
+				}
+			}
+			// Subscriber knows the series, storage name contains tenant.
+			record.Series = ""
+			if err := out.record(record); err != nil {
+				return
+			}
+		case <-heartbeat:
+			if err := out.heartbeat(); err != nil {
+				return
+			}
+		case <-sub.Done():
+			if err := sub.Err(); err != nil {
+				h.logger.WarnContext(ctx, "stream is ended", slog.Any("error", err))
+				out.end(err)
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// serveSSE streams records as `record` events with json data, comments are sent as heartbeats.
+func (h *Stream) serveSSE(w http.ResponseWriter, r *http.Request, backfill []models.Record, sub *stream.Subscription) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Proxies must not buffer events.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	out := &sseWriter{w: w, rc: http.NewResponseController(w)}
+	if err := out.flush(); err != nil {
+		return
+	}
+	h.stream(r.Context(), out, backfill, sub)
+}
+
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s *sseWriter) record(record models.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.write("event: record\ndata: " + string(data) + "\n\n")
+}
+
+func (s *sseWriter) heartbeat() error {
+	return s.write(": heartbeat\n\n")
+}
+
+func (s *sseWriter) end(err error) {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	_ = s.write("event: end\ndata: " + string(data) + "\n\n")
+}
+
+func (s *sseWriter) write(message string) error {
+	// Stream outlives write timeout of the server, so each message has its own deadline.
+	_ = s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := s.w.Write([]byte(message)); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (s *sseWriter) flush() error {
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// serveWebSocket streams records as json text messages, pings are sent as heartbeats.
+func (h *Stream) serveWebSocket(
+	w http.ResponseWriter, r *http.Request, backfill []models.Record, sub *stream.Subscription,
+) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrader has already responded.
+		return
+	}
+	defer conn.Close()
+
+	// Request context isn't canceled when hijacked connection is closed, so it is canceled by reader.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		// Subscribers only answer pings and close the stream, so messages are small.
+		conn.SetReadLimit(512)
+		if h.opts.Heartbeat > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(2 * h.opts.Heartbeat))
+			conn.SetPongHandler(func(string) error {
+				return conn.SetReadDeadline(time.Now().Add(2 * h.opts.Heartbeat))
+			})
+		}
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	h.stream(ctx, &webSocketWriter{conn: conn}, backfill, sub)
+}
+
+type webSocketWriter struct {
+	conn *websocket.Conn
+}
+
+func (s *webSocketWriter) record(record models.Record) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return s.conn.WriteJSON(record)
+}
+
+func (s *webSocketWriter) heartbeat() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+}
+
+func (s *webSocketWriter) end(err error) {
+	code := websocket.CloseGoingAway
+	if errors.Is(err, stream.ErrSlowConsumer) {
+		code = websocket.CloseTryAgainLater
+	}
+	message := websocket.FormatCloseMessage(code, err.Error())
+	_ = s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(streamWriteTimeout))
+}
+
+// upgradeError converts error of websocket handshake to models error.
+func upgradeError(status int, reason error) error {
+	switch status {
+	case http.StatusForbidden:
+		return models.NewDetailError(models.ErrForbidden, "%s", reason)
+	case http.StatusBadRequest, http.StatusMethodNotAllowed:
+		return models.NewDetailError(models.ErrInvalidArgument, "%s", reason)
+	default:
+		return reason
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/stream"
+)
+
+// streamServiceMock subscribes to the hub, backfill has records with timestamps from 1 to backfill.
+type streamServiceMock struct {
+	hub *stream.Hub
+}
+
+func (mock streamServiceMock) Subscribe(
+	_ context.Context, series string, backfill int,
+) ([]models.Record, *stream.Subscription, error) {
+	if series == unknownSeries {
+		return nil, nil, fmt.Errorf("failed to get series: %w", models.ErrNotFound)
+	}
+	sub, err := mock.hub.Subscribe(series)
+	if err != nil {
+		return nil, nil, err
+	}
+	records := make([]models.Record, 0, backfill)
+	for i := 1; i <= backfill; i++ {
+		records = append(records, models.Record{Timestamp: int64(i), MetricValue: 1.5})
+	}
+	return records, sub, nil
+}
+
+func newStreamMock(heartbeat time.Duration) (*Stream, *stream.Hub) {
+	hub := stream.NewHub(stream.Options{BufferSize: 10})
+	h := NewStream(streamServiceMock{hub: hub}, StreamOptions{MaxBackfill: 5, Heartbeat: heartbeat},
+		slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	return h, hub
+}
+
+func TestStream_Invalid(t *testing.T) {
+	t.Parallel()
+	h, _ := newStreamMock(0)
+	testCases := []struct {
+		query  string
+		status int
+	}{
+		{"backfill=abc", http.StatusBadRequest},
+		{"backfill=-1", http.StatusBadRequest},
+		{"backfill=6", http.StatusBadRequest},
+		{"series=unknown", http.StatusNotFound},
+	}
+
+	for i, tc := range testCases {
+		rec := httptest.NewRecorder()
+		h.Subscribe(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/stream?"+tc.query, nil))
+		require.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		require.Equal(t, contentTypeProblem, rec.Header().Get("Content-Type"), fmt.Sprintf("case %d", i))
+	}
+}
+
+// readEvent reads the next event of a stream, heartbeats are reported as events without name.
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return event, data
+		case strings.HasPrefix(line, ": "):
+			data = strings.TrimPrefix(line, ": ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStream_SSE(t *testing.T) {
+	t.Parallel()
+	h, hub := newStreamMock(20 * time.Millisecond)
+	server := httptest.NewServer(http.HandlerFunc(h.Subscribe))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?series=cpu&backfill=2")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	for _, expected := range []string{`{"timestamp":1,"metric_value":1.5}`, `{"timestamp":2,"metric_value":1.5}`} {
+		event, data := readEvent(t, reader)
+		require.Equal(t, "record", event)
+		require.JSONEq(t, expected, data)
+	}
+
+	// Record from backfill is sent once, its overwrite is sent, series isn't sent.
+	hub.Publish(models.Record{Series: "cpu", Timestamp: 2, MetricValue: 1.5})
+	hub.Publish(models.Record{Series: "cpu", Timestamp: 1, MetricValue: 0.5})
+	hub.Publish(models.Record{Series: "cpu", Timestamp: 3, MetricValue: 2.5})
+	hub.Publish(models.Record{Series: "memory", Timestamp: 4, MetricValue: 2.5})
+	for _, expected := range []string{`{"timestamp":1,"metric_value":0.5}`, `{"timestamp":3,"metric_value":2.5}`} {
+		event, data := readEvent(t, reader)
+		for event == "" {
+			event, data = readEvent(t, reader)
+		}
+		require.Equal(t, "record", event)
+		require.JSONEq(t, expected, data)
+	}
+
+	event, data := readEvent(t, reader)
+	require.Equal(t, "", event)
+	require.Equal(t, "heartbeat", data)
+
+	hub.Close()
+	event, data = readEvent(t, reader)
+	for event == "" {
+		event, data = readEvent(t, reader)
+	}
+	require.Equal(t, "end", event)
+	require.JSONEq(t, `{"error":"hub is closed"}`, data)
+}
+
+func TestStream_WebSocket(t *testing.T) {
+	t.Parallel()
+	h, hub := newStreamMock(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(h.Subscribe))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?series=cpu&backfill=1"
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	var record models.Record
+	require.NoError(t, conn.ReadJSON(&record))
+	require.Equal(t, int64(1), record.Timestamp)
+
+	hub.Publish(models.Record{Series: "cpu", Timestamp: 3, MetricValue: 2.5})
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"timestamp":3,"metric_value":2.5}`, string(message))
+
+	hub.Close()
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+
+	// Errors of subscription are returned before upgrade.
+	_, resp, err = websocket.DefaultDialer.Dial(url, nil)
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
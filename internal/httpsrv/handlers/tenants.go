@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type TenantService interface {
+	Usage(ctx context.Context) (models.TenantUsage, error)
+	ListUsage(ctx context.Context) ([]models.TenantUsage, error)
+}
+
+// Tenants contains handlers for usage reporting of tenants.
+type Tenants struct {
+	service TenantService
+	logger  *slog.Logger
+}
+
+// NewTenants returns new tenants handlers struct.
+func NewTenants(service TenantService, logger *slog.Logger) *Tenants {
+	return &Tenants{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Usage returns resources used by the request tenant and its limits.
+func (h *Tenants) Usage(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.Usage(r.Context())
+	if err != nil {
+		writeError(w, r, h.logger, "failed to get tenant usage", err)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, result)
+}
+
+// List returns resources used by all tenants.
+func (h *Tenants) List(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.ListUsage(r.Context())
+	if err != nil {
+		writeError(w, r, h.logger, "failed to list tenants usage", err)
+		return
+	}
+
+	writeJSON(w, r, h.logger, http.StatusOK, result)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type tenantServiceMock struct {
+	err error
+}
+
+func (mock tenantServiceMock) Usage(ctx context.Context) (models.TenantUsage, error) {
+	if mock.err != nil {
+		return models.TenantUsage{}, mock.err
+	}
+	return models.TenantUsage{
+		Tenant:   models.TenantFromContext(ctx),
+		Series:   2,
+		Points:   10,
+		Capacity: 200,
+		Ingest:   models.IngestUsage{Accepted: 10, Rejected: 1},
+		Limits:   models.TenantLimits{MaxSeries: 5, Capacity: 100},
+	}, nil
+}
+
+func (mock tenantServiceMock) ListUsage(ctx context.Context) ([]models.TenantUsage, error) {
+	usage, err := mock.Usage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []models.TenantUsage{usage}, nil
+}
+
+func TestTenants(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		service    tenantServiceMock
+		handler    func(h *Tenants) http.HandlerFunc
+		statusCode int
+		body       string
+	}{
+		{
+			tenantServiceMock{},
+			func(h *Tenants) http.HandlerFunc { return h.Usage },
+			http.StatusOK,
+			`{"tenant":"default","series":2,"points":10,"capacity":200,"ingest":{"accepted":10,"rejected":1},` +
+				`"limits":{"max_series":5,"capacity":100}}`,
+		},
+		{
+			tenantServiceMock{},
+			func(h *Tenants) http.HandlerFunc { return h.List },
+			http.StatusOK,
+			`[{"tenant":"default","series":2,"points":10,"capacity":200,"ingest":{"accepted":10,"rejected":1},` +
+				`"limits":{"max_series":5,"capacity":100}}]`,
+		},
+		{
+			tenantServiceMock{err: fmt.Errorf("failed to list: %w", models.ErrUnavailable)},
+			func(h *Tenants) http.HandlerFunc { return h.Usage },
+			http.StatusServiceUnavailable,
+			"",
+		},
+		{
+			tenantServiceMock{err: fmt.Errorf("failed to list: %w", models.ErrUnavailable)},
+			func(h *Tenants) http.HandlerFunc { return h.List },
+			http.StatusServiceUnavailable,
+			"",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			h := NewTenants(tc.service, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+			test := apitest.New().
+				HandlerFunc(tc.handler(h)).
+				Get("/usage").
+				Expect(t).
+				Status(tc.statusCode)
+			if tc.body != "" {
+				test = test.Body(tc.body)
+			}
+			test.End()
+		})
+	}
+}
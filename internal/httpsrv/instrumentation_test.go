@@ -0,0 +1,107 @@
+package httpsrv
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+)
+
+type observation struct {
+	route  string
+	method string
+	status int
+}
+
+type observerMock struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (mock *observerMock) ObserveRequest(route, method string, status int, _ time.Duration) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.observations = append(mock.observations, observation{route: route, method: method, status: status})
+}
+
+func testHandlers(observer RequestObserver, metricsPath string) Handlers {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	return Handlers{
+		RRD:       handlers.NewRRD(nil, nil, nil, logger),
+		Series:    handlers.NewSeries(nil, logger),
+		Retention: handlers.NewRetention(nil, logger),
+		Tenants:   handlers.NewTenants(nil, logger),
+		Health:    handlers.NewHealth(nil, logger),
+		Stream:    handlers.NewStream(nil, handlers.StreamOptions{}, logger),
+		Alerts:    handlers.NewAlerts(nil, logger),
+		Forecast:  handlers.NewForecast(nil, logger),
+		Metrics: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+		MetricsPath: metricsPath,
+		Observer:    observer,
+	}
+}
+
+func TestRouter_Instrumentation(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		method   string
+		path     string
+		expected observation
+	}{
+		{http.MethodGet, "/healthz", observation{"/healthz", http.MethodGet, http.StatusOK}},
+		{http.MethodGet, "/internal/metrics", observation{"/internal/metrics", http.MethodGet, http.StatusOK}},
+		{http.MethodGet, "/unknown", observation{routeUnmatched, http.MethodGet, http.StatusNotFound}},
+		{http.MethodDelete, "/healthz", observation{routeUnmatched, http.MethodDelete, http.StatusMethodNotAllowed}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			observer := &observerMock{}
+			router, err := NewRouter(testHandlers(observer, "/internal/metrics"),
+				slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+			require.NoError(t, err)
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+			require.Equal(t, []observation{tc.expected}, observer.observations)
+		})
+	}
+}
+
+func TestRouter_MetricsPath(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		path  string
+		valid bool
+	}{
+		{"/internal/metrics", true},
+		{"/prometheus", true},
+		{"/metrics", false},
+		{"/series/cpu", false},
+		{"/healthz", false},
+		{"internal/metrics", false},
+		{"", false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			_, err := NewRouter(testHandlers(nil, tc.path), slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
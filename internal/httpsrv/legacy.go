@@ -0,0 +1,58 @@
+package httpsrv
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// prefixV1 is a path prefix of API v1, routes without prefix are legacy routes of the first release.
+const prefixV1 = "/api/v1"
+
+// Headers of deprecated routes.
+const (
+	headerDeprecation = "Deprecation"
+	headerSunset      = "Sunset"
+	headerLink        = "Link"
+)
+
+// legacyDeprecatedAt is when routes without version prefix were deprecated in favor of API v1.
+var legacyDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// LegacyOptions configures legacy routes without version prefix.
+type LegacyOptions struct {
+	// Disabled removes legacy routes, so only API v1 is served.
+	Disabled bool
+	// Sunset is when legacy routes are removed, it is sent in Sunset header if it is set.
+	Sunset time.Time
+}
+
+// LegacyObserver records usage of legacy routes.
+type LegacyObserver interface {
+	ObserveLegacyRequest(route, method string)
+}
+
+// deprecate returns middleware of legacy routes, it adds Deprecation (RFC 9745) and Sunset (RFC 8594) headers
+// with a link to the successor route of API v1, and records usage of the route.
+func deprecate(opts LegacyOptions, observer LegacyObserver) func(http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(legacyDeprecatedAt.Unix(), 10)
+	var sunset string
+	if !opts.Sunset.IsZero() {
+		sunset = opts.Sunset.UTC().Format(http.TimeFormat)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			header.Set(headerDeprecation, deprecation)
+			header.Add(headerLink, fmt.Sprintf(`<%s>; rel="successor-version"`, prefixV1+r.URL.EscapedPath()))
+			if sunset != "" {
+				header.Set(headerSunset, sunset)
+			}
+			if observer != nil {
+				observer.ObserveLegacyRequest(routeTemplate(r), r.Method)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
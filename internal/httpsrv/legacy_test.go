@@ -0,0 +1,87 @@
+package httpsrv
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type legacyObserverMock struct {
+	observerMock
+	mu     sync.Mutex
+	routes []string
+}
+
+func (mock *legacyObserverMock) ObserveLegacyRequest(route, method string) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.routes = append(mock.routes, method+" "+route)
+}
+
+func TestRouter_Legacy(t *testing.T) {
+	t.Parallel()
+	sunset := time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		method string
+		path   string
+		link   string
+		route  string
+	}{
+		{http.MethodGet, "/status", `</api/v1/status>; rel="successor-version"`, "GET /status"},
+		{http.MethodGet, "/series/cpu", `</api/v1/series/cpu>; rel="successor-version"`, "GET /series/{name}"},
+		// Headers are sent with errors too.
+		{http.MethodPut, "/metrics", `</api/v1/metrics>; rel="successor-version"`, "PUT /metrics"},
+		{http.MethodGet, "/api/v1/status", "", ""},
+		{http.MethodGet, "/healthz", "", ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			observer := &legacyObserverMock{}
+			h := newOpenAPITestHandlers()
+			h.Observer = observer
+			h.Legacy = LegacyOptions{Sunset: sunset}
+			router, err := NewRouter(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+			require.Equal(t, tc.link, rec.Header().Get(headerLink))
+			if tc.route == "" {
+				require.Empty(t, rec.Header().Get(headerDeprecation))
+				require.Empty(t, rec.Header().Get(headerSunset))
+				require.Empty(t, observer.routes)
+				return
+			}
+			require.Equal(t, fmt.Sprintf("@%d", legacyDeprecatedAt.Unix()), rec.Header().Get(headerDeprecation))
+			require.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", rec.Header().Get(headerSunset))
+			require.Equal(t, []string{tc.route}, observer.routes)
+		})
+	}
+}
+
+func TestRouter_LegacyDisabled(t *testing.T) {
+	t.Parallel()
+	h := newOpenAPITestHandlers()
+	h.Legacy = LegacyOptions{Disabled: true}
+	router, err := NewRouter(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+
+	for path, status := range map[string]int{
+		"/status":        http.StatusNotFound,
+		"/metrics":       http.StatusNotFound,
+		"/api/v1/status": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, status, rec.Code, path)
+	}
+}
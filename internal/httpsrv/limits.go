@@ -0,0 +1,192 @@
+package httpsrv
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
+)
+
+const (
+	// clientIdleTimeout is a time after which rate limiter of an idle client is dropped.
+	clientIdleTimeout = 10 * time.Minute
+	// routeAuthentication is a route of rate limits of failed authentication of remote ips.
+	routeAuthentication = "authentication"
+)
+
+// Limits are limits of requests of each client.
+type Limits struct {
+	// Routes are rate limits keyed by method and route template, e.g. `PUT /metrics`.
+	Routes map[string]models.RateLimit
+	// Default is a rate limit of routes that are not listed.
+	Default models.RateLimit
+	// Authentication is a rate limit of failed authentication of each remote ip, so credentials can't be guessed
+	// at the rate of identified clients. Requests of a remote ip that exceeded it are rejected before
+	// authentication, authenticated requests don't count toward it.
+	Authentication models.RateLimit
+	// MaxBodyBytes is a max size of request body, zero means no limit.
+	MaxBodyBytes int64
+}
+
+func (l Limits) route(route string) models.RateLimit {
+	if route == routeAuthentication {
+		return l.Authentication
+	}
+	if limit, ok := l.Routes[route]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+type clientRoute struct {
+	client string
+	route  string
+}
+
+type clientLimiter struct {
+	limiter  *resilience.Limiter
+	lastSeen time.Time
+}
+
+// Limiter enforces rate limits of clients on routes and max body size, limits can be changed on config reload.
+type Limiter struct {
+	mu          sync.Mutex
+	limits      Limits
+	clients     map[clientRoute]*clientLimiter
+	lastCleanup time.Time
+}
+
+// NewLimiter returns new limiter of requests.
+func NewLimiter(limits Limits) *Limiter {
+	return &Limiter{
+		limits:      limits,
+		clients:     make(map[clientRoute]*clientLimiter),
+		lastCleanup: time.Now(),
+	}
+}
+
+// SetLimits changes limits, rate limiters of known clients keep their tokens up to the new burst.
+func (l *Limiter) SetLimits(limits Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+	for key, client := range l.clients {
+		limit := limits.route(key.route)
+		client.limiter.SetLimit(limit.Rate, limit.Burst)
+	}
+}
+
+func (l *Limiter) maxBodyBytes() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits.MaxBodyBytes
+}
+
+// allow takes a token of the client on the route, or returns time after which it will be available.
+func (l *Limiter) allow(route, client string) (bool, time.Duration) {
+	if limiter := l.client(route, client); limiter != nil {
+		return limiter.Allow(1)
+	}
+	return true, 0
+}
+
+// available reports whether the client has a token on the route like allow, but it doesn't take it.
+func (l *Limiter) available(route, client string) (bool, time.Duration) {
+	if limiter := l.client(route, client); limiter != nil {
+		return limiter.Available(1)
+	}
+	return true, 0
+}
+
+// client returns rate limiter of the client on the route, it is nil if the route is not limited.
+func (l *Limiter) client(route, client string) *resilience.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit := l.limits.route(route)
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(l.lastCleanup) > clientIdleTimeout {
+		for key, state := range l.clients {
+			if now.Sub(state.lastSeen) > clientIdleTimeout {
+				delete(l.clients, key)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	key := clientRoute{client: client, route: route}
+	state, ok := l.clients[key]
+	if !ok {
+		state = &clientLimiter{limiter: resilience.NewLimiter(limit.Rate, limit.Burst)}
+		l.clients[key] = state
+	}
+	state.lastSeen = now
+	return state.limiter
+}
+
+// limitBody returns middleware that rejects requests with body larger than max body size.
+func limitBody(limiter *Limiter, logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBytes := limiter.maxBodyBytes(); maxBytes > 0 {
+				if r.ContentLength > maxBytes {
+					handlers.WriteError(w, r, logger, "request body is too large",
+						models.NewDetailError(models.ErrTooLarge, "body exceeds %d bytes", maxBytes))
+					return
+				}
+				// Body without content length is limited while it is read.
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitRate returns middleware that limits rate of requests of each client on the route. Clients are identified
+// by identity of the request, so it must be applied after authentication, or by remote ip.
+func limitRate(limiter *Limiter, route string, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := clientKey(r)
+			if allowed, wait := limiter.allow(route, client); !allowed {
+				writeRateLimited(w, r, logger, route, client, wait)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeRateLimited(w http.ResponseWriter, r *http.Request, logger *slog.Logger, route, client string,
+	wait time.Duration,
+) {
+	handlers.WriteError(w, r, logger, "rate limit is exceeded",
+		models.NewRetryError(wait, models.NewDetailError(models.ErrRateLimited, "rate limit of %s is exceeded", route)),
+		slog.String("client", client),
+	)
+}
+
+// clientKey returns identity subject of the request or remote ip if request is not authenticated.
+func clientKey(r *http.Request) string {
+	if identity, ok := models.IdentityFromContext(r.Context()); ok {
+		return "subject:" + identity.Tenant + models.TenantSeparator + identity.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,178 @@
+package httpsrv
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func TestRouter_RateLimit(t *testing.T) {
+	t.Parallel()
+	limiter := NewLimiter(Limits{
+		Routes: map[string]models.RateLimit{"GET /internal/metrics": {Rate: 1, Burst: 2}},
+	})
+	h := testHandlers(nil, "/internal/metrics")
+	h.Limiter = limiter
+	router, err := NewRouter(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		method     string
+		path       string
+		remoteAddr string
+		statusCode int
+	}{
+		{http.MethodGet, "/internal/metrics", "10.0.0.1:1000", http.StatusOK},
+		{http.MethodGet, "/internal/metrics", "10.0.0.1:1001", http.StatusOK},
+		{http.MethodGet, "/internal/metrics", "10.0.0.1:1002", http.StatusTooManyRequests},
+		// Clients and routes have separate limits, probes are not limited.
+		{http.MethodGet, "/internal/metrics", "10.0.0.2:1000", http.StatusOK},
+		{http.MethodPut, "/metrics", "10.0.0.1:1000", http.StatusBadRequest},
+		{http.MethodGet, "/healthz", "10.0.0.1:1000", http.StatusOK},
+	}
+	for i, tc := range testCases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.RemoteAddr = tc.remoteAddr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, tc.statusCode, rec.Code, fmt.Sprintf("case %d", i))
+		if tc.statusCode == http.StatusTooManyRequests {
+			require.Equal(t, "1", rec.Header().Get("Retry-After"), fmt.Sprintf("case %d", i))
+		}
+	}
+
+	// Reloaded limits apply to known clients.
+	limiter.SetLimits(Limits{})
+	req := httptest.NewRequest(http.MethodGet, "/internal/metrics", nil)
+	req.RemoteAddr = "10.0.0.1:1000"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRouter_AuthRateLimit(t *testing.T) {
+	t.Parallel()
+	h := testHandlers(nil, "/internal/metrics")
+	h.Auth = testAuthenticator
+	h.Limiter = NewLimiter(Limits{Authentication: models.RateLimit{Rate: 1, Burst: 2}})
+	router, err := NewRouter(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		key        string
+		remoteAddr string
+		statusCode int
+	}{
+		// Requests with invalid credentials are limited by remote ip.
+		{"unknown", "10.0.0.1:1000", http.StatusUnauthorized},
+		{"guess", "10.0.0.1:1001", http.StatusUnauthorized},
+		{"admin", "10.0.0.1:1002", http.StatusTooManyRequests},
+		{"admin", "10.0.0.2:1000", http.StatusOK},
+	}
+	for i, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/internal/metrics", nil)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set("X-API-Key", tc.key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, tc.statusCode, rec.Code, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestRouter_AuthRateLimitSharedIP(t *testing.T) {
+	t.Parallel()
+	h := testHandlers(nil, "/internal/metrics")
+	h.Auth = authenticatorMock{
+		"first":  {Subject: "first", Tenant: "ops", Scopes: []models.Scope{models.ScopeAdmin}},
+		"second": {Subject: "second", Tenant: "ops", Scopes: []models.Scope{models.ScopeAdmin}},
+		"third":  {Subject: "third", Tenant: "ops", Scopes: []models.Scope{models.ScopeAdmin}},
+	}
+	h.Limiter = NewLimiter(Limits{
+		Default:        models.RateLimit{Rate: 1, Burst: 2},
+		Authentication: models.RateLimit{Rate: 1, Burst: 1},
+	})
+	router, err := NewRouter(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		key        string
+		statusCode int
+	}{
+		// Authenticated requests of clients behind one ip don't count toward the limit of the ip.
+		{"first", http.StatusOK},
+		{"first", http.StatusOK},
+		{"first", http.StatusTooManyRequests},
+		{"second", http.StatusOK},
+		{"second", http.StatusOK},
+		{"second", http.StatusTooManyRequests},
+		// Failed authentication exhausts the limit of the ip, so the ip is rejected before authentication.
+		{"guess", http.StatusUnauthorized},
+		{"third", http.StatusTooManyRequests},
+	}
+	for i, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/internal/metrics", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		req.Header.Set("X-API-Key", tc.key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, tc.statusCode, rec.Code, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestRouter_BodyLimit(t *testing.T) {
+	t.Parallel()
+	h := testHandlers(nil, "/internal/metrics")
+	h.Limiter = NewLimiter(Limits{MaxBodyBytes: 10})
+	router, err := NewRouter(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		body          string
+		contentLength bool
+		statusCode    int
+	}{
+		{`{"timestamp": 1, "metric_value": 1}`, true, http.StatusRequestEntityTooLarge},
+		// Body without content length is limited on read.
+		{`{"timestamp": 1, "metric_value": 1}`, false, http.StatusRequestEntityTooLarge},
+		{`{"a": 1`, true, http.StatusBadRequest},
+	}
+	for i, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPut, "/metrics", strings.NewReader(tc.body))
+		if !tc.contentLength {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, tc.statusCode, rec.Code, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		identity   *models.Identity
+		remoteAddr string
+		expected   string
+	}{
+		{&models.Identity{Subject: "agent", Tenant: "acme"}, "10.0.0.1:1000", "subject:acme/agent"},
+		{nil, "10.0.0.1:1000", "ip:10.0.0.1"},
+		{nil, "[::1]:1000", "ip:::1"},
+		{nil, "pipe", "ip:pipe"},
+	}
+	for i, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.identity != nil {
+			req = req.WithContext(models.WithIdentity(req.Context(), *tc.identity))
+		}
+		require.Equal(t, tc.expected, clientKey(req), fmt.Sprintf("case %d", i))
+	}
+}
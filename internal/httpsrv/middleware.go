@@ -0,0 +1,185 @@
+package httpsrv
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"aerospike.com/rrd/internal/httpsrv/auth"
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
+)
+
+// corsAllowedHeaders are request headers that browsers may send, they are used by authentication and compression.
+var corsAllowedHeaders = []string{
+	"Authorization", "Content-Type", "Content-Encoding", auth.HeaderAPIKey, auth.HeaderTimestamp, HeaderTenant,
+	handlers.HeaderRequestID,
+}
+
+// corsExposedHeaders are response headers that browsers expose to scripts.
+var corsExposedHeaders = []string{
+	"Retry-After", "WWW-Authenticate", handlers.HeaderRequestID, handlers.HeaderNextPageToken,
+	headerDeprecation, headerSunset, headerLink,
+}
+
+// corsAllowedMethods are methods of all routes.
+var corsAllowedMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodPatch, http.MethodDelete,
+}
+
+// Middleware wraps http handler.
+type Middleware func(http.Handler) http.Handler
+
+// chain applies middlewares to handler, the first middleware is the outermost one.
+func chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// MiddlewareOptions configure middlewares that are applied to all requests, including unknown routes.
+type MiddlewareOptions struct {
+	// AccessLog enables logging of each request.
+	AccessLog bool
+	CORS      CORSOptions
+	// Compression are response encodings in order of preference, responses are not compressed if empty.
+	Compression []string
+	// CompressionMinSize is a min size of response body that is compressed.
+	CompressionMinSize int
+}
+
+// CORSOptions allow browsers to call API from other origins.
+type CORSOptions struct {
+	// AllowedOrigins are origins allowed to call API, `*` allows any origin, CORS is disabled if empty.
+	AllowedOrigins []string
+	// MaxAge is a time of caching preflight responses by browsers.
+	MaxAge time.Duration
+}
+
+// middlewares returns middlewares in order of application. Request id and span are the outermost ones, so all logs
+// have them, panics are recovered inside compression, so their responses are written and compressed as usual.
+func (o MiddlewareOptions) middlewares(logger *slog.Logger) []Middleware {
+	middlewares := []Middleware{requestID, traceRequest}
+	if o.AccessLog {
+		middlewares = append(middlewares, accessLog(logger))
+	}
+	if len(o.CORS.AllowedOrigins) > 0 {
+		middlewares = append(middlewares, cors(o.CORS))
+	}
+	if len(o.Compression) > 0 {
+		middlewares = append(middlewares, compress(o.Compression, o.CompressionMinSize))
+	}
+	return append(middlewares, recoverPanic(logger), decompress(logger))
+}
+
+// requestID adds request id to context and response headers, id is taken from request header or generated.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := handlers.RequestID(w, r)
+		next.ServeHTTP(w, r.WithContext(models.WithRequestID(r.Context(), id)))
+	})
+}
+
+// accessLog returns middleware that logs each request, successful probes are logged with debug level.
+func accessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			level := slog.LevelInfo
+			if (r.URL.Path == pathLive || r.URL.Path == pathReady) && recorder.status() == http.StatusOK {
+				level = slog.LevelDebug
+			}
+			logger.LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("proto", r.Proto),
+				slog.Int("status", recorder.status()),
+				slog.Int64("bytes", recorder.bytes),
+				slog.Duration("duration", time.Since(started)),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
+
+// recoverPanic returns middleware that converts panics of handlers to 500 responses, so the connection is kept.
+// http.ErrAbortHandler is not recovered, as it aborts the response on purpose.
+func recoverPanic(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				err := fmt.Errorf("panic: %v", p)
+				if recorder.code != 0 {
+					// Headers are already sent, so the response can't be replaced.
+					logger.ErrorContext(r.Context(), "handler panicked after response is started",
+						slog.Any("error", err), slog.String("stack", string(debug.Stack())))
+					return
+				}
+				handlers.WriteError(recorder, r, logger, "handler panicked", err,
+					slog.String("stack", string(debug.Stack())))
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// cors returns middleware that answers preflight requests and allows configured origins to read responses.
+// Preflight requests are not authenticated, as browsers send them without credentials.
+func cors(opts CORSOptions) Middleware {
+	anyOrigin := slices.Contains(opts.AllowedOrigins, "*")
+	allowedHeaders := strings.Join(corsAllowedHeaders, ", ")
+	exposedHeaders := strings.Join(corsExposedHeaders, ", ")
+	allowedMethods := strings.Join(corsAllowedMethods, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+			if !anyOrigin && !slices.Contains(opts.AllowedOrigins, origin) {
+				// Browser blocks the response without CORS headers.
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+				w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
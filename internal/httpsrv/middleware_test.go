@@ -0,0 +1,207 @@
+package httpsrv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/logging"
+	"aerospike.com/rrd/internal/models"
+)
+
+func TestMiddleware_RequestID(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		header string
+		keep   bool
+	}{
+		{"client-id.1:2", true},
+		{"", false},
+		// Ids that are unsafe to log are replaced.
+		{"bad id\n", false},
+		{strings.Repeat("a", 129), false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			var fromContext string
+			handler := requestID(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				fromContext = models.RequestIDFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/series", nil)
+			req.Header.Set(handlers.HeaderRequestID, tc.header)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(handlers.HeaderRequestID)
+			require.NotEmpty(t, id)
+			require.Equal(t, id, fromContext)
+			if tc.keep {
+				require.Equal(t, tc.header, id)
+			} else {
+				require.NotEqual(t, tc.header, id)
+			}
+		})
+	}
+}
+
+func TestMiddleware_AccessLog(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	h := testHandlers(nil, "/internal/metrics")
+	h.Middleware = MiddlewareOptions{AccessLog: true}
+	handler, err := NewHandler(h, logger)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		method string
+		path   string
+		status int
+		level  string
+	}{
+		{http.MethodGet, "/internal/metrics", http.StatusOK, "INFO"},
+		// Unknown routes are logged too.
+		{http.MethodGet, "/unknown", http.StatusNotFound, "INFO"},
+		{http.MethodGet, "/healthz", http.StatusOK, "DEBUG"},
+	}
+	for i, tc := range testCases {
+		buf.Reset()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set(handlers.HeaderRequestID, "req-1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &entry), fmt.Sprintf("case %d", i))
+		require.Equal(t, "request", entry["msg"], fmt.Sprintf("case %d", i))
+		require.Equal(t, tc.level, entry["level"], fmt.Sprintf("case %d", i))
+		require.Equal(t, "req-1", entry["request_id"], fmt.Sprintf("case %d", i))
+		require.Equal(t, tc.path, entry["path"], fmt.Sprintf("case %d", i))
+		require.InDelta(t, tc.status, entry["status"], 0, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestMiddleware_RecoverPanic(t *testing.T) {
+	t.Parallel()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	testCases := []struct {
+		handler http.HandlerFunc
+		status  int
+	}{
+		{func(_ http.ResponseWriter, _ *http.Request) { panic("boom") }, http.StatusInternalServerError},
+		// Started response is kept.
+		{func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("boom")
+		}, http.StatusAccepted},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			rec := httptest.NewRecorder()
+			handler := chain(tc.handler, requestID, recoverPanic(logger))
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/series", nil))
+			require.Equal(t, tc.status, rec.Code)
+			if tc.status == http.StatusInternalServerError {
+				var problem handlers.Problem
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+				require.Equal(t, rec.Header().Get(handlers.HeaderRequestID), problem.RequestID)
+				require.Empty(t, problem.Detail)
+			}
+		})
+	}
+
+	// Aborted response is not recovered.
+	handler := recoverPanic(logger)(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/series", nil))
+	})
+}
+
+func TestMiddleware_CORS(t *testing.T) {
+	t.Parallel()
+	h := testHandlers(nil, "/internal/metrics")
+	h.Middleware = MiddlewareOptions{CORS: CORSOptions{
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+		MaxAge:         10 * time.Minute,
+	}}
+	handler, err := NewHandler(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		method    string
+		origin    string
+		preflight bool
+		status    int
+		allowed   bool
+	}{
+		// Preflight is answered before routing, so route methods don't matter.
+		{http.MethodOptions, "https://dashboard.example.com", true, http.StatusNoContent, true},
+		{http.MethodGet, "https://dashboard.example.com", false, http.StatusOK, true},
+		{http.MethodOptions, "https://evil.example.com", true, http.StatusMethodNotAllowed, false},
+		{http.MethodGet, "https://evil.example.com", false, http.StatusOK, false},
+		{http.MethodGet, "", false, http.StatusOK, false},
+	}
+	for i, tc := range testCases {
+		req := httptest.NewRequest(tc.method, "/internal/metrics", nil)
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		if tc.preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		require.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+		if !tc.allowed {
+			require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), fmt.Sprintf("case %d", i))
+			continue
+		}
+		require.Equal(t, tc.origin, rec.Header().Get("Access-Control-Allow-Origin"), fmt.Sprintf("case %d", i))
+		require.Contains(t, rec.Header().Values("Vary"), "Origin", fmt.Sprintf("case %d", i))
+		if tc.preflight {
+			require.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"), fmt.Sprintf("case %d", i))
+			require.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "X-API-Key", fmt.Sprintf("case %d", i))
+			for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodPatch, http.MethodDelete} {
+				require.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), method, fmt.Sprintf("case %d", i))
+			}
+		} else {
+			require.Contains(t, rec.Header().Get("Access-Control-Expose-Headers"), "Retry-After", fmt.Sprintf("case %d", i))
+		}
+	}
+
+	// Preflight for deleting an alert rule.
+	req := httptest.NewRequest(http.MethodOptions, prefixV1+"/alerts/rules/cpu", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), http.MethodDelete)
+
+	// Any origin is allowed with wildcard.
+	h.Middleware.CORS.AllowedOrigins = []string{"*"}
+	handler, err = NewHandler(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodGet, "/internal/metrics", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+}
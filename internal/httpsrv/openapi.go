@@ -0,0 +1,220 @@
+package httpsrv
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
+)
+
+// Paths of the API document and its docs UI, they are not authenticated like probes.
+const (
+	pathOpenAPI    = "/openapi.json"
+	pathDocs       = "/docs"
+	pathDocsAssets = "/docs/{" + pathParamAsset + "}"
+	pathParamAsset = "asset"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// swaggerUI keeps swagger ui assets of the version in swaggerui/VERSION, they are committed and updated by
+// swaggerui/fetch.sh, so docs don't depend on a CDN. Assets are embedded by name, so build fails without them.
+//
+//go:generate sh swaggerui/fetch.sh
+//go:embed swaggerui/swagger-ui.css swaggerui/swagger-ui-bundle.js
+var swaggerUI embed.FS
+
+// docsAssets are content types of swagger ui assets served by the docs page.
+var docsAssets = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+}
+
+// openAPI is an OpenAPI 3 document of the router, operations are added with routes, so the document
+// is always in sync with served routes.
+type openAPI struct {
+	doc *openapi3.T
+	// body is the encoded document, it is served at pathOpenAPI.
+	body []byte
+}
+
+func newOpenAPI(version string) *openAPI {
+	return &openAPI{doc: &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:       "rrd-service",
+			Description: apiDescription,
+			Version:     version,
+		},
+		Servers:    openapi3.Servers{{URL: "/"}},
+		Paths:      openapi3.NewPaths(),
+		Components: components(),
+		Security: openapi3.SecurityRequirements{
+			openapi3.NewSecurityRequirement().Authenticate(securityAPIKey),
+			openapi3.NewSecurityRequirement().Authenticate(securityHMAC),
+			openapi3.NewSecurityRequirement().Authenticate(securityBearer),
+		},
+	}}
+}
+
+// add adds operation of the route, it returns route that is used to validate requests.
+func (c *openAPI) add(method, path string, op *operation) *routers.Route {
+	item := c.doc.Paths.Value(path)
+	if item == nil {
+		item = &openapi3.PathItem{}
+		c.doc.Paths.Set(path, item)
+	}
+	item.SetOperation(method, op.Operation)
+	return &routers.Route{Spec: c.doc, Path: path, PathItem: item, Method: method, Operation: op.Operation}
+}
+
+// encode encodes the document, it must be called after all operations are added.
+func (c *openAPI) encode() error {
+	body, err := json.Marshal(c.doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode openapi document: %w", err)
+	}
+	c.body = body
+	return nil
+}
+
+// ServeHTTP serves the encoded document.
+func (c *openAPI) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(c.body)
+}
+
+// serveDocs serves swagger ui page for the document.
+func serveDocs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(docsPage)
+}
+
+// serveDocsAssets serves embedded swagger ui assets of the docs page.
+func serveDocsAssets(logger *slog.Logger) http.HandlerFunc {
+	notFound := handlers.NotFound(logger)
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)[pathParamAsset]
+		contentType, ok := docsAssets[name]
+		if !ok {
+			notFound(w, r)
+			return
+		}
+		body, err := swaggerUI.ReadFile("swaggerui/" + name)
+		if err != nil {
+			notFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		_, _ = w.Write(body)
+	}
+}
+
+// validateRequest returns middleware that validates params and json body of requests against the operation
+// of the route. Bodies of other formats are validated by handlers while they are decoded.
+func validateRequest(route *routers.Route, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Request is cloned, so content type of json bodies sent without it is set only for validation.
+			req := r.Clone(r.Context())
+			options := &openapi3filter.Options{
+				// Requests are authenticated by auth middleware.
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+				SkipSettingDefaults: true,
+				MultiError:          true,
+				ExcludeRequestBody:  !jsonBody(req),
+			}
+			err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: mux.Vars(r),
+				Route:      route,
+				Options:    options,
+			})
+			// Validation reads the body and replaces it with a buffered copy.
+			r.Body = req.Body
+			if err != nil {
+				handlers.WriteError(w, r, logger, "invalid request", requestError(err))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// jsonBody returns true if request body is json, it sets json content type if it is missing, as handlers
+// decode such bodies as json.
+func jsonBody(r *http.Request) bool {
+	if format := r.URL.Query().Get(handlers.QueryParamFormat); format != "" {
+		if !strings.EqualFold(format, handlers.FormatJSON) {
+			return false
+		}
+		r.Header.Set("Content-Type", "application/json")
+		return true
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		r.Header.Set("Content-Type", "application/json")
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// requestError converts errors of request validation to validation error with a field per error.
+func requestError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return models.NewDetailError(models.ErrTooLarge, "body exceeds %d bytes", tooLarge.Limit)
+	}
+	var validationErr models.ValidationError
+	addRequestError(&validationErr, "request", err)
+	return &validationErr
+}
+
+func addRequestError(validationErr *models.ValidationError, field string, err error) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, one := range e {
+			addRequestError(validationErr, field, one)
+		}
+	case *openapi3filter.RequestError:
+		field = "body"
+		if e.Parameter != nil {
+			field = e.Parameter.Name
+		}
+		switch e.Err.(type) {
+		case nil:
+			validationErr.Add(field, "%s", e.Reason)
+		case openapi3.MultiError, *openapi3.SchemaError:
+			addRequestError(validationErr, field, e.Err)
+		default:
+			if e.Reason == "" {
+				validationErr.Add(field, "%s", e.Err)
+				return
+			}
+			validationErr.Add(field, "%s: %s", e.Reason, e.Err)
+		}
+	case *openapi3.SchemaError:
+		// Fields of body are named by json pointer, e.g. retention.max_age or 1.timestamp in a batch.
+		if pointer := e.JSONPointer(); len(pointer) > 0 && field == "body" {
+			field = strings.Join(pointer, ".")
+		}
+		validationErr.Add(field, "%s", e.Reason)
+	default:
+		validationErr.Add(field, "%s", err)
+	}
+}
@@ -0,0 +1,476 @@
+package httpsrv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/stream"
+	"aerospike.com/rrd/internal/timeexpr"
+)
+
+type recordsMock struct{}
+
+func (mock recordsMock) GetByRange(_ context.Context, series string, _, _ int64) ([]models.Record, error) {
+	if series == "unknown" {
+		return nil, fmt.Errorf("series %q: %w", series, models.ErrNotFound)
+	}
+	return []models.Record{{Timestamp: 1, MetricValue: 1.5}, {Timestamp: 2, MetricValue: 2.5}}, nil
+}
+
+func (mock recordsMock) ListRecords(ctx context.Context, series string, start, end int64, _ int,
+) ([]models.Record, error) {
+	return mock.GetByRange(ctx, series, start, end)
+}
+
+func (mock recordsMock) Evaluate(_ context.Context, query models.ExprQuery) ([]models.Record, error) {
+	return []models.Record{
+		{Timestamp: query.Start, MetricValue: 0.5},
+		{Timestamp: query.Start + 1, MetricValue: math.NaN()},
+	}, nil
+}
+
+func (mock recordsMock) Create(_ context.Context, _ models.Record) error {
+	return nil
+}
+
+func (mock recordsMock) Validate(context.Context, models.Record) error {
+	return nil
+}
+
+func (mock recordsMock) Admit(int) error {
+	return nil
+}
+
+// streamServiceMock subscribes to the hub, backfill has records with timestamps from 1 to backfill.
+type streamServiceMock struct {
+	hub *stream.Hub
+}
+
+func (mock streamServiceMock) Subscribe(
+	_ context.Context, series string, backfill int,
+) ([]models.Record, *stream.Subscription, error) {
+	if series == "unknown" {
+		return nil, nil, fmt.Errorf("series %q: %w", series, models.ErrNotFound)
+	}
+	sub, err := mock.hub.Subscribe(series)
+	if err != nil {
+		return nil, nil, err
+	}
+	records := make([]models.Record, 0, backfill)
+	for i := 1; i <= backfill; i++ {
+		records = append(records, models.Record{Timestamp: int64(i), MetricValue: 1.5})
+	}
+	return records, sub, nil
+}
+
+type alertServiceMock struct{}
+
+func (mock alertServiceMock) CreateRule(_ context.Context, rule models.AlertRule) (models.AlertRule, error) {
+	if err := rule.Validate(); err != nil {
+		return models.AlertRule{}, err
+	}
+	rule.Source = models.AlertRuleSourceAPI
+	return rule, nil
+}
+
+func (mock alertServiceMock) ReplaceRule(_ context.Context, name string, _ models.AlertRule,
+) (models.AlertRule, error) {
+	return models.AlertRule{}, fmt.Errorf("rule %s is defined in alert rules file: %w", name, models.ErrConflict)
+}
+
+func (mock alertServiceMock) DeleteRule(_ context.Context, name string) error {
+	return fmt.Errorf("rule %s: %w", name, models.ErrNotFound)
+}
+
+func (mock alertServiceMock) GetRule(_ context.Context, name string) (models.AlertRule, error) {
+	return testAlertRule(name), nil
+}
+
+func (mock alertServiceMock) ListRules(_ context.Context) ([]models.AlertRule, error) {
+	return []models.AlertRule{testAlertRule("high_cpu")}, nil
+}
+
+func (mock alertServiceMock) ListAlerts(_ context.Context, _ models.AlertState) ([]models.Alert, error) {
+	return []models.Alert{{Rule: "high_cpu", Series: "cpu", State: models.AlertStateFiring, Value: 95, ActiveAt: 1,
+		FiredAt: 2}}, nil
+}
+
+func (mock alertServiceMock) CreateSilence(_ context.Context, silence models.Silence) (models.Silence, error) {
+	silence.ID = "0011223344556677"
+	return silence, nil
+}
+
+func (mock alertServiceMock) ListSilences(_ context.Context) ([]models.Silence, error) {
+	return []models.Silence{}, nil
+}
+
+func (mock alertServiceMock) DeleteSilence(_ context.Context, _ string) error {
+	return nil
+}
+
+type forecastServiceMock struct{}
+
+func (mock forecastServiceMock) Forecast(_ context.Context, series string, horizon int) (models.Forecast, error) {
+	if series != "cpu" {
+		return models.Forecast{}, fmt.Errorf("series %q: %w", series, models.ErrNotFound)
+	}
+	return models.Forecast{Series: series, Step: 60, Points: []models.ForecastPoint{
+		{Timestamp: 60000000, Value: 1.5, Lower: 1, Upper: 2},
+	}}, nil
+}
+
+func testAlertRule(name string) models.AlertRule {
+	return models.AlertRule{
+		Name:        name,
+		Selector:    models.AlertSelector{Labels: map[string]string{"host": "web-1"}},
+		Aggregation: models.AggregationAvg,
+		Window:      60,
+		Comparison:  models.ComparisonGreater,
+		Threshold:   90,
+		Source:      models.AlertRuleSourceConfig,
+	}
+}
+
+type seriesServiceMock struct{}
+
+func (mock seriesServiceMock) CreateSeries(_ context.Context, series models.Series) (models.Series, error) {
+	if series.Name == "exists" {
+		return models.Series{}, fmt.Errorf("series %q: %w", series.Name, models.ErrConflict)
+	}
+	return series, nil
+}
+
+func (mock seriesServiceMock) UpdateSeries(_ context.Context, name string, _ models.SeriesUpdate) (models.Series, error) {
+	return testSeries(name), nil
+}
+
+func (mock seriesServiceMock) DescribeSeries(_ context.Context, name string) (models.SeriesDescription, error) {
+	return models.SeriesDescription{Series: testSeries(name), Stats: models.SeriesStats{Points: 2, Oldest: 1, Newest: 2}}, nil
+}
+
+func (mock seriesServiceMock) ListSeries(_ context.Context, _ models.SeriesFilter) (models.SeriesPage, error) {
+	return models.SeriesPage{Series: []models.Series{testSeries("cpu")}, NextPageToken: "cpu"}, nil
+}
+
+func testSeries(name string) models.Series {
+	return models.Series{
+		Name:       name,
+		Labels:     map[string]string{"host": "web-1"},
+		DataSource: models.DataSourceGauge,
+		Retention:  models.Retention{MaxPoints: 100},
+		ValueType:  models.ValueSpec{Type: models.ValueTypeFloat},
+		Version:    1,
+	}
+}
+
+type tenantServiceMock struct{}
+
+func (mock tenantServiceMock) Usage(_ context.Context) (models.TenantUsage, error) {
+	return models.TenantUsage{Tenant: "default", Series: 1, Limits: models.TenantLimits{IngestRate: 1.5}}, nil
+}
+
+func (mock tenantServiceMock) ListUsage(_ context.Context) ([]models.TenantUsage, error) {
+	return nil, fmt.Errorf("failed to list tenants: %w", models.ErrUnavailable)
+}
+
+type retentionMock struct{}
+
+func (mock retentionMock) Stats() models.RetentionStats {
+	return models.RetentionStats{Series: map[string]models.SeriesRetentionStats{"cpu": {Points: 1}}}
+}
+
+type healthMock struct{}
+
+func (mock healthMock) Ready(_ context.Context) models.Readiness {
+	return models.NewReadiness([]models.Check{{Name: "aerospike", Status: models.CheckStatusFailed, Error: "timeout"}})
+}
+
+func (mock healthMock) Status(_ context.Context) (models.Status, error) {
+	return models.Status{Version: "v1", Readiness: models.NewReadiness([]models.Check{})}, nil
+}
+
+// newOpenAPITestHandlers returns handlers with mocked services.
+func newOpenAPITestHandlers() Handlers {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	return Handlers{
+		RRD:       handlers.NewRRD(recordsMock{}, recordsMock{}, timeexpr.NewParser(time.UTC), logger),
+		Series:    handlers.NewSeries(seriesServiceMock{}, logger),
+		Retention: handlers.NewRetention(retentionMock{}, logger),
+		Tenants:   handlers.NewTenants(tenantServiceMock{}, logger),
+		Health:    handlers.NewHealth(healthMock{}, logger),
+		Stream: handlers.NewStream(streamServiceMock{hub: stream.NewHub(stream.Options{BufferSize: 10})},
+			handlers.StreamOptions{MaxBackfill: 10}, logger),
+		Alerts:   handlers.NewAlerts(alertServiceMock{}, logger),
+		Forecast: handlers.NewForecast(forecastServiceMock{}, logger),
+		Metrics: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("# metrics\n"))
+		}),
+		MetricsPath: "/internal/metrics",
+		Version:     "v1.2.3",
+	}
+}
+
+func newOpenAPITestHandler(t *testing.T) http.Handler {
+	t.Helper()
+	handler, err := NewHandler(newOpenAPITestHandlers(), slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+	return handler
+}
+
+// loadDocument loads the document served by handler, as clients do.
+func loadDocument(t *testing.T, handler http.Handler) *openapi3.T {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, pathOpenAPI, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	doc, err := openapi3.NewLoader().LoadFromData(rec.Body.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+	return doc
+}
+
+// validateResponse checks that response is described by the document, including its status.
+func validateResponse(t *testing.T, doc *openapi3.T, req *http.Request, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	router, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+	route, pathParams, err := router.FindRoute(req)
+	require.NoError(t, err)
+
+	contentType := rec.Header().Get("Content-Type")
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: rec.Code,
+		Header: rec.Header(),
+		Body:   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			// Other formats are described as strings.
+			ExcludeResponseBody: !strings.Contains(contentType, "json"),
+		},
+	})
+	require.NoError(t, err, "%s %s: %d %s", req.Method, req.URL, rec.Code, rec.Body.String())
+}
+
+func TestOpenAPI_Document(t *testing.T) {
+	t.Parallel()
+	handler := newOpenAPITestHandler(t)
+	doc := loadDocument(t, handler)
+	require.Equal(t, "v1.2.3", doc.Info.Version)
+
+	// Each route of the router is described by the document.
+	router, err := NewRouter(testHandlers(nil, "/internal/metrics"), slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+	routes := 0
+	err = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		require.NoError(t, err)
+		methods, err := route.GetMethods()
+		require.NoError(t, err)
+		for _, method := range methods {
+			item := doc.Paths.Value(path)
+			require.NotNil(t, item, path)
+			require.NotNil(t, item.GetOperation(method), "%s %s", method, path)
+			routes++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	operations := 0
+	for _, item := range doc.Paths.Map() {
+		operations += len(item.Operations())
+	}
+	require.Equal(t, routes, operations)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, pathDocs, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "openapi.json")
+	// Assets are served locally, so docs work without access to a CDN.
+	require.Contains(t, rec.Body.String(), "docs/swagger-ui-bundle.js")
+	require.NotContains(t, rec.Body.String(), "https://")
+
+	for name, contentType := range docsAssets {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, pathDocs+"/"+name, nil))
+		require.Equal(t, http.StatusOK, rec.Code, name)
+		require.Equal(t, contentType, rec.Header().Get("Content-Type"), name)
+		require.NotEmpty(t, rec.Body.Bytes(), name)
+	}
+	// Bundle is of the pinned version.
+	version, err := os.ReadFile("swaggerui/VERSION")
+	require.NoError(t, err)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/swagger-ui-bundle.js", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `PACKAGE_VERSION:"`+strings.TrimSpace(string(version))+`"`)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/fetch.sh", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestOpenAPI_ValidateRequest(t *testing.T) {
+	t.Parallel()
+	handler := newOpenAPITestHandler(t)
+
+	testCases := []struct {
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		field       string
+	}{
+		{http.MethodGet, "/series?limit=ten", "", "", http.StatusBadRequest, "limit"},
+		{http.MethodGet, "/metrics?format=xml", "", "", http.StatusBadRequest, "format"},
+		{http.MethodGet, "/api/v1/metrics?expr=cpu&step=-1", "", "", http.StatusBadRequest, "step"},
+		// Body is a record or a batch, so errors of records are reported for the whole body.
+		{http.MethodPut, "/metrics", "", `{"timestamp":"now","metric_value":1}`, http.StatusBadRequest, "body"},
+		{http.MethodPut, "/metrics", "application/json", `[{"timestamp":1},{"timestamp":true}]`,
+			http.StatusBadRequest, "body"},
+		{http.MethodPut, "/metrics", "application/json", ``, http.StatusBadRequest, "body"},
+		{http.MethodPut, "/metrics", "application/json", `{"timestamp":`, http.StatusBadRequest, "body"},
+		{http.MethodPost, "/series", "", `{"name":"cpu","data_source":"RATE"}`, http.StatusBadRequest, "data_source"},
+		{http.MethodPatch, "/series/cpu", "", `{"retention":{"max_age":-1}}`, http.StatusBadRequest, "retention.max_age"},
+		{http.MethodPost, "/api/v1/alerts/rules", "", `{"name":"cpu","window":0}`, http.StatusBadRequest, "window"},
+		{http.MethodGet, "/api/v1/alerts?state=unknown", "", "", http.StatusBadRequest, "state"},
+		{http.MethodGet, "/api/v1/series/cpu/forecast?horizon=-1", "", "", http.StatusBadRequest, "horizon"},
+		{http.MethodPost, "/series", "", `{"name":"cpu","holt_winters":{"alpha":2}}`, http.StatusBadRequest,
+			"holt_winters.alpha"},
+		// Bodies of other formats are validated by handlers.
+		{http.MethodPut, "/metrics", "text/csv", "timestamp,metric_value\n1,1.5\n", http.StatusOK, ""},
+		{http.MethodPut, "/metrics?format=ndjson", "", `{"timestamp":1,"metric_value":1}`, http.StatusOK, ""},
+		// Legacy clients send json with form content type.
+		{http.MethodPut, "/metrics", "application/x-www-form-urlencoded", `{"timestamp":1,"metric_value":1}`,
+			http.StatusOK, ""},
+		{http.MethodPut, "/metrics", "", `{"timestamp":1,"metric_value":1}`, http.StatusOK, ""},
+		{http.MethodPost, "/series", "application/json; charset=utf-8", `{"name":"cpu","data_source":"GAUGE"}`,
+			http.StatusCreated, ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			if tc.field == "" {
+				return
+			}
+
+			var problem handlers.Problem
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+			require.NotEmpty(t, problem.Errors)
+			require.Equal(t, tc.field, problem.Errors[0].Field)
+		})
+	}
+}
+
+func TestOpenAPI_Responses(t *testing.T) {
+	t.Parallel()
+	handler := newOpenAPITestHandler(t)
+	doc := loadDocument(t, handler)
+
+	testCases := []struct {
+		method string
+		target string
+		header string
+		value  string
+		body   string
+	}{
+		{http.MethodGet, "/metrics?start=0", "", "", ""},
+		{http.MethodGet, "/metrics?start=0", "Accept", "text/csv", ""},
+		{http.MethodGet, "/metrics?start=0&format=protobuf", "", "", ""},
+		{http.MethodGet, "/metrics?start=0", "Accept", "image/png", ""},
+		{http.MethodGet, "/metrics?series=unknown&start=0", "", "", ""},
+		{http.MethodGet, "/metrics?start=yesterday-1", "", "", ""},
+		{http.MethodPut, "/metrics", "", "", `{"timestamp":1,"metric_value":1.5}`},
+		{http.MethodPut, "/metrics", "Content-Type", "application/xml", `<record/>`},
+		{http.MethodPost, "/series", "", "", `{"name":"cpu","data_source":"GAUGE","value_type":{"type":"float64"}}`},
+		{http.MethodPost, "/series", "", "", `{"name":"exists","data_source":"GAUGE","value_type":{"type":"float64"}}`},
+		{http.MethodGet, "/series?label=host:web-1&limit=10", "", "", ""},
+		{http.MethodGet, "/series/cpu", "", "", ""},
+		{http.MethodPatch, "/series/cpu", "", "", `{"unit":"percent","version":1}`},
+		{http.MethodGet, "/usage", "", "", ""},
+		{http.MethodGet, "/tenants", "", "", ""},
+		{http.MethodGet, "/retention", "", "", ""},
+		{http.MethodGet, "/api/v1/metrics?start=0&limit=1", "", "", ""},
+		{http.MethodGet, "/api/v1/metrics?start=0", "Accept", "text/csv", ""},
+		{http.MethodGet, "/api/v1/metrics?limit=-1", "", "", ""},
+		{http.MethodGet, "/api/v1/metrics?expr=cpu*8&start=1&step=60", "", "", ""},
+		{http.MethodGet, "/metrics?expr=cpu,8,*&start=1", "", "", ""},
+		{http.MethodPut, "/api/v1/metrics", "", "", `[{"timestamp":1,"metric_value":1.5}]`},
+		{http.MethodGet, "/api/v1/series/cpu", "", "", ""},
+		{http.MethodGet, "/api/v1/status", "", "", ""},
+		{http.MethodGet, "/api/v1/metrics/stream?series=unknown", "", "", ""},
+		{http.MethodGet, "/api/v1/metrics/stream?backfill=11", "", "", ""},
+		{http.MethodGet, "/api/v1/alerts?state=firing", "", "", ""},
+		{http.MethodGet, "/api/v1/alerts/rules", "", "", ""},
+		{http.MethodPost, "/api/v1/alerts/rules", "", "", `{"name":"high_cpu","selector":{"series":"cpu"},` +
+			`"aggregation":"max","window":60,"comparison":"gte","threshold":90,"for":120}`},
+		{http.MethodPost, "/api/v1/alerts/rules", "", "", `{"name":"high_cpu","window":60}`},
+		{http.MethodGet, "/api/v1/alerts/rules/high_cpu", "", "", ""},
+		{http.MethodPut, "/api/v1/alerts/rules/high_cpu", "", "", `{"selector":{"series":"cpu"},` +
+			`"aggregation":"max","window":60,"comparison":"gte","threshold":90}`},
+		{http.MethodDelete, "/api/v1/alerts/rules/unknown", "", "", ""},
+		{http.MethodPost, "/api/v1/alerts/silences", "", "", `{"rule":"high_cpu","starts_at":1,"ends_at":2}`},
+		{http.MethodGet, "/api/v1/alerts/silences", "", "", ""},
+		{http.MethodDelete, "/api/v1/alerts/silences/0011223344556677", "", "", ""},
+		{http.MethodGet, "/api/v1/series/cpu/forecast?horizon=1", "", "", ""},
+		{http.MethodGet, "/api/v1/series/unknown/forecast", "", "", ""},
+		{http.MethodPost, "/series", "", "", `{"name":"cpu","data_source":"GAUGE","value_type":{"type":"float64"},` +
+			`"step":60,"holt_winters":{"alpha":0.5,"beta":0.1,"gamma":0.1,"season_length":24}}`},
+		{http.MethodGet, "/healthz", "", "", ""},
+		{http.MethodGet, "/readyz", "", "", ""},
+		{http.MethodGet, "/status", "", "", ""},
+		{http.MethodGet, "/internal/metrics", "", "", ""},
+		{http.MethodGet, pathOpenAPI, "", "", ""},
+		{http.MethodGet, pathDocs, "", "", ""},
+		{http.MethodGet, "/docs/unknown.js", "", "", ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			validateResponse(t, doc, req, rec)
+		})
+	}
+}
@@ -0,0 +1,783 @@
+package httpsrv
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"aerospike.com/rrd/internal/httpsrv/auth"
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
+)
+
+const apiDescription = `Request bodies may be compressed with gzip or zstd Content-Encoding, responses are compressed
+according to Accept-Encoding. Each response has X-Request-ID header, it is taken from request or generated.
+W3C traceparent and tracestate headers of requests are used as parent of server spans if tracing is enabled.
+Data routes without /api/v1 prefix are deprecated routes of the first release, they are served for old clients.`
+
+// Security schemes of authenticated routes.
+const (
+	securityAPIKey = "apiKey"
+	securityHMAC   = "hmac"
+	securityBearer = "bearer"
+)
+
+// Patterns of series names, names of records and query params may be empty for default series.
+const (
+	seriesNamePattern     = `^[a-zA-Z_][a-zA-Z0-9_.:-]*$`
+	optionalSeriesPattern = `^([a-zA-Z_][a-zA-Z0-9_.:-]*)?$`
+)
+
+// Names of shared schemas and responses in components of the document.
+const (
+	schemaRecord      = "Record"
+	schemaSeries      = "Series"
+	schemaRetention   = "Retention"
+	schemaTenantUsage = "TenantUsage"
+	schemaReadiness   = "Readiness"
+	schemaProblem     = "Problem"
+	schemaAlertRule   = "AlertRule"
+	schemaAlert       = "Alert"
+	schemaSilence     = "Silence"
+	schemaHoltWinters = "HoltWinters"
+
+	responseInvalid              = "InvalidArgument"
+	responseUnauthenticated      = "Unauthenticated"
+	responseForbidden            = "Forbidden"
+	responseNotFound             = "NotFound"
+	responseNotAcceptable        = "NotAcceptable"
+	responseTooLarge             = "TooLarge"
+	responseUnsupportedMediaType = "UnsupportedMediaType"
+	responseRateLimited          = "RateLimited"
+	responseInternal             = "Internal"
+	responseUnavailable          = "Unavailable"
+)
+
+// Media types of record formats, the first one is json.
+var recordMediaTypes = []string{
+	"application/json", "application/x-ndjson", "text/csv", "application/msgpack", "application/x-protobuf",
+}
+
+// components returns schemas, responses and security schemes that are referenced by operations.
+func components() *openapi3.Components {
+	retention := openapi3.NewObjectSchema().
+		WithProperty("max_points", describe(openapi3.NewInt64Schema(),
+			"Maximum number of points, the oldest point is evicted on write, STORAGE_CAP is used if 0.")).
+		WithProperty("max_age", describe(openapi3.NewInt64Schema().WithMin(0).WithMax(float64(models.MaxRetentionAge)),
+			"Maximum age of points in seconds, points don't expire if 0."))
+
+	holtWinters := openapi3.NewObjectSchema().
+		WithProperty("alpha", describe(openapi3.NewFloat64Schema().WithMin(0).WithMax(1),
+			"Smoothing factor of level, in range (0, 1).")).
+		WithProperty("beta", describe(openapi3.NewFloat64Schema().WithMin(0).WithMax(1),
+			"Smoothing factor of trend, in range (0, 1).")).
+		WithProperty("gamma", describe(openapi3.NewFloat64Schema().WithMin(0).WithMax(1),
+			"Smoothing factor of seasonal coefficients and deviations, in range (0, 1).")).
+		WithProperty("season_length", describe(openapi3.NewIntegerSchema().WithMin(0).WithMax(10000),
+			"Number of steps in a season, at least 2. Forecasting is disabled by 0 in update.")).
+		WithProperty("deviation_scale", describe(openapi3.NewFloat64Schema().WithMin(0).WithMax(10),
+			"Number of deviations a point may differ from prediction, 2 by default.")).
+		WithProperty("failure_threshold", describe(openapi3.NewIntegerSchema().WithMin(0),
+			"Number of violations in the failure window that is a failure, 7 by default.")).
+		WithProperty("failure_window", describe(openapi3.NewIntegerSchema().WithMin(0).WithMax(28),
+			"Number of the last points checked for failure, 9 by default."))
+
+	valueTypes := make([]any, 0, 5)
+	for _, valueType := range []models.ValueType{
+		models.ValueTypeFloat, models.ValueTypeInt, models.ValueTypeBool, models.ValueTypeString,
+		models.ValueTypeHistogram,
+	} {
+		valueTypes = append(valueTypes, string(valueType))
+	}
+	series := openapi3.NewObjectSchema().
+		WithProperty("name", openapi3.NewStringSchema().WithMaxLength(128).WithPattern(seriesNamePattern)).
+		WithProperty("labels", labelsSchema()).
+		WithProperty("unit", openapi3.NewStringSchema()).
+		WithProperty("description", openapi3.NewStringSchema().WithMaxLength(1024)).
+		WithProperty("data_source", openapi3.NewStringSchema().WithEnum(
+			string(models.DataSourceGauge), string(models.DataSourceCounter), string(models.DataSourceDerive),
+			string(models.DataSourceAbsolute))).
+		WithProperty("step", describe(openapi3.NewInt64Schema().WithMin(0).WithMax(float64(models.MaxSeriesStep)),
+			"Expected interval between points in seconds.")).
+		WithPropertyRef("retention", schemaRef(schemaRetention, retention)).
+		WithProperty("value_type", openapi3.NewObjectSchema().
+			WithProperty("type", openapi3.NewStringSchema().WithEnum(valueTypes...)).
+			WithProperty("enum", describe(openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+				"Allowed values of string series."))).
+		WithPropertyRef("holt_winters", schemaRef(schemaHoltWinters, describe(holtWinters,
+			"Holt-Winters forecasting of a GAUGE numeric series with step, results are saved to series with "+
+				models.PredictionSeriesSuffix+", "+models.DeviationSeriesSuffix+" and "+
+				models.FailuresSeriesSuffix+" suffixes."))).
+		WithProperty("version", describe(openapi3.NewInt64Schema(), "Incremented on each update.")).
+		WithProperty("created_at", openapi3.NewInt64Schema()).
+		WithProperty("updated_at", openapi3.NewInt64Schema()).
+		WithProperty("stats", describe(openapi3.NewObjectSchema().
+			WithProperty("points", openapi3.NewInt64Schema()).
+			WithProperty("oldest", openapi3.NewInt64Schema()).
+			WithProperty("newest", openapi3.NewInt64Schema()),
+			"Statistics of stored points, it is returned only by describe."))
+
+	record := openapi3.NewObjectSchema().
+		WithProperty("series", describe(openapi3.NewStringSchema().WithPattern(optionalSeriesPattern),
+			"Series name, default series is used if empty.")).
+		WithProperty("timestamp", describe(openapi3.NewInt64Schema(), "Unix time in microseconds.")).
+		WithProperty("metric_value", describe(openapi3.NewSchema(),
+			"Number, integer, bool, string or histogram object, according to value type of the series. "+
+				`Non-finite floats are strings "NaN", "+Inf" and "-Inf".`))
+
+	check := openapi3.NewObjectSchema().
+		WithProperty("name", openapi3.NewStringSchema()).
+		WithProperty("status", checkStatusSchema()).
+		WithProperty("error", openapi3.NewStringSchema())
+	readiness := openapi3.NewObjectSchema().
+		WithProperty("status", checkStatusSchema()).
+		WithProperty("checks", openapi3.NewArraySchema().WithItems(check).WithNullable())
+
+	tenantUsage := openapi3.NewObjectSchema().
+		WithProperty("tenant", openapi3.NewStringSchema()).
+		WithProperty("series", openapi3.NewIntegerSchema()).
+		WithProperty("points", describe(openapi3.NewInt64Schema(), "Number of stored points of all series.")).
+		WithProperty("capacity", describe(openapi3.NewInt64Schema(), "Max number of points of all series.")).
+		WithProperty("ingest", describe(openapi3.NewObjectSchema().
+			WithProperty("accepted", openapi3.NewInt64Schema()).
+			WithProperty("rejected", openapi3.NewInt64Schema()),
+			"Points accepted and rejected by ingest rate quota since start of the instance.")).
+		WithProperty("limits", describe(openapi3.NewObjectSchema().
+			WithProperty("max_series", openapi3.NewIntegerSchema()).
+			WithProperty("capacity", openapi3.NewInt64Schema()).
+			WithProperty("ingest_rate", openapi3.NewFloat64Schema()).
+			WithProperty("ingest_burst", openapi3.NewIntegerSchema()),
+			"Quotas of the tenant, missing limit means no limit."))
+
+	alertRule := openapi3.NewObjectSchema().
+		WithProperty("name", openapi3.NewStringSchema().WithMaxLength(128).WithPattern(seriesNamePattern)).
+		WithProperty("selector", describe(openapi3.NewObjectSchema().
+			WithProperty("series", openapi3.NewStringSchema()).
+			WithProperty("labels", labelsSchema()),
+			"Series name or labels of evaluated series, only one of them can be set.")).
+		WithProperty("aggregation", describe(openapi3.NewStringSchema().WithEnum(
+			string(models.AggregationAvg), string(models.AggregationMin), string(models.AggregationMax),
+			string(models.AggregationSum), string(models.AggregationCount), string(models.AggregationLast)),
+			"Aggregation of points in the window, values that are not numbers are only counted.")).
+		WithProperty("window", describe(openapi3.NewInt64Schema().WithMin(1).WithMax(float64(models.MaxAlertDuration)),
+			"Duration of the evaluated range in seconds, the range ends at evaluation time.")).
+		WithProperty("comparison", openapi3.NewStringSchema().WithEnum(
+			string(models.ComparisonGreater), string(models.ComparisonGreaterOrEqual), string(models.ComparisonLess),
+			string(models.ComparisonLessOrEqual), string(models.ComparisonEqual), string(models.ComparisonNotEqual))).
+		WithProperty("threshold", openapi3.NewFloat64Schema()).
+		WithProperty("for", describe(openapi3.NewInt64Schema().WithMin(0).WithMax(float64(models.MaxAlertDuration)),
+			"Duration in seconds the condition must hold before the alert fires.")).
+		WithProperty("description", openapi3.NewStringSchema().WithMaxLength(1024)).
+		WithProperty("source", describe(openapi3.NewStringSchema().WithEnum(
+			string(models.AlertRuleSourceAPI), string(models.AlertRuleSourceConfig)),
+			"Rules of ALERT_RULES_FILE can't be changed by API, it is ignored in requests.")).
+		WithProperty("created_at", openapi3.NewInt64Schema()).
+		WithProperty("updated_at", openapi3.NewInt64Schema())
+
+	alert := openapi3.NewObjectSchema().
+		WithProperty("rule", openapi3.NewStringSchema()).
+		WithProperty("series", openapi3.NewStringSchema()).
+		WithProperty("state", alertStateSchema()).
+		WithProperty("value", describe(openapi3.NewFloat64Schema(),
+			"Aggregated value of the last evaluation that matched the condition.")).
+		WithProperty("active_at", describe(openapi3.NewInt64Schema(), "Unix time in microseconds.")).
+		WithProperty("fired_at", openapi3.NewInt64Schema()).
+		WithProperty("resolved_at", openapi3.NewInt64Schema()).
+		WithProperty("silenced", openapi3.NewBoolSchema())
+
+	silence := openapi3.NewObjectSchema().
+		WithProperty("id", openapi3.NewStringSchema()).
+		WithProperty("rule", describe(openapi3.NewStringSchema(), "Silenced rule, any rule if empty.")).
+		WithProperty("series", describe(openapi3.NewStringSchema(), "Silenced series, any series if empty.")).
+		WithProperty("starts_at", describe(openapi3.NewInt64Schema().WithMin(0),
+			"Unix time in microseconds, creation time if it is not set.")).
+		WithProperty("ends_at", describe(openapi3.NewInt64Schema(), "Unix time in microseconds.")).
+		WithProperty("comment", openapi3.NewStringSchema().WithMaxLength(1024)).
+		WithProperty("created_by", describe(openapi3.NewStringSchema(), "Subject of the request identity.")).
+		WithProperty("created_at", openapi3.NewInt64Schema())
+
+	problem := openapi3.NewObjectSchema().
+		WithProperty("type", openapi3.NewStringSchema()).
+		WithProperty("title", openapi3.NewStringSchema()).
+		WithProperty("status", openapi3.NewIntegerSchema()).
+		WithProperty("detail", openapi3.NewStringSchema()).
+		WithProperty("instance", openapi3.NewStringSchema()).
+		WithProperty("request_id", openapi3.NewStringSchema()).
+		WithProperty("errors", openapi3.NewArraySchema().WithItems(openapi3.NewObjectSchema().
+			WithProperty("field", openapi3.NewStringSchema()).
+			WithProperty("message", openapi3.NewStringSchema()))).
+		WithProperty("accepted", describe(openapi3.NewIntegerSchema(),
+			"Number of saved records of a batch that failed, records before the failed one are saved.")).
+		WithRequired([]string{"type", "title", "status"})
+
+	return &openapi3.Components{
+		Schemas: openapi3.Schemas{
+			schemaRecord:      openapi3.NewSchemaRef("", record),
+			schemaSeries:      openapi3.NewSchemaRef("", series),
+			schemaRetention:   openapi3.NewSchemaRef("", retention),
+			schemaTenantUsage: openapi3.NewSchemaRef("", tenantUsage),
+			schemaReadiness:   openapi3.NewSchemaRef("", readiness),
+			schemaProblem:     openapi3.NewSchemaRef("", problem),
+			schemaAlertRule:   openapi3.NewSchemaRef("", alertRule),
+			schemaAlert:       openapi3.NewSchemaRef("", alert),
+			schemaSilence:     openapi3.NewSchemaRef("", silence),
+			schemaHoltWinters: openapi3.NewSchemaRef("", holtWinters),
+		},
+		Responses: openapi3.ResponseBodies{
+			responseInvalid: problemResponse("Invalid params or body.", problem),
+			responseUnauthenticated: problemResponse(
+				"Credentials are missing or invalid, returned only if AUTH_ENABLED is set.", problem),
+			responseForbidden: problemResponse("Identity has no required scope, or has no admin scope and X-Tenant "+
+				"header is set to another tenant, or quota of the tenant is exceeded.", problem),
+			responseNotFound:      problemResponse("Series not found.", problem),
+			responseNotAcceptable: problemResponse("None of formats in Accept header is supported.", problem),
+			responseTooLarge: problemResponse("Request body exceeds HTTP_MAX_BODY_SIZE, batch exceeds "+
+				"HTTP_MAX_BATCH_POINTS or range exceeds HTTP_MAX_QUERY_SPAN.", problem),
+			responseUnsupportedMediaType: problemResponse("Content-Encoding of request body is not gzip or zstd, "+
+				"or Content-Type is not a supported format.", problem),
+			responseRateLimited: problemResponse("Rate limit of the client on the route is exceeded, "+
+				"retry after Retry-After seconds.", problem),
+			responseInternal:    problemResponse("Internal error.", problem),
+			responseUnavailable: problemResponse("Storage is unavailable, retry after Retry-After seconds.", problem),
+		},
+		SecuritySchemes: openapi3.SecuritySchemes{
+			securityAPIKey: &openapi3.SecuritySchemeRef{Value: openapi3.NewSecurityScheme().
+				WithType("apiKey").WithIn("header").WithName(auth.HeaderAPIKey).
+				WithDescription(`Static api key, it can also be sent as "Authorization: ApiKey <key>".`)},
+			securityHMAC: &openapi3.SecuritySchemeRef{Value: openapi3.NewSecurityScheme().
+				WithType("apiKey").WithIn("header").WithName("Authorization").
+				WithDescription(`"HMAC <key id>:<signature>" with ` + auth.HeaderTimestamp + ` header, signature ` +
+					`is hex encoded HMAC-SHA256 of method, request uri, timestamp and hex encoded SHA-256 of the body ` +
+					`separated by new lines.`)},
+			securityBearer: &openapi3.SecuritySchemeRef{Value: openapi3.NewJWTSecurityScheme().
+				WithDescription("JWT signed by a key from AUTH_JWKS_FILE.")},
+		},
+	}
+}
+
+func labelsSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewStringSchema()).WithMaxProperties(32)
+}
+
+func checkStatusSchema() *openapi3.Schema {
+	return openapi3.NewStringSchema().WithEnum(string(models.CheckStatusOK), string(models.CheckStatusFailed))
+}
+
+func alertStateSchema() *openapi3.Schema {
+	return openapi3.NewStringSchema().WithEnum(
+		string(models.AlertStatePending), string(models.AlertStateFiring), string(models.AlertStateResolved))
+}
+
+func arrayOf(items *openapi3.SchemaRef) *openapi3.Schema {
+	schema := openapi3.NewArraySchema()
+	schema.Items = items
+	return schema
+}
+
+func describe(schema *openapi3.Schema, description string) *openapi3.Schema {
+	schema.Description = description
+	return schema
+}
+
+// schemaRef references a schema of components, value is kept for validation.
+func schemaRef(name string, value *openapi3.Schema) *openapi3.SchemaRef {
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, value)
+}
+
+func problemResponse(description string, problem *openapi3.Schema) *openapi3.ResponseRef {
+	return &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription(description).WithContent(
+		openapi3.Content{"application/problem+json": openapi3.NewMediaType().WithSchemaRef(
+			schemaRef(schemaProblem, problem))})}
+}
+
+func jsonResponse(description string, schema *openapi3.SchemaRef) *openapi3.Response {
+	return openapi3.NewResponse().WithDescription(description).WithJSONSchemaRef(schema)
+}
+
+// operation is a builder of an operation of the document.
+type operation struct {
+	*openapi3.Operation
+	components *openapi3.Components
+}
+
+// newOperation returns operation that may return invalid argument and internal error.
+func (c *openAPI) newOperation(id, summary, description string) *operation {
+	op := &operation{Operation: openapi3.NewOperation(), components: c.doc.Components}
+	op.Responses = openapi3.NewResponsesWithCapacity(8)
+	op.OperationID = id
+	op.Summary = summary
+	op.Description = description
+	return op.withResponse(http.StatusBadRequest, responseInvalid).
+		withResponse(http.StatusInternalServerError, responseInternal)
+}
+
+// withResponse adds a shared response of components.
+func (op *operation) withResponse(status int, name string) *operation {
+	op.Responses.Set(strconv.Itoa(status), &openapi3.ResponseRef{
+		Ref:   "#/components/responses/" + name,
+		Value: op.components.Responses[name].Value,
+	})
+	return op
+}
+
+func (op *operation) withStatus(status int, response *openapi3.Response) *operation {
+	op.Responses.Set(strconv.Itoa(status), &openapi3.ResponseRef{Value: response})
+	return op
+}
+
+func (op *operation) withParam(param *openapi3.Parameter) *operation {
+	op.AddParameter(param)
+	return op
+}
+
+// withBody adds request body with content of media types, the first media type has schema, other are binary.
+func (op *operation) withBody(schema *openapi3.SchemaRef, mediaTypes ...string) *operation {
+	content := openapi3.Content{}
+	for i, mediaType := range mediaTypes {
+		if i == 0 {
+			content[mediaType] = openapi3.NewMediaType().WithSchemaRef(schema)
+			continue
+		}
+		content[mediaType] = openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema().WithFormat("binary"))
+	}
+	op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithContent(content)}
+	return op.withResponse(http.StatusRequestEntityTooLarge, responseTooLarge).
+		withResponse(http.StatusUnsupportedMediaType, responseUnsupportedMediaType)
+}
+
+// public removes security requirements, so operation is called without credentials.
+func (op *operation) public() *operation {
+	op.Security = openapi3.NewSecurityRequirements()
+	return op
+}
+
+// authenticated adds responses of authentication and rate limits.
+func (op *operation) authenticated() *operation {
+	return op.withResponse(http.StatusUnauthorized, responseUnauthenticated).
+		withResponse(http.StatusForbidden, responseForbidden).
+		withResponse(http.StatusTooManyRequests, responseRateLimited)
+}
+
+// deprecated marks operation of a legacy route, it gets unique id as the same operation is served in API v1.
+func (op *operation) deprecated() *operation {
+	op.Deprecated = true
+	op.OperationID += "Legacy"
+	op.Description = strings.TrimSpace(op.Description + " Deprecated, responses have Deprecation header and Link " +
+		"header to the successor route of API v1 under " + prefixV1 + ".")
+	return op
+}
+
+func (c *openAPI) createRecords() *operation {
+	accepted := openapi3.NewObjectSchema().
+		WithProperty("accepted", describe(openapi3.NewIntegerSchema(), "Number of records in request."))
+
+	return c.newOperation("createRecords", "Create records",
+		"Create a record, or an array of up to HTTP_MAX_BATCH_POINTS records. All records of a batch are "+
+			"validated before any of them is saved, then they are saved in order until the first error.").
+		withParam(formatParam()).
+		withBody(openapi3.NewSchemaRef("", c.recordsSchema()), recordMediaTypes...).
+		withStatus(http.StatusCreated, jsonResponse(
+			"Records are saved, or buffered in write-ahead log while storage is unavailable.",
+			openapi3.NewSchemaRef("", accepted))).
+		withStatus(http.StatusAccepted, jsonResponse(
+			"Records are queued and will be saved asynchronously, see INGEST_ASYNC and INGEST_ACK.",
+			openapi3.NewSchemaRef("", accepted))).
+		withResponse(http.StatusNotFound, responseNotFound).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) listRecords() *operation {
+	page := openapi3.NewObjectSchema().
+		WithProperty("records", arrayOf(c.ref(schemaRecord))).
+		WithProperty("next_page_token", describe(openapi3.NewStringSchema(),
+			"Token of the next page, it is missing on the last page."))
+	content := c.recordsContent(page)
+	nextPage := &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+		Description: "Token of the next page, it is set if there are more records.",
+		Schema:      openapi3.NewSchemaRef("", openapi3.NewStringSchema()),
+	}}}
+	ok := openapi3.NewResponse().WithDescription("Page of records in range sorted by timestamp, JSON page has " +
+		"records in an object, other formats have only records.").WithContent(content)
+	ok.Headers = openapi3.Headers{handlers.HeaderNextPageToken: nextPage}
+
+	return c.newOperation("listRecords", "List records", "List records by range from start to end by pages.").
+		withRangeParams().
+		withParam(openapi3.NewQueryParameter("limit").WithSchema(openapi3.NewIntegerSchema().WithMin(0)).
+			WithDescription("Max number of records on a page, default is 1000, max is 10000.")).
+		withParam(openapi3.NewQueryParameter("page_token").WithSchema(openapi3.NewStringSchema()).
+			WithDescription("Token of the next page from the previous response.")).
+		withParam(formatParam()).
+		withStatus(http.StatusOK, ok).
+		withResponse(http.StatusNotFound, responseNotFound).
+		withResponse(http.StatusNotAcceptable, responseNotAcceptable).
+		withResponse(http.StatusRequestEntityTooLarge, responseTooLarge).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) streamMetrics() *operation {
+	events := openapi3.NewStringSchema()
+	events.Description = "`record` events with a record in json data, `end` event with an error in json data " +
+		"is sent before the stream ends because of a slow consumer or shutdown."
+
+	return c.newOperation("streamMetrics", "Stream metrics",
+		"Stream records of a series created after subscription over Server-Sent Events, or over WebSocket if "+
+			"request is an upgrade. WebSocket messages are records in json, the connection is closed with code "+
+			"1013 if the subscriber is too slow and 1001 on shutdown. Records are sent without series. Idle "+
+			"streams get SSE comments or WebSocket pings every STREAM_HEARTBEAT_INTERVAL.").
+		withParam(openapi3.NewQueryParameter("series").
+			WithSchema(openapi3.NewStringSchema().WithPattern(optionalSeriesPattern)).
+			WithDescription("Series name, default series is used if empty.")).
+		withParam(openapi3.NewQueryParameter("backfill").WithSchema(openapi3.NewIntegerSchema().WithMin(0)).
+			WithDescription("Number of last records sent before new ones, up to STREAM_MAX_BACKFILL.")).
+		withStatus(http.StatusOK, openapi3.NewResponse().WithDescription("Stream of events.").
+			WithContent(openapi3.NewContentWithSchema(events, []string{"text/event-stream"}))).
+		withStatus(http.StatusSwitchingProtocols, openapi3.NewResponse().WithDescription("WebSocket stream.")).
+		withResponse(http.StatusNotFound, responseNotFound).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) putMetrics() *operation {
+	return c.newOperation("putMetric", "Put metric",
+		"Put a record, or an array of up to HTTP_MAX_BATCH_POINTS records. All records of a batch are validated "+
+			"before any of them is saved, then they are saved in order until the first error.").
+		withParam(formatParam()).
+		withBody(openapi3.NewSchemaRef("", c.recordsSchema()), recordMediaTypes...).
+		withStatus(http.StatusOK, openapi3.NewResponse().
+			WithDescription("Record is saved, or buffered in write-ahead log while storage is unavailable.")).
+		withStatus(http.StatusAccepted, openapi3.NewResponse().
+			WithDescription("Record is queued and will be saved asynchronously, see INGEST_ASYNC and INGEST_ACK.")).
+		withResponse(http.StatusNotFound, responseNotFound).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) getMetrics() *operation {
+	content := c.recordsContent(arrayOf(c.ref(schemaRecord)))
+
+	return c.newOperation("getMetrics", "Get metrics", "Get metrics by range from start to end.").
+		withRangeParams().
+		withParam(formatParam()).
+		withStatus(http.StatusOK, openapi3.NewResponse().WithDescription("Records in range.").WithContent(content)).
+		withStatus(http.StatusNoContent, openapi3.NewResponse().WithDescription("No records in range.")).
+		withResponse(http.StatusNotFound, responseNotFound).
+		withResponse(http.StatusNotAcceptable, responseNotAcceptable).
+		withResponse(http.StatusRequestEntityTooLarge, responseTooLarge).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+// withRangeParams adds params of a range of records.
+func (op *operation) withRangeParams() *operation {
+	return op.withParam(openapi3.NewQueryParameter("series").
+		WithSchema(openapi3.NewStringSchema().WithPattern(optionalSeriesPattern)).
+		WithDescription("Series name, default series is used if empty. It is not used with expr.")).
+		withParam(openapi3.NewQueryParameter("expr").WithSchema(openapi3.NewStringSchema()).
+			WithDescription("Expression over series in RPN, e.g. `errors,requests,/,100,*`, or in infix form, " +
+				"e.g. `errors / requests * 100`. Start is required, unknown values are NaN.")).
+		withParam(openapi3.NewQueryParameter("step").WithSchema(openapi3.NewIntegerSchema().WithMin(0)).
+			WithDescription("Step of expr in seconds, defaults to the largest step of its series or 60. It must not be " +
+				"longer than the range.")).
+		withParam(openapi3.NewQueryParameter("start").WithSchema(openapi3.NewStringSchema()).
+			WithDescription("Time expression, e.g. 1717745157997559, 2024-06-07T07:25:57Z, now-6h, -1d, today.")).
+		withParam(openapi3.NewQueryParameter("end").WithSchema(openapi3.NewStringSchema()).
+			WithDescription("Time expression, defaults to now if start is set."))
+}
+
+// recordsSchema is a schema of a record or an array of records in request body.
+func (c *openAPI) recordsSchema() *openapi3.Schema {
+	return &openapi3.Schema{OneOf: openapi3.SchemaRefs{
+		c.ref(schemaRecord),
+		openapi3.NewSchemaRef("", arrayOf(c.ref(schemaRecord))),
+	}}
+}
+
+// recordsContent is a content of records in all formats, json has the schema, other formats are binary.
+func (c *openAPI) recordsContent(schema *openapi3.Schema) openapi3.Content {
+	content := openapi3.Content{}
+	for i, mediaType := range recordMediaTypes {
+		if i == 0 {
+			content[mediaType] = openapi3.NewMediaType().WithSchema(schema)
+			continue
+		}
+		content[mediaType] = openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema().WithFormat("binary"))
+	}
+	return content
+}
+
+func formatParam() *openapi3.Parameter {
+	return openapi3.NewQueryParameter(handlers.QueryParamFormat).
+		WithSchema(openapi3.NewStringSchema().WithEnum(handlers.FormatJSON, handlers.FormatNDJSON,
+			handlers.FormatCSV, handlers.FormatMsgPack, handlers.FormatProtobuf)).
+		WithDescription("Format of records, overrides Accept and Content-Type headers. Protobuf messages are " +
+			"described in api/proto/rrd/v1/records.proto.")
+}
+
+func (c *openAPI) createSeries() *operation {
+	return c.newOperation("createSeries", "Create series", "").
+		withBody(c.ref(schemaSeries), "application/json").
+		withStatus(http.StatusCreated, jsonResponse("Created series.", c.ref(schemaSeries))).
+		withStatus(http.StatusConflict, c.problemResponse("Series already exists.")).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) listSeries() *operation {
+	page := openapi3.NewObjectSchema().
+		WithProperty("series", arrayOf(c.ref(schemaSeries)).WithNullable()).
+		WithProperty("next_page_token", describe(openapi3.NewStringSchema(),
+			"Token of the next page, it is empty on the last page."))
+
+	return c.newOperation("listSeries", "List series", "List series sorted by name, filtered by labels.").
+		withParam(openapi3.NewQueryParameter("label").
+			WithSchema(openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema())).
+			WithDescription("Label filter in format key:value, all labels must match.")).
+		withParam(openapi3.NewQueryParameter("limit").WithSchema(openapi3.NewIntegerSchema())).
+		withParam(openapi3.NewQueryParameter("page_token").WithSchema(openapi3.NewStringSchema()).
+			WithDescription("next_page_token of the previous page.")).
+		withStatus(http.StatusOK, jsonResponse("Page of series.", openapi3.NewSchemaRef("", page))).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) describeSeries() *operation {
+	return c.newOperation("describeSeries", "Describe series", "").
+		withParam(seriesParam()).
+		withStatus(http.StatusOK, jsonResponse("Series with stats.", c.ref(schemaSeries))).
+		withResponse(http.StatusNotFound, responseNotFound).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) updateSeries() *operation {
+	update := openapi3.NewObjectSchema().
+		WithProperty("labels", labelsSchema()).
+		WithProperty("unit", openapi3.NewStringSchema()).
+		WithProperty("description", openapi3.NewStringSchema().WithMaxLength(1024)).
+		WithPropertyRef("retention", c.ref(schemaRetention)).
+		WithPropertyRef("holt_winters", c.ref(schemaHoltWinters)).
+		WithProperty("version", describe(openapi3.NewInt64Schema(),
+			"Version of the last read, series is not updated if it was changed concurrently."))
+
+	return c.newOperation("updateSeries", "Update series",
+		"Update labels, unit, description, retention or Holt-Winters params.").
+		withParam(seriesParam()).
+		withBody(openapi3.NewSchemaRef("", update), "application/json").
+		withStatus(http.StatusOK, jsonResponse("Updated series.", c.ref(schemaSeries))).
+		withResponse(http.StatusNotFound, responseNotFound).
+		withStatus(http.StatusConflict, c.problemResponse("Series was modified concurrently.")).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) getForecast() *operation {
+	forecast := openapi3.NewObjectSchema().
+		WithProperty("series", openapi3.NewStringSchema()).
+		WithProperty("step", describe(openapi3.NewInt64Schema(), "Interval between points in seconds.")).
+		WithProperty("points", openapi3.NewArraySchema().WithItems(openapi3.NewObjectSchema().
+			WithProperty("timestamp", describe(openapi3.NewInt64Schema(), "Unix time in microseconds.")).
+			WithProperty("value", openapi3.NewFloat64Schema()).
+			WithProperty("lower", openapi3.NewFloat64Schema()).
+			WithProperty("upper", openapi3.NewFloat64Schema())))
+
+	return c.newOperation("getForecast", "Forecast series",
+		"Holt-Winters predictions of the next steps of a series with holt_winters params, bounds are predicted "+
+			"deviation times deviation_scale.").
+		withParam(seriesParam()).
+		withParam(openapi3.NewQueryParameter("horizon").
+			WithSchema(openapi3.NewIntegerSchema().WithMin(0).WithMax(10000)).
+			WithDescription("Number of predicted steps, one season if 0 or not set.")).
+		withStatus(http.StatusOK, jsonResponse("Forecast.", openapi3.NewSchemaRef("", forecast))).
+		withResponse(http.StatusNotFound, responseNotFound).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func seriesParam() *openapi3.Parameter {
+	return openapi3.NewPathParameter(handlers.PathParamSeries).
+		WithSchema(openapi3.NewStringSchema().WithPattern(seriesNamePattern))
+}
+
+func (c *openAPI) getUsage() *operation {
+	return c.newOperation("getUsage", "Get tenant usage", "Usage and limits of the request tenant.").
+		withStatus(http.StatusOK, jsonResponse("Tenant usage.", c.ref(schemaTenantUsage))).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) listAlerts() *operation {
+	alerts := openapi3.NewObjectSchema().WithProperty("alerts", arrayOf(c.ref(schemaAlert)))
+
+	return c.newOperation("listAlerts", "List alerts", "Alerts of the request tenant sorted by rule and series.").
+		withParam(openapi3.NewQueryParameter("state").WithSchema(alertStateSchema()).
+			WithDescription("State of alerts, all alerts are returned if it is not set.")).
+		withStatus(http.StatusOK, jsonResponse("Alerts.", openapi3.NewSchemaRef("", alerts)))
+}
+
+func (c *openAPI) createAlertRule() *operation {
+	return c.newOperation("createAlertRule", "Create alert rule",
+		"Rule is evaluated every ALERT_EVALUATION_INTERVAL, a tenant can create up to ALERT_MAX_RULES rules.").
+		withBody(c.ref(schemaAlertRule), "application/json").
+		withStatus(http.StatusCreated, jsonResponse("Created rule.", c.ref(schemaAlertRule))).
+		withStatus(http.StatusConflict, c.problemResponse("Rule already exists."))
+}
+
+func (c *openAPI) listAlertRules() *operation {
+	rules := openapi3.NewObjectSchema().WithProperty("rules", arrayOf(c.ref(schemaAlertRule)))
+
+	return c.newOperation("listAlertRules", "List alert rules", "Rules of the request tenant sorted by name.").
+		withStatus(http.StatusOK, jsonResponse("Rules.", openapi3.NewSchemaRef("", rules)))
+}
+
+func (c *openAPI) getAlertRule() *operation {
+	return c.newOperation("getAlertRule", "Get alert rule", "").
+		withParam(ruleParam()).
+		withStatus(http.StatusOK, jsonResponse("Rule.", c.ref(schemaAlertRule))).
+		withStatus(http.StatusNotFound, c.problemResponse("Rule not found."))
+}
+
+func (c *openAPI) replaceAlertRule() *operation {
+	return c.newOperation("replaceAlertRule", "Replace alert rule",
+		"Replace definition of the rule, alerts of the rule are resolved.").
+		withParam(ruleParam()).
+		withBody(c.ref(schemaAlertRule), "application/json").
+		withStatus(http.StatusOK, jsonResponse("Replaced rule.", c.ref(schemaAlertRule))).
+		withStatus(http.StatusNotFound, c.problemResponse("Rule not found.")).
+		withStatus(http.StatusConflict, c.problemResponse("Rule is defined in ALERT_RULES_FILE."))
+}
+
+func (c *openAPI) deleteAlertRule() *operation {
+	return c.newOperation("deleteAlertRule", "Delete alert rule", "Firing alerts of the rule are resolved.").
+		withParam(ruleParam()).
+		withStatus(http.StatusNoContent, openapi3.NewResponse().WithDescription("Rule is deleted.")).
+		withStatus(http.StatusNotFound, c.problemResponse("Rule not found.")).
+		withStatus(http.StatusConflict, c.problemResponse("Rule is defined in ALERT_RULES_FILE."))
+}
+
+func ruleParam() *openapi3.Parameter {
+	return openapi3.NewPathParameter(handlers.PathParamRule).WithSchema(openapi3.NewStringSchema())
+}
+
+func (c *openAPI) createSilence() *operation {
+	return c.newOperation("createSilence", "Create silence",
+		"Suppress notifications of matching alerts until the end, alerts still change state.").
+		withBody(c.ref(schemaSilence), "application/json").
+		withStatus(http.StatusCreated, jsonResponse("Created silence.", c.ref(schemaSilence)))
+}
+
+func (c *openAPI) listSilences() *operation {
+	silences := openapi3.NewObjectSchema().WithProperty("silences", arrayOf(c.ref(schemaSilence)))
+
+	return c.newOperation("listSilences", "List silences",
+		"Silences of the request tenant that are not expired, sorted by start.").
+		withStatus(http.StatusOK, jsonResponse("Silences.", openapi3.NewSchemaRef("", silences)))
+}
+
+func (c *openAPI) deleteSilence() *operation {
+	return c.newOperation("deleteSilence", "Delete silence", "").
+		withParam(openapi3.NewPathParameter(handlers.PathParamSilence).WithSchema(openapi3.NewStringSchema())).
+		withStatus(http.StatusNoContent, openapi3.NewResponse().WithDescription("Silence is deleted.")).
+		withStatus(http.StatusNotFound, c.problemResponse("Silence not found."))
+}
+
+func (c *openAPI) getRetentionStats() *operation {
+	seriesStats := openapi3.NewObjectSchema().
+		WithProperty("points", openapi3.NewInt64Schema()).
+		WithProperty("expired", openapi3.NewInt64Schema()).
+		WithProperty("evicted", openapi3.NewInt64Schema())
+	stats := openapi3.NewObjectSchema().
+		WithProperty("sweeps", openapi3.NewInt64Schema()).
+		WithProperty("last_sweep_at", openapi3.NewInt64Schema()).
+		WithProperty("last_sweep_duration_ms", openapi3.NewInt64Schema()).
+		WithProperty("expired", describe(openapi3.NewInt64Schema(), "Points deleted by max age since start.")).
+		WithProperty("evicted", describe(openapi3.NewInt64Schema(), "Points evicted by max points since start.")).
+		WithProperty("series", openapi3.NewObjectSchema().WithAdditionalProperties(seriesStats).WithNullable())
+
+	return c.newOperation("getRetentionStats", "Get retention statistics", "Retention statistics since start.").
+		withStatus(http.StatusOK, jsonResponse("Retention statistics.", openapi3.NewSchemaRef("", stats)))
+}
+
+func (c *openAPI) listTenants() *operation {
+	return c.newOperation("listTenants", "List tenants usage", "Usage and limits of all tenants.").
+		withStatus(http.StatusOK, jsonResponse("Usage of tenants.",
+			openapi3.NewSchemaRef("", arrayOf(c.ref(schemaTenantUsage)).WithNullable()))).
+		withResponse(http.StatusServiceUnavailable, responseUnavailable)
+}
+
+func (c *openAPI) getLiveness() *operation {
+	return c.newOperation("getLiveness", "Liveness probe", "").
+		withStatus(http.StatusOK, jsonResponse("Process is alive.", c.ref(schemaReadiness))).
+		public()
+}
+
+func (c *openAPI) getReadiness() *operation {
+	return c.newOperation("getReadiness", "Readiness probe", "").
+		withStatus(http.StatusOK, jsonResponse("All dependency checks passed.", c.ref(schemaReadiness))).
+		withStatus(http.StatusServiceUnavailable, jsonResponse("Some dependency checks failed.", c.ref(schemaReadiness))).
+		public()
+}
+
+func (c *openAPI) getStatus() *operation {
+	status := openapi3.NewAllOfSchema()
+	status.AllOf = openapi3.SchemaRefs{
+		c.ref(schemaReadiness),
+		openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+			WithProperty("version", openapi3.NewStringSchema()).
+			WithProperty("started_at", describe(openapi3.NewInt64Schema(), "Unix time in microseconds.")).
+			WithProperty("uptime_seconds", openapi3.NewInt64Schema()).
+			WithProperty("series", openapi3.NewArraySchema().WithItems(openapi3.NewObjectSchema().
+				WithProperty("name", openapi3.NewStringSchema()).
+				WithProperty("points", openapi3.NewInt64Schema()).
+				WithProperty("capacity", openapi3.NewInt64Schema())).WithNullable())),
+	}
+
+	return c.newOperation("getStatus", "Get service status", "Version, uptime, dependency checks and series.").
+		withStatus(http.StatusOK, jsonResponse("Service status.", openapi3.NewSchemaRef("", status)))
+}
+
+func (c *openAPI) getServiceMetrics() *operation {
+	return c.newOperation("getServiceMetrics", "Get service self-instrumentation", "Path is set by METRICS_PATH.").
+		withStatus(http.StatusOK, openapi3.NewResponse().
+			WithDescription("Service metrics in prometheus text format.").
+			WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/plain"})))
+}
+
+func (c *openAPI) getOpenAPI() *operation {
+	return c.newOperation("getOpenAPI", "Get OpenAPI document", "This document.").
+		withStatus(http.StatusOK, openapi3.NewResponse().WithDescription("OpenAPI 3 document.").
+			WithJSONSchema(openapi3.NewObjectSchema())).
+		public()
+}
+
+func (c *openAPI) getDocs() *operation {
+	return c.newOperation("getDocs", "API documentation", "Swagger UI for this document.").
+		withStatus(http.StatusOK, openapi3.NewResponse().WithDescription("HTML page.").
+			WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/html"}))).
+		public()
+}
+
+func (c *openAPI) getDocsAssets() *operation {
+	names := make([]string, 0, len(docsAssets))
+	for name := range docsAssets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	assets := make([]any, len(names))
+	for i, name := range names {
+		assets[i] = name
+	}
+	return c.newOperation("getDocsAssets", "API documentation assets", "Swagger UI assets of the docs page.").
+		withParam(openapi3.NewPathParameter(pathParamAsset).WithSchema(openapi3.NewStringSchema().WithEnum(assets...))).
+		withStatus(http.StatusOK, openapi3.NewResponse().WithDescription("Stylesheet or script.").
+			WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/css", "text/javascript"}))).
+		withStatus(http.StatusNotFound, c.problemResponse("Asset not found.")).
+		public()
+}
+
+// schema returns shared schema of components.
+func (c *openAPI) schema(name string) *openapi3.Schema {
+	return c.doc.Components.Schemas[name].Value
+}
+
+func (c *openAPI) problemResponse(description string) *openapi3.Response {
+	return problemResponse(description, c.schema(schemaProblem)).Value
+}
+
+// ref references shared schema of components.
+func (c *openAPI) ref(name string) *openapi3.SchemaRef {
+	return schemaRef(name, c.schema(name))
+}
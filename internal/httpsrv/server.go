@@ -1,45 +1,267 @@
 package httpsrv
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
 )
 
 const defaultTimeout = 15 * time.Second
 
+// Paths of probes, they are not authenticated and not limited.
+const (
+	pathLive  = "/healthz"
+	pathReady = "/readyz"
+)
+
+// Handlers contains all handlers served by the server.
+type Handlers struct {
+	RRD       *handlers.RRD
+	Series    *handlers.Series
+	Retention *handlers.Retention
+	Tenants   *handlers.Tenants
+	Health    *handlers.Health
+	// Stream streams new records, it is optional.
+	Stream *handlers.Stream
+	// Alerts manages alert rules, alerts and silences, it is optional.
+	Alerts *handlers.Alerts
+	// Forecast returns forecasts of series, it is optional.
+	Forecast *handlers.Forecast
+	// Metrics serves self-instrumentation on MetricsPath, it is optional.
+	Metrics     http.Handler
+	MetricsPath string
+	// Observer records all requests, it is optional.
+	Observer RequestObserver
+	// Auth authenticates requests to all routes except health checks, authentication is disabled if it is nil.
+	Auth Authenticator
+	// Limiter limits body size of requests and rate of requests to all routes except probes, it is optional.
+	Limiter *Limiter
+	// Middleware configures middlewares that are applied to all requests.
+	Middleware MiddlewareOptions
+	// Version is a version of the service in the API document.
+	Version string
+	// Legacy configures routes without version prefix, they are served unless disabled.
+	Legacy LegacyOptions
+}
+
 // Server contains http server with handlers.
 type Server struct {
 	srv *http.Server
+	// tls is true if server uses HTTPS.
+	tls bool
 }
 
-// NewServer returns new http server for serving API.
-func NewServer(port int, handlers *handlers.RRD) *Server {
+// NewServer returns new http server for serving API, it serves HTTPS if certificate is set in tls options.
+func NewServer(port int, h Handlers, tlsOpts TLSOptions, logger *slog.Logger) (*Server, error) {
+	handler, err := NewHandler(h, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      NewRouter(handlers),
+		Handler:      handler,
 		WriteTimeout: defaultTimeout,
 		ReadTimeout:  defaultTimeout,
 	}
+	if tlsOpts.Enabled() {
+		reloader, err := newCertReloader(tlsOpts, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize tls: %w", err)
+		}
+		// Config is taken on each handshake, so reloaded certificates are used by new connections.
+		srv.TLSConfig = &tls.Config{GetConfigForClient: reloader.getConfig}
+		if tlsOpts.DisableHTTP2 {
+			// Non-nil map disables automatic HTTP/2.
+			srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		}
+	}
 	return &Server{
 		srv: srv,
-	}
+		tls: tlsOpts.Enabled(),
+	}, nil
 }
 
-// Start starts http server.
+// Start starts http server, it blocks until server is shut down.
 func (s *Server) Start() error {
-	return s.srv.ListenAndServe()
+	listener, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener, it blocks until server is shut down.
+func (s *Server) Serve(listener net.Listener) error {
+	var err error
+	if s.tls {
+		// Certificates are served by tls config.
+		err = s.srv.ServeTLS(listener, "", "")
+	} else {
+		err = s.srv.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting new connections and waits for in-flight requests until context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
 }
 
-// NewRouter registers router paths.
-func NewRouter(handlers *handlers.RRD) *mux.Router {
+// NewHandler returns router wrapped with middlewares. Middlewares of router are applied only to matched routes,
+// so middlewares that handle all requests, e.g. CORS preflight, wrap the router.
+func NewHandler(h Handlers, logger *slog.Logger) (http.Handler, error) {
+	router, err := NewRouter(h, logger)
+	if err != nil {
+		return nil, err
+	}
+	return chain(router, h.Middleware.middlewares(logger)...), nil
+}
+
+// NewRouter registers router paths. It returns error if metrics path conflicts with API paths.
+func NewRouter(h Handlers, logger *slog.Logger) (*mux.Router, error) {
 	r := mux.NewRouter()
-	r.HandleFunc("/metrics", handlers.Create).Methods("PUT")
-	r.HandleFunc("/metrics", handlers.GetByRange).Methods("GET")
+	r.NotFoundHandler = handlers.NotFound(logger)
+	r.MethodNotAllowedHandler = handlers.MethodNotAllowed(logger)
+	r.Use(nameSpan)
+	if h.Observer != nil {
+		r.Use(instrument(h.Observer))
+		// Middlewares are applied to matched routes only.
+		r.NotFoundHandler = instrument(h.Observer)(r.NotFoundHandler)
+		r.MethodNotAllowedHandler = instrument(h.Observer)(r.MethodNotAllowedHandler)
+	}
+	if h.Limiter != nil {
+		r.Use(limitBody(h.Limiter, logger))
+	}
 
-	return r
+	api := newOpenAPI(h.Version)
+	read := authorize(h.Auth, models.ScopeRead, h.Limiter, logger)
+	write := authorize(h.Auth, models.ScopeWrite, h.Limiter, logger)
+	admin := authorize(h.Auth, models.ScopeAdmin, h.Limiter, logger)
+	// Rate is limited after authentication, so clients are identified by identity, and failed authentication
+	// is limited by remote ip in authorize, so credentials can't be guessed. Requests are validated
+	// against the API document after authentication, so unauthenticated clients don't learn API details.
+	// Rate limits are keyed by path without version prefix, so a route of API v1 and its legacy route share limits.
+	handleRoute := func(method, path, limitPath string, guard func(http.Handler) http.Handler, handler http.Handler,
+		op *operation,
+	) http.Handler {
+		validate := validateRequest(api.add(method, path, op.authenticated()), logger)
+		return guard(limitRate(h.Limiter, method+" "+limitPath, logger)(validate(handler)))
+	}
+	handle := func(method, path string, guard func(http.Handler) http.Handler, handler http.Handler, op *operation) {
+		r.Handle(path, handleRoute(method, path, path, guard, handler, op)).Methods(method)
+	}
+	handleV1 := func(method, path string, guard func(http.Handler) http.Handler, handler http.Handler, op *operation) {
+		r.Handle(prefixV1+path, handleRoute(method, prefixV1+path, path, guard, handler, op)).Methods(method)
+	}
+	// Legacy routes keep the contract of the first release, deprecation headers are set before authentication,
+	// so they are sent with errors too.
+	legacyObserver, _ := h.Observer.(LegacyObserver)
+	legacy := deprecate(h.Legacy, legacyObserver)
+	handleLegacy := func(method, path string, guard func(http.Handler) http.Handler, handler http.Handler,
+		op *operation,
+	) {
+		if h.Legacy.Disabled {
+			return
+		}
+		r.Handle(path, legacy(handleRoute(method, path, path, guard, handler, op.deprecated()))).Methods(method)
+	}
+	// handleBoth serves the same handler in API v1 and as a legacy route.
+	handleBoth := func(method, path string, guard func(http.Handler) http.Handler, handler http.HandlerFunc,
+		op func() *operation,
+	) {
+		handleV1(method, path, guard, handler, op())
+		handleLegacy(method, path, guard, handler, op())
+	}
+	// Public routes are not authenticated and not limited, they don't expose any data.
+	handlePublic := func(method, path string, handler http.HandlerFunc, op *operation) {
+		api.add(method, path, op)
+		r.Handle(path, handler).Methods(method)
+	}
+
+	handleV1(http.MethodPut, "/metrics", write, http.HandlerFunc(h.RRD.CreateRecords), api.createRecords())
+	handleV1(http.MethodGet, "/metrics", read, http.HandlerFunc(h.RRD.ListRecords), api.listRecords())
+	if h.Stream != nil {
+		handleV1(http.MethodGet, "/metrics/stream", read, http.HandlerFunc(h.Stream.Subscribe), api.streamMetrics())
+	}
+	handleLegacy(http.MethodPut, "/metrics", write, http.HandlerFunc(h.RRD.Create), api.putMetrics())
+	handleLegacy(http.MethodGet, "/metrics", read, http.HandlerFunc(h.RRD.GetByRange), api.getMetrics())
+
+	seriesPath := fmt.Sprintf("/series/{%s}", handlers.PathParamSeries)
+	handleBoth(http.MethodPost, "/series", write, h.Series.Create, api.createSeries)
+	handleBoth(http.MethodGet, "/series", read, h.Series.List, api.listSeries)
+	handleBoth(http.MethodGet, seriesPath, read, h.Series.Describe, api.describeSeries)
+	handleBoth(http.MethodPatch, seriesPath, write, h.Series.Update, api.updateSeries)
+	if h.Forecast != nil {
+		handleV1(http.MethodGet, seriesPath+"/forecast", read, http.HandlerFunc(h.Forecast.Get), api.getForecast())
+	}
+
+	handleBoth(http.MethodGet, "/usage", read, h.Tenants.Usage, api.getUsage)
+
+	if h.Alerts != nil {
+		rulePath := fmt.Sprintf("/alerts/rules/{%s}", handlers.PathParamRule)
+		silencePath := fmt.Sprintf("/alerts/silences/{%s}", handlers.PathParamSilence)
+		handleV1(http.MethodGet, "/alerts", read, http.HandlerFunc(h.Alerts.List), api.listAlerts())
+		handleV1(http.MethodPost, "/alerts/rules", write, http.HandlerFunc(h.Alerts.CreateRule), api.createAlertRule())
+		handleV1(http.MethodGet, "/alerts/rules", read, http.HandlerFunc(h.Alerts.ListRules), api.listAlertRules())
+		handleV1(http.MethodGet, rulePath, read, http.HandlerFunc(h.Alerts.GetRule), api.getAlertRule())
+		handleV1(http.MethodPut, rulePath, write, http.HandlerFunc(h.Alerts.ReplaceRule), api.replaceAlertRule())
+		handleV1(http.MethodDelete, rulePath, write, http.HandlerFunc(h.Alerts.DeleteRule), api.deleteAlertRule())
+		handleV1(http.MethodPost, "/alerts/silences", write, http.HandlerFunc(h.Alerts.CreateSilence),
+			api.createSilence())
+		handleV1(http.MethodGet, "/alerts/silences", read, http.HandlerFunc(h.Alerts.ListSilences), api.listSilences())
+		handleV1(http.MethodDelete, silencePath, write, http.HandlerFunc(h.Alerts.DeleteSilence), api.deleteSilence())
+	}
+
+	// Retention stats, usage of tenants and status contain series of all tenants.
+	handleBoth(http.MethodGet, "/retention", admin, h.Retention.Stats, api.getRetentionStats)
+	handleBoth(http.MethodGet, "/tenants", admin, h.Tenants.List, api.listTenants)
+	handleBoth(http.MethodGet, "/status", admin, h.Health.Status, api.getStatus)
+
+	handlePublic(http.MethodGet, pathLive, h.Health.Live, api.getLiveness())
+	handlePublic(http.MethodGet, pathReady, h.Health.Ready, api.getReadiness())
+	handlePublic(http.MethodGet, pathOpenAPI, api.ServeHTTP, api.getOpenAPI())
+	handlePublic(http.MethodGet, pathDocs, serveDocs, api.getDocs())
+	handlePublic(http.MethodGet, pathDocsAssets, serveDocsAssets(logger), api.getDocsAssets())
+
+	if h.Metrics != nil {
+		if err := checkPathIsFree(r, h.MetricsPath); err != nil {
+			return nil, fmt.Errorf("invalid metrics path: %w", err)
+		}
+		handle(http.MethodGet, h.MetricsPath, admin, h.Metrics, api.getServiceMetrics())
+	}
+
+	// Document is encoded once all routes are added.
+	if err := api.encode(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// checkPathIsFree returns error if path is not absolute or is already served by any route.
+func checkPathIsFree(r *mux.Router, path string) error {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil || len(path) == 0 || path[0] != '/' {
+		return fmt.Errorf("path %q must be absolute", path)
+	}
+	// Router with not found handler matches any path, so match error is checked.
+	var match mux.RouteMatch
+	r.Match(req, &match)
+	if match.MatchErr == nil || errors.Is(match.MatchErr, mux.ErrMethodMismatch) {
+		return fmt.Errorf("path %q is already used", path)
+	}
+	return nil
 }
@@ -0,0 +1,200 @@
+package httpsrv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/timeexpr"
+)
+
+type getterMock struct{}
+
+func (mock getterMock) GetByRange(_ context.Context, _ string, _, _ int64) ([]models.Record, error) {
+	return nil, nil
+}
+
+func (mock getterMock) ListRecords(context.Context, string, int64, int64, int) ([]models.Record, error) {
+	return nil, nil
+}
+
+func (mock getterMock) Evaluate(context.Context, models.ExprQuery) ([]models.Record, error) {
+	return nil, nil
+}
+
+// slowSetterMock blocks writes until release is closed.
+type slowSetterMock struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu      sync.Mutex
+	records []models.Record
+}
+
+func (mock *slowSetterMock) Create(_ context.Context, record models.Record) error {
+	close(mock.started)
+	<-mock.release
+
+	mock.mu.Lock()
+	mock.records = append(mock.records, record)
+	mock.mu.Unlock()
+	return nil
+}
+
+func (mock *slowSetterMock) Validate(context.Context, models.Record) error {
+	return nil
+}
+
+func (mock *slowSetterMock) Admit(int) error {
+	return nil
+}
+
+func newTestServer(t *testing.T, setter handlers.RRDSetter) (*Server, string) {
+	t.Helper()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	rrdHandlers := handlers.NewRRD(getterMock{}, setter, timeexpr.NewParser(time.UTC), logger)
+	srv, err := NewServer(0, Handlers{
+		RRD:       rrdHandlers,
+		Series:    handlers.NewSeries(nil, logger),
+		Retention: handlers.NewRetention(nil, logger),
+		Tenants:   handlers.NewTenants(nil, logger),
+		Health:    handlers.NewHealth(nil, logger),
+	}, TLSOptions{}, logger)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		if err := srv.Serve(listener); err != nil {
+			t.Errorf("failed to serve: %v", err)
+		}
+	}()
+
+	return srv, fmt.Sprintf("http://%s/metrics", listener.Addr())
+}
+
+func putRecord(url string, record models.Record) (*http.Response, error) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func TestServer_ShutdownDrainsInFlightWrite(t *testing.T) {
+	t.Parallel()
+	setter := &slowSetterMock{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	srv, url := newTestServer(t, setter)
+
+	record := models.Record{Timestamp: time.Now().UnixMicro(), MetricValue: 1.5}
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		resp, err := putRecord(url, record)
+		resultCh <- result{resp: resp, err: err}
+	}()
+	<-setter.started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(context.Background())
+	}()
+
+	// Shutdown waits for the in-flight write.
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("shutdown returned before write completed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// New requests are rejected while draining.
+	_, err := putRecord(url, record)
+	require.Error(t, err)
+
+	close(setter.release)
+
+	res := <-resultCh
+	require.NoError(t, res.err)
+	defer res.resp.Body.Close()
+	require.Equal(t, http.StatusOK, res.resp.StatusCode)
+	require.NoError(t, <-shutdownErr)
+	require.Len(t, setter.records, 1)
+	require.Equal(t, record.Timestamp, setter.records[0].Timestamp)
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	t.Parallel()
+	setter := &slowSetterMock{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	defer close(setter.release)
+	srv, url := newTestServer(t, setter)
+
+	go func() {
+		resp, err := putRecord(url, models.Record{Timestamp: time.Now().UnixMicro(), MetricValue: 1.5})
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-setter.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, srv.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestHandler_WebSocket(t *testing.T) {
+	t.Parallel()
+	observer := &observerMock{}
+	h := newOpenAPITestHandlers()
+	h.Observer = observer
+	// Wrappers of response writer must support hijacking.
+	h.Middleware = MiddlewareOptions{AccessLog: true, Compression: []string{EncodingGzip}}
+	handler, err := NewHandler(h, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/metrics/stream?series=cpu&backfill=1"
+	header := http.Header{"Accept-Encoding": []string{"gzip"}}
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"timestamp":1,"metric_value":1.5}`, string(message))
+	require.NoError(t, conn.Close())
+
+	require.Eventually(t, func() bool {
+		observer.mu.Lock()
+		defer observer.mu.Unlock()
+		return len(observer.observations) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, observation{"/api/v1/metrics/stream", http.MethodGet, http.StatusSwitchingProtocols},
+		observer.observations[0])
+}
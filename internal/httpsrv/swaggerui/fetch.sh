@@ -0,0 +1,12 @@
+#!/bin/sh
+# Fetches swagger ui assets of the version in VERSION from npm registry, they are embedded and served at /docs.
+set -eu
+
+dir=$(dirname "$0")
+version=$(cat "$dir/VERSION")
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL "https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-$version.tgz" -o "$tmp/swagger-ui.tgz"
+tar -xzf "$tmp/swagger-ui.tgz" -C "$tmp" package/swagger-ui.css package/swagger-ui-bundle.js
+cp "$tmp/package/swagger-ui.css" "$tmp/package/swagger-ui-bundle.js" "$dir/"
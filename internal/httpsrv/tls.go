@@ -0,0 +1,180 @@
+package httpsrv
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ClientAuth is a policy of verification of client certificates.
+type ClientAuth string
+
+// Client certificate policies, client certificates are verified with client CA if they are sent.
+const (
+	// ClientAuthNone doesn't request client certificates.
+	ClientAuthNone ClientAuth = "none"
+	// ClientAuthOptional verifies client certificate if it is sent.
+	ClientAuthOptional ClientAuth = "optional"
+	// ClientAuthRequire rejects connections without valid client certificate.
+	ClientAuthRequire ClientAuth = "require"
+)
+
+// tlsClientAuth returns client auth type of tls config.
+func (a ClientAuth) tlsClientAuth() (tls.ClientAuthType, error) {
+	switch a {
+	case ClientAuthNone, "":
+		return tls.NoClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unknown client auth %q, must be one of none, optional, require", a)
+	}
+}
+
+// TLSOptions contains HTTPS params, server uses plain http if certificate is not set.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a path to CA certificates of clients, it is required if client certificates are verified.
+	ClientCAFile string
+	ClientAuth   ClientAuth
+	// ReloadInterval is an interval of checking files for changes, 0 disables reload.
+	ReloadInterval time.Duration
+	// DisableHTTP2 restricts HTTPS to HTTP/1.1.
+	DisableHTTP2 bool
+}
+
+// Enabled returns true if server uses HTTPS.
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != ""
+}
+
+// files returns all files of the options, they are watched for changes.
+func (o TLSOptions) files() []string {
+	files := []string{o.CertFile, o.KeyFile}
+	if o.ClientCAFile != "" {
+		files = append(files, o.ClientCAFile)
+	}
+	return files
+}
+
+// config loads certificates and returns tls config of the server.
+func (o TLSOptions) config() (*tls.Config, error) {
+	clientAuth, err := o.ClientAuth.tlsClientAuth()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if o.DisableHTTP2 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+
+	if clientAuth != tls.NoClientCert {
+		if o.ClientCAFile == "" {
+			return nil, fmt.Errorf("client CA file is required for client auth %q", o.ClientAuth)
+		}
+		pem, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse client CA file %s", o.ClientCAFile)
+		}
+		config.ClientCAs = pool
+	}
+
+	return config, nil
+}
+
+// certReloader serves tls config and reloads it when certificate files are changed.
+// Files are checked lazily on handshakes, so an idle server doesn't touch them.
+type certReloader struct {
+	opts   TLSOptions
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	config    *tls.Config
+	modTimes  []time.Time
+	lastCheck time.Time
+}
+
+// newCertReloader loads certificates, it returns error if they are invalid.
+func newCertReloader(opts TLSOptions, logger *slog.Logger) (*certReloader, error) {
+	modTimes, err := modTimes(opts.files())
+	if err != nil {
+		return nil, err
+	}
+	config, err := opts.config()
+	if err != nil {
+		return nil, err
+	}
+	return &certReloader{
+		opts:      opts,
+		logger:    logger,
+		config:    config,
+		modTimes:  modTimes,
+		lastCheck: time.Now(),
+	}, nil
+}
+
+// getConfig returns current tls config, it is used as tls.Config.GetConfigForClient.
+func (c *certReloader) getConfig(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.opts.ReloadInterval <= 0 || now.Sub(c.lastCheck) < c.opts.ReloadInterval {
+		return c.config, nil
+	}
+	c.lastCheck = now
+
+	modTimes, err := modTimes(c.opts.files())
+	if err != nil {
+		c.logger.Error("failed to check certificate files", slog.Any("error", err))
+		return c.config, nil
+	}
+	if slices.EqualFunc(modTimes, c.modTimes, time.Time.Equal) {
+		return c.config, nil
+	}
+	// Files can be partially written, failed load is retried on the next check as times are not updated.
+	config, err := c.opts.config()
+	if err != nil {
+		c.logger.Error("failed to reload certificates, previous ones are used", slog.Any("error", err))
+		return c.config, nil
+	}
+	c.config = config
+	c.modTimes = modTimes
+	c.logger.Info("certificates are reloaded")
+	return c.config, nil
+}
+
+// modTimes returns modification times of files.
+func modTimes(files []string) ([]time.Time, error) {
+	times := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", file, err)
+		}
+		times = append(times, info.ModTime())
+	}
+	return times, nil
+}
@@ -0,0 +1,242 @@
+package httpsrv
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+)
+
+// testCA issues certificates for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns pem encoded certificate and key, server certificates are issued for 127.0.0.1.
+func (ca *testCA) issue(t *testing.T, serial int64, cn string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeCert(t *testing.T, dir, name string, data []byte, modTime time.Time) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+	return path
+}
+
+func newTLSTestServer(t *testing.T, opts TLSOptions) string {
+	t.Helper()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	srv, err := NewServer(0, Handlers{
+		RRD:       handlers.NewRRD(nil, nil, nil, logger),
+		Series:    handlers.NewSeries(nil, logger),
+		Retention: handlers.NewRetention(nil, logger),
+		Tenants:   handlers.NewTenants(nil, logger),
+		Health:    handlers.NewHealth(nil, logger),
+	}, opts, logger)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		if err := srv.Serve(listener); err != nil {
+			t.Errorf("failed to serve: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, srv.Shutdown(context.Background()))
+	})
+
+	return fmt.Sprintf("https://%s/healthz", listener.Addr())
+}
+
+func newTLSClient(ca *testCA, cert *tls.Certificate, http2 bool) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: http2},
+		Timeout:   5 * time.Second,
+	}
+}
+
+func TestServer_TLS(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, 2, "rrd", x509.ExtKeyUsageServerAuth)
+	clientCertPEM, clientKeyPEM := ca.issue(t, 3, "collector", x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	now := time.Now()
+	certFile := writeCert(t, dir, "server.pem", serverCert, now)
+	keyFile := writeCert(t, dir, "server.key", serverKey, now)
+	caFile := writeCert(t, dir, "ca.pem", ca.pem, now)
+
+	testCases := []struct {
+		opts       TLSOptions
+		clientCert *tls.Certificate
+		http2      bool
+		proto      string
+		err        bool
+	}{
+		{TLSOptions{CertFile: certFile, KeyFile: keyFile}, nil, true, "HTTP/2.0", false},
+		{TLSOptions{CertFile: certFile, KeyFile: keyFile}, nil, false, "HTTP/1.1", false},
+		// Client can't negotiate HTTP/2 if it is disabled.
+		{TLSOptions{CertFile: certFile, KeyFile: keyFile, DisableHTTP2: true}, nil, true, "HTTP/1.1", false},
+		{
+			TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: ClientAuthRequire},
+			&clientCert, true, "HTTP/2.0", false,
+		},
+		{
+			TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: ClientAuthRequire},
+			nil, true, "", true,
+		},
+		{
+			TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: ClientAuthOptional},
+			nil, true, "HTTP/2.0", false,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			url := newTLSTestServer(t, tc.opts)
+			resp, err := newTLSClient(ca, tc.clientCert, tc.http2).Get(url)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, tc.proto, resp.Proto)
+		})
+	}
+}
+
+func TestServer_TLSReload(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, 2, "rrd", x509.ExtKeyUsageServerAuth)
+
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Minute)
+	certFile := writeCert(t, dir, "server.pem", serverCert, modTime)
+	keyFile := writeCert(t, dir, "server.key", serverKey, modTime)
+	url := newTLSTestServer(t, TLSOptions{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Millisecond})
+
+	serial := func() int64 {
+		t.Helper()
+		// New client makes new handshake.
+		resp, err := newTLSClient(ca, nil, true).Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+	require.Equal(t, int64(2), serial())
+
+	// Invalid files are not loaded, previous certificate is used.
+	writeCert(t, dir, "server.pem", []byte("garbage"), time.Now())
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, int64(2), serial())
+
+	serverCert, serverKey = ca.issue(t, 4, "rrd", x509.ExtKeyUsageServerAuth)
+	writeCert(t, dir, "server.pem", serverCert, time.Now())
+	writeCert(t, dir, "server.key", serverKey, time.Now())
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, int64(4), serial())
+}
+
+func TestNewServer_InvalidTLS(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, 2, "rrd", x509.ExtKeyUsageServerAuth)
+	dir := t.TempDir()
+	certFile := writeCert(t, dir, "server.pem", serverCert, time.Now())
+	keyFile := writeCert(t, dir, "server.key", serverKey, time.Now())
+
+	testCases := []struct {
+		opts TLSOptions
+		err  string
+	}{
+		{TLSOptions{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.key")}, "failed to stat"},
+		{TLSOptions{CertFile: certFile, KeyFile: certFile}, "failed to load server certificate"},
+		{TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire}, "client CA file is required"},
+		{TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile, ClientAuth: ClientAuthOptional}, "failed to parse client CA file"},
+		{TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientAuth: "always"}, "unknown client auth"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			_, err := NewServer(0, Handlers{}, tc.opts, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
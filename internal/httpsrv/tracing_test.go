@@ -0,0 +1,83 @@
+package httpsrv
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"aerospike.com/rrd/internal/httpsrv/handlers"
+	"aerospike.com/rrd/internal/timeexpr"
+)
+
+// Test is not parallel, as tracer provider is global. Package tracers delegate to the first provider set,
+// so it is set once for all tests of the package.
+func TestHandler_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	h := testHandlers(nil, "/internal/metrics")
+	h.RRD = handlers.NewRRD(getterMock{}, nil, timeexpr.NewParser(time.UTC), logger)
+	handler, err := NewHandler(h, logger)
+	require.NoError(t, err)
+
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+	testCases := []struct {
+		path        string
+		traceparent string
+		name        string
+		status      int
+		children    []string
+	}{
+		{"/metrics?series=cpu", "00-" + traceID + "-" + parentID + "-01", "GET /metrics", http.StatusNoContent,
+			[]string{"RRD.GetByRange"}},
+		{"/metrics", "", "GET /metrics", http.StatusNoContent, []string{"RRD.GetByRange"}},
+		// Unknown routes keep the name without route.
+		{"/unknown", "", "GET", http.StatusNotFound, nil},
+	}
+
+	for i, tc := range testCases {
+		ended := len(recorder.Ended())
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.traceparent != "" {
+			req.Header.Set("traceparent", tc.traceparent)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, tc.status, rec.Code, fmt.Sprintf("case %d", i))
+
+		spans := recorder.Ended()[ended:]
+		require.Len(t, spans, len(tc.children)+1, fmt.Sprintf("case %d", i))
+		server := spans[len(spans)-1]
+		require.Equal(t, tc.name, server.Name(), fmt.Sprintf("case %d", i))
+		require.Contains(t, server.Attributes(), semconv.HTTPResponseStatusCode(tc.status), fmt.Sprintf("case %d", i))
+		require.Equal(t, codes.Unset, server.Status().Code, fmt.Sprintf("case %d", i))
+		if tc.traceparent != "" {
+			require.Equal(t, traceID, server.SpanContext().TraceID().String(), fmt.Sprintf("case %d", i))
+			require.Equal(t, parentID, server.Parent().SpanID().String(), fmt.Sprintf("case %d", i))
+			require.True(t, server.Parent().IsRemote(), fmt.Sprintf("case %d", i))
+		} else {
+			require.False(t, server.Parent().IsValid(), fmt.Sprintf("case %d", i))
+		}
+		for j, name := range tc.children {
+			require.Equal(t, name, spans[j].Name(), fmt.Sprintf("case %d", i))
+			require.Equal(t, server.SpanContext().SpanID(), spans[j].Parent().SpanID(), fmt.Sprintf("case %d", i))
+		}
+	}
+}
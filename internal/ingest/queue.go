@@ -0,0 +1,249 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Acknowledgement modes of queued writes.
+const (
+	// AckEnqueue acknowledges write when it is enqueued, storage errors are logged only.
+	AckEnqueue = "enqueue"
+	// AckDurable acknowledges write when its batch is saved to storage.
+	AckDurable = "durable"
+)
+
+type batchSetter interface {
+	SetBatch(ctx context.Context, writes []models.Write) []error
+}
+
+type observer interface {
+	ObserveEnqueue(accepted bool)
+	ObserveBatch(size, failed int, duration time.Duration)
+}
+
+// Options contains queue params.
+type Options struct {
+	// Size is a max number of queued writes, writes are rejected when queue is full.
+	Size    int
+	Workers int
+	// BatchSize is a max number of writes in a batch.
+	BatchSize int
+	// BatchWait is a max time to wait for more writes to fill a batch.
+	BatchWait time.Duration
+	// Ack is one of AckEnqueue or AckDurable.
+	Ack string
+}
+
+// write is a queued write, done receives its result in durable mode.
+type write struct {
+	models.Write
+	done chan error
+}
+
+// Queue is a bounded queue of writes, workers coalesce queued writes into batches and save them to storage.
+// Writes of a series may be saved out of order, as batches are saved concurrently.
+type Queue struct {
+	storage  batchSetter
+	opts     Options
+	observer observer
+	logger   *slog.Logger
+
+	writes chan write
+	// pending is a number of enqueued writes that are not saved yet.
+	pending atomic.Int64
+	// mu guards closed, writes channel is closed under write lock, so no write is sent to closed channel.
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// NewQueue returns new queue and starts its workers.
+func NewQueue(storage batchSetter, opts Options, observer observer, logger *slog.Logger) *Queue {
+	q := &Queue{
+		storage:  storage,
+		opts:     opts,
+		observer: observer,
+		logger:   logger,
+		writes:   make(chan write, opts.Size),
+	}
+	workers := max(opts.Workers, 1)
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Set enqueues record. It returns models.ErrOverloaded if queue is full, and models.ErrUnavailable if queue is closed.
+// In durable mode it waits until the record is saved and returns storage error.
+func (q *Queue) Set(ctx context.Context, record models.Record, retention models.Retention) error {
+	item := write{Write: models.Write{Record: record, Retention: retention}}
+	if q.opts.Ack == AckDurable {
+		item.done = make(chan error, 1)
+	}
+
+	if err := q.enqueue(item); err != nil {
+		return err
+	}
+	if item.done == nil {
+		return nil
+	}
+
+	select {
+	case err := <-item.done:
+		return err
+	case <-ctx.Done():
+		// Record may still be saved, as its batch is not canceled.
+		return fmt.Errorf("context error: %w", ctx.Err())
+	}
+}
+
+func (q *Queue) enqueue(item write) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return models.NewDetailError(models.ErrUnavailable, "write queue is closed")
+	}
+
+	q.pending.Add(1)
+	select {
+	case q.writes <- item:
+		q.observe(true)
+		return nil
+	default:
+		q.pending.Add(-1)
+		q.observe(false)
+		return models.NewDetailError(models.ErrOverloaded, "write queue is full")
+	}
+}
+
+// Admit returns models.ErrOverloaded if queue has no room for n writes, so a batch is rejected before any of its
+// writes is enqueued. Room may still be taken by concurrent writes before the batch is enqueued.
+func (q *Queue) Admit(n int) error {
+	if n > cap(q.writes) {
+		return models.NewDetailError(models.ErrOverloaded, "batch of %d writes exceeds write queue capacity %d",
+			n, cap(q.writes))
+	}
+	if n > cap(q.writes)-len(q.writes) {
+		return models.NewDetailError(models.ErrOverloaded, "write queue is full")
+	}
+	return nil
+}
+
+// PendingWrites returns number of queued and not saved writes.
+func (q *Queue) PendingWrites() int {
+	return int(q.pending.Load())
+}
+
+// Capacity returns max number of queued writes.
+func (q *Queue) Capacity() int {
+	return cap(q.writes)
+}
+
+// Close rejects new writes and waits until queued writes are saved or context is done.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.writes)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to drain write queue, %d writes are pending: %w", q.PendingWrites(), ctx.Err())
+	}
+}
+
+// work saves batches until queue is closed and drained.
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	batchSize := max(q.opts.BatchSize, 1)
+	for {
+		first, ok := <-q.writes
+		if !ok {
+			return
+		}
+		batch := append(make([]write, 0, batchSize), first)
+		batch = q.fill(batch, batchSize)
+		q.save(batch)
+	}
+}
+
+// fill adds queued writes to batch until it is full or batch wait is over.
+func (q *Queue) fill(batch []write, batchSize int) []write {
+	if len(batch) >= batchSize {
+		return batch
+	}
+	timer := time.NewTimer(q.opts.BatchWait)
+	defer timer.Stop()
+	for len(batch) < batchSize {
+		select {
+		case item, ok := <-q.writes:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, item)
+		case <-timer.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+// save writes batch to storage and passes results to waiting writers.
+func (q *Queue) save(batch []write) {
+	defer q.pending.Add(-int64(len(batch)))
+
+	writes := make([]models.Write, len(batch))
+	for i, item := range batch {
+		writes[i] = item.Write
+	}
+
+	// Batch is not bound to any request, storage policies limit its duration.
+	started := time.Now()
+	errs := q.storage.SetBatch(context.Background(), writes)
+
+	failed := 0
+	for i, item := range batch {
+		err := errs[i]
+		if err != nil {
+			failed++
+		}
+		if item.done != nil {
+			item.done <- err
+			continue
+		}
+		if err != nil {
+			q.logger.Error("failed to save queued write",
+				slog.String("series", item.Record.Series),
+				slog.Int64("timestamp", item.Record.Timestamp),
+				slog.Any("error", err),
+			)
+		}
+	}
+	if q.observer != nil {
+		q.observer.ObserveBatch(len(batch), failed, time.Since(started))
+	}
+}
+
+func (q *Queue) observe(accepted bool) {
+	if q.observer != nil {
+		q.observer.ObserveEnqueue(accepted)
+	}
+}
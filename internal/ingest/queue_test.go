@@ -0,0 +1,185 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// storageMock saves batches, records with negative timestamp fail. Batches are blocked until release is closed.
+type storageMock struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	batches [][]models.Write
+}
+
+func newStorageMock() *storageMock {
+	return &storageMock{release: make(chan struct{})}
+}
+
+func (mock *storageMock) SetBatch(_ context.Context, writes []models.Write) []error {
+	<-mock.release
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.batches = append(mock.batches, writes)
+
+	errs := make([]error, len(writes))
+	for i, write := range writes {
+		if write.Record.Timestamp < 0 {
+			errs[i] = models.NewValidationError("timestamp", "must be positive")
+		}
+	}
+	return errs
+}
+
+func (mock *storageMock) saved() int {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	saved := 0
+	for _, batch := range mock.batches {
+		saved += len(batch)
+	}
+	return saved
+}
+
+type observerMock struct {
+	mu       sync.Mutex
+	accepted int
+	rejected int
+	batches  int
+}
+
+func (mock *observerMock) ObserveEnqueue(accepted bool) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if accepted {
+		mock.accepted++
+	} else {
+		mock.rejected++
+	}
+}
+
+func (mock *observerMock) ObserveBatch(_, _ int, _ time.Duration) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.batches++
+}
+
+func newTestQueue(storage batchSetter, opts Options, observer observer) *Queue {
+	return NewQueue(storage, opts, observer, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+}
+
+func record(timestamp int64) models.Record {
+	return models.Record{Series: "cpu", Timestamp: timestamp, MetricValue: 1.5}
+}
+
+func TestQueue_Enqueue(t *testing.T) {
+	t.Parallel()
+	storage := newStorageMock()
+	observer := &observerMock{}
+	queue := newTestQueue(storage, Options{Size: 2, Workers: 1, BatchSize: 3, BatchWait: time.Hour, Ack: AckEnqueue},
+		observer)
+	ctx := context.Background()
+
+	// Worker takes the first write and waits for the batch to fill.
+	require.NoError(t, queue.Set(ctx, record(1), models.Retention{}))
+	require.Eventually(t, func() bool { return len(queue.writes) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, queue.Set(ctx, record(2), models.Retention{}))
+	require.NoError(t, queue.Set(ctx, record(3), models.Retention{}))
+	require.Equal(t, 3, queue.PendingWrites())
+	require.Equal(t, 2, queue.Capacity())
+	require.ErrorIs(t, queue.Admit(3), models.ErrOverloaded)
+
+	// Backpressure: worker blocks on storage with full batch and queue is full.
+	require.Eventually(t, func() bool { return len(queue.writes) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, queue.Admit(2))
+	require.NoError(t, queue.Set(ctx, record(4), models.Retention{}))
+	require.ErrorIs(t, queue.Admit(2), models.ErrOverloaded)
+	require.NoError(t, queue.Admit(1))
+	require.NoError(t, queue.Set(ctx, record(5), models.Retention{}))
+	require.ErrorIs(t, queue.Set(ctx, record(6), models.Retention{}), models.ErrOverloaded)
+
+	// Close drains the queue and rejects new writes.
+	close(storage.release)
+	require.NoError(t, queue.Close(ctx))
+	require.ErrorIs(t, queue.Set(ctx, record(7), models.Retention{}), models.ErrUnavailable)
+	require.Equal(t, 5, storage.saved())
+	require.Equal(t, 0, queue.PendingWrites())
+	require.Equal(t, 5, observer.accepted)
+	require.Equal(t, 1, observer.rejected)
+	require.Equal(t, len(storage.batches), observer.batches)
+}
+
+func TestQueue_Batches(t *testing.T) {
+	t.Parallel()
+	storage := newStorageMock()
+	close(storage.release)
+	queue := newTestQueue(storage, Options{Size: 100, Workers: 1, BatchSize: 3, BatchWait: 10 * time.Millisecond,
+		Ack: AckEnqueue}, nil)
+
+	for i := int64(1); i <= 7; i++ {
+		require.NoError(t, queue.Set(context.Background(), record(i), models.Retention{}))
+	}
+	require.NoError(t, queue.Close(context.Background()))
+
+	require.Equal(t, 7, storage.saved())
+	for _, batch := range storage.batches {
+		require.LessOrEqual(t, len(batch), 3)
+	}
+}
+
+func TestQueue_Durable(t *testing.T) {
+	t.Parallel()
+	storage := newStorageMock()
+	close(storage.release)
+	queue := newTestQueue(storage, Options{Size: 10, Workers: 2, BatchSize: 10, BatchWait: time.Millisecond,
+		Ack: AckDurable}, nil)
+	defer queue.Close(context.Background())
+
+	testCases := []struct {
+		record models.Record
+		err    error
+	}{
+		{record(1), nil},
+		{record(-1), models.ErrInvalidArgument},
+	}
+	for i, tc := range testCases {
+		err := queue.Set(context.Background(), tc.record, models.Retention{})
+		require.ErrorIs(t, err, tc.err, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestQueue_DurableCanceled(t *testing.T) {
+	t.Parallel()
+	storage := newStorageMock()
+	queue := newTestQueue(storage, Options{Size: 10, Workers: 1, BatchSize: 1, Ack: AckDurable}, nil)
+	defer func() {
+		close(storage.release)
+		require.NoError(t, queue.Close(context.Background()))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, queue.Set(ctx, record(1), models.Retention{}), context.DeadlineExceeded)
+}
+
+func TestQueue_CloseTimeout(t *testing.T) {
+	t.Parallel()
+	storage := newStorageMock()
+	defer close(storage.release)
+	queue := newTestQueue(storage, Options{Size: 10, Workers: 1, BatchSize: 1, Ack: AckEnqueue}, nil)
+	require.NoError(t, queue.Set(context.Background(), record(1), models.Retention{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, queue.Close(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,85 @@
+package instrumentation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// AlertSource reports rules and alerts of the alerting engine.
+type AlertSource interface {
+	Rules() int
+	AlertStates() map[models.AlertState]int
+	EvaluationFailures() uint64
+}
+
+// NotificationSource reports results of webhook notifications.
+type NotificationSource interface {
+	Sent() uint64
+	Failed() uint64
+	Dropped() uint64
+}
+
+// AlertCollector collects number of rules, alerts by state and notifications on scrape.
+type AlertCollector struct {
+	alerts        AlertSource
+	notifications NotificationSource
+
+	rules              *prometheus.Desc
+	states             *prometheus.Desc
+	evaluationFailures *prometheus.Desc
+	notificationsTotal *prometheus.Desc
+}
+
+// NewAlertCollector returns new alert collector.
+func NewAlertCollector(alerts AlertSource, notifications NotificationSource) *AlertCollector {
+	return &AlertCollector{
+		alerts:        alerts,
+		notifications: notifications,
+		rules: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "alert", "rules"),
+			"Number of alert rules of all tenants.",
+			nil, nil,
+		),
+		states: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "alerts"),
+			"Number of alerts by state.",
+			[]string{"state"}, nil,
+		),
+		evaluationFailures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "alert", "evaluation_failures_total"),
+			"Number of rule evaluations that failed to query series.",
+			nil, nil,
+		),
+		notificationsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "alert", "notifications_total"),
+			"Number of webhook notifications by result, sent, failed after retries or dropped on full queue.",
+			[]string{"result"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *AlertCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.rules
+	ch <- c.states
+	ch <- c.evaluationFailures
+	ch <- c.notificationsTotal
+}
+
+// Collect implements prometheus.Collector.
+func (c *AlertCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.rules, prometheus.GaugeValue, float64(c.alerts.Rules()))
+	for state, n := range c.alerts.AlertStates() {
+		ch <- prometheus.MustNewConstMetric(c.states, prometheus.GaugeValue, float64(n), string(state))
+	}
+	ch <- prometheus.MustNewConstMetric(c.evaluationFailures, prometheus.CounterValue,
+		float64(c.alerts.EvaluationFailures()))
+	for result, n := range map[string]uint64{
+		"sent":    c.notifications.Sent(),
+		"failed":  c.notifications.Failed(),
+		"dropped": c.notifications.Dropped(),
+	} {
+		ch <- prometheus.MustNewConstMetric(c.notificationsTotal, prometheus.CounterValue, float64(n), result)
+	}
+}
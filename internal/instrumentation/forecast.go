@@ -0,0 +1,39 @@
+package instrumentation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ForecastSource reports forecasting of created records.
+type ForecastSource interface {
+	ForecastFailures() uint64
+}
+
+// ForecastCollector collects number of records that failed to update forecast on scrape.
+type ForecastCollector struct {
+	source ForecastSource
+
+	failures *prometheus.Desc
+}
+
+// NewForecastCollector returns new forecast collector.
+func NewForecastCollector(source ForecastSource) *ForecastCollector {
+	return &ForecastCollector{
+		source: source,
+		failures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "forecast", "failures_total"),
+			"Number of saved records that failed to update forecast of their series.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *ForecastCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.failures
+}
+
+// Collect implements prometheus.Collector.
+func (c *ForecastCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.failures, prometheus.CounterValue, float64(c.source.ForecastFailures()))
+}
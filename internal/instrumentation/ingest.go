@@ -0,0 +1,62 @@
+package instrumentation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stages of ingest of pending writes.
+const (
+	IngestStageQueue   = "queue"
+	IngestStageWAL     = "wal"
+	IngestStageStorage = "storage"
+)
+
+// PendingSource reports number of writes that are not saved to storage yet.
+type PendingSource interface {
+	PendingWrites() int
+}
+
+// IngestCollector collects number of not saved writes on scrape.
+type IngestCollector struct {
+	capacity int
+	sources  map[string]PendingSource
+
+	queueDepth    *prometheus.Desc
+	queueCapacity *prometheus.Desc
+}
+
+// NewIngestCollector returns new ingest collector of pending writes of sources keyed by stage, e.g. write queue,
+// write-ahead log and storage. Depth is reported by stage, as a write of a queued batch is pending in the queue
+// while storage saves it. Zero capacity means there is no write queue.
+func NewIngestCollector(capacity int, sources map[string]PendingSource) *IngestCollector {
+	return &IngestCollector{
+		capacity: capacity,
+		sources:  sources,
+		queueDepth: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ingest", "queue_depth"),
+			"Number of writes that are not saved to storage yet by stage.",
+			[]string{"stage"}, nil,
+		),
+		queueCapacity: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ingest", "queue_capacity"),
+			"Max number of queued writes.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *IngestCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queueDepth
+	ch <- c.queueCapacity
+}
+
+// Collect implements prometheus.Collector.
+func (c *IngestCollector) Collect(ch chan<- prometheus.Metric) {
+	for stage, source := range c.sources {
+		ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(source.PendingWrites()), stage)
+	}
+	if c.capacity > 0 {
+		ch <- prometheus.MustNewConstMetric(c.queueCapacity, prometheus.GaugeValue, float64(c.capacity))
+	}
+}
@@ -0,0 +1,186 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const namespace = "rrd"
+
+// Error kinds of storage operations.
+const (
+	errorKindNotFound    = "not_found"
+	errorKindConflict    = "conflict"
+	errorKindInvalid     = "invalid_argument"
+	errorKindUnavailable = "unavailable"
+	errorKindCanceled    = "canceled"
+	errorKindInternal    = "internal"
+)
+
+// Metrics contains metrics of the service itself, they are exposed in prometheus text format.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	httpLegacyRequests  *prometheus.CounterVec
+	storageDuration     *prometheus.HistogramVec
+	storageErrors       *prometheus.CounterVec
+	ingestEnqueued      *prometheus.CounterVec
+	ingestBatchSize     prometheus.Histogram
+	ingestBatchDuration prometheus.Histogram
+	ingestFailedWrites  prometheus.Counter
+}
+
+// NewMetrics returns metrics registered in a new registry together with go runtime and process metrics.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of http requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of http requests by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		httpLegacyRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "legacy_requests_total",
+			Help:      "Number of http requests to deprecated routes without version prefix by route and method.",
+		}, []string{"route", "method"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of storage operations.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "errors_total",
+			Help:      "Number of failed storage operations by error kind.",
+		}, []string{"operation", "kind"}),
+		ingestEnqueued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "enqueued_total",
+			Help:      "Number of writes accepted or rejected by write queue.",
+		}, []string{"result"}),
+		ingestBatchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "batch_size",
+			Help:      "Number of writes in batches saved by write queue.",
+			Buckets:   []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+		ingestBatchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "batch_duration_seconds",
+			Help:      "Latency of saving batches by write queue.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}),
+		ingestFailedWrites: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "failed_writes_total",
+			Help:      "Number of queued writes that failed to be saved.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.httpLegacyRequests,
+		m.storageDuration,
+		m.storageErrors,
+		m.ingestEnqueued,
+		m.ingestBatchSize,
+		m.ingestBatchDuration,
+		m.ingestFailedWrites,
+	)
+
+	return m
+}
+
+// ObserveRequest records http request, route is a path template to keep labels cardinality low.
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(route, method, code).Inc()
+	m.httpRequestDuration.WithLabelValues(route, method, code).Observe(duration.Seconds())
+}
+
+// ObserveLegacyRequest records request to a deprecated route, so clients that still use it can be found.
+func (m *Metrics) ObserveLegacyRequest(route, method string) {
+	m.httpLegacyRequests.WithLabelValues(route, method).Inc()
+}
+
+// ObserveStorage records storage operation latency and error if operation failed.
+func (m *Metrics) ObserveStorage(operation string, duration time.Duration, err error) {
+	m.storageDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		m.storageErrors.WithLabelValues(operation, errorKind(err)).Inc()
+	}
+}
+
+// ObserveEnqueue records write accepted or rejected by write queue.
+func (m *Metrics) ObserveEnqueue(accepted bool) {
+	result := "accepted"
+	if !accepted {
+		result = "rejected"
+	}
+	m.ingestEnqueued.WithLabelValues(result).Inc()
+}
+
+// ObserveBatch records size, latency and failed writes of a batch saved by write queue.
+func (m *Metrics) ObserveBatch(size, failed int, duration time.Duration) {
+	m.ingestBatchSize.Observe(float64(size))
+	m.ingestBatchDuration.Observe(duration.Seconds())
+	m.ingestFailedWrites.Add(float64(failed))
+}
+
+// Register registers additional collectors, e.g. series collector.
+func (m *Metrics) Register(collector prometheus.Collector) error {
+	return m.registry.Register(collector)
+}
+
+// Handler returns http handler serving metrics in prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return errorKindNotFound
+	case errors.Is(err, models.ErrConflict):
+		return errorKindConflict
+	case errors.Is(err, models.ErrInvalidArgument):
+		return errorKindInvalid
+	case errors.Is(err, models.ErrUnavailable):
+		return errorKindUnavailable
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return errorKindCanceled
+	default:
+		return errorKindInternal
+	}
+}
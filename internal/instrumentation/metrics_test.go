@@ -0,0 +1,263 @@
+package instrumentation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type seriesSourceMock struct {
+	err error
+}
+
+func (mock seriesSourceMock) ListSeries(_ context.Context) ([]models.Series, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	return []models.Series{
+		{Name: models.DefaultSeriesName},
+		{Name: "cpu", Retention: models.Retention{MaxPoints: 10}},
+	}, nil
+}
+
+func (mock seriesSourceMock) RetentionStats() map[string]models.SeriesRetentionStats {
+	return map[string]models.SeriesRetentionStats{
+		"cpu": {Points: 10, Expired: 2, Evicted: 3},
+	}
+}
+
+func (mock seriesSourceMock) Capacity(retention models.Retention) uint64 {
+	if retention.MaxPoints > 0 {
+		return retention.MaxPoints
+	}
+	return 1000
+}
+
+type pendingSourceMock int
+
+func (mock pendingSourceMock) PendingWrites() int {
+	return int(mock)
+}
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/internal/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	m := NewMetrics()
+	m.ObserveRequest("/series/{name}", http.MethodGet, http.StatusOK, 10*time.Millisecond)
+	m.ObserveRequest("/series/{name}", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	m.ObserveLegacyRequest("/metrics", http.MethodPut)
+	m.ObserveStorage("set", time.Millisecond, nil)
+	m.ObserveStorage("set", time.Millisecond, fmt.Errorf("failed to put: %w", models.ErrUnavailable))
+	m.ObserveStorage("get_series", time.Millisecond, fmt.Errorf("failed to get: %w", models.ErrNotFound))
+	m.ObserveStorage("get_by_range", time.Millisecond, context.DeadlineExceeded)
+	m.ObserveEnqueue(true)
+	m.ObserveEnqueue(true)
+	m.ObserveEnqueue(false)
+	m.ObserveBatch(2, 1, time.Millisecond)
+
+	body := scrape(t, m)
+
+	for _, line := range []string{
+		`rrd_http_requests_total{method="GET",route="/series/{name}",status="200"} 2`,
+		`rrd_http_request_duration_seconds_count{method="GET",route="/series/{name}",status="200"} 2`,
+		`rrd_http_legacy_requests_total{method="PUT",route="/metrics"} 1`,
+		`rrd_storage_operation_duration_seconds_count{operation="set"} 2`,
+		`rrd_storage_errors_total{kind="unavailable",operation="set"} 1`,
+		`rrd_storage_errors_total{kind="not_found",operation="get_series"} 1`,
+		`rrd_storage_errors_total{kind="canceled",operation="get_by_range"} 1`,
+		`rrd_ingest_enqueued_total{result="accepted"} 2`,
+		`rrd_ingest_enqueued_total{result="rejected"} 1`,
+		`rrd_ingest_batch_size_sum 2`,
+		`rrd_ingest_batch_duration_seconds_count 1`,
+		`rrd_ingest_failed_writes_total 1`,
+		`go_goroutines`,
+	} {
+		require.Contains(t, body, line)
+	}
+}
+
+func TestIngestCollector(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		collector *IngestCollector
+		contains  []string
+		missing   []string
+	}{
+		{
+			NewIngestCollector(100, map[string]PendingSource{
+				IngestStageQueue:   pendingSourceMock(4),
+				IngestStageStorage: pendingSourceMock(3),
+			}),
+			[]string{
+				`rrd_ingest_queue_depth{stage="queue"} 4`,
+				`rrd_ingest_queue_depth{stage="storage"} 3`,
+				`rrd_ingest_queue_capacity 100`,
+			},
+			nil,
+		},
+		// Capacity is not reported without write queue.
+		{
+			NewIngestCollector(0, map[string]PendingSource{IngestStageStorage: pendingSourceMock(4)}),
+			[]string{`rrd_ingest_queue_depth{stage="storage"} 4`},
+			[]string{`rrd_ingest_queue_capacity`},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			m := NewMetrics()
+			require.NoError(t, m.Register(tc.collector))
+
+			body := scrape(t, m)
+			for _, line := range tc.contains {
+				require.Contains(t, body, line)
+			}
+			for _, line := range tc.missing {
+				require.NotContains(t, body, line)
+			}
+		})
+	}
+}
+
+func TestSeriesCollector(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		source   seriesSourceMock
+		contains []string
+		missing  []string
+	}{
+		{
+			seriesSourceMock{},
+			[]string{
+				`rrd_series_points{series="cpu"} 10`,
+				`rrd_series_evicted_points_total{series="cpu"} 3`,
+				`rrd_series_expired_points_total{series="cpu"} 2`,
+				`rrd_series_capacity{series="cpu"} 10`,
+				`rrd_series_capacity{series="default"} 1000`,
+			},
+			nil,
+		},
+		// Counters are still reported when series can't be listed.
+		{
+			seriesSourceMock{err: models.ErrUnavailable},
+			[]string{
+				`rrd_series_points{series="cpu"} 10`,
+			},
+			[]string{`rrd_series_capacity`},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			m := NewMetrics()
+			require.NoError(t, m.Register(NewSeriesCollector(tc.source, slog.New(slog.NewJSONHandler(os.Stdout, nil)))))
+
+			body := scrape(t, m)
+			for _, line := range tc.contains {
+				require.Contains(t, body, line)
+			}
+			for _, line := range tc.missing {
+				require.NotContains(t, body, line)
+			}
+		})
+	}
+}
+
+type streamSourceMock struct{}
+
+func (mock streamSourceMock) Subscribers() int {
+	return 3
+}
+
+func (mock streamSourceMock) SlowDisconnects() uint64 {
+	return 2
+}
+
+func TestStreamCollector(t *testing.T) {
+	t.Parallel()
+	m := NewMetrics()
+	require.NoError(t, m.Register(NewStreamCollector(streamSourceMock{})))
+
+	body := scrape(t, m)
+	require.Contains(t, body, `rrd_stream_subscribers 3`)
+	require.Contains(t, body, `rrd_stream_slow_disconnects_total 2`)
+}
+
+type forecastSourceMock struct{}
+
+func (mock forecastSourceMock) ForecastFailures() uint64 {
+	return 7
+}
+
+func TestForecastCollector(t *testing.T) {
+	t.Parallel()
+	m := NewMetrics()
+	require.NoError(t, m.Register(NewForecastCollector(forecastSourceMock{})))
+
+	require.Contains(t, scrape(t, m), `rrd_forecast_failures_total 7`)
+}
+
+type alertSourceMock struct{}
+
+func (mock alertSourceMock) Rules() int {
+	return 2
+}
+
+func (mock alertSourceMock) AlertStates() map[models.AlertState]int {
+	return map[models.AlertState]int{
+		models.AlertStatePending:  1,
+		models.AlertStateFiring:   3,
+		models.AlertStateResolved: 0,
+	}
+}
+
+func (mock alertSourceMock) EvaluationFailures() uint64 {
+	return 4
+}
+
+func (mock alertSourceMock) Sent() uint64 {
+	return 5
+}
+
+func (mock alertSourceMock) Failed() uint64 {
+	return 1
+}
+
+func (mock alertSourceMock) Dropped() uint64 {
+	return 0
+}
+
+func TestAlertCollector(t *testing.T) {
+	t.Parallel()
+	m := NewMetrics()
+	require.NoError(t, m.Register(NewAlertCollector(alertSourceMock{}, alertSourceMock{})))
+
+	body := scrape(t, m)
+	require.Contains(t, body, `rrd_alert_rules 2`)
+	require.Contains(t, body, `rrd_alerts{state="firing"} 3`)
+	require.Contains(t, body, `rrd_alerts{state="resolved"} 0`)
+	require.Contains(t, body, `rrd_alert_evaluation_failures_total 4`)
+	require.Contains(t, body, `rrd_alert_notifications_total{result="sent"} 5`)
+	require.Contains(t, body, `rrd_alert_notifications_total{result="dropped"} 0`)
+}
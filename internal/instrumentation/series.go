@@ -0,0 +1,90 @@
+package instrumentation
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// scrapeTimeout limits listing of series on scrape.
+const scrapeTimeout = 5 * time.Second
+
+type seriesSource interface {
+	ListSeries(ctx context.Context) ([]models.Series, error)
+	RetentionStats() map[string]models.SeriesRetentionStats
+	Capacity(retention models.Retention) uint64
+}
+
+// SeriesCollector collects counters and capacity of series on scrape.
+type SeriesCollector struct {
+	source seriesSource
+	logger *slog.Logger
+
+	points   *prometheus.Desc
+	capacity *prometheus.Desc
+	evicted  *prometheus.Desc
+	expired  *prometheus.Desc
+}
+
+// NewSeriesCollector returns new series collector.
+func NewSeriesCollector(source seriesSource, logger *slog.Logger) *SeriesCollector {
+	return &SeriesCollector{
+		source: source,
+		logger: logger,
+		points: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "series", "points"),
+			"Current value of the series counter.",
+			[]string{"series"}, nil,
+		),
+		capacity: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "series", "capacity"),
+			"Max number of points of the series.",
+			[]string{"series"}, nil,
+		),
+		evicted: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "series", "evicted_points_total"),
+			"Number of points deleted because of max points since start.",
+			[]string{"series"}, nil,
+		),
+		expired: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "series", "expired_points_total"),
+			"Number of points deleted because of max age since start.",
+			[]string{"series"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *SeriesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.points
+	ch <- c.capacity
+	ch <- c.evicted
+	ch <- c.expired
+}
+
+// Collect implements prometheus.Collector. Counters are known only for series
+// that were written or swept since start, so points are reported for them only.
+func (c *SeriesCollector) Collect(ch chan<- prometheus.Metric) {
+	for series, stats := range c.source.RetentionStats() {
+		ch <- prometheus.MustNewConstMetric(c.points, prometheus.GaugeValue, float64(stats.Points), series)
+		ch <- prometheus.MustNewConstMetric(c.evicted, prometheus.CounterValue, float64(stats.Evicted), series)
+		ch <- prometheus.MustNewConstMetric(c.expired, prometheus.CounterValue, float64(stats.Expired), series)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+	series, err := c.source.ListSeries(ctx)
+	if err != nil {
+		// Other metrics are still useful, so the error is only logged.
+		c.logger.Error("failed to list series for metrics", slog.Any("error", err))
+		return
+	}
+	for _, one := range series {
+		ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue,
+			float64(c.source.Capacity(one.Retention)), one.Name)
+	}
+}
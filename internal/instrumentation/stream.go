@@ -0,0 +1,48 @@
+package instrumentation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StreamSource reports subscriptions of record streams.
+type StreamSource interface {
+	Subscribers() int
+	SlowDisconnects() uint64
+}
+
+// StreamCollector collects number of stream subscribers and slow subscribers disconnected on scrape.
+type StreamCollector struct {
+	source StreamSource
+
+	subscribers     *prometheus.Desc
+	slowDisconnects *prometheus.Desc
+}
+
+// NewStreamCollector returns new stream collector.
+func NewStreamCollector(source StreamSource) *StreamCollector {
+	return &StreamCollector{
+		source: source,
+		subscribers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "stream", "subscribers"),
+			"Number of open subscriptions to record streams.",
+			nil, nil,
+		),
+		slowDisconnects: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "stream", "slow_disconnects_total"),
+			"Number of subscribers disconnected because they didn't read records fast enough.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *StreamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.subscribers
+	ch <- c.slowDisconnects
+}
+
+// Collect implements prometheus.Collector.
+func (c *StreamCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.subscribers, prometheus.GaugeValue, float64(c.source.Subscribers()))
+	ch <- prometheus.MustNewConstMetric(c.slowDisconnects, prometheus.CounterValue, float64(c.source.SlowDisconnects()))
+}
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// ContextHandler adds request id and trace context to records, so logs of a request can be correlated.
+// Records must be logged with context, e.g. with slog.Logger.ErrorContext.
+type ContextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler returns handler that wraps next handler.
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: next}
+}
+
+// Handle adds attributes from context to the record.
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := models.RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns handler with attributes, that keeps adding attributes from context.
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewContextHandler(h.Handler.WithAttrs(attrs))
+}
+
+// WithGroup returns handler with group, that keeps adding attributes from context.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return NewContextHandler(h.Handler.WithGroup(name))
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func TestContextHandler(t *testing.T) {
+	t.Parallel()
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	testCases := []struct {
+		ctx   context.Context
+		group bool
+		want  map[string]any
+	}{
+		{models.WithRequestID(context.Background(), "abc"), false, map[string]any{"request_id": "abc", "series": "cpu"}},
+		{context.Background(), false, map[string]any{"series": "cpu"}},
+		{trace.ContextWithSpanContext(context.Background(), spanContext), false, map[string]any{
+			"series": "cpu", "trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "span_id": "00f067aa0ba902b7",
+		}},
+		// Request id is added to the group like other attributes of the record.
+		{models.WithRequestID(context.Background(), "abc"), true, map[string]any{
+			"series": "cpu", "http": map[string]any{"request_id": "abc"},
+		}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			logger := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))).With(slog.String("series", "cpu"))
+			if tc.group {
+				logger = logger.WithGroup("http")
+			}
+			logger.InfoContext(tc.ctx, "request")
+
+			var got map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
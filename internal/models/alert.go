@@ -0,0 +1,229 @@
+package models
+
+import "math"
+
+const (
+	maxAlertRuleNameLength = 128
+	maxAlertCommentLength  = 1024
+)
+
+// MaxAlertDuration is a max window and for of rules in seconds, a window longer than max age of points
+// would not find more points.
+const MaxAlertDuration = MaxRetentionAge
+
+// AlertAggregation reduces values of points in the window of a rule to one value.
+type AlertAggregation string
+
+// Supported aggregations, values that are not numbers are only counted.
+const (
+	AggregationAvg   AlertAggregation = "avg"
+	AggregationMin   AlertAggregation = "min"
+	AggregationMax   AlertAggregation = "max"
+	AggregationSum   AlertAggregation = "sum"
+	AggregationCount AlertAggregation = "count"
+	AggregationLast  AlertAggregation = "last"
+)
+
+// AlertComparison compares aggregated value with threshold of a rule.
+type AlertComparison string
+
+// Supported comparisons.
+const (
+	ComparisonGreater        AlertComparison = "gt"
+	ComparisonGreaterOrEqual AlertComparison = "gte"
+	ComparisonLess           AlertComparison = "lt"
+	ComparisonLessOrEqual    AlertComparison = "lte"
+	ComparisonEqual          AlertComparison = "eq"
+	ComparisonNotEqual       AlertComparison = "ne"
+)
+
+// Compare reports whether value matches threshold, it is false for unknown comparison.
+func (c AlertComparison) Compare(value, threshold float64) bool {
+	switch c {
+	case ComparisonGreater:
+		return value > threshold
+	case ComparisonGreaterOrEqual:
+		return value >= threshold
+	case ComparisonLess:
+		return value < threshold
+	case ComparisonLessOrEqual:
+		return value <= threshold
+	case ComparisonEqual:
+		return value == threshold
+	case ComparisonNotEqual:
+		return value != threshold
+	default:
+		return false
+	}
+}
+
+// AlertRuleSource tells where a rule is defined.
+type AlertRuleSource string
+
+// Sources of rules, rules of config file can't be changed by API.
+const (
+	AlertRuleSourceAPI    AlertRuleSource = "api"
+	AlertRuleSourceConfig AlertRuleSource = "config"
+)
+
+// AlertSelector selects series of the tenant that are evaluated by a rule.
+type AlertSelector struct {
+	// Series is a name of one series, it can't be set together with labels.
+	Series string `json:"series,omitempty"`
+	// Labels select all series with these labels.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// AlertRule fires an alert for each selected series when aggregation of its points in the window matches
+// the threshold for the duration.
+type AlertRule struct {
+	Name        string           `json:"name"`
+	Selector    AlertSelector    `json:"selector"`
+	Aggregation AlertAggregation `json:"aggregation"`
+	// Window is a duration of the evaluated range in seconds, the range ends at evaluation time.
+	Window     int64           `json:"window"`
+	Comparison AlertComparison `json:"comparison"`
+	Threshold  float64         `json:"threshold"`
+	// For is a duration in seconds the condition must hold before alert fires, 0 fires on the first match.
+	For         int64           `json:"for"`
+	Description string          `json:"description,omitempty"`
+	Source      AlertRuleSource `json:"source"`
+	CreatedAt   int64           `json:"created_at"`
+	UpdatedAt   int64           `json:"updated_at"`
+}
+
+// Validate checks that rule definition is correct.
+func (r *AlertRule) Validate() error {
+	var validationErr ValidationError
+
+	switch {
+	case r.Name == "":
+		validationErr.Add("name", "must be set")
+	case len(r.Name) > maxAlertRuleNameLength:
+		validationErr.Add("name", "must not be longer than %d", maxAlertRuleNameLength)
+	case !seriesNameRegexp.MatchString(r.Name):
+		validationErr.Add("name", "must match %s", seriesNameRegexp)
+	}
+
+	switch {
+	case r.Selector.Series == "" && len(r.Selector.Labels) == 0:
+		validationErr.Add("selector", "series or labels must be set")
+	case r.Selector.Series != "" && len(r.Selector.Labels) > 0:
+		validationErr.Add("selector", "series and labels can't be set together")
+	case r.Selector.Series != "" && !seriesNameRegexp.MatchString(r.Selector.Series):
+		validationErr.Add("selector.series", "must match %s", seriesNameRegexp)
+	}
+	validateLabels(&validationErr, r.Selector.Labels)
+
+	switch r.Aggregation {
+	case AggregationAvg, AggregationMin, AggregationMax, AggregationSum, AggregationCount, AggregationLast:
+	default:
+		validationErr.Add("aggregation", "unknown aggregation %q", r.Aggregation)
+	}
+	switch r.Comparison {
+	case ComparisonGreater, ComparisonGreaterOrEqual, ComparisonLess, ComparisonLessOrEqual, ComparisonEqual,
+		ComparisonNotEqual:
+	default:
+		validationErr.Add("comparison", "unknown comparison %q", r.Comparison)
+	}
+
+	if r.Window <= 0 || r.Window > MaxAlertDuration {
+		validationErr.Add("window", "must be in range [1, %d]", MaxAlertDuration)
+	}
+	if r.For < 0 || r.For > MaxAlertDuration {
+		validationErr.Add("for", "must be in range [0, %d]", MaxAlertDuration)
+	}
+	if math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0) {
+		validationErr.Add("threshold", "must be finite")
+	}
+	if len(r.Description) > maxDescriptionLength {
+		validationErr.Add("description", "must not be longer than %d", maxDescriptionLength)
+	}
+
+	if len(validationErr.Fields) > 0 {
+		return &validationErr
+	}
+	return nil
+}
+
+// AlertState is a state of an alert, series that don't match rule condition have no alert.
+type AlertState string
+
+// States of alerts.
+const (
+	// AlertStatePending means that condition matches for less than `for` duration of the rule.
+	AlertStatePending AlertState = "pending"
+	// AlertStateFiring means that condition matches for `for` duration of the rule.
+	AlertStateFiring AlertState = "firing"
+	// AlertStateResolved means that condition of a firing alert doesn't match anymore,
+	// it is kept until the next evaluation.
+	AlertStateResolved AlertState = "resolved"
+)
+
+// Alert is a state of a rule for one series.
+type Alert struct {
+	Rule   string     `json:"rule"`
+	Series string     `json:"series"`
+	State  AlertState `json:"state"`
+	// Value is an aggregated value of the last evaluation that matched the condition.
+	Value float64 `json:"value"`
+	// ActiveAt, FiredAt and ResolvedAt are times of state changes in unix microseconds.
+	ActiveAt   int64 `json:"active_at"`
+	FiredAt    int64 `json:"fired_at,omitempty"`
+	ResolvedAt int64 `json:"resolved_at,omitempty"`
+	// Silenced is true if notifications of the alert are suppressed by a silence.
+	Silenced bool `json:"silenced"`
+}
+
+// AlertNotification is sent to webhooks when an alert fires or is resolved.
+type AlertNotification struct {
+	// Status is firing or resolved.
+	Status AlertState `json:"status"`
+	Tenant string     `json:"tenant"`
+	Alert  Alert      `json:"alert"`
+	Rule   AlertRule  `json:"rule"`
+	// SentAt is a time of the notification in unix microseconds, it is the same for all retries.
+	SentAt int64 `json:"sent_at"`
+}
+
+// Silence suppresses notifications of matching alerts from start to end.
+type Silence struct {
+	ID string `json:"id"`
+	// Rule and Series select silenced alerts, empty field matches any rule or series.
+	Rule   string `json:"rule,omitempty"`
+	Series string `json:"series,omitempty"`
+	// StartsAt and EndsAt are times in unix microseconds, StartsAt is creation time if it is not set.
+	StartsAt  int64  `json:"starts_at"`
+	EndsAt    int64  `json:"ends_at"`
+	Comment   string `json:"comment,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Validate checks that silence is correct.
+func (s *Silence) Validate() error {
+	var validationErr ValidationError
+	if s.StartsAt < 0 {
+		validationErr.Add("starts_at", "must not be negative")
+	}
+	if s.EndsAt <= s.StartsAt {
+		validationErr.Add("ends_at", "must be after starts_at")
+	}
+	if len(s.Comment) > maxAlertCommentLength {
+		validationErr.Add("comment", "must not be longer than %d", maxAlertCommentLength)
+	}
+	if len(validationErr.Fields) > 0 {
+		return &validationErr
+	}
+	return nil
+}
+
+// Active reports whether silence is in effect at the time in unix microseconds.
+func (s *Silence) Active(at int64) bool {
+	return s.StartsAt <= at && at < s.EndsAt
+}
+
+// Matches reports whether silence suppresses alert of the rule and series.
+func (s *Silence) Matches(rule, series string) bool {
+	return (s.Rule == "" || s.Rule == rule) && (s.Series == "" || s.Series == series)
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Sentinel errors that are shared by all layers, so transport can map them to responses.
+// Errors must be wrapped with `%w`, so they can be checked with errors.Is.
+var (
+	// ErrInvalidArgument means that request params or data are invalid.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrNotFound means that requested entity doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means that entity already exists or was modified concurrently.
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable means that storage is temporary unavailable and request can be retried.
+	ErrUnavailable = errors.New("unavailable")
+	// ErrOverloaded means that service can't accept more requests now, client should slow down.
+	ErrOverloaded = errors.New("overloaded")
+	// ErrUnauthenticated means that request has no valid credentials.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden means that identity of the request has no access to the resource.
+	ErrForbidden = errors.New("forbidden")
+	// ErrQuotaExceeded means that tenant reached a limit of its resources.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrRateLimited means that client exceeded allowed request rate and should retry later.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrTooLarge means that request body, batch or queried range exceeds allowed size.
+	ErrTooLarge = errors.New("too large")
+	// ErrUnsupportedMediaType means that request body has unsupported content type or encoding.
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrNotAcceptable means that response can't be encoded in any format accepted by the client.
+	ErrNotAcceptable = errors.New("not acceptable")
+)
+
+// RetryError tells when a rejected request can be retried.
+type RetryError struct {
+	Err   error
+	After time.Duration
+}
+
+// NewRetryError returns error that can be retried after the duration.
+func NewRetryError(after time.Duration, err error) *RetryError {
+	return &RetryError{Err: err, After: after}
+}
+
+// Error implements error interface.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", e.Err, e.After.Round(time.Millisecond))
+}
+
+// Unwrap allows to match wrapped error.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// DetailError has a message that can be shown to clients, unlike messages of errors wrapping it.
+type DetailError struct {
+	Err    error
+	Detail string
+}
+
+// NewDetailError returns error matching err, usually a sentinel error, with a message for clients.
+func NewDetailError(err error, format string, args ...any) *DetailError {
+	return &DetailError{Err: err, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Error implements error interface.
+func (e *DetailError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, e.Detail)
+}
+
+// Unwrap allows to match wrapped error.
+func (e *DetailError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError describes validation error of a single field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError contains field level validation errors. It matches ErrInvalidArgument.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewValidationError returns validation error for one field.
+func NewValidationError(field, format string, args ...any) *ValidationError {
+	return &ValidationError{
+		Fields: []FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}},
+	}
+}
+
+// Add appends field error.
+func (e *ValidationError) Add(field, format string, args ...any) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Error implements error interface.
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", f.Field, f.Message))
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidArgument, strings.Join(messages, "; "))
+}
+
+// Unwrap allows to match validation error with ErrInvalidArgument.
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidArgument
+}
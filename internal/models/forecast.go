@@ -0,0 +1,113 @@
+package models
+
+import "strings"
+
+// Suffixes of series that keep results of Holt-Winters forecasting of a series, like HWPREDICT, DEVPREDICT
+// and FAILURES archives of rrdtool. Series with these suffixes are written only by forecasting.
+const (
+	PredictionSeriesSuffix = ":hwpredict"
+	DeviationSeriesSuffix  = ":devpredict"
+	FailuresSeriesSuffix   = ":failures"
+	// ForecastLabel is a label of series with results of forecasting, its value is the suffix without colon.
+	// Label filters without it don't match these series.
+	ForecastLabel = "forecast"
+)
+
+// Defaults and limits of Holt-Winters params, defaults of failures are the same as in rrdtool.
+const (
+	DefaultDeviationScale   = 2
+	DefaultFailureThreshold = 7
+	DefaultFailureWindow    = 9
+
+	maxSeasonLength  = 10000
+	maxFailureWindow = 28
+
+	// HoltWintersTrainingSeasons is a number of last seasons of stored points that train a model that is not
+	// in memory.
+	HoltWintersTrainingSeasons = 2
+	// MaxHoltWintersStep is a max step of forecasted series in seconds, so training seasons in microseconds are
+	// within int64.
+	MaxHoltWintersStep = MaxSeriesStep / maxSeasonLength / HoltWintersTrainingSeasons
+)
+
+// HoltWinters configures additive Holt-Winters forecasting of a series. Each point is predicted from level,
+// trend and seasonal coefficient of its step in the season, and its deviation from the prediction is smoothed
+// per step of the season. Failure is reported when enough points of the window deviate too much.
+type HoltWinters struct {
+	// Alpha, Beta and Gamma are smoothing factors of level, trend, and seasonal coefficients and deviations.
+	Alpha float64 `json:"alpha"`
+	Beta  float64 `json:"beta"`
+	Gamma float64 `json:"gamma"`
+	// SeasonLength is a number of series steps in a season, e.g. 1440 for daily season of minute steps.
+	SeasonLength int `json:"season_length"`
+	// DeviationScale is a number of deviations a point may differ from prediction without violation.
+	DeviationScale float64 `json:"deviation_scale,omitempty"`
+	// FailureThreshold is a min number of violations in the last FailureWindow points that is a failure.
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+	FailureWindow    int `json:"failure_window,omitempty"`
+}
+
+// WithDefaults returns params with defaults for params that are not set.
+func (hw HoltWinters) WithDefaults() HoltWinters {
+	if hw.DeviationScale == 0 {
+		hw.DeviationScale = DefaultDeviationScale
+	}
+	if hw.FailureWindow == 0 {
+		hw.FailureWindow = DefaultFailureWindow
+	}
+	if hw.FailureThreshold == 0 {
+		hw.FailureThreshold = min(DefaultFailureThreshold, hw.FailureWindow)
+	}
+	return hw
+}
+
+// validate adds errors of params to validation error, params must have defaults.
+func (hw *HoltWinters) validate(validationErr *ValidationError) {
+	for _, factor := range []struct {
+		name  string
+		value float64
+	}{{"alpha", hw.Alpha}, {"beta", hw.Beta}, {"gamma", hw.Gamma}} {
+		if !(factor.value > 0 && factor.value < 1) {
+			validationErr.Add("holt_winters."+factor.name, "must be in range (0, 1)")
+		}
+	}
+	if hw.SeasonLength < 2 || hw.SeasonLength > maxSeasonLength {
+		validationErr.Add("holt_winters.season_length", "must be in range [2, %d]", maxSeasonLength)
+	}
+	if !(hw.DeviationScale > 0 && hw.DeviationScale <= 10) {
+		validationErr.Add("holt_winters.deviation_scale", "must be in range (0, 10]")
+	}
+	if hw.FailureWindow < 1 || hw.FailureWindow > maxFailureWindow {
+		validationErr.Add("holt_winters.failure_window", "must be in range [1, %d]", maxFailureWindow)
+	}
+	if hw.FailureThreshold < 1 || hw.FailureThreshold > hw.FailureWindow {
+		validationErr.Add("holt_winters.failure_threshold", "must be in range [1, failure_window]")
+	}
+}
+
+// ForecastSeriesNames returns names of series with predictions, deviations and failures of a series.
+func ForecastSeriesNames(series string) (prediction, deviation, failures string) {
+	return series + PredictionSeriesSuffix, series + DeviationSeriesSuffix, series + FailuresSeriesSuffix
+}
+
+// IsForecastSeries reports whether series keeps results of forecasting of another series.
+func IsForecastSeries(series string) bool {
+	return strings.HasSuffix(series, PredictionSeriesSuffix) || strings.HasSuffix(series, DeviationSeriesSuffix) ||
+		strings.HasSuffix(series, FailuresSeriesSuffix)
+}
+
+// ForecastPoint is a predicted value of a future step with bounds of expected deviation.
+type ForecastPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+}
+
+// Forecast contains predictions of the next steps of a series.
+type Forecast struct {
+	Series string `json:"series"`
+	// Step is an interval between points in seconds.
+	Step   int64           `json:"step"`
+	Points []ForecastPoint `json:"points"`
+}
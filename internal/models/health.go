@@ -0,0 +1,57 @@
+package models
+
+// CheckStatus is a result of a dependency check.
+type CheckStatus string
+
+const (
+	CheckStatusOK     CheckStatus = "ok"
+	CheckStatusFailed CheckStatus = "failed"
+)
+
+// Check contains result of a dependency check.
+type Check struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	// Error is a reason of a failed check.
+	Error string `json:"error,omitempty"`
+}
+
+// Readiness contains results of all dependency checks, service is ready when all checks are ok.
+type Readiness struct {
+	Status CheckStatus `json:"status"`
+	Checks []Check     `json:"checks"`
+}
+
+// NewReadiness returns readiness with status calculated from checks.
+func NewReadiness(checks []Check) Readiness {
+	status := CheckStatusOK
+	for _, check := range checks {
+		if check.Status != CheckStatusOK {
+			status = CheckStatusFailed
+		}
+	}
+	return Readiness{
+		Status: status,
+		Checks: checks,
+	}
+}
+
+// Status contains detailed service status.
+type Status struct {
+	Version string `json:"version"`
+	// StartedAt is a time of service start in unix microseconds.
+	StartedAt int64 `json:"started_at"`
+	// Uptime is a number of seconds since service start.
+	Uptime int64 `json:"uptime_seconds"`
+	Readiness
+	Series []SeriesStatus `json:"series"`
+}
+
+// SeriesStatus contains current counter value and capacity of a series.
+type SeriesStatus struct {
+	Name string `json:"name"`
+	// Points is a current value of the series counter.
+	Points uint64 `json:"points"`
+	// Capacity is a max number of points of the series.
+	Capacity uint64 `json:"capacity"`
+}
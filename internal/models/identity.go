@@ -0,0 +1,72 @@
+package models
+
+import "context"
+
+// Scope is a permission granted to an identity.
+type Scope string
+
+// Supported scopes.
+const (
+	// ScopeRead allows to read points and series of the tenant.
+	ScopeRead Scope = "read"
+	// ScopeWrite allows to write points and manage series of the tenant.
+	ScopeWrite Scope = "write"
+	// ScopeAdmin allows everything, including service endpoints and access to other tenants.
+	ScopeAdmin Scope = "admin"
+)
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Subject string
+	Tenant  string
+	Scopes  []Scope
+}
+
+// HasScope reports whether identity is granted the scope, admin scope grants all scopes.
+func (i Identity) HasScope(scope Scope) bool {
+	for _, granted := range i.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that identity has valid tenant and known scopes.
+func (i Identity) Validate() error {
+	if i.Subject == "" {
+		return NewDetailError(ErrInvalidArgument, "subject must be set")
+	}
+	if err := ValidateTenant(i.Tenant); err != nil {
+		return err
+	}
+	for _, scope := range i.Scopes {
+		switch scope {
+		case ScopeRead, ScopeWrite, ScopeAdmin:
+		default:
+			return NewDetailError(ErrInvalidArgument, "unknown scope %q, must be one of read, write, admin", scope)
+		}
+	}
+	return nil
+}
+
+type identityKey struct{}
+
+// WithIdentity returns context with identity of the request.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns identity of the request, it is missing if authentication is disabled.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+// TenantFromContext returns tenant of the request, it is default tenant if authentication is disabled.
+func TenantFromContext(ctx context.Context) string {
+	if identity, ok := IdentityFromContext(ctx); ok && identity.Tenant != "" {
+		return identity.Tenant
+	}
+	return DefaultTenant
+}
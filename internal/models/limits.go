@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RateLimit is a token bucket limit, Burst requests can be made at once, zero rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimit parses limit in format `rate[:burst]`, burst defaults to rate.
+func ParseRateLimit(s string) (RateLimit, error) {
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	var (
+		limit RateLimit
+		err   error
+	)
+	if limit.Rate, err = strconv.ParseFloat(rate, 64); err != nil || limit.Rate < 0 {
+		return RateLimit{}, fmt.Errorf("%w: rate limit %q must be in format rate[:burst] with non-negative rate",
+			ErrInvalidArgument, s)
+	}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 0 {
+			return RateLimit{}, fmt.Errorf("%w: burst of rate limit %q must be non-negative integer",
+				ErrInvalidArgument, s)
+		}
+	}
+	return limit, nil
+}
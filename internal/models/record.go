@@ -1,10 +1,74 @@
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
 // Record represents a record with timestamp and metric value.
 type Record struct {
-	// It would be great to add Source fields, so we can save metrics from different services/sources.
-	// But in requirements, there is no such field.
-	// Source string `json:"string"`
-	Timestamp   int64 `json:"timestamp"`
-	MetricValue any   `json:"metric_value"`
+	// Series is a name of registered series, empty series means DefaultSeriesName.
+	Series    string `json:"series,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+	// MetricValue is validated and normalized by ValueSpec of a series before saving.
+	MetricValue any `json:"metric_value"`
+}
+
+// Write is a record with retention of its series, it is saved by storage in a batch.
+type Write struct {
+	Record    Record
+	Retention Retention
+}
+
+// RecordPage is a page of records of a range.
+type RecordPage struct {
+	Records []Record `json:"records"`
+	// NextPageToken must be passed as `page_token` to get the next page, it is empty on the last page.
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// ExprQuery selects values of an expression over series from start to end.
+type ExprQuery struct {
+	Expr       string
+	Start, End int64
+	// Step is an interval between values in seconds, zero step is chosen by series and range.
+	Step int64
+	// After is a timestamp of the last value of the previous page, values up to it are skipped.
+	After int64
+	// Limit is a maximum number of values, zero means all values of the range.
+	Limit int
+}
+
+// record is used to avoid recursion in json methods.
+type record Record
+
+// MarshalJSON encodes non-finite float values as strings.
+func (r Record) MarshalJSON() ([]byte, error) {
+	out := record(r)
+	out.MetricValue = JSONValue(r.MetricValue)
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes numbers in metric value as json.Number, so big integers don't lose precision.
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		record
+		MetricValue json.RawMessage `json:"metric_value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = Record(raw.record)
+	r.MetricValue = nil
+
+	if len(raw.MetricValue) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw.MetricValue))
+	decoder.UseNumber()
+	if err := decoder.Decode(&r.MetricValue); err != nil {
+		return fmt.Errorf("failed to decode metric_value: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,16 @@
+package models
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID returns context with id of the request, it is added to logs of the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns id of the request, it is empty outside of requests.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,25 @@
+package models
+
+// RetentionStats contains statistics of points deleted by retention policies since start.
+type RetentionStats struct {
+	// Sweeps is a number of completed retention sweeps.
+	Sweeps uint64 `json:"sweeps"`
+	// LastSweepAt is a time of the last sweep start in unix microseconds.
+	LastSweepAt int64 `json:"last_sweep_at"`
+	// LastSweepDuration is a duration of the last sweep in milliseconds.
+	LastSweepDuration int64 `json:"last_sweep_duration_ms"`
+	// Expired is a total number of points deleted because of max age.
+	Expired uint64 `json:"expired"`
+	// Evicted is a total number of points deleted because of max points.
+	Evicted uint64 `json:"evicted"`
+	// Series contains statistics per series.
+	Series map[string]SeriesRetentionStats `json:"series"`
+}
+
+// SeriesRetentionStats contains retention statistics of a series.
+type SeriesRetentionStats struct {
+	// Points is a current number of points.
+	Points  uint64 `json:"points"`
+	Expired uint64 `json:"expired"`
+	Evicted uint64 `json:"evicted"`
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// DefaultSeriesName is a name of series for records without series, it keeps legacy API working.
+const DefaultSeriesName = "default"
+
+// MaxRetentionAge is a max age of points in seconds, so max age is within time.Duration.
+const MaxRetentionAge = math.MaxInt64 / int64(time.Second)
+
+// MaxSeriesStep is a max step of series in seconds, so step is within time.Duration and microseconds.
+const MaxSeriesStep = math.MaxInt64 / int64(time.Second)
+
+const (
+	maxSeriesNameLength  = 128
+	maxLabelsCount       = 32
+	maxDescriptionLength = 1024
+)
+
+var (
+	seriesNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.:-]*$`)
+	labelNameRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// ValidateSeriesName checks that a series name of a request matches names of series. Names with tenant separator
+// would name series of other tenants in storage.
+func ValidateSeriesName(name string) error {
+	if !seriesNameRegexp.MatchString(name) {
+		return NewValidationError("series", "must match %s", seriesNameRegexp)
+	}
+	return nil
+}
+
+// DataSourceType defines how values of a series are interpreted, like data source types in rrdtool.
+type DataSourceType string
+
+// Supported data source types.
+const (
+	// DataSourceGauge is a value that can go up and down, e.g. temperature.
+	DataSourceGauge DataSourceType = "GAUGE"
+	// DataSourceCounter is a monotonically increasing counter, e.g. bytes sent.
+	DataSourceCounter DataSourceType = "COUNTER"
+	// DataSourceDerive is a counter that can decrease.
+	DataSourceDerive DataSourceType = "DERIVE"
+	// DataSourceAbsolute is a counter that is reset on each read.
+	DataSourceAbsolute DataSourceType = "ABSOLUTE"
+)
+
+// Series is a definition of time series.
+type Series struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Unit        string            `json:"unit,omitempty"`
+	Description string            `json:"description,omitempty"`
+	DataSource  DataSourceType    `json:"data_source"`
+	// Step is an expected interval between points in seconds.
+	Step      int64     `json:"step"`
+	Retention Retention `json:"retention"`
+	ValueType ValueSpec `json:"value_type"`
+	// HoltWinters enables forecasting of the series, it is optional.
+	HoltWinters *HoltWinters `json:"holt_winters,omitempty"`
+	// Version is incremented on each update, it is used for optimistic locking.
+	Version   uint32 `json:"version"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Retention defines how many points are kept for a series.
+type Retention struct {
+	// MaxPoints is a maximum number of points, 0 means storage default capacity.
+	MaxPoints uint64 `json:"max_points,omitempty"`
+	// MaxAge is a maximum age of points in seconds, 0 means points don't expire.
+	MaxAge int64 `json:"max_age,omitempty"`
+}
+
+// Validate checks that series definition is correct.
+func (s *Series) Validate() error {
+	var validationErr ValidationError
+
+	switch {
+	case s.Name == "":
+		validationErr.Add("name", "must be set")
+	case len(s.Name) > maxSeriesNameLength:
+		validationErr.Add("name", "must not be longer than %d", maxSeriesNameLength)
+	case !seriesNameRegexp.MatchString(s.Name):
+		validationErr.Add("name", "must match %s", seriesNameRegexp)
+	case IsForecastSeries(s.Name):
+		validationErr.Add("name", "suffixes %s, %s and %s are reserved for forecasting",
+			PredictionSeriesSuffix, DeviationSeriesSuffix, FailuresSeriesSuffix)
+	}
+
+	validateLabels(&validationErr, s.Labels)
+
+	if len(s.Description) > maxDescriptionLength {
+		validationErr.Add("description", "must not be longer than %d", maxDescriptionLength)
+	}
+
+	switch s.DataSource {
+	case DataSourceGauge:
+	case DataSourceCounter, DataSourceDerive, DataSourceAbsolute:
+		if s.ValueType.Type != ValueTypeFloat && s.ValueType.Type != ValueTypeInt {
+			validationErr.Add("data_source", "%s requires numeric value type", s.DataSource)
+		}
+	default:
+		validationErr.Add("data_source", "unknown data source type %q", s.DataSource)
+	}
+
+	if s.Step < 0 {
+		validationErr.Add("step", "must not be negative")
+	} else if s.Step > MaxSeriesStep {
+		validationErr.Add("step", "must be at most %d", MaxSeriesStep)
+	}
+
+	if s.Retention.MaxAge < 0 {
+		validationErr.Add("retention.max_age", "must not be negative")
+	} else if s.Retention.MaxAge > MaxRetentionAge {
+		validationErr.Add("retention.max_age", "must be at most %d", MaxRetentionAge)
+	}
+
+	if s.HoltWinters != nil {
+		s.HoltWinters.validate(&validationErr)
+		if s.Step <= 0 {
+			validationErr.Add("step", "must be positive for holt_winters")
+		} else if s.Step > MaxHoltWintersStep {
+			validationErr.Add("step", "must be at most %d for holt_winters", MaxHoltWintersStep)
+		}
+		if s.DataSource != DataSourceGauge ||
+			(s.ValueType.Type != ValueTypeFloat && s.ValueType.Type != ValueTypeInt) {
+			validationErr.Add("holt_winters", "requires GAUGE data source and numeric value type")
+		}
+	}
+
+	var specErr *ValidationError
+	if err := s.ValueType.Validate(); err != nil && errors.As(err, &specErr) {
+		validationErr.Fields = append(validationErr.Fields, specErr.Fields...)
+	}
+
+	if len(validationErr.Fields) > 0 {
+		return &validationErr
+	}
+	return nil
+}
+
+// SeriesUpdate contains mutable fields of series, nil fields are not changed.
+// Name, data source, step and value type can't be changed, as they define how stored points are interpreted.
+type SeriesUpdate struct {
+	Labels      *map[string]string `json:"labels,omitempty"`
+	Unit        *string            `json:"unit,omitempty"`
+	Description *string            `json:"description,omitempty"`
+	Retention   *Retention         `json:"retention,omitempty"`
+	// HoltWinters replaces forecasting params, forecasting is disabled if season length is 0.
+	HoltWinters *HoltWinters `json:"holt_winters,omitempty"`
+	// Version must be equal to the current series version if set.
+	Version *uint32 `json:"version,omitempty"`
+}
+
+// Apply applies update to the series.
+func (u *SeriesUpdate) Apply(s *Series) {
+	if u.Labels != nil {
+		s.Labels = *u.Labels
+	}
+	if u.Unit != nil {
+		s.Unit = *u.Unit
+	}
+	if u.Description != nil {
+		s.Description = *u.Description
+	}
+	if u.Retention != nil {
+		s.Retention = *u.Retention
+	}
+	if u.HoltWinters != nil {
+		s.HoltWinters = nil
+		if u.HoltWinters.SeasonLength != 0 {
+			hw := u.HoltWinters.WithDefaults()
+			s.HoltWinters = &hw
+		}
+	}
+}
+
+// SeriesFilter selects series for listing.
+type SeriesFilter struct {
+	// Labels must all match series labels.
+	Labels map[string]string
+	// After is a name of the last series of the previous page.
+	After string
+	// Limit is a maximum number of series on page.
+	Limit int
+}
+
+// Matches checks if series matches filter labels. Series with results of forecasting have labels of the
+// forecasted series, so they are matched only by filters with ForecastLabel.
+func (f *SeriesFilter) Matches(s *Series) bool {
+	if _, ok := f.Labels[ForecastLabel]; !ok && len(f.Labels) > 0 && IsForecastSeries(s.Name) {
+		return false
+	}
+	for k, v := range f.Labels {
+		if value, ok := s.Labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SeriesPage is a page of series list.
+type SeriesPage struct {
+	Series []Series `json:"series"`
+	// NextPageToken must be passed as `page_token` to get the next page, it is empty on the last page.
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// SeriesStats contains statistics of stored points of a series.
+type SeriesStats struct {
+	Points uint64 `json:"points"`
+	// Oldest and Newest are timestamps of the oldest and newest points, they are 0 if series is empty.
+	Oldest int64 `json:"oldest"`
+	Newest int64 `json:"newest"`
+}
+
+// SeriesDescription is a series definition with statistics.
+type SeriesDescription struct {
+	Series
+	Stats SeriesStats `json:"stats"`
+}
+
+func validateLabels(validationErr *ValidationError, labels map[string]string) {
+	if len(labels) > maxLabelsCount {
+		validationErr.Add("labels", "must not contain more than %d labels", maxLabelsCount)
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if !labelNameRegexp.MatchString(k) {
+			validationErr.Add("labels."+k, "label name must match %s", labelNameRegexp)
+		}
+	}
+}
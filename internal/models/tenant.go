@@ -0,0 +1,71 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultTenant owns series created before tenants were introduced, its series names are not qualified.
+const DefaultTenant = "default"
+
+// TenantSeparator separates tenant and series name in storage, it is not allowed in series names.
+const TenantSeparator = "/"
+
+// maxTenantLength keeps aerospike set names of a tenant within 63 characters.
+const maxTenantLength = 32
+
+var tenantRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateTenant checks tenant name.
+func ValidateTenant(tenant string) error {
+	if len(tenant) == 0 || len(tenant) > maxTenantLength || !tenantRegexp.MatchString(tenant) {
+		return NewDetailError(ErrInvalidArgument, "tenant %q must match %s and be at most %d characters",
+			tenant, tenantRegexp, maxTenantLength)
+	}
+	return nil
+}
+
+// TenantSeriesName returns full name of a series of the tenant, series of default tenant are not qualified.
+func TenantSeriesName(tenant, series string) string {
+	if tenant == DefaultTenant {
+		return series
+	}
+	return tenant + TenantSeparator + series
+}
+
+// SplitSeriesName returns tenant and series name of the full series name.
+func SplitSeriesName(fullName string) (tenant, series string) {
+	tenant, series, ok := strings.Cut(fullName, TenantSeparator)
+	if !ok {
+		return DefaultTenant, fullName
+	}
+	return tenant, series
+}
+
+// TenantLimits are quotas of a tenant, zero value means no limit.
+type TenantLimits struct {
+	// MaxSeries is a max number of series of the tenant.
+	MaxSeries int `json:"max_series,omitempty"`
+	// Capacity is a default and max number of points of a series of the tenant.
+	Capacity uint64 `json:"capacity,omitempty"`
+	// IngestRate is a max number of written points per second, IngestBurst points can be written at once.
+	IngestRate  float64 `json:"ingest_rate,omitempty"`
+	IngestBurst int     `json:"ingest_burst,omitempty"`
+}
+
+// IngestUsage contains number of points accepted and rejected by ingest rate quota since start.
+type IngestUsage struct {
+	Accepted uint64 `json:"accepted"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// TenantUsage contains resources used by a tenant.
+type TenantUsage struct {
+	Tenant string `json:"tenant"`
+	Series int    `json:"series"`
+	// Points is a number of stored points, Capacity is a max number of points of all series.
+	Points   uint64       `json:"points"`
+	Capacity uint64       `json:"capacity"`
+	Ingest   IngestUsage  `json:"ingest"`
+	Limits   TenantLimits `json:"limits"`
+}
@@ -0,0 +1,317 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ValueType is a type of metric values of a series.
+type ValueType string
+
+// Supported value types.
+const (
+	ValueTypeFloat     ValueType = "float64"
+	ValueTypeInt       ValueType = "int64"
+	ValueTypeBool      ValueType = "bool"
+	ValueTypeString    ValueType = "string"
+	ValueTypeHistogram ValueType = "histogram"
+)
+
+// String representations of non-finite floats in JSON, as JSON has no such numbers.
+const (
+	floatNaN     = "NaN"
+	floatPosInf  = "+Inf"
+	floatNegInf  = "-Inf"
+	floatInfAlt  = "Inf"
+	floatInfAlt2 = "Infinity"
+)
+
+// ValueSpec describes allowed metric values of a series.
+type ValueSpec struct {
+	Type ValueType `json:"type"`
+	// Enum restricts allowed values of string type. Empty enum allows any string.
+	Enum []string `json:"enum,omitempty"`
+}
+
+// Validate checks that value spec is correct.
+func (s ValueSpec) Validate() error {
+	switch s.Type {
+	case ValueTypeFloat, ValueTypeInt, ValueTypeBool, ValueTypeHistogram:
+		if len(s.Enum) > 0 {
+			return NewValidationError("value_type.enum", "is allowed only for %s type", ValueTypeString)
+		}
+	case ValueTypeString:
+	default:
+		return NewValidationError("value_type.type", "unknown value type %q", s.Type)
+	}
+	return nil
+}
+
+// Normalize validates value and converts it to canonical go type of the spec:
+// float64, int64, bool, string or Histogram.
+// Values decoded from json may be json.Number, non-finite floats are accepted as "NaN", "+Inf", "-Inf" strings.
+func (s ValueSpec) Normalize(v any) (any, error) {
+	if v == nil {
+		return nil, NewValidationError("metric_value", "must be set")
+	}
+
+	var (
+		result any
+		err    error
+	)
+	switch s.Type {
+	case ValueTypeFloat:
+		result, err = toFloat(v)
+	case ValueTypeInt:
+		result, err = toInt(v)
+	case ValueTypeBool:
+		b, ok := v.(bool)
+		if !ok {
+			err = fmt.Errorf("expected bool, got %T", v)
+		}
+		result = b
+	case ValueTypeString:
+		str, ok := v.(string)
+		if !ok {
+			err = fmt.Errorf("expected string, got %T", v)
+		} else if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			err = fmt.Errorf("value %q is not one of [%s]", str, strings.Join(s.Enum, ", "))
+		}
+		result = str
+	case ValueTypeHistogram:
+		result, err = toHistogram(v)
+	default:
+		err = fmt.Errorf("unknown value type %q", s.Type)
+	}
+	if err != nil {
+		return nil, NewValidationError("metric_value", "invalid %s value: %s", s.Type, err)
+	}
+
+	return result, nil
+}
+
+// ValueTypeOf returns value type of normalized value.
+func ValueTypeOf(v any) (ValueType, bool) {
+	switch v.(type) {
+	case float64:
+		return ValueTypeFloat, true
+	case int64:
+		return ValueTypeInt, true
+	case bool:
+		return ValueTypeBool, true
+	case string:
+		return ValueTypeString, true
+	case Histogram:
+		return ValueTypeHistogram, true
+	default:
+		return "", false
+	}
+}
+
+// Histogram is a distribution of observed values with cumulative buckets, like in Prometheus.
+type Histogram struct {
+	// Buckets are sorted by upper bound, counts are cumulative.
+	Buckets []Bucket `json:"buckets"`
+	// Count is a total number of observations.
+	Count uint64 `json:"count"`
+	// Sum is a sum of all observations.
+	Sum float64 `json:"sum"`
+}
+
+// Bucket is a histogram bucket with count of observations less or equal to upper bound.
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// Validate checks that buckets are sorted and counts are cumulative.
+func (h Histogram) Validate() error {
+	var prev *Bucket
+	for i := range h.Buckets {
+		b := h.Buckets[i]
+		if math.IsNaN(b.UpperBound) {
+			return fmt.Errorf("bucket %d upper bound is NaN", i)
+		}
+		if prev != nil && b.UpperBound <= prev.UpperBound {
+			return fmt.Errorf("bucket %d upper bound must be greater than previous", i)
+		}
+		if prev != nil && b.Count < prev.Count {
+			return fmt.Errorf("bucket %d count must be cumulative", i)
+		}
+		prev = &h.Buckets[i]
+	}
+	if prev != nil && h.Count < prev.Count {
+		return fmt.Errorf("count must not be less than bucket counts")
+	}
+	return nil
+}
+
+// MarshalJSON encodes non-finite floats as strings.
+func (h Histogram) MarshalJSON() ([]byte, error) {
+	type bucket struct {
+		UpperBound any    `json:"le"`
+		Count      uint64 `json:"count"`
+	}
+	buckets := make([]bucket, 0, len(h.Buckets))
+	for _, b := range h.Buckets {
+		buckets = append(buckets, bucket{UpperBound: jsonFloat(b.UpperBound), Count: b.Count})
+	}
+	return json.Marshal(struct {
+		Buckets []bucket `json:"buckets"`
+		Count   uint64   `json:"count"`
+		Sum     any      `json:"sum"`
+	}{
+		Buckets: buckets,
+		Count:   h.Count,
+		Sum:     jsonFloat(h.Sum),
+	})
+}
+
+// JSONValue converts normalized value to a form that can be encoded to json.
+func JSONValue(v any) any {
+	if f, ok := v.(float64); ok {
+		return jsonFloat(f)
+	}
+	return v
+}
+
+func jsonFloat(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return floatNaN
+	case math.IsInf(f, 1):
+		return floatPosInf
+	case math.IsInf(f, -1):
+		return floatNegInf
+	default:
+		return f
+	}
+}
+
+func toFloat(v any) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case float32:
+		return float64(val), nil
+	case int:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case uint64:
+		return float64(val), nil
+	case json.Number:
+		return val.Float64()
+	case string:
+		switch val {
+		case floatNaN:
+			return math.NaN(), nil
+		case floatPosInf, floatInfAlt, floatInfAlt2:
+			return math.Inf(1), nil
+		case floatNegInf, "-" + floatInfAlt2:
+			return math.Inf(-1), nil
+		}
+		return 0, fmt.Errorf("expected number, got string %q", val)
+	default:
+		return 0, fmt.Errorf("expected number, got %T", v)
+	}
+}
+
+func toInt(v any) (int64, error) {
+	switch val := v.(type) {
+	case int64:
+		return val, nil
+	case int:
+		return int64(val), nil
+	case uint64:
+		if val > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", val)
+		}
+		return int64(val), nil
+	case json.Number:
+		i, err := strconv.ParseInt(string(val), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected integer, got %s", val)
+		}
+		return i, nil
+	case float64:
+		if val != math.Trunc(val) || val < math.MinInt64 || val >= math.MaxInt64 {
+			return 0, fmt.Errorf("expected integer, got %v", val)
+		}
+		return int64(val), nil
+	default:
+		return 0, fmt.Errorf("expected integer, got %T", v)
+	}
+}
+
+func toUint(v any) (uint64, error) {
+	i, err := toInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("expected non negative integer, got %d", i)
+	}
+	return uint64(i), nil
+}
+
+func toHistogram(v any) (Histogram, error) {
+	var h Histogram
+	switch val := v.(type) {
+	case Histogram:
+		h = val
+	case map[string]any:
+		var err error
+		if h, err = histogramFromMap(val); err != nil {
+			return Histogram{}, err
+		}
+	default:
+		return Histogram{}, fmt.Errorf("expected histogram object, got %T", v)
+	}
+
+	if err := h.Validate(); err != nil {
+		return Histogram{}, err
+	}
+	return h, nil
+}
+
+func histogramFromMap(m map[string]any) (Histogram, error) {
+	var (
+		h   Histogram
+		err error
+	)
+	if h.Count, err = toUint(m["count"]); err != nil {
+		return Histogram{}, fmt.Errorf("count: %w", err)
+	}
+	if m["sum"] != nil {
+		if h.Sum, err = toFloat(m["sum"]); err != nil {
+			return Histogram{}, fmt.Errorf("sum: %w", err)
+		}
+	}
+
+	buckets, ok := m["buckets"].([]any)
+	if !ok && m["buckets"] != nil {
+		return Histogram{}, fmt.Errorf("buckets: expected array, got %T", m["buckets"])
+	}
+	h.Buckets = make([]Bucket, 0, len(buckets))
+	for i, raw := range buckets {
+		bm, ok := raw.(map[string]any)
+		if !ok {
+			return Histogram{}, fmt.Errorf("bucket %d: expected object, got %T", i, raw)
+		}
+		var b Bucket
+		if b.UpperBound, err = toFloat(bm["le"]); err != nil {
+			return Histogram{}, fmt.Errorf("bucket %d le: %w", i, err)
+		}
+		if b.Count, err = toUint(bm["count"]); err != nil {
+			return Histogram{}, fmt.Errorf("bucket %d count: %w", i, err)
+		}
+		h.Buckets = append(h.Buckets, b)
+	}
+
+	return h, nil
+}
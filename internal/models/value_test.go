@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueSpec_Validate(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		spec ValueSpec
+		err  error
+	}{
+		{ValueSpec{Type: ValueTypeFloat}, nil},
+		{ValueSpec{Type: ValueTypeInt}, nil},
+		{ValueSpec{Type: ValueTypeBool}, nil},
+		{ValueSpec{Type: ValueTypeString, Enum: []string{"up", "down"}}, nil},
+		{ValueSpec{Type: ValueTypeHistogram}, nil},
+		{ValueSpec{Type: "complex"}, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeInt, Enum: []string{"1"}}, ErrInvalidArgument},
+	}
+
+	for i, tt := range testCases {
+		require.ErrorIs(t, tt.spec.Validate(), tt.err, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestValueSpec_Normalize(t *testing.T) {
+	t.Parallel()
+	histogram := Histogram{
+		Buckets: []Bucket{{UpperBound: 0.5, Count: 1}, {UpperBound: math.Inf(1), Count: 3}},
+		Count:   3,
+		Sum:     4.5,
+	}
+
+	testCases := []struct {
+		spec   ValueSpec
+		value  any
+		result any
+		err    error
+	}{
+		{ValueSpec{Type: ValueTypeFloat}, json.Number("3.5"), 3.5, nil},
+		{ValueSpec{Type: ValueTypeFloat}, json.Number("3"), 3.0, nil},
+		{ValueSpec{Type: ValueTypeFloat}, 3.5, 3.5, nil},
+		{ValueSpec{Type: ValueTypeFloat}, "+Inf", math.Inf(1), nil},
+		{ValueSpec{Type: ValueTypeFloat}, "-Inf", math.Inf(-1), nil},
+		{ValueSpec{Type: ValueTypeFloat}, "3.5", nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeFloat}, true, nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeFloat}, nil, nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeInt}, json.Number("9007199254740993"), int64(9007199254740993), nil},
+		{ValueSpec{Type: ValueTypeInt}, 42.0, int64(42), nil},
+		{ValueSpec{Type: ValueTypeInt}, json.Number("3.5"), nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeInt}, 3.5, nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeBool}, true, true, nil},
+		{ValueSpec{Type: ValueTypeBool}, json.Number("1"), nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeString}, "up", "up", nil},
+		{ValueSpec{Type: ValueTypeString, Enum: []string{"up", "down"}}, "down", "down", nil},
+		{ValueSpec{Type: ValueTypeString, Enum: []string{"up", "down"}}, "unknown", nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeString}, json.Number("1"), nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeHistogram}, histogram, histogram, nil},
+		{ValueSpec{Type: ValueTypeHistogram}, map[string]any{
+			"buckets": []any{
+				map[string]any{"le": json.Number("0.5"), "count": json.Number("1")},
+				map[string]any{"le": "+Inf", "count": json.Number("3")},
+			},
+			"count": json.Number("3"),
+			"sum":   json.Number("4.5"),
+		}, histogram, nil},
+		{ValueSpec{Type: ValueTypeHistogram}, map[string]any{
+			"buckets": []any{
+				map[string]any{"le": json.Number("1"), "count": json.Number("3")},
+				map[string]any{"le": json.Number("0.5"), "count": json.Number("3")},
+			},
+			"count": json.Number("3"),
+		}, nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeHistogram}, map[string]any{
+			"buckets": []any{
+				map[string]any{"le": json.Number("0.5"), "count": json.Number("3")},
+				map[string]any{"le": json.Number("1"), "count": json.Number("2")},
+			},
+			"count": json.Number("3"),
+		}, nil, ErrInvalidArgument},
+		{ValueSpec{Type: ValueTypeHistogram}, json.Number("1"), nil, ErrInvalidArgument},
+	}
+
+	for i, tt := range testCases {
+		result, err := tt.spec.Normalize(tt.value)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+		require.Equal(t, tt.result, result, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestValueSpec_NormalizeNaN(t *testing.T) {
+	t.Parallel()
+	result, err := ValueSpec{Type: ValueTypeFloat}.Normalize("NaN")
+	require.NoError(t, err)
+	f, ok := result.(float64)
+	require.True(t, ok)
+	require.True(t, math.IsNaN(f))
+}
+
+func TestRecord_JSON(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		record Record
+		json   string
+	}{
+		{Record{Timestamp: 1, MetricValue: 3.5}, `{"timestamp":1,"metric_value":3.5}`},
+		{Record{Timestamp: 1, MetricValue: int64(9007199254740993)}, `{"timestamp":1,"metric_value":9007199254740993}`},
+		{Record{Timestamp: 1, MetricValue: math.NaN()}, `{"timestamp":1,"metric_value":"NaN"}`},
+		{Record{Timestamp: 1, MetricValue: math.Inf(-1)}, `{"timestamp":1,"metric_value":"-Inf"}`},
+		{Record{Timestamp: 1, MetricValue: Histogram{
+			Buckets: []Bucket{{UpperBound: math.Inf(1), Count: 2}},
+			Count:   2,
+			Sum:     3,
+		}}, `{"timestamp":1,"metric_value":{"buckets":[{"le":"+Inf","count":2}],"count":2,"sum":3}}`},
+	}
+
+	for i, tt := range testCases {
+		body, err := json.Marshal(tt.record)
+		require.NoError(t, err, fmt.Sprintf("case %d", i))
+		require.JSONEq(t, tt.json, string(body), fmt.Sprintf("case %d", i))
+	}
+
+	var record Record
+	require.NoError(t, json.Unmarshal([]byte(`{"timestamp":1,"metric_value":9007199254740993}`), &record))
+	require.Equal(t, Record{Timestamp: 1, MetricValue: json.Number("9007199254740993")}, record)
+}
@@ -0,0 +1,111 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned when circuit breaker is open and calls fail fast.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// BreakerState is a state of circuit breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets all calls through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects all calls until cooldown is over.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe call through, its result closes or opens the breaker.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// Breaker is a circuit breaker that opens after a number of consecutive failures.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	// now is replaced in tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker returns new circuit breaker, zero threshold disables it.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow returns ErrOpen if call must not be made. Each allowed call must be followed by Success or Failure.
+func (b *Breaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		// Only one probe is allowed at a time.
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success records successful call and closes the breaker.
+func (b *Breaker) Success() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records failed call, breaker opens when failures reach threshold or probe fails.
+func (b *Breaker) Failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
+
+// State returns current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,54 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	breaker := NewBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	// Breaker opens after threshold consecutive failures only.
+	require.NoError(t, breaker.Allow())
+	breaker.Failure()
+	require.NoError(t, breaker.Allow())
+	breaker.Success()
+	require.NoError(t, breaker.Allow())
+	breaker.Failure()
+	require.Equal(t, BreakerClosed, breaker.State())
+	require.NoError(t, breaker.Allow())
+	breaker.Failure()
+	require.Equal(t, BreakerOpen, breaker.State())
+	require.ErrorIs(t, breaker.Allow(), ErrOpen)
+
+	// Single probe is allowed after cooldown, failed probe opens breaker again.
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.Allow())
+	require.Equal(t, BreakerHalfOpen, breaker.State())
+	require.ErrorIs(t, breaker.Allow(), ErrOpen)
+	breaker.Failure()
+	require.Equal(t, BreakerOpen, breaker.State())
+	require.ErrorIs(t, breaker.Allow(), ErrOpen)
+
+	// Successful probe closes breaker.
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.Allow())
+	breaker.Success()
+	require.Equal(t, BreakerClosed, breaker.State())
+	require.NoError(t, breaker.Allow())
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	t.Parallel()
+	breaker := NewBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, breaker.Allow())
+		breaker.Failure()
+	}
+	require.Equal(t, BreakerClosed, breaker.State())
+}
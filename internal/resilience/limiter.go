@@ -0,0 +1,88 @@
+package resilience
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket rate limiter, bucket of burst tokens is refilled with rate tokens per second.
+type Limiter struct {
+	// now is replaced in tests.
+	now func() time.Time
+
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns new rate limiter with full bucket, zero rate disables it.
+// Burst less than one second of rate is increased to it.
+func NewLimiter(rate float64, burst int) *Limiter {
+	l := &Limiter{
+		now: time.Now,
+	}
+	l.SetLimit(rate, burst)
+	l.tokens = l.burst
+	return l
+}
+
+// SetLimit changes rate and burst, tokens above the new burst are dropped.
+func (l *Limiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = max(float64(burst), math.Ceil(rate), 1)
+	l.tokens = min(l.tokens, l.burst)
+}
+
+// Allow takes n tokens if they are available. Otherwise it returns false and time after which they will be,
+// time is zero if n is greater than burst, as such request is never allowed.
+func (l *Limiter) Allow(n int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	allowed, wait := l.available(n)
+	if allowed && l.rate > 0 {
+		l.tokens -= float64(n)
+	}
+	return allowed, wait
+}
+
+// Available reports whether n tokens are available like Allow, but it doesn't take them.
+func (l *Limiter) Available(n int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.available(n)
+}
+
+// available refills the bucket and checks that it has n tokens, it must be called with lock.
+func (l *Limiter) available(n int) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	need := float64(n)
+	switch {
+	case l.tokens >= need:
+		return true, 0
+	case need > l.burst:
+		return false, 0
+	default:
+		return false, time.Duration((need - l.tokens) / l.rate * float64(time.Second))
+	}
+}
+
+// Return puts back n tokens taken by Allow for a request that was not done, bucket is not filled above burst.
+func (l *Limiter) Return(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+float64(n))
+}
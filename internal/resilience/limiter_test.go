@@ -0,0 +1,104 @@
+package resilience
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+	type step struct {
+		elapsed time.Duration
+		n       int
+		allowed bool
+		wait    time.Duration
+	}
+	testCases := []struct {
+		rate  float64
+		burst int
+		steps []step
+	}{
+		// Full bucket allows burst at once, then it is refilled with rate.
+		{10, 20, []step{
+			{0, 20, true, 0},
+			{0, 1, false, 100 * time.Millisecond},
+			{500 * time.Millisecond, 5, true, 0},
+			{0, 1, false, 100 * time.Millisecond},
+			{10 * time.Second, 21, false, 0},
+			{0, 20, true, 0},
+		}},
+		// Burst is at least one second of rate.
+		{5, 0, []step{
+			{0, 5, true, 0},
+			{0, 1, false, 200 * time.Millisecond},
+		}},
+		// Zero rate disables limiter.
+		{0, 0, []step{
+			{0, 1000, true, 0},
+		}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			now := time.Unix(1700000000, 0)
+			l := NewLimiter(tc.rate, tc.burst)
+			l.now = func() time.Time { return now }
+
+			for j, s := range tc.steps {
+				now = now.Add(s.elapsed)
+				allowed, wait := l.Allow(s.n)
+				require.Equal(t, s.allowed, allowed, fmt.Sprintf("step %d", j))
+				require.InDelta(t, s.wait, wait, float64(time.Millisecond), fmt.Sprintf("step %d", j))
+			}
+		})
+	}
+}
+
+func TestLimiter_SetLimit(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(100, 100)
+	l.SetLimit(1, 2)
+	allowed, _ := l.Allow(2)
+	require.True(t, allowed)
+	allowed, wait := l.Allow(1)
+	require.False(t, allowed)
+	require.InDelta(t, time.Second, wait, float64(10*time.Millisecond))
+}
+
+func TestLimiter_Return(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return time.Unix(1700000000, 0) }
+	allowed, _ := l.Allow(2)
+	require.True(t, allowed)
+	l.Return(1)
+	allowed, _ = l.Allow(1)
+	require.True(t, allowed)
+	allowed, _ = l.Allow(1)
+	require.False(t, allowed)
+
+	// Bucket is not filled above burst.
+	l.Return(5)
+	allowed, _ = l.Allow(3)
+	require.False(t, allowed)
+}
+
+func TestLimiter_Available(t *testing.T) {
+	t.Parallel()
+	l := NewLimiter(1, 1)
+	l.now = func() time.Time { return time.Unix(1700000000, 0) }
+	// Available doesn't take tokens.
+	for range 2 {
+		allowed, _ := l.Available(1)
+		require.True(t, allowed)
+	}
+	allowed, _ := l.Allow(1)
+	require.True(t, allowed)
+	allowed, wait := l.Available(1)
+	require.False(t, allowed)
+	require.Equal(t, time.Second, wait)
+}
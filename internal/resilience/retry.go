@@ -0,0 +1,51 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Retry calls a function again with exponential backoff while it returns retryable errors.
+type Retry struct {
+	// Attempts is a total number of calls, values less than 2 disable retries.
+	Attempts int
+	// Backoff is a delay before the first retry, it is doubled for every next retry.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Do calls fn until it succeeds, returns not retryable error, attempts are exhausted or context is done.
+// It returns the last error of fn.
+func (r Retry) Do(ctx context.Context, retryable func(err error) bool, fn func() error) error {
+	backoff := r.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.Attempts || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(jitter(backoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w, retry canceled: %w", err, ctx.Err())
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+			backoff = r.MaxBackoff
+		}
+	}
+}
+
+// jitter returns random delay in [d/2, d], so clients don't retry at the same time.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
@@ -0,0 +1,72 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errPermanent = errors.New("permanent")
+)
+
+func isTransient(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func TestRetry_Do(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		attempts int
+		errs     []error
+		calls    int
+		err      error
+	}{
+		{attempts: 3, errs: []error{nil}, calls: 1},
+		{attempts: 3, errs: []error{errTransient, nil}, calls: 2},
+		{attempts: 3, errs: []error{errTransient, errTransient, errTransient}, calls: 3, err: errTransient},
+		{attempts: 3, errs: []error{errPermanent}, calls: 1, err: errPermanent},
+		{attempts: 3, errs: []error{errTransient, errPermanent}, calls: 2, err: errPermanent},
+		{attempts: 1, errs: []error{errTransient}, calls: 1, err: errTransient},
+		{attempts: 0, errs: []error{errTransient}, calls: 1, err: errTransient},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			retry := Retry{Attempts: tc.attempts, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+			calls := 0
+			err := retry.Do(context.Background(), isTransient, func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+			require.Equal(t, tc.calls, calls)
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestRetry_DoCanceled(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	retry := Retry{Attempts: 5, Backoff: time.Hour}
+	calls := 0
+	err := retry.Do(ctx, isTransient, func() error {
+		calls++
+		cancel()
+		return errTransient
+	})
+	require.Equal(t, 1, calls)
+	require.ErrorIs(t, err, errTransient)
+	require.ErrorIs(t, err, context.Canceled)
+}
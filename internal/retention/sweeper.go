@@ -0,0 +1,142 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type storage interface {
+	ListSeries(ctx context.Context) ([]models.Series, error)
+	ExpireOlderThan(ctx context.Context, series string, cutoff int64) (uint64, error)
+	TrimToCapacity(ctx context.Context, series string, retention models.Retention) (uint64, error)
+	RetentionStats() map[string]models.SeriesRetentionStats
+}
+
+// Sweeper periodically deletes points that are out of series retention.
+// Max age is also enforced by aerospike ttl when it is enabled, but sweeper is still needed
+// for namespaces without expiration, for points written before max age was set, and to reconcile counters.
+type Sweeper struct {
+	storage storage
+	// interval is a duration between sweeps, it can be changed on config reload.
+	interval atomic.Int64
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	stats models.RetentionStats
+}
+
+// NewSweeper returns new retention sweeper.
+func NewSweeper(storage storage, interval time.Duration, logger *slog.Logger) *Sweeper {
+	s := &Sweeper{
+		storage: storage,
+		logger:  logger,
+	}
+	s.interval.Store(int64(interval))
+	return s
+}
+
+// SetInterval changes interval between sweeps, it is applied when the current wait is over.
+func (s *Sweeper) SetInterval(interval time.Duration) {
+	s.interval.Store(int64(interval))
+}
+
+// Run sweeps series with interval until context is canceled.
+func (s *Sweeper) Run(ctx context.Context) {
+	timer := time.NewTimer(time.Duration(s.interval.Load()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if err := s.Sweep(ctx); err != nil {
+				s.logger.Error("failed to sweep series", slog.Any("error", err))
+			}
+			timer.Reset(time.Duration(s.interval.Load()))
+		}
+	}
+}
+
+// Sweep applies retention policies to all series once.
+// Errors of a single series are logged, so other series are still processed.
+func (s *Sweeper) Sweep(ctx context.Context) error {
+	started := time.Now()
+
+	series, err := s.storage.ListSeries(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list series: %w", err)
+	}
+
+	var expired, evicted uint64
+	for _, one := range series {
+		if cutoff := expireCutoff(started, one.Retention.MaxAge); cutoff > 0 {
+			deleted, err := s.storage.ExpireOlderThan(ctx, one.Name, cutoff)
+			expired += deleted
+			if err != nil {
+				s.logger.Error("failed to expire series points",
+					slog.String("series", one.Name),
+					slog.Any("error", err),
+				)
+			}
+		}
+
+		deleted, err := s.storage.TrimToCapacity(ctx, one.Name, one.Retention)
+		evicted += deleted
+		if err != nil {
+			s.logger.Error("failed to trim series to capacity",
+				slog.String("series", one.Name),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	duration := time.Since(started)
+
+	s.mu.Lock()
+	s.stats.Sweeps++
+	s.stats.LastSweepAt = started.UnixMicro()
+	s.stats.LastSweepDuration = duration.Milliseconds()
+	s.mu.Unlock()
+
+	s.logger.Info("retention sweep completed",
+		slog.Int("series", len(series)),
+		slog.Uint64("expired", expired),
+		slog.Uint64("evicted", evicted),
+		slog.Duration("duration", duration),
+	)
+
+	return nil
+}
+
+// Stats returns retention statistics since start, including points evicted on the write path.
+func (s *Sweeper) Stats() models.RetentionStats {
+	s.mu.Lock()
+	stats := s.stats
+	s.mu.Unlock()
+
+	stats.Series = s.storage.RetentionStats()
+	for _, one := range stats.Series {
+		stats.Expired += one.Expired
+		stats.Evicted += one.Evicted
+	}
+
+	return stats
+}
+
+// expireCutoff returns timestamp in microseconds before which points are older than max age, it is zero if
+// points don't expire or max age reaches before unix epoch, so no point is old enough.
+func expireCutoff(now time.Time, maxAge int64) int64 {
+	const microsPerSecond = int64(time.Second / time.Microsecond)
+	nowMicros := now.UnixMicro()
+	if maxAge <= 0 || maxAge >= nowMicros/microsPerSecond {
+		return 0
+	}
+	return nowMicros - maxAge*microsPerSecond
+}
@@ -0,0 +1,99 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+var errTest = errors.New("test error")
+
+type storageMock struct {
+	series  []models.Series
+	cutoffs map[string]int64
+	trimmed map[string]models.Retention
+}
+
+func (mock *storageMock) ListSeries(_ context.Context) ([]models.Series, error) {
+	return mock.series, nil
+}
+
+func (mock *storageMock) ExpireOlderThan(_ context.Context, series string, cutoff int64) (uint64, error) {
+	mock.cutoffs[series] = cutoff
+	if series == "broken" {
+		return 0, errTest
+	}
+	return 2, nil
+}
+
+func (mock *storageMock) TrimToCapacity(_ context.Context, series string, retention models.Retention,
+) (uint64, error) {
+	mock.trimmed[series] = retention
+	return 1, nil
+}
+
+func (mock *storageMock) RetentionStats() map[string]models.SeriesRetentionStats {
+	return map[string]models.SeriesRetentionStats{
+		"a": {Points: 1, Expired: 2, Evicted: 1},
+		"b": {Points: 1, Evicted: 1},
+	}
+}
+
+func TestSweeper_Sweep(t *testing.T) {
+	t.Parallel()
+	storage := &storageMock{
+		series: []models.Series{
+			{Name: "a", Retention: models.Retention{MaxAge: 60}},
+			{Name: "b", Retention: models.Retention{MaxPoints: 10}},
+			{Name: "broken", Retention: models.Retention{MaxAge: 60}},
+			// Max age before unix epoch keeps all points, even above the limit of series saved before it.
+			{Name: "huge", Retention: models.Retention{MaxAge: models.MaxRetentionAge}},
+			{Name: "overflow", Retention: models.Retention{MaxAge: 1 << 40}},
+		},
+		cutoffs: make(map[string]int64),
+		trimmed: make(map[string]models.Retention),
+	}
+	sweeper := NewSweeper(storage, time.Minute, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	before := time.Now()
+	require.NoError(t, sweeper.Sweep(context.Background()))
+
+	// Only series with max age after unix epoch are expired.
+	require.Len(t, storage.cutoffs, 2)
+	require.NotContains(t, storage.cutoffs, "huge")
+	require.NotContains(t, storage.cutoffs, "overflow")
+	require.InDelta(t, before.Add(-time.Minute).UnixMicro(), storage.cutoffs["a"], float64(time.Second/time.Microsecond))
+	// All series are trimmed, even if expiration failed.
+	require.Len(t, storage.trimmed, 5)
+	require.Equal(t, models.Retention{MaxPoints: 10}, storage.trimmed["b"])
+
+	stats := sweeper.Stats()
+	require.Equal(t, uint64(1), stats.Sweeps)
+	require.Equal(t, uint64(2), stats.Expired)
+	require.Equal(t, uint64(2), stats.Evicted)
+	require.Len(t, stats.Series, 2)
+	require.GreaterOrEqual(t, stats.LastSweepAt, before.UnixMicro())
+}
+
+func TestSweeper_Run(t *testing.T) {
+	t.Parallel()
+	storage := &storageMock{
+		series:  []models.Series{{Name: "a"}},
+		cutoffs: make(map[string]int64),
+		trimmed: make(map[string]models.Retention),
+	}
+	sweeper := NewSweeper(storage, 10*time.Millisecond, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	sweeper.Run(ctx)
+
+	require.Positive(t, sweeper.Stats().Sweeps)
+}
@@ -0,0 +1,168 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"aerospike.com/rrd/internal/expr"
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/tracing"
+)
+
+const (
+	// defaultEvalStep is a step in seconds of expressions of series without step.
+	defaultEvalStep = 60
+	// maxEvalPoints limits number of evaluated steps, and number of steps before them used by TREND and SHIFT.
+	maxEvalPoints = 10000
+)
+
+// Evaluate returns values of an expression over series of the request tenant from start to end. Values are
+// evaluated at multiples of step seconds, value of a series at a step is an average of its numbers after
+// the previous step up to the step. Zero step means the largest step of the series, it is increased to fit
+// the range into max points. Steps of a page depend only on the range, so only steps of the page and
+// steps before them used by TREND and SHIFT are evaluated. Unknown values are NaN.
+func (s *Service) Evaluate(ctx context.Context, query models.ExprQuery) (_ []models.Record, err error) {
+	ctx, span := tracer.Start(ctx, "Service.Evaluate", trace.WithAttributes(attribute.String("rrd.expr", query.Expr)))
+	defer func() { tracing.End(span, err) }()
+	e, err := expr.Parse(query.Expr)
+	if err != nil {
+		return nil, models.NewValidationError("expr", "%s", err)
+	}
+	start, end, step := query.Start, query.End, query.Step
+	switch {
+	case start <= 0:
+		return nil, models.NewValidationError("start", "is required with expr")
+	case start > end:
+		return nil, models.NewDetailError(models.ErrInvalidArgument, "invalid range [%d, %d]", start, end)
+	case step < 0:
+		return nil, models.NewValidationError("step", "must not be negative")
+	case step > max(end-start, int64(time.Second/time.Microsecond))/int64(time.Second/time.Microsecond):
+		// Step within the range also keeps step in microseconds from overflow.
+		return nil, models.NewValidationError("step", "must not be longer than the range")
+	case query.Limit < 0:
+		return nil, models.NewValidationError("limit", "must not be negative")
+	}
+
+	if step == 0 {
+		if step, err = s.evalStep(ctx, e.Series(), end-start); err != nil {
+			return nil, err
+		}
+	}
+	micros := step * int64(time.Second/time.Microsecond)
+	// Steps are aligned to multiples of step, so pages of a range have the same steps.
+	first := (start + micros - 1) / micros * micros
+	if first > end {
+		return []models.Record{}, nil
+	}
+	if points := (end-first)/micros + 1; points > maxEvalPoints {
+		return nil, models.NewValidationError("step", "range has %d steps, max is %d", points, maxEvalPoints)
+	}
+	if query.After >= first {
+		first = (query.After/micros + 1) * micros
+		if first > end {
+			return []models.Record{}, nil
+		}
+	}
+	points := (end-first)/micros + 1
+	if query.Limit > 0 {
+		points = min(points, int64(query.Limit))
+	}
+	lookback := e.Lookback(step)
+	if lookback > maxEvalPoints {
+		return nil, models.NewValidationError("expr", "uses %d steps before start, max is %d", lookback, maxEvalPoints)
+	}
+	gridStart := first - int64(lookback)*micros
+	gridEnd := first + (points-1)*micros
+	n := lookback + int(points)
+	span.SetAttributes(attribute.Int64("rrd.step", step), attribute.Int("rrd.steps", n))
+
+	values := make(map[string][]float64, len(e.Series()))
+	for _, name := range e.Series() {
+		records, err := s.GetByRange(ctx, name, max(gridStart-micros+1, 0), gridEnd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get series %s: %w", name, err)
+		}
+		values[name] = consolidate(records, gridStart, micros, n)
+	}
+
+	result := e.Eval(values, n, step)
+	records := make([]models.Record, 0, points)
+	for i := lookback; i < n; i++ {
+		records = append(records, models.Record{
+			Timestamp:   gridStart + int64(i)*micros,
+			MetricValue: result[i],
+		})
+	}
+	span.SetAttributes(attribute.Int("rrd.records", len(records)))
+	return records, nil
+}
+
+// evalStep returns the largest step of series, or default step, increased to fit the span into max points.
+func (s *Service) evalStep(ctx context.Context, names []string, span int64) (int64, error) {
+	step := int64(0)
+	for _, name := range names {
+		fullName, err := storageName(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+		series, err := s.getSeries(ctx, fullName)
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			return 0, fmt.Errorf("failed to get series %s: %w", name, err)
+		}
+		// Steps of series saved before steps were limited are limited too, so step in microseconds doesn't
+		// overflow.
+		step = max(step, min(series.Step, models.MaxSeriesStep))
+	}
+	if step == 0 {
+		step = defaultEvalStep
+	}
+	// Span in microseconds is divided into max points, rounded up to seconds.
+	fit := (span/(maxEvalPoints-1) + int64(time.Second/time.Microsecond) - 1) / int64(time.Second/time.Microsecond)
+	return max(step, fit), nil
+}
+
+// consolidate returns averages of numbers of records at n steps from start, a step includes records after
+// the previous step up to the step. Bools are 1 and 0, other values are ignored.
+func consolidate(records []models.Record, start, step int64, n int) []float64 {
+	sums := make([]float64, n)
+	counts := make([]int, n)
+	for _, record := range records {
+		var value float64
+		switch v := record.MetricValue.(type) {
+		case float64:
+			value = v
+		case int64:
+			value = float64(v)
+		case bool:
+			if v {
+				value = 1
+			}
+		default:
+			continue
+		}
+		if record.Timestamp <= start-step {
+			continue
+		}
+		i := (record.Timestamp - start + step - 1) / step
+		if i >= int64(n) || math.IsNaN(value) {
+			continue
+		}
+		sums[i] += value
+		counts[i]++
+	}
+
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.NaN()
+		if counts[i] > 0 {
+			values[i] = sums[i] / float64(counts[i])
+		}
+	}
+	return values
+}
@@ -0,0 +1,118 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// seriesRecordsMock returns records of series by storage name in range.
+type seriesRecordsMock map[string][]models.Record
+
+func (mock seriesRecordsMock) GetByRange(_ context.Context, series string, min, max int64) ([]models.Record, error) {
+	var result []models.Record
+	for _, record := range mock[series] {
+		if record.Timestamp >= min && record.Timestamp <= max {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+func TestService_Evaluate(t *testing.T) {
+	t.Parallel()
+	const minute = int64(60000000)
+	requests := testSeries("requests", nil)
+	requests.Step = 60
+	// Series saved before step was limited, its step in microseconds would overflow.
+	huge := testSeries("huge", nil)
+	huge.Step = 1 << 58
+	srv := newServiceMock(requests, testSeries("errors", nil), testSeries("status", nil), huge)
+	srv.storageGetter = seriesRecordsMock{
+		"requests": {
+			{Timestamp: minute, MetricValue: 100.0},
+			{Timestamp: 2 * minute, MetricValue: 200.0},
+			{Timestamp: 3*minute - 1, MetricValue: 150.0},
+			{Timestamp: 3 * minute, MetricValue: 250.0},
+			{Timestamp: 4 * minute, MetricValue: 0.0},
+		},
+		"errors": {
+			{Timestamp: 2 * minute, MetricValue: int64(10)},
+			{Timestamp: 3 * minute, MetricValue: int64(20)},
+			{Timestamp: 4 * minute, MetricValue: int64(0)},
+		},
+		"status": {
+			{Timestamp: 2 * minute, MetricValue: true},
+			{Timestamp: 3 * minute, MetricValue: "up"},
+		},
+	}
+
+	testCases := []struct {
+		expression string
+		start, end int64
+		step       int64
+		after      int64
+		limit      int
+		values     []float64
+		err        error
+	}{
+		{"errors / requests * 100", minute, 4 * minute, 0, 0, 0, []float64{math.NaN(), 5, 10, math.NaN()}, nil},
+		{"errors,requests,/,100,*", minute + 1, 4 * minute, 60, 0, 0, []float64{5, 10, math.NaN()}, nil},
+		{"requests", 2 * minute, 4 * minute, 120, 0, 0, []float64{150, 400.0 / 3}, nil},
+		{"SHIFT(requests, 60)", 2 * minute, 3 * minute, 0, 0, 0, []float64{100, 200}, nil},
+		{"TREND(requests, 120)", 3 * minute, 3 * minute, 0, 0, 0, []float64{200}, nil},
+		{"UN(status)", minute, 3 * minute, 0, 0, 0, []float64{1, 0, 1}, nil},
+		{"requests", minute + 1, minute + 2, 0, 0, 0, []float64{}, nil},
+		{"errors / requests * 100", minute, 4 * minute, 0, 0, 2, []float64{math.NaN(), 5}, nil},
+		{"errors / requests * 100", minute, 4 * minute, 0, 2 * minute, 2, []float64{10, math.NaN()}, nil},
+		{"SHIFT(requests, 60)", 2 * minute, 4 * minute, 0, 2*minute + 1, 1, []float64{200}, nil},
+		{"requests", minute, 4 * minute, 0, 4 * minute, 2, []float64{}, nil},
+		{"requests", minute, 4 * minute, 0, 0, -1, nil, models.ErrInvalidArgument},
+		{"requests,", minute, 2 * minute, 0, 0, 0, nil, models.ErrInvalidArgument},
+		{"requests", 0, 2 * minute, 0, 0, 0, nil, models.ErrInvalidArgument},
+		{"requests", 2 * minute, minute, 0, 0, 0, nil, models.ErrInvalidArgument},
+		{"requests", minute, 2 * minute, -1, 0, 0, nil, models.ErrInvalidArgument},
+		{"requests", minute, 2 * minute, 61, 0, 0, nil, models.ErrInvalidArgument},
+		{"requests", minute, 2 * minute, 1 << 58, 0, 0, nil, models.ErrInvalidArgument},
+		{"requests", minute, 2 * minute, 1e13, 0, 0, nil, models.ErrInvalidArgument},
+		{"requests", minute, minute, 1, 0, 0, []float64{100}, nil},
+		{"requests", minute, (maxEvalPoints + 1) * minute, 60, 0, 0, nil, models.ErrInvalidArgument},
+		{"SHIFT(requests, 86400000)", minute, 2 * minute, 60, 0, 0, nil, models.ErrInvalidArgument},
+		{"huge", minute, 2 * minute, 0, 0, 0, []float64{}, nil},
+		{"huge + requests", minute, 3 * minute, 0, 0, 0, []float64{}, nil},
+		{"unknown + 1", minute, 2 * minute, 0, 0, 0, nil, models.ErrNotFound},
+	}
+
+	for i, tt := range testCases {
+		records, err := srv.Evaluate(context.Background(), models.ExprQuery{
+			Expr: tt.expression, Start: tt.start, End: tt.end, Step: tt.step, Limit: tt.limit, After: tt.after,
+		})
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+		if err != nil {
+			continue
+		}
+		require.Len(t, records, len(tt.values), fmt.Sprintf("case %d", i))
+		for j, record := range records {
+			value, ok := record.MetricValue.(float64)
+			require.True(t, ok, fmt.Sprintf("case %d", i))
+			if math.IsNaN(tt.values[j]) {
+				require.True(t, math.IsNaN(value), fmt.Sprintf("case %d: record %d: %v", i, j, value))
+			} else {
+				require.Equal(t, tt.values[j], value, fmt.Sprintf("case %d: record %d", i, j))
+			}
+		}
+	}
+
+	// Large ranges use larger steps than series step.
+	records, err := srv.Evaluate(context.Background(), models.ExprQuery{
+		Expr: "requests", Start: minute, End: 2 * maxEvalPoints * minute,
+	})
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(records), maxEvalPoints)
+	require.Greater(t, records[1].Timestamp-records[0].Timestamp, minute)
+}
@@ -0,0 +1,188 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/tracing"
+)
+
+// maxForecastHorizon limits number of predicted steps.
+const maxForecastHorizon = 10000
+
+// forecaster keeps Holt-Winters models of series.
+type forecaster interface {
+	Observe(ctx context.Context, series models.Series, record models.Record) ([]models.Record, error)
+	Forecast(ctx context.Context, series models.Series, horizon int) ([]models.ForecastPoint, error)
+}
+
+// Forecast returns predictions of the next horizon steps of a series of the request tenant,
+// zero horizon means one season.
+func (s *Service) Forecast(ctx context.Context, name string, horizon int) (_ models.Forecast, err error) {
+	ctx, span := tracer.Start(ctx, "Service.Forecast", trace.WithAttributes(attribute.String("rrd.series", name)))
+	defer func() { tracing.End(span, err) }()
+	if s.forecaster == nil {
+		return models.Forecast{}, models.NewDetailError(models.ErrUnavailable, "forecasting is disabled")
+	}
+	if horizon < 0 || horizon > maxForecastHorizon {
+		return models.Forecast{}, models.NewValidationError("horizon", "must be in range [0, %d]", maxForecastHorizon)
+	}
+	fullName, err := storageName(ctx, name)
+	if err != nil {
+		return models.Forecast{}, err
+	}
+	series, err := s.getSeries(ctx, fullName)
+	if err != nil {
+		return models.Forecast{}, fmt.Errorf("failed to get series: %w", err)
+	}
+	if series.HoltWinters == nil {
+		return models.Forecast{}, models.NewValidationError("series", "has no holt_winters params")
+	}
+	if horizon == 0 {
+		horizon = series.HoltWinters.SeasonLength
+	}
+
+	points, err := s.forecaster.Forecast(ctx, series, horizon)
+	if err != nil {
+		return models.Forecast{}, fmt.Errorf("failed to forecast: %w", err)
+	}
+	return models.Forecast{
+		Series: name,
+		Step:   series.Step,
+		Points: points,
+	}, nil
+}
+
+// ForecastFailures returns number of saved records that failed to update forecast of their series.
+func (s *Service) ForecastFailures() uint64 {
+	return s.forecastFailures.Load()
+}
+
+// saveForecast updates model of the series with saved record, and saves prediction, deviation and failure
+// of the record. Quotas are not applied, as these points are not ingested by the tenant.
+func (s *Service) saveForecast(ctx context.Context, series models.Series, record models.Record) error {
+	if series.HoltWinters == nil || s.forecaster == nil {
+		return nil
+	}
+	records, err := s.forecaster.Observe(ctx, series, record)
+	if err != nil {
+		return err
+	}
+	for _, one := range records {
+		if err := s.storageSetter.Set(ctx, one, series.Retention); err != nil {
+			return fmt.Errorf("failed to create record of %s: %w", one.Series, err)
+		}
+		if s.hub != nil {
+			s.hub.Publish(one)
+		}
+	}
+	return nil
+}
+
+// forecastCompanion is a series with results of forecasting of another series.
+type forecastCompanion struct {
+	name        string
+	kind        string
+	description string
+	valueType   models.ValueType
+}
+
+// forecastCompanions returns series with results of forecasting of the series.
+func forecastCompanions(series string) []forecastCompanion {
+	prediction, deviation, failures := models.ForecastSeriesNames(series)
+	return []forecastCompanion{
+		{prediction, "hwpredict", "Holt-Winters prediction", models.ValueTypeFloat},
+		{deviation, "devpredict", "Holt-Winters predicted deviation", models.ValueTypeFloat},
+		{failures, "failures", "Holt-Winters failure, 1 if too many points deviate from prediction", models.ValueTypeInt},
+	}
+}
+
+// labels returns labels of the forecasted series with the kind of the companion.
+func (c forecastCompanion) labels(series models.Series) map[string]string {
+	labels := maps.Clone(series.Labels)
+	if labels == nil {
+		labels = make(map[string]string, 1)
+	}
+	labels[models.ForecastLabel] = c.kind
+	return labels
+}
+
+// checkForecastSeries checks that the tenant can create missing series of forecasting results of the series
+// in addition to extra series. Series name must be a storage name.
+func (s *Service) checkForecastSeries(ctx context.Context, series models.Series, extra int) error {
+	missing := extra
+	if series.HoltWinters != nil {
+		for _, companion := range forecastCompanions(series.Name) {
+			_, err := s.seriesStorage.GetSeries(ctx, companion.name)
+			switch {
+			case errors.Is(err, models.ErrNotFound):
+				missing++
+			case err != nil:
+				return fmt.Errorf("failed to get series %s: %w", companion.name, err)
+			}
+		}
+	}
+	if missing == 0 {
+		return nil
+	}
+	tenant := models.TenantFromContext(ctx)
+	return s.checkMaxSeries(ctx, tenant, s.tenants.Limits(tenant), missing)
+}
+
+// ensureForecastSeries creates series of forecasting results of the series with its labels, step
+// and retention, or updates them in existing ones. Series name must be a storage name.
+func (s *Service) ensureForecastSeries(ctx context.Context, series models.Series) error {
+	if series.HoltWinters == nil {
+		return nil
+	}
+	for _, companion := range forecastCompanions(series.Name) {
+		now := time.Now().UnixMicro()
+		derived := models.Series{
+			Name:        companion.name,
+			Description: companion.description,
+			Labels:      companion.labels(series),
+			DataSource:  models.DataSourceGauge,
+			Step:        series.Step,
+			Retention:   series.Retention,
+			ValueType:   models.ValueSpec{Type: companion.valueType},
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		err := s.seriesStorage.CreateSeries(ctx, derived)
+		if err == nil {
+			derived.Version = 1
+			s.seriesCache.set(derived)
+			continue
+		}
+		if !errors.Is(err, models.ErrConflict) {
+			return fmt.Errorf("failed to create series %s: %w", companion.name, err)
+		}
+
+		existing, err := s.seriesStorage.GetSeries(ctx, companion.name)
+		if err != nil {
+			return fmt.Errorf("failed to get series %s: %w", companion.name, err)
+		}
+		if existing.Step == series.Step && existing.Retention == series.Retention &&
+			maps.Equal(existing.Labels, derived.Labels) {
+			continue
+		}
+		existing.Step = series.Step
+		existing.Retention = series.Retention
+		existing.Labels = derived.Labels
+		existing.UpdatedAt = now
+		if err := s.seriesStorage.UpdateSeries(ctx, existing); err != nil {
+			s.seriesCache.delete(companion.name)
+			return fmt.Errorf("failed to update series %s: %w", companion.name, err)
+		}
+		existing.Version++
+		s.seriesCache.set(existing)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/stream"
+)
+
+// forecasterMock predicts test metric for any step.
+type forecasterMock struct{}
+
+func (mock forecasterMock) Observe(_ context.Context, series models.Series, record models.Record,
+) ([]models.Record, error) {
+	prediction, deviation, failures := models.ForecastSeriesNames(series.Name)
+	return []models.Record{
+		{Series: prediction, Timestamp: record.Timestamp, MetricValue: testMetric},
+		{Series: deviation, Timestamp: record.Timestamp, MetricValue: 1.0},
+		{Series: failures, Timestamp: record.Timestamp, MetricValue: int64(0)},
+	}, nil
+}
+
+func (mock forecasterMock) Forecast(_ context.Context, series models.Series, horizon int,
+) ([]models.ForecastPoint, error) {
+	points := make([]models.ForecastPoint, horizon)
+	for i := range points {
+		points[i] = models.ForecastPoint{Timestamp: int64(i+1) * series.Step, Value: testMetric}
+	}
+	return points, nil
+}
+
+// failingForecasterMock fails to observe records.
+type failingForecasterMock struct {
+	forecasterMock
+}
+
+func (mock failingForecasterMock) Observe(context.Context, models.Series, models.Record) ([]models.Record, error) {
+	return nil, errTest
+}
+
+func forecastedSeries() models.Series {
+	series := testSeries("cpu", map[string]string{"host": "a"})
+	series.Step = 60
+	series.HoltWinters = &models.HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.1, SeasonLength: 24}
+	return series
+}
+
+func TestService_CreateForecastedSeries(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+
+	series, err := srv.CreateSeries(context.Background(), forecastedSeries())
+	require.NoError(t, err)
+	require.Equal(t, models.DefaultFailureWindow, series.HoltWinters.FailureWindow)
+	for _, name := range []string{"cpu:hwpredict", "cpu:devpredict", "cpu:failures"} {
+		derived, err := srv.DescribeSeries(context.Background(), name)
+		require.NoError(t, err, name)
+		require.Equal(t, series.Labels["host"], derived.Labels["host"], name)
+		require.Equal(t, name[len("cpu:"):], derived.Labels[models.ForecastLabel], name)
+		require.Equal(t, series.Step, derived.Step, name)
+	}
+
+	// Label filters match series of forecasting results only by forecast label.
+	for i, tt := range []struct {
+		labels map[string]string
+		names  []string
+	}{
+		{map[string]string{"host": "a"}, []string{"cpu"}},
+		{map[string]string{"host": "a", models.ForecastLabel: "failures"}, []string{"cpu:failures"}},
+	} {
+		page, err := srv.ListSeries(context.Background(), models.SeriesFilter{Labels: tt.labels})
+		require.NoError(t, err)
+		names := make([]string, 0, len(page.Series))
+		for _, one := range page.Series {
+			names = append(names, one.Name)
+		}
+		require.Equal(t, tt.names, names, fmt.Sprintf("case %d", i))
+	}
+
+	retention := models.Retention{MaxAge: 3600}
+	_, err = srv.UpdateSeries(context.Background(), "cpu", models.SeriesUpdate{Retention: &retention})
+	require.NoError(t, err)
+	derived, err := srv.DescribeSeries(context.Background(), "cpu:failures")
+	require.NoError(t, err)
+	require.Equal(t, retention, derived.Retention)
+	require.Equal(t, uint32(2), derived.Version)
+
+	testCases := []models.Series{
+		testSeries("cpu:failures", nil),
+		{Name: "bad_params", HoltWinters: &models.HoltWinters{Alpha: 2, SeasonLength: 24}, Step: 60},
+		{Name: "no_step", HoltWinters: &models.HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.1, SeasonLength: 24}},
+		// Step in microseconds would wrap to zero.
+		{Name: "huge_step", HoltWinters: &models.HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.1, SeasonLength: 24},
+			Step: 1 << 58},
+		{Name: "long_step", HoltWinters: &models.HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.1, SeasonLength: 24},
+			Step: models.MaxHoltWintersStep + 1},
+	}
+	for i, tt := range testCases {
+		_, err := srv.CreateSeries(context.Background(), tt)
+		require.ErrorIs(t, err, models.ErrInvalidArgument, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestService_CreateForecastedSeriesQuota(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	srv.tenants = tenantsMock{
+		limits: map[string]models.TenantLimits{"acme": {MaxSeries: 4}},
+	}
+	acme := tenantContext("acme")
+
+	// Series of forecasting results count toward max series.
+	mem := testSeries("mem", nil)
+	mem.Step = 60
+	_, err := srv.CreateSeries(acme, mem)
+	require.NoError(t, err)
+	_, err = srv.CreateSeries(acme, forecastedSeries())
+	require.ErrorIs(t, err, models.ErrQuotaExceeded)
+	_, err = srv.CreateSeries(acme, testSeries("cpu", nil))
+	require.NoError(t, err)
+	hw := models.HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.1, SeasonLength: 24}
+	_, err = srv.UpdateSeries(acme, "mem", models.SeriesUpdate{HoltWinters: &hw})
+	require.ErrorIs(t, err, models.ErrQuotaExceeded)
+	_, err = srv.DescribeSeries(acme, "mem:hwpredict")
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestService_CreateForecasts(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	srv.SetHub(stream.NewHub(stream.Options{BufferSize: 10}))
+	srv.SetForecaster(forecasterMock{})
+	_, err := srv.CreateSeries(context.Background(), forecastedSeries())
+	require.NoError(t, err)
+	_, sub, err := srv.Subscribe(context.Background(), "cpu:hwpredict", 0)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	record := testRecord()
+	record.Series = "cpu"
+	require.NoError(t, srv.Create(context.Background(), record))
+	prediction := <-sub.Records()
+	require.Equal(t, "cpu:hwpredict", prediction.Series)
+	require.Equal(t, int64(testTimestamp), prediction.Timestamp)
+
+	// Saved record is not failed by forecasting.
+	srv.SetForecaster(failingForecasterMock{})
+	record.Timestamp++
+	require.NoError(t, srv.Create(context.Background(), record))
+	require.Equal(t, uint64(1), srv.ForecastFailures())
+
+	// Results of forecasting are not written by clients.
+	record.Series = "cpu:hwpredict"
+	require.ErrorIs(t, srv.Create(context.Background(), record), models.ErrInvalidArgument)
+}
+
+func TestService_Forecast(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	_, err := srv.CreateSeries(context.Background(), forecastedSeries())
+	require.NoError(t, err)
+	_, err = srv.Forecast(context.Background(), "cpu", 0)
+	require.ErrorIs(t, err, models.ErrUnavailable)
+
+	srv.SetForecaster(forecasterMock{})
+	testCases := []struct {
+		series  string
+		horizon int
+		points  int
+		err     error
+	}{
+		{"cpu", 0, 24, nil},
+		{"cpu", 5, 5, nil},
+		{"cpu", -1, 0, models.ErrInvalidArgument},
+		{"cpu", maxForecastHorizon + 1, 0, models.ErrInvalidArgument},
+		{models.DefaultSeriesName, 0, 0, models.ErrInvalidArgument},
+		{"unknown", 0, 0, models.ErrNotFound},
+	}
+
+	for i, tt := range testCases {
+		result, err := srv.Forecast(context.Background(), tt.series, tt.horizon)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+		require.Len(t, result.Points, tt.points, fmt.Sprintf("case %d", i))
+		if err == nil {
+			require.Equal(t, tt.series, result.Series, fmt.Sprintf("case %d", i))
+			require.Equal(t, int64(60), result.Step, fmt.Sprintf("case %d", i))
+		}
+	}
+}
@@ -1,48 +1,336 @@
 package rrd
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"math"
+	"slices"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
 	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/stream"
+	"aerospike.com/rrd/internal/tracing"
 )
 
+var tracer = otel.Tracer("aerospike.com/rrd/internal/rrd")
+
 type storageGetter interface {
-	GetByRange(ctx context.Context, min, max int64) ([]models.Record, error)
+	GetByRange(ctx context.Context, series string, min, max int64) ([]models.Record, error)
 }
 
 type storageSetter interface {
-	Set(ctx context.Context, record models.Record) error
+	Set(ctx context.Context, record models.Record, retention models.Retention) error
+}
+
+// admitter is implemented by storage setters that can't accept any number of writes at once, e.g. write queue.
+type admitter interface {
+	Admit(n int) error
+}
+
+// hub delivers new records to subscribers of their series.
+type hub interface {
+	Publish(record models.Record)
+	Subscribe(series string) (*stream.Subscription, error)
 }
 
 type Service struct {
 	storageGetter storageGetter
 	storageSetter storageSetter
+	seriesStorage seriesStorage
+	tenants       tenantLimits
+	// seriesCache keeps series definitions for the write path.
+	seriesCache *seriesCache
+	// hub is optional, records are not streamed if it is nil.
+	hub hub
+	// forecaster is optional, series are not forecasted if it is nil.
+	forecaster forecaster
+	// forecastFailures counts saved records that failed to update forecast.
+	forecastFailures atomic.Uint64
 }
 
-func NewService(storageGetter storageGetter, storageSetter storageSetter) *Service {
+func NewService(
+	storageGetter storageGetter,
+	storageSetter storageSetter,
+	seriesStorage seriesStorage,
+	tenants tenantLimits,
+) *Service {
 	return &Service{
 		storageGetter: storageGetter,
 		storageSetter: storageSetter,
+		seriesStorage: seriesStorage,
+		tenants:       tenants,
+		seriesCache:   newSeriesCache(seriesCacheTTL),
 	}
 }
 
-func (s *Service) Create(ctx context.Context, record models.Record) error {
-	if err := s.storageSetter.Set(ctx, record); err != nil {
+// SetHub sets hub that created records are published to.
+func (s *Service) SetHub(hub hub) {
+	s.hub = hub
+}
+
+// SetForecaster sets forecaster of series with Holt-Winters params.
+func (s *Service) SetForecaster(forecaster forecaster) {
+	s.forecaster = forecaster
+}
+
+// Create validates record against its series definition and saves it to the series of the request tenant.
+func (s *Service) Create(ctx context.Context, record models.Record) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.Create")
+	defer func() { tracing.End(span, err) }()
+	record, series, err := s.prepare(ctx, record)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.String("rrd.series", record.Series))
+
+	tenant := models.TenantFromContext(ctx)
+	if err := s.tenants.AllowIngest(tenant, 1); err != nil {
+		return err
+	}
+	if err := s.storageSetter.Set(ctx, record, series.Retention); err != nil {
+		s.tenants.RefundIngest(tenant, 1)
 		return fmt.Errorf("failed to create record: %w", err)
 	}
+	if s.hub != nil {
+		s.hub.Publish(record)
+	}
+	// Record is saved, so failed forecast is only counted, retry of the record would observe it twice.
+	if err := s.saveForecast(ctx, series, record); err != nil {
+		s.forecastFailures.Add(1)
+		span.RecordError(fmt.Errorf("failed to save forecast: %w", err))
+	}
+	return nil
+}
+
+// Validate checks record against its series definition like Create without saving it, so a batch can be
+// checked before any of its records is saved.
+func (s *Service) Validate(ctx context.Context, record models.Record) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.Validate")
+	defer func() { tracing.End(span, err) }()
+	_, _, err = s.prepare(ctx, record)
+	return err
+}
+
+// Admit returns models.ErrOverloaded if storage can't accept a batch of n records now, so a batch is rejected
+// before any of its records is saved.
+func (s *Service) Admit(n int) error {
+	if admitter, ok := s.storageSetter.(admitter); ok {
+		return admitter.Admit(n)
+	}
 	return nil
 }
 
-func (s *Service) GetByRange(ctx context.Context, start, end int64) ([]models.Record, error) {
+// prepare validates record and returns it with storage name of the series and normalized value, and its series.
+func (s *Service) prepare(ctx context.Context, record models.Record) (models.Record, models.Series, error) {
+	if record.Timestamp <= 0 {
+		return models.Record{}, models.Series{}, models.NewValidationError("timestamp", "must be positive")
+	}
+	if record.Series == "" {
+		record.Series = models.DefaultSeriesName
+	}
+	if models.IsForecastSeries(record.Series) {
+		return models.Record{}, models.Series{}, models.NewValidationError("series", "is written by forecasting")
+	}
+	var err error
+	if record.Series, err = storageName(ctx, record.Series); err != nil {
+		return models.Record{}, models.Series{}, err
+	}
+
+	series, err := s.getSeries(ctx, record.Series)
+	if err != nil {
+		return models.Record{}, models.Series{}, fmt.Errorf("failed to get series: %w", err)
+	}
+
+	value, err := series.ValueType.Normalize(record.MetricValue)
+	if err != nil {
+		return models.Record{}, models.Series{}, err
+	}
+	record.MetricValue = value
+	return record, series, nil
+}
+
+// GetByRange returns records of a series of the request tenant by range, empty series means default series.
+func (s *Service) GetByRange(ctx context.Context, series string, start, end int64) (_ []models.Record, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetByRange")
+	defer func() { tracing.End(span, err) }()
 	// if start = 0 and end = 0 we select all records.
 	if start == 0 && end == 0 {
 		end = time.Now().UnixMicro()
 	}
-	records, err := s.storageGetter.GetByRange(ctx, start, end)
+	if start < 0 || start > end {
+		return nil, models.NewDetailError(models.ErrInvalidArgument, "invalid range [%d, %d]", start, end)
+	}
+	if series == "" {
+		series = models.DefaultSeriesName
+	}
+	if series, err = storageName(ctx, series); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("rrd.series", series))
+	if _, err := s.getSeries(ctx, series); err != nil {
+		return nil, fmt.Errorf("failed to get series: %w", err)
+	}
+
+	records, err := s.storageGetter.GetByRange(ctx, series, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+	span.SetAttributes(attribute.Int("rrd.records", len(records)))
+	return records, nil
+}
+
+// ListRecords returns up to limit records of a series of the request tenant from start to end sorted by
+// timestamp, empty series means default series. Unlike GetByRange, it reads only records near the start.
+func (s *Service) ListRecords(
+	ctx context.Context, series string, start, end int64, limit int,
+) (_ []models.Record, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ListRecords")
+	defer func() { tracing.End(span, err) }()
+	if start < 0 || start > end {
+		return nil, models.NewDetailError(models.ErrInvalidArgument, "invalid range [%d, %d]", start, end)
+	}
+	if limit <= 0 {
+		return nil, models.NewValidationError("limit", "must be positive")
+	}
+	if series == "" {
+		series = models.DefaultSeriesName
+	}
+	if series, err = storageName(ctx, series); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("rrd.series", series))
+	definition, err := s.getSeries(ctx, series)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series: %w", err)
+	}
+
+	records, err := s.readFirst(ctx, definition, start, end, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get records: %w", err)
 	}
+	span.SetAttributes(attribute.Int("rrd.records", len(records)))
+	return records, nil
+}
+
+// readFirst returns up to limit first records of a series in range sorted by timestamp. Range of a series with
+// more points than limit is read by windows from start, the first window has limit steps of the series, or
+// is one minute if series has no step, and each next window is twice longer.
+func (s *Service) readFirst(ctx context.Context, series models.Series, start, end int64, limit int,
+) ([]models.Record, error) {
+	window := s.readWindow(ctx, series, limit)
+	var records []models.Record
+	for start <= end && len(records) < limit {
+		to := end
+		if window <= end-start {
+			to = start + window - 1
+		}
+		part, err := s.storageGetter.GetByRange(ctx, series.Name, start, to)
+		if err != nil {
+			return nil, err
+		}
+		// Storage returns records of a range in any order.
+		slices.SortFunc(part, func(a, b models.Record) int {
+			return cmp.Compare(a.Timestamp, b.Timestamp)
+		})
+		records = append(records, part...)
+		if to == end {
+			break
+		}
+		start = to + 1
+		window = min(window, math.MaxInt64/2) * 2
+	}
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// readLast returns up to limit last records of a series up to end sorted by timestamp. Like readFirst, it reads
+// range by windows, but from end backwards.
+func (s *Service) readLast(ctx context.Context, series models.Series, end int64, limit int) ([]models.Record, error) {
+	window := s.readWindow(ctx, series, limit)
+	var records []models.Record
+	for end >= 0 && len(records) < limit {
+		from := int64(0)
+		if window <= end {
+			from = end - window + 1
+		}
+		part, err := s.storageGetter.GetByRange(ctx, series.Name, from, end)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(part, func(a, b models.Record) int {
+			return cmp.Compare(a.Timestamp, b.Timestamp)
+		})
+		records = append(part, records...)
+		if from == 0 {
+			break
+		}
+		end = from - 1
+		window = min(window, math.MaxInt64/2) * 2
+	}
+	if len(records) > limit {
+		records = records[len(records)-limit:]
+	}
 	return records, nil
 }
+
+// readWindow returns length of the first window of reading limit records of a series, it is the whole range
+// if the series has no more points than limit.
+func (s *Service) readWindow(ctx context.Context, series models.Series, limit int) int64 {
+	if points, err := s.seriesStorage.SeriesPoints(ctx, series.Name); err == nil && points <= uint64(limit) {
+		return math.MaxInt64
+	}
+	if series.Step > 0 && series.Step <= math.MaxInt64/int64(time.Second/time.Microsecond)/int64(limit) {
+		return series.Step * int64(time.Second/time.Microsecond) * int64(limit)
+	}
+	return int64(time.Minute / time.Microsecond)
+}
+
+// Subscribe subscribes to new records of a series of the request tenant, empty series means default series.
+// It returns up to backfill last records saved before subscription sorted by timestamp, records created while
+// backfill is loaded may be both in backfill and in subscription.
+func (s *Service) Subscribe(
+	ctx context.Context, series string, backfill int,
+) (_ []models.Record, _ *stream.Subscription, err error) {
+	ctx, span := tracer.Start(ctx, "Service.Subscribe")
+	defer func() { tracing.End(span, err) }()
+	if s.hub == nil {
+		return nil, nil, models.NewDetailError(models.ErrUnavailable, "streaming is disabled")
+	}
+	if backfill < 0 {
+		return nil, nil, models.NewValidationError("backfill", "must not be negative")
+	}
+	if series == "" {
+		series = models.DefaultSeriesName
+	}
+	if series, err = storageName(ctx, series); err != nil {
+		return nil, nil, err
+	}
+	span.SetAttributes(attribute.String("rrd.series", series))
+	definition, err := s.getSeries(ctx, series)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get series: %w", err)
+	}
+
+	// Subscription starts before backfill is loaded, so no record is missed between them.
+	sub, err := s.hub.Subscribe(series)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+	if backfill == 0 {
+		return nil, sub, nil
+	}
+	records, err := s.readLast(ctx, definition, time.Now().UnixMicro(), backfill)
+	if err != nil {
+		sub.Close()
+		return nil, nil, fmt.Errorf("failed to get backfill: %w", err)
+	}
+	span.SetAttributes(attribute.Int("rrd.records", len(records)))
+	return records, sub, nil
+}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/stream"
 )
 
 const (
@@ -35,7 +40,7 @@ func errorRecord() models.Record {
 
 type storageGetterMock struct{}
 
-func (mock storageGetterMock) GetByRange(_ context.Context, min, max int64) ([]models.Record, error) {
+func (mock storageGetterMock) GetByRange(_ context.Context, _ string, min, max int64) ([]models.Record, error) {
 	if min < 0 {
 		return nil, fmt.Errorf("failed to get by range: %w", errTest)
 	}
@@ -44,29 +49,180 @@ func (mock storageGetterMock) GetByRange(_ context.Context, min, max int64) ([]m
 
 type storageSetterMock struct{}
 
-func (mock storageSetterMock) Set(_ context.Context, record models.Record) error {
-	if record.MetricValue != testMetric {
+func (mock storageSetterMock) Set(_ context.Context, record models.Record, _ models.Retention) error {
+	if record.MetricValue == float64(errorMetric) {
 		return fmt.Errorf("failed to set: %w", errTest)
 	}
 	return nil
 }
 
-func newServiceMock() *Service {
+// seriesStorageMock keeps series in memory, it emulates aerospike generations.
+type seriesStorageMock struct {
+	mu     sync.Mutex
+	series map[string]models.Series
+	// getErr is returned by GetSeries if set.
+	getErr error
+	// points is returned by SeriesPoints if set.
+	points uint64
+}
+
+func newSeriesStorageMock(series ...models.Series) *seriesStorageMock {
+	mock := &seriesStorageMock{series: make(map[string]models.Series)}
+	for _, one := range series {
+		one.Version = 1
+		mock.series[one.Name] = one
+	}
+	return mock
+}
+
+func (mock *seriesStorageMock) CreateSeries(_ context.Context, series models.Series) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if _, ok := mock.series[series.Name]; ok {
+		return fmt.Errorf("failed to create: %w", models.ErrConflict)
+	}
+	series.Version = 1
+	mock.series[series.Name] = series
+	return nil
+}
+
+func (mock *seriesStorageMock) UpdateSeries(_ context.Context, series models.Series) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	current, ok := mock.series[series.Name]
+	if !ok {
+		return fmt.Errorf("failed to update: %w", models.ErrNotFound)
+	}
+	if current.Version != series.Version {
+		return fmt.Errorf("failed to update: %w", models.ErrConflict)
+	}
+	series.Version++
+	mock.series[series.Name] = series
+	return nil
+}
+
+func (mock *seriesStorageMock) GetSeries(_ context.Context, name string) (models.Series, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.getErr != nil {
+		return models.Series{}, mock.getErr
+	}
+	series, ok := mock.series[name]
+	if !ok {
+		return models.Series{}, fmt.Errorf("failed to get: %w", models.ErrNotFound)
+	}
+	return series, nil
+}
+
+func (mock *seriesStorageMock) ListTenantSeries(_ context.Context, tenant string) ([]models.Series, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make([]models.Series, 0, len(mock.series))
+	for name, series := range mock.series {
+		if seriesTenant, _ := models.SplitSeriesName(name); seriesTenant == tenant {
+			result = append(result, series)
+		}
+	}
+	return result, nil
+}
+
+func (mock *seriesStorageMock) ListTenants(_ context.Context) ([]string, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	tenants := []string{models.DefaultTenant}
+	for name := range mock.series {
+		if tenant, _ := models.SplitSeriesName(name); !slices.Contains(tenants, tenant) {
+			tenants = append(tenants, tenant)
+		}
+	}
+	return tenants, nil
+}
+
+func (mock *seriesStorageMock) SeriesStats(_ context.Context, name string) (models.SeriesStats, error) {
+	return models.SeriesStats{Points: 1, Oldest: testTimestamp, Newest: testTimestamp}, nil
+}
+
+func (mock *seriesStorageMock) SeriesPoints(_ context.Context, _ string) (uint64, error) {
+	if mock.points > 0 {
+		return mock.points, nil
+	}
+	return 1, nil
+}
+
+func (mock *seriesStorageMock) Capacity(retention models.Retention) uint64 {
+	if retention.MaxPoints > 0 {
+		return retention.MaxPoints
+	}
+	return 1000
+}
+
+// tenantsMock has limits of tenants and rejects ingest of tenants with rateLimited flag, refunded points are
+// counted if refunded is set.
+type tenantsMock struct {
+	limits      map[string]models.TenantLimits
+	rateLimited map[string]bool
+	refunded    *atomic.Int64
+}
+
+func (mock tenantsMock) Limits(tenant string) models.TenantLimits {
+	return mock.limits[tenant]
+}
+
+func (mock tenantsMock) AllowIngest(tenant string, _ int) error {
+	if mock.rateLimited[tenant] {
+		return fmt.Errorf("failed to allow ingest: %w", models.ErrRateLimited)
+	}
+	return nil
+}
+
+func (mock tenantsMock) RefundIngest(_ string, points int) {
+	if mock.refunded != nil {
+		mock.refunded.Add(int64(points))
+	}
+}
+
+func (mock tenantsMock) IngestUsage(_ string) models.IngestUsage {
+	return models.IngestUsage{Accepted: 1}
+}
+
+func defaultSeries() models.Series {
+	return models.Series{
+		Name:       models.DefaultSeriesName,
+		DataSource: models.DataSourceGauge,
+		ValueType:  models.ValueSpec{Type: models.ValueTypeFloat},
+	}
+}
+
+func newServiceMock(series ...models.Series) *Service {
 	return &Service{
 		storageGetter: storageGetterMock{},
 		storageSetter: storageSetterMock{},
+		seriesStorage: newSeriesStorageMock(append(series, defaultSeries())...),
+		tenants:       tenantsMock{},
+		seriesCache:   newSeriesCache(seriesCacheTTL),
 	}
 }
 
 func TestService_Create(t *testing.T) {
 	t.Parallel()
-	srv := newServiceMock()
+	srv := newServiceMock(models.Series{
+		Name:       "status",
+		DataSource: models.DataSourceGauge,
+		ValueType:  models.ValueSpec{Type: models.ValueTypeString},
+	})
 	testCases := []struct {
 		record models.Record
 		err    error
 	}{
 		{testRecord(), nil},
 		{errorRecord(), errTest},
+		{models.Record{Timestamp: -1, MetricValue: testMetric}, models.ErrInvalidArgument},
+		{models.Record{Timestamp: testTimestamp}, models.ErrInvalidArgument},
+		{models.Record{Timestamp: testTimestamp, MetricValue: "3.5"}, models.ErrInvalidArgument},
+		{models.Record{Timestamp: testTimestamp, MetricValue: true}, models.ErrInvalidArgument},
+		{models.Record{Series: "unknown", Timestamp: testTimestamp, MetricValue: testMetric}, models.ErrNotFound},
+		{models.Record{Series: "status", Timestamp: testTimestamp, MetricValue: "up"}, nil},
+		{models.Record{Series: "status", Timestamp: testTimestamp, MetricValue: testMetric}, models.ErrInvalidArgument},
 	}
 
 	for i, tt := range testCases {
@@ -75,6 +231,46 @@ func TestService_Create(t *testing.T) {
 	}
 }
 
+func TestService_Validate(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	testCases := []struct {
+		record models.Record
+		err    error
+	}{
+		{testRecord(), nil},
+		// Record is not saved, so error of storage is not returned.
+		{errorRecord(), nil},
+		{models.Record{Timestamp: testTimestamp, MetricValue: "3.5"}, models.ErrInvalidArgument},
+		{models.Record{Series: "acme/cpu", Timestamp: testTimestamp, MetricValue: testMetric}, models.ErrInvalidArgument},
+		{models.Record{Series: "unknown", Timestamp: testTimestamp, MetricValue: testMetric}, models.ErrNotFound},
+	}
+
+	for i, tt := range testCases {
+		err := srv.Validate(context.Background(), tt.record)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestService_CreateWithStaleSeries(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	storage := srv.seriesStorage.(*seriesStorageMock)
+	srv.seriesCache = newSeriesCache(0)
+
+	// Expired series definition is used only while storage is unavailable.
+	require.NoError(t, srv.Create(context.Background(), testRecord()))
+	storage.getErr = fmt.Errorf("failed to get: %w", models.ErrUnavailable)
+	require.NoError(t, srv.Create(context.Background(), testRecord()))
+	storage.getErr = fmt.Errorf("failed to get: %w", errTest)
+	require.ErrorIs(t, srv.Create(context.Background(), testRecord()), errTest)
+
+	record := testRecord()
+	record.Series = "unknown"
+	storage.getErr = fmt.Errorf("failed to get: %w", models.ErrUnavailable)
+	require.ErrorIs(t, srv.Create(context.Background(), record), models.ErrUnavailable)
+}
+
 func TestService_GetByRange(t *testing.T) {
 	t.Parallel()
 	srv := newServiceMock()
@@ -85,12 +281,159 @@ func TestService_GetByRange(t *testing.T) {
 	}{
 		{0, 10, []models.Record{testRecord()}, nil},
 		{0, 0, []models.Record{testRecord()}, nil},
-		{-1, 0, nil, errTest},
+		{-1, 0, nil, models.ErrInvalidArgument},
+		{10, 5, nil, models.ErrInvalidArgument},
 	}
 
 	for i, tt := range testCases {
-		result, err := srv.GetByRange(context.Background(), tt.min, tt.max)
+		result, err := srv.GetByRange(context.Background(), "", tt.min, tt.max)
 		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
 		require.Equal(t, tt.records, result, fmt.Sprintf("case %d", i))
 	}
 }
+
+func TestService_ListRecords(t *testing.T) {
+	t.Parallel()
+	const minute, hour = int64(60000000), int64(3600000000)
+	cpu := testSeries("cpu", nil)
+	cpu.Step = 60
+	srv := newServiceMock(cpu, testSeries("sparse", nil))
+	srv.seriesStorage.(*seriesStorageMock).points = 5
+	srv.storageGetter = seriesRecordsMock{
+		"cpu": {
+			{Timestamp: 5 * minute, MetricValue: 5.0},
+			{Timestamp: 2 * minute, MetricValue: 2.0},
+			{Timestamp: minute, MetricValue: 1.0},
+			{Timestamp: 4 * minute, MetricValue: 4.0},
+			{Timestamp: 3 * minute, MetricValue: 3.0},
+		},
+		"sparse": {
+			{Timestamp: 30 * hour, MetricValue: 2.0},
+			{Timestamp: hour, MetricValue: 1.0},
+		},
+	}
+	testCases := []struct {
+		series     string
+		start, end int64
+		limit      int
+		timestamps []int64
+		err        error
+	}{
+		{"cpu", 0, 10 * minute, 2, []int64{minute, 2 * minute}, nil},
+		{"cpu", 3*minute + 1, 10 * minute, 2, []int64{4 * minute, 5 * minute}, nil},
+		{"cpu", 0, 3 * minute, 10, []int64{minute, 2 * minute, 3 * minute}, nil},
+		{"sparse", 0, 100 * hour, 2, []int64{hour, 30 * hour}, nil},
+		{"sparse", hour + 1, 100 * hour, 1, []int64{30 * hour}, nil},
+		{"sparse", 31 * hour, 100 * hour, 1, []int64{}, nil},
+		{"cpu", 0, 10, 0, nil, models.ErrInvalidArgument},
+		{"cpu", 10, 5, 1, nil, models.ErrInvalidArgument},
+		{"unknown", 0, 10, 1, nil, models.ErrNotFound},
+	}
+
+	for i, tt := range testCases {
+		records, err := srv.ListRecords(context.Background(), tt.series, tt.start, tt.end, tt.limit)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+		if err != nil {
+			continue
+		}
+		timestamps := make([]int64, 0, len(records))
+		for _, record := range records {
+			timestamps = append(timestamps, record.Timestamp)
+		}
+		require.Equal(t, tt.timestamps, timestamps, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestService_Subscribe(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	_, _, err := srv.Subscribe(context.Background(), "", 0)
+	require.ErrorIs(t, err, models.ErrUnavailable)
+
+	srv.SetHub(stream.NewHub(stream.Options{BufferSize: 10}))
+	testCases := []struct {
+		series   string
+		backfill int
+		records  []models.Record
+		err      error
+	}{
+		{"", 0, nil, nil},
+		{models.DefaultSeriesName, 5, []models.Record{testRecord()}, nil},
+		{"unknown", 0, nil, models.ErrNotFound},
+		{"", -1, nil, models.ErrInvalidArgument},
+	}
+
+	for i, tt := range testCases {
+		records, sub, err := srv.Subscribe(context.Background(), tt.series, tt.backfill)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+		require.Equal(t, tt.records, records, fmt.Sprintf("case %d", i))
+		if err == nil {
+			sub.Close()
+		}
+	}
+}
+
+// rangesMock records ranges of reads of records.
+type rangesMock struct {
+	seriesRecordsMock
+	mins []int64
+}
+
+func (mock *rangesMock) GetByRange(ctx context.Context, series string, min, max int64) ([]models.Record, error) {
+	mock.mins = append(mock.mins, min)
+	return mock.seriesRecordsMock.GetByRange(ctx, series, min, max)
+}
+
+func TestService_SubscribeBackfill(t *testing.T) {
+	t.Parallel()
+	const minute = int64(60000000)
+	now := time.Now().UnixMicro()
+	cpu := testSeries("cpu", nil)
+	cpu.Step = 60
+	srv := newServiceMock(cpu)
+	srv.SetHub(stream.NewHub(stream.Options{BufferSize: 10}))
+	srv.seriesStorage.(*seriesStorageMock).points = 5
+	storage := &rangesMock{seriesRecordsMock: seriesRecordsMock{"cpu": {
+		{Timestamp: now - minute, MetricValue: 4.0},
+		{Timestamp: now - 30*minute, MetricValue: 2.0},
+		{Timestamp: now - 2*minute, MetricValue: 3.0},
+		{Timestamp: 1, MetricValue: 1.0},
+	}}}
+	srv.storageGetter = storage
+
+	// The first window has backfill steps, it is widened until backfill is loaded.
+	records, sub, err := srv.Subscribe(context.Background(), "cpu", 3)
+	require.NoError(t, err)
+	sub.Close()
+	require.Equal(t, []models.Record{
+		{Timestamp: now - 30*minute, MetricValue: 2.0},
+		{Timestamp: now - 2*minute, MetricValue: 3.0},
+		{Timestamp: now - minute, MetricValue: 4.0},
+	}, records)
+	require.Greater(t, storage.mins[0], now-4*minute)
+	require.Greater(t, storage.mins[len(storage.mins)-1], int64(0))
+}
+
+func TestService_CreatePublishes(t *testing.T) {
+	t.Parallel()
+	tenantSeries := defaultSeries()
+	tenantSeries.Name = models.TenantSeriesName("acme", models.DefaultSeriesName)
+	srv := newServiceMock(tenantSeries)
+	srv.SetHub(stream.NewHub(stream.Options{BufferSize: 10}))
+	_, sub, err := srv.Subscribe(context.Background(), "", 0)
+	require.NoError(t, err)
+	defer sub.Close()
+	tenantCtx := models.WithIdentity(context.Background(), models.Identity{Tenant: "acme"})
+	_, tenantSub, err := srv.Subscribe(tenantCtx, "", 0)
+	require.NoError(t, err)
+	defer tenantSub.Close()
+
+	require.NoError(t, srv.Create(context.Background(), testRecord()))
+	require.Error(t, srv.Create(context.Background(), errorRecord()))
+
+	record := <-sub.Records()
+	require.Equal(t, models.DefaultSeriesName, record.Series)
+	require.Equal(t, int64(testTimestamp), record.Timestamp)
+	require.Empty(t, sub.Records())
+	require.Empty(t, tenantSub.Records())
+}
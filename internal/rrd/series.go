@@ -0,0 +1,283 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/tracing"
+)
+
+const (
+	// seriesCacheTTL limits how long other instances may use outdated series definition after update.
+	seriesCacheTTL = 10 * time.Second
+
+	defaultSeriesPageLimit = 100
+	maxSeriesPageLimit     = 1000
+)
+
+type seriesStorage interface {
+	CreateSeries(ctx context.Context, series models.Series) error
+	UpdateSeries(ctx context.Context, series models.Series) error
+	GetSeries(ctx context.Context, name string) (models.Series, error)
+	ListTenantSeries(ctx context.Context, tenant string) ([]models.Series, error)
+	ListTenants(ctx context.Context) ([]string, error)
+	SeriesStats(ctx context.Context, name string) (models.SeriesStats, error)
+	SeriesPoints(ctx context.Context, name string) (uint64, error)
+	Capacity(retention models.Retention) uint64
+}
+
+// CreateSeries validates and registers new series within quotas of the request tenant.
+func (s *Service) CreateSeries(ctx context.Context, series models.Series) (_ models.Series, err error) {
+	ctx, span := tracer.Start(ctx, "Service.CreateSeries", trace.WithAttributes(attribute.String("rrd.series", series.Name)))
+	defer func() { tracing.End(span, err) }()
+	if series.DataSource == "" {
+		series.DataSource = models.DataSourceGauge
+	}
+	if series.ValueType.Type == "" {
+		series.ValueType.Type = models.ValueTypeFloat
+	}
+	if series.HoltWinters != nil {
+		hw := series.HoltWinters.WithDefaults()
+		series.HoltWinters = &hw
+	}
+	if err := series.Validate(); err != nil {
+		return models.Series{}, err
+	}
+	if err := applyCapacity(s.tenants.Limits(models.TenantFromContext(ctx)), &series.Retention); err != nil {
+		return models.Series{}, err
+	}
+
+	name := series.Name
+	if series.Name, err = storageName(ctx, name); err != nil {
+		return models.Series{}, err
+	}
+	// Series of forecasting results are created with the series, so they count toward the limit too.
+	if err := s.checkForecastSeries(ctx, series, 1); err != nil {
+		return models.Series{}, err
+	}
+
+	now := time.Now().UnixMicro()
+	series.CreatedAt = now
+	series.UpdatedAt = now
+
+	if err := s.seriesStorage.CreateSeries(ctx, series); err != nil {
+		return models.Series{}, fmt.Errorf("failed to create series %s: %w", name, err)
+	}
+	// New record generation is always 1.
+	series.Version = 1
+	s.seriesCache.set(series)
+	if err := s.ensureForecastSeries(ctx, series); err != nil {
+		return models.Series{}, err
+	}
+
+	series.Name = name
+	return series, nil
+}
+
+// EnsureSeries creates series if it doesn't exist, or returns existing one.
+func (s *Service) EnsureSeries(ctx context.Context, series models.Series) (models.Series, error) {
+	created, err := s.CreateSeries(ctx, series)
+	switch {
+	case err == nil:
+		return created, nil
+	case errors.Is(err, models.ErrConflict):
+		// Name is valid, as the series is created.
+		name, _ := storageName(ctx, series.Name)
+		existing, err := s.getSeries(ctx, name)
+		existing.Name = series.Name
+		return existing, err
+	default:
+		return models.Series{}, err
+	}
+}
+
+// UpdateSeries applies update to the series mutable fields.
+func (s *Service) UpdateSeries(ctx context.Context, name string, update models.SeriesUpdate) (_ models.Series, err error) {
+	ctx, span := tracer.Start(ctx, "Service.UpdateSeries", trace.WithAttributes(attribute.String("rrd.series", name)))
+	defer func() { tracing.End(span, err) }()
+	fullName, err := storageName(ctx, name)
+	if err != nil {
+		return models.Series{}, err
+	}
+	series, err := s.seriesStorage.GetSeries(ctx, fullName)
+	if err != nil {
+		return models.Series{}, fmt.Errorf("failed to get series: %w", err)
+	}
+	if update.Version != nil && *update.Version != series.Version {
+		return models.Series{}, models.NewDetailError(models.ErrConflict, "series %s version is %d, not %d",
+			name, series.Version, *update.Version)
+	}
+
+	update.Apply(&series)
+	// Name is validated as it is seen by the tenant.
+	series.Name = name
+	if err = series.Validate(); err != nil {
+		return models.Series{}, err
+	}
+	if err = applyCapacity(s.tenants.Limits(models.TenantFromContext(ctx)), &series.Retention); err != nil {
+		return models.Series{}, err
+	}
+	series.UpdatedAt = time.Now().UnixMicro()
+
+	series.Name = fullName
+	if err = s.checkForecastSeries(ctx, series, 0); err != nil {
+		return models.Series{}, err
+	}
+	if err = s.seriesStorage.UpdateSeries(ctx, series); err != nil {
+		s.seriesCache.delete(fullName)
+		return models.Series{}, fmt.Errorf("failed to update series %s: %w", name, err)
+	}
+	series.Version++
+	s.seriesCache.set(series)
+	if err = s.ensureForecastSeries(ctx, series); err != nil {
+		return models.Series{}, err
+	}
+
+	series.Name = name
+	return series, nil
+}
+
+// DescribeSeries returns series definition with statistics of stored points.
+func (s *Service) DescribeSeries(ctx context.Context, name string) (_ models.SeriesDescription, err error) {
+	ctx, span := tracer.Start(ctx, "Service.DescribeSeries", trace.WithAttributes(attribute.String("rrd.series", name)))
+	defer func() { tracing.End(span, err) }()
+	fullName, err := storageName(ctx, name)
+	if err != nil {
+		return models.SeriesDescription{}, err
+	}
+	series, err := s.seriesStorage.GetSeries(ctx, fullName)
+	if err != nil {
+		return models.SeriesDescription{}, fmt.Errorf("failed to get series: %w", err)
+	}
+	series.Name = name
+
+	stats, err := s.seriesStorage.SeriesStats(ctx, fullName)
+	if err != nil {
+		return models.SeriesDescription{}, fmt.Errorf("failed to get series stats: %w", err)
+	}
+
+	return models.SeriesDescription{
+		Series: series,
+		Stats:  stats,
+	}, nil
+}
+
+// ListSeries returns page of series of the request tenant sorted by name, that match filter labels.
+func (s *Service) ListSeries(ctx context.Context, filter models.SeriesFilter) (_ models.SeriesPage, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ListSeries")
+	defer func() { tracing.End(span, err) }()
+	switch {
+	case filter.Limit < 0:
+		return models.SeriesPage{}, models.NewValidationError("limit", "must not be negative")
+	case filter.Limit == 0:
+		filter.Limit = defaultSeriesPageLimit
+	case filter.Limit > maxSeriesPageLimit:
+		filter.Limit = maxSeriesPageLimit
+	}
+
+	all, err := s.seriesStorage.ListTenantSeries(ctx, models.TenantFromContext(ctx))
+	if err != nil {
+		return models.SeriesPage{}, fmt.Errorf("failed to list series: %w", err)
+	}
+	for i := range all {
+		_, all[i].Name = models.SplitSeriesName(all[i].Name)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+
+	page := models.SeriesPage{
+		Series: make([]models.Series, 0, filter.Limit),
+	}
+	for i := range all {
+		if all[i].Name <= filter.After || !filter.Matches(&all[i]) {
+			continue
+		}
+		if len(page.Series) == filter.Limit {
+			page.NextPageToken = page.Series[len(page.Series)-1].Name
+			break
+		}
+		page.Series = append(page.Series, all[i])
+	}
+
+	return page, nil
+}
+
+// getSeries returns series definition from cache or storage by its storage name.
+func (s *Service) getSeries(ctx context.Context, name string) (models.Series, error) {
+	if series, ok := s.seriesCache.get(name); ok {
+		return series, nil
+	}
+
+	series, err := s.seriesStorage.GetSeries(ctx, name)
+	if err != nil {
+		// Expired definition is used while storage is unavailable, so writes can be buffered.
+		if stale, ok := s.seriesCache.stale(name); ok && errors.Is(err, models.ErrUnavailable) {
+			return stale, nil
+		}
+		return models.Series{}, err
+	}
+	s.seriesCache.set(series)
+
+	return series, nil
+}
+
+// seriesCache keeps series definitions for a limited time.
+type seriesCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]seriesCacheEntry
+}
+
+type seriesCacheEntry struct {
+	series  models.Series
+	expires time.Time
+}
+
+func newSeriesCache(ttl time.Duration) *seriesCache {
+	return &seriesCache{
+		ttl:     ttl,
+		entries: make(map[string]seriesCacheEntry),
+	}
+}
+
+func (c *seriesCache) get(name string) (models.Series, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[name]
+	if !ok || time.Now().After(entry.expires) {
+		return models.Series{}, false
+	}
+	return entry.series, true
+}
+
+// stale returns series definition even if it is expired.
+func (c *seriesCache) stale(name string) (models.Series, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[name]
+	return entry.series, ok
+}
+
+func (c *seriesCache) set(series models.Series) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[series.Name] = seriesCacheEntry{
+		series:  series,
+		expires: time.Now().Add(c.ttl),
+	}
+}
+
+func (c *seriesCache) delete(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
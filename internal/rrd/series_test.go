@@ -0,0 +1,139 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func testSeries(name string, labels map[string]string) models.Series {
+	return models.Series{
+		Name:       name,
+		Labels:     labels,
+		DataSource: models.DataSourceGauge,
+		ValueType:  models.ValueSpec{Type: models.ValueTypeFloat},
+	}
+}
+
+func TestService_CreateSeries(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	testCases := []struct {
+		series models.Series
+		err    error
+	}{
+		{models.Series{Name: "cpu_usage"}, nil},
+		{models.Series{Name: "cpu_usage"}, models.ErrConflict},
+		{models.Series{Name: ""}, models.ErrInvalidArgument},
+		{models.Series{Name: "1cpu"}, models.ErrInvalidArgument},
+		{models.Series{Name: "bad_labels", Labels: map[string]string{"a-b": "c"}}, models.ErrInvalidArgument},
+		{models.Series{Name: "bad_type", ValueType: models.ValueSpec{Type: "complex"}}, models.ErrInvalidArgument},
+		{models.Series{Name: "bad_age", Retention: models.Retention{MaxAge: models.MaxRetentionAge + 1}},
+			models.ErrInvalidArgument},
+		{models.Series{
+			Name:       "bad_counter",
+			DataSource: models.DataSourceCounter,
+			ValueType:  models.ValueSpec{Type: models.ValueTypeString},
+		}, models.ErrInvalidArgument},
+	}
+
+	for i, tt := range testCases {
+		_, err := srv.CreateSeries(context.Background(), tt.series)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+	}
+
+	series, err := srv.DescribeSeries(context.Background(), "cpu_usage")
+	require.NoError(t, err)
+	require.Equal(t, models.DataSourceGauge, series.DataSource)
+	require.Equal(t, models.ValueTypeFloat, series.ValueType.Type)
+	require.Equal(t, uint32(1), series.Version)
+	require.Equal(t, uint64(1), series.Stats.Points)
+}
+
+func TestService_EnsureSeries(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	series := defaultSeries()
+	series.ValueType.Type = models.ValueTypeInt
+
+	result, err := srv.EnsureSeries(context.Background(), series)
+	require.NoError(t, err)
+	// Existing series is not changed.
+	require.Equal(t, models.ValueTypeFloat, result.ValueType.Type)
+}
+
+func TestService_UpdateSeries(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock(testSeries("cpu_usage", nil))
+	unit := "percent"
+	labels := map[string]string{"host": "a"}
+	badLabels := map[string]string{"-": "a"}
+	staleVersion := uint32(1)
+
+	testCases := []struct {
+		name    string
+		update  models.SeriesUpdate
+		version uint32
+		err     error
+	}{
+		{"cpu_usage", models.SeriesUpdate{Unit: &unit}, 2, nil},
+		{"cpu_usage", models.SeriesUpdate{Labels: &labels, Version: &staleVersion}, 0, models.ErrConflict},
+		{"cpu_usage", models.SeriesUpdate{Labels: &badLabels}, 0, models.ErrInvalidArgument},
+		{"cpu_usage", models.SeriesUpdate{Labels: &labels}, 3, nil},
+		{"unknown", models.SeriesUpdate{Unit: &unit}, 0, models.ErrNotFound},
+	}
+
+	for i, tt := range testCases {
+		result, err := srv.UpdateSeries(context.Background(), tt.name, tt.update)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+		require.Equal(t, tt.version, result.Version, fmt.Sprintf("case %d", i))
+	}
+
+	series, err := srv.getSeries(context.Background(), "cpu_usage")
+	require.NoError(t, err)
+	require.Equal(t, unit, series.Unit)
+	require.Equal(t, labels, series.Labels)
+}
+
+func TestService_ListSeries(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock(
+		testSeries("a", map[string]string{"env": "prod"}),
+		testSeries("b", map[string]string{"env": "dev"}),
+		testSeries("c", map[string]string{"env": "prod", "team": "core"}),
+		testSeries("d", map[string]string{"env": "prod"}),
+	)
+
+	testCases := []struct {
+		filter models.SeriesFilter
+		names  []string
+		next   string
+		err    error
+	}{
+		{models.SeriesFilter{}, []string{"a", "b", "c", "d", models.DefaultSeriesName}, "", nil},
+		{models.SeriesFilter{Labels: map[string]string{"env": "prod"}}, []string{"a", "c", "d"}, "", nil},
+		{models.SeriesFilter{Labels: map[string]string{"env": "prod", "team": "core"}}, []string{"c"}, "", nil},
+		{models.SeriesFilter{Labels: map[string]string{"env": "prod"}, Limit: 2}, []string{"a", "c"}, "c", nil},
+		{models.SeriesFilter{Labels: map[string]string{"env": "prod"}, Limit: 2, After: "c"}, []string{"d"}, "", nil},
+		{models.SeriesFilter{Limit: -1}, nil, "", models.ErrInvalidArgument},
+	}
+
+	for i, tt := range testCases {
+		page, err := srv.ListSeries(context.Background(), tt.filter)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d", i))
+		names := make([]string, 0, len(page.Series))
+		for _, series := range page.Series {
+			names = append(names, series.Name)
+		}
+		if tt.names == nil {
+			require.Empty(t, names, fmt.Sprintf("case %d", i))
+		} else {
+			require.Equal(t, tt.names, names, fmt.Sprintf("case %d", i))
+		}
+		require.Equal(t, tt.next, page.NextPageToken, fmt.Sprintf("case %d", i))
+	}
+}
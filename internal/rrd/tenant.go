@@ -0,0 +1,107 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/tracing"
+)
+
+type tenantLimits interface {
+	Limits(tenant string) models.TenantLimits
+	AllowIngest(tenant string, points int) error
+	RefundIngest(tenant string, points int)
+	IngestUsage(tenant string) models.IngestUsage
+}
+
+// storageName returns name of the series of the request tenant in storage. Series of other tenants than
+// default are prefixed with tenant, names are validated not to contain the separator, so tenants can't clash.
+func storageName(ctx context.Context, name string) (string, error) {
+	if err := models.ValidateSeriesName(name); err != nil {
+		return "", err
+	}
+	return models.TenantSeriesName(models.TenantFromContext(ctx), name), nil
+}
+
+// applyCapacity sets tenant capacity as max points of the series without them, or checks that
+// max points don't exceed tenant capacity.
+func applyCapacity(limits models.TenantLimits, retention *models.Retention) error {
+	switch {
+	case limits.Capacity == 0:
+		return nil
+	case retention.MaxPoints == 0:
+		retention.MaxPoints = limits.Capacity
+		return nil
+	case retention.MaxPoints > limits.Capacity:
+		return models.NewDetailError(models.ErrQuotaExceeded, "max points %d exceed tenant capacity %d",
+			retention.MaxPoints, limits.Capacity)
+	default:
+		return nil
+	}
+}
+
+// checkMaxSeries returns error if tenant can't create n more series. Concurrent requests may exceed
+// the limit slightly, because series are counted before creation.
+func (s *Service) checkMaxSeries(ctx context.Context, tenant string, limits models.TenantLimits, n int) error {
+	if limits.MaxSeries == 0 {
+		return nil
+	}
+	series, err := s.seriesStorage.ListTenantSeries(ctx, tenant)
+	if err != nil {
+		return fmt.Errorf("failed to count series: %w", err)
+	}
+	if len(series)+n > limits.MaxSeries {
+		return models.NewDetailError(models.ErrQuotaExceeded, "tenant %s has %d series, limit is %d",
+			tenant, len(series), limits.MaxSeries)
+	}
+	return nil
+}
+
+// Usage returns resources used by the request tenant.
+func (s *Service) Usage(ctx context.Context) (_ models.TenantUsage, err error) {
+	ctx, span := tracer.Start(ctx, "Service.Usage")
+	defer func() { tracing.End(span, err) }()
+	return s.tenantUsage(ctx, models.TenantFromContext(ctx))
+}
+
+// ListUsage returns resources used by all tenants that have series.
+func (s *Service) ListUsage(ctx context.Context) (_ []models.TenantUsage, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ListUsage")
+	defer func() { tracing.End(span, err) }()
+	tenants, err := s.seriesStorage.ListTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	result := make([]models.TenantUsage, 0, len(tenants))
+	for _, tenant := range tenants {
+		usage, err := s.tenantUsage(ctx, tenant)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, usage)
+	}
+	return result, nil
+}
+
+func (s *Service) tenantUsage(ctx context.Context, tenant string) (models.TenantUsage, error) {
+	series, err := s.seriesStorage.ListTenantSeries(ctx, tenant)
+	if err != nil {
+		return models.TenantUsage{}, fmt.Errorf("failed to list series of tenant %s: %w", tenant, err)
+	}
+	usage := models.TenantUsage{
+		Tenant: tenant,
+		Series: len(series),
+		Ingest: s.tenants.IngestUsage(tenant),
+		Limits: s.tenants.Limits(tenant),
+	}
+	for _, one := range series {
+		points, err := s.seriesStorage.SeriesPoints(ctx, one.Name)
+		if err != nil {
+			return models.TenantUsage{}, fmt.Errorf("failed to get points of series %s: %w", one.Name, err)
+		}
+		usage.Points += points
+		usage.Capacity += s.seriesStorage.Capacity(one.Retention)
+	}
+	return usage, nil
+}
@@ -0,0 +1,161 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func tenantContext(tenant string) context.Context {
+	return models.WithIdentity(context.Background(), models.Identity{
+		Subject: tenant,
+		Tenant:  tenant,
+		Scopes:  []models.Scope{models.ScopeWrite},
+	})
+}
+
+func TestService_TenantIsolation(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock(testSeries("cpu", nil))
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+
+	created, err := srv.CreateSeries(acme, testSeries("cpu", map[string]string{"owner": "acme"}))
+	require.NoError(t, err)
+	require.Equal(t, "cpu", created.Name)
+	_, err = srv.CreateSeries(globex, testSeries("mem", nil))
+	require.NoError(t, err)
+
+	// Tenants see only their series with names without tenant.
+	testCases := []struct {
+		ctx   context.Context
+		names []string
+	}{
+		{context.Background(), []string{"cpu", models.DefaultSeriesName}},
+		{tenantContext(models.DefaultTenant), []string{"cpu", models.DefaultSeriesName}},
+		{acme, []string{"cpu"}},
+		{globex, []string{"mem"}},
+	}
+	for i, tc := range testCases {
+		page, err := srv.ListSeries(tc.ctx, models.SeriesFilter{})
+		require.NoError(t, err, fmt.Sprintf("case %d", i))
+		names := make([]string, 0, len(page.Series))
+		for _, series := range page.Series {
+			names = append(names, series.Name)
+		}
+		require.Equal(t, tc.names, names, fmt.Sprintf("case %d", i))
+	}
+
+	description, err := srv.DescribeSeries(acme, "cpu")
+	require.NoError(t, err)
+	require.Equal(t, "cpu", description.Name)
+	require.Equal(t, map[string]string{"owner": "acme"}, description.Labels)
+
+	_, err = srv.DescribeSeries(globex, "cpu")
+	require.ErrorIs(t, err, models.ErrNotFound)
+	_, err = srv.GetByRange(globex, "cpu", 0, 10)
+	require.ErrorIs(t, err, models.ErrNotFound)
+	// Default series is not shared with tenants.
+	require.ErrorIs(t, srv.Create(acme, testRecord()), models.ErrNotFound)
+	require.NoError(t, srv.Create(acme, models.Record{Series: "cpu", Timestamp: testTimestamp, MetricValue: 1.5}))
+
+	unit := "percent"
+	updated, err := srv.UpdateSeries(acme, "cpu", models.SeriesUpdate{Unit: &unit})
+	require.NoError(t, err)
+	require.Equal(t, "cpu", updated.Name)
+	original, err := srv.DescribeSeries(context.Background(), "cpu")
+	require.NoError(t, err)
+	require.Empty(t, original.Unit)
+	_, err = srv.UpdateSeries(globex, "cpu", models.SeriesUpdate{Unit: &unit})
+	require.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestService_TenantQuotas(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock()
+	refunded := &atomic.Int64{}
+	srv.tenants = tenantsMock{
+		limits: map[string]models.TenantLimits{
+			"acme": {MaxSeries: 2, Capacity: 100},
+		},
+		rateLimited: map[string]bool{"globex": true},
+		refunded:    refunded,
+	}
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+
+	withMaxPoints := func(name string, maxPoints uint64) models.Series {
+		series := testSeries(name, nil)
+		series.Retention.MaxPoints = maxPoints
+		return series
+	}
+	testCases := []struct {
+		ctx       context.Context
+		series    models.Series
+		maxPoints uint64
+		err       error
+	}{
+		// Tenant capacity is a default max points.
+		{acme, testSeries("cpu", nil), 100, nil},
+		{acme, withMaxPoints("big", 101), 0, models.ErrQuotaExceeded},
+		{acme, withMaxPoints("mem", 50), 50, nil},
+		{acme, testSeries("disk", nil), 0, models.ErrQuotaExceeded},
+		// Other tenants are not limited.
+		{globex, withMaxPoints("big", 101), 101, nil},
+	}
+	for i, tc := range testCases {
+		series, err := srv.CreateSeries(tc.ctx, tc.series)
+		require.ErrorIs(t, err, tc.err, fmt.Sprintf("case %d", i))
+		require.Equal(t, tc.maxPoints, series.Retention.MaxPoints, fmt.Sprintf("case %d", i))
+	}
+
+	maxPoints := uint64(200)
+	_, err := srv.UpdateSeries(acme, "cpu", models.SeriesUpdate{Retention: &models.Retention{MaxPoints: maxPoints}})
+	require.ErrorIs(t, err, models.ErrQuotaExceeded)
+	_, err = srv.UpdateSeries(globex, "big", models.SeriesUpdate{Retention: &models.Retention{MaxPoints: maxPoints}})
+	require.NoError(t, err)
+
+	require.NoError(t, srv.Create(acme, models.Record{Series: "cpu", Timestamp: testTimestamp, MetricValue: 1.5}))
+	require.ErrorIs(t, srv.Create(globex, models.Record{Series: "big", Timestamp: testTimestamp, MetricValue: 1.5}),
+		models.ErrRateLimited)
+	require.Zero(t, refunded.Load())
+	// Points which failed to be saved are refunded to ingest quota.
+	require.ErrorIs(t, srv.Create(acme, models.Record{Series: "cpu", Timestamp: testTimestamp,
+		MetricValue: float64(errorMetric)}), errTest)
+	require.Equal(t, int64(1), refunded.Load())
+}
+
+func TestService_Usage(t *testing.T) {
+	t.Parallel()
+	srv := newServiceMock(testSeries("cpu", nil))
+	srv.tenants = tenantsMock{
+		limits: map[string]models.TenantLimits{"acme": {Capacity: 100}},
+	}
+	acme := tenantContext("acme")
+	_, err := srv.CreateSeries(acme, testSeries("cpu", nil))
+	require.NoError(t, err)
+	_, err = srv.CreateSeries(acme, testSeries("mem", nil))
+	require.NoError(t, err)
+
+	usage, err := srv.Usage(acme)
+	require.NoError(t, err)
+	require.Equal(t, models.TenantUsage{
+		Tenant:   "acme",
+		Series:   2,
+		Points:   2,
+		Capacity: 200,
+		Ingest:   models.IngestUsage{Accepted: 1},
+		Limits:   models.TenantLimits{Capacity: 100},
+	}, usage)
+
+	all, err := srv.ListUsage(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	require.Equal(t, models.DefaultTenant, all[0].Tenant)
+	require.Equal(t, 2, all[0].Series)
+	require.Equal(t, uint64(2000), all[0].Capacity)
+	require.Equal(t, usage, all[1])
+}
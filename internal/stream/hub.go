@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Errors that end subscriptions, subscriptions closed by subscribers end without error.
+var (
+	// ErrSlowConsumer means that buffer of the subscriber was full when a record was published.
+	ErrSlowConsumer = errors.New("slow consumer")
+	// ErrClosed means that hub is closed on shutdown.
+	ErrClosed = errors.New("hub is closed")
+)
+
+// Options contains hub params.
+type Options struct {
+	// BufferSize is a number of records buffered for each subscriber, subscriber is disconnected when it is full.
+	BufferSize int
+	// MaxSubscribers is a max number of subscriptions of all series, zero means no limit.
+	MaxSubscribers int
+}
+
+// Hub is an in-process pub/sub of new records by series. Publishing never blocks, records are dropped
+// together with the subscriber that doesn't read them fast enough.
+type Hub struct {
+	opts Options
+
+	mu     sync.RWMutex
+	series map[string]map[*Subscription]struct{}
+	count  int
+	closed bool
+
+	slowDisconnects atomic.Uint64
+}
+
+// NewHub returns new hub.
+func NewHub(opts Options) *Hub {
+	return &Hub{
+		opts:   opts,
+		series: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe subscribes to records of the series, series is a storage name qualified by tenant.
+// It returns models.ErrOverloaded if there are too many subscriptions and models.ErrUnavailable if hub is closed.
+func (h *Hub) Subscribe(series string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, fmt.Errorf("%w: %w", models.NewDetailError(models.ErrUnavailable, "streaming is shut down"), ErrClosed)
+	}
+	if h.opts.MaxSubscribers > 0 && h.count >= h.opts.MaxSubscribers {
+		return nil, models.NewDetailError(models.ErrOverloaded, "%d subscriptions are open", h.count)
+	}
+
+	sub := &Subscription{
+		hub:     h,
+		series:  series,
+		records: make(chan models.Record, max(h.opts.BufferSize, 1)),
+		done:    make(chan struct{}),
+	}
+	subs, ok := h.series[series]
+	if !ok {
+		subs = make(map[*Subscription]struct{})
+		h.series[series] = subs
+	}
+	subs[sub] = struct{}{}
+	h.count++
+	return sub, nil
+}
+
+// Publish sends record to subscribers of its series, subscribers with full buffers are disconnected.
+func (h *Hub) Publish(record models.Record) {
+	var slow []*Subscription
+	h.mu.RLock()
+	for sub := range h.series[record.Series] {
+		select {
+		case sub.records <- record:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range slow {
+		if sub.end(ErrSlowConsumer) {
+			h.slowDisconnects.Add(1)
+		}
+	}
+}
+
+// Close ends all subscriptions with ErrClosed, so streams are finished on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	var subs []*Subscription
+	for _, seriesSubs := range h.series {
+		for sub := range seriesSubs {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.end(ErrClosed)
+	}
+}
+
+// Subscribers returns number of open subscriptions.
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.count
+}
+
+// SlowDisconnects returns number of subscribers disconnected because of full buffers.
+func (h *Hub) SlowDisconnects() uint64 {
+	return h.slowDisconnects.Load()
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs := h.series[sub.series]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.series, sub.series)
+	}
+	h.count--
+}
+
+// Subscription receives records published to a series until it is closed.
+type Subscription struct {
+	hub     *Hub
+	series  string
+	records chan models.Record
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// Records returns channel of published records, it is never closed, Done is closed when subscription ends.
+func (s *Subscription) Records() <-chan models.Record {
+	return s.records
+}
+
+// Done is closed when subscription ends.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns reason of the end of subscription, it is nil if subscription is open or closed by subscriber.
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close ends subscription, it is safe to call it several times.
+func (s *Subscription) Close() {
+	s.end(nil)
+}
+
+// end removes subscription from hub, it returns false if subscription has already ended.
+func (s *Subscription) end(err error) bool {
+	ended := false
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+		s.hub.remove(s)
+		ended = true
+	})
+	return ended
+}
@@ -0,0 +1,93 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+func TestHub_Publish(t *testing.T) {
+	t.Parallel()
+	hub := NewHub(Options{BufferSize: 2})
+	cpu, err := hub.Subscribe("cpu")
+	require.NoError(t, err)
+	otherCPU, err := hub.Subscribe("cpu")
+	require.NoError(t, err)
+	tenantCPU, err := hub.Subscribe("acme/cpu")
+	require.NoError(t, err)
+	require.Equal(t, 3, hub.Subscribers())
+
+	hub.Publish(models.Record{Series: "cpu", Timestamp: 1})
+	hub.Publish(models.Record{Series: "memory", Timestamp: 2})
+
+	for _, sub := range []*Subscription{cpu, otherCPU} {
+		require.Equal(t, models.Record{Series: "cpu", Timestamp: 1}, <-sub.Records())
+		require.Empty(t, sub.Records())
+	}
+	require.Empty(t, tenantCPU.Records())
+
+	cpu.Close()
+	cpu.Close()
+	require.NoError(t, cpu.Err())
+	require.Equal(t, 2, hub.Subscribers())
+	hub.Publish(models.Record{Series: "cpu", Timestamp: 3})
+	require.Empty(t, cpu.Records())
+	require.Len(t, otherCPU.Records(), 1)
+}
+
+func TestHub_SlowConsumer(t *testing.T) {
+	t.Parallel()
+	hub := NewHub(Options{BufferSize: 2})
+	slow, err := hub.Subscribe("cpu")
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		hub.Publish(models.Record{Series: "cpu", Timestamp: int64(i)})
+	}
+
+	<-slow.Done()
+	require.ErrorIs(t, slow.Err(), ErrSlowConsumer)
+	require.Equal(t, 0, hub.Subscribers())
+	require.Equal(t, uint64(1), hub.SlowDisconnects())
+	// Buffered records are still readable.
+	require.Len(t, slow.Records(), 2)
+}
+
+func TestHub_Limits(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		maxSubscribers int
+		closed         bool
+		err            error
+	}{
+		{0, false, nil},
+		{2, false, models.ErrOverloaded},
+		{0, true, models.ErrUnavailable},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			hub := NewHub(Options{MaxSubscribers: tc.maxSubscribers})
+			first, err := hub.Subscribe("cpu")
+			require.NoError(t, err)
+			_, err = hub.Subscribe("memory")
+			require.NoError(t, err)
+			if tc.closed {
+				hub.Close()
+				<-first.Done()
+				require.ErrorIs(t, first.Err(), ErrClosed)
+			}
+
+			_, err = hub.Subscribe("cpu")
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
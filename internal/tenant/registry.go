@@ -0,0 +1,171 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+
+	"aerospike.com/rrd/internal/models"
+	"aerospike.com/rrd/internal/resilience"
+)
+
+// Config contains placement and limits of tenants, it is loaded from yaml file.
+type Config struct {
+	// Defaults are limits of tenants that are not listed.
+	Defaults Limits   `yaml:"defaults"`
+	Tenants  []Tenant `yaml:"tenants"`
+}
+
+// Tenant contains placement and limits of a tenant.
+type Tenant struct {
+	Name string `yaml:"name"`
+	// Namespace is aerospike namespace of tenant sets, storage namespace is used if empty.
+	Namespace string `yaml:"namespace"`
+	Limits    Limits `yaml:"limits"`
+}
+
+// Limits are tenant quotas, see models.TenantLimits.
+type Limits struct {
+	MaxSeries   int     `yaml:"max_series"`
+	Capacity    uint64  `yaml:"capacity"`
+	IngestRate  float64 `yaml:"ingest_rate"`
+	IngestBurst int     `yaml:"ingest_burst"`
+}
+
+func (l Limits) model() models.TenantLimits {
+	return models.TenantLimits{
+		MaxSeries:   l.MaxSeries,
+		Capacity:    l.Capacity,
+		IngestRate:  l.IngestRate,
+		IngestBurst: l.IngestBurst,
+	}
+}
+
+func (l Limits) validate() error {
+	if l.MaxSeries < 0 || l.IngestRate < 0 || l.IngestBurst < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
+
+// LoadConfig reads tenants config from yaml file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+	var cfg Config
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse tenants file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Registry keeps limits of tenants and enforces ingest rate quota.
+type Registry struct {
+	defaults   models.TenantLimits
+	limits     map[string]models.TenantLimits
+	namespaces map[string]string
+
+	mu     sync.Mutex
+	ingest map[string]*ingestState
+}
+
+// ingestState contains rate limiter and counters of a tenant, it is created on the first write.
+type ingestState struct {
+	limiter  *resilience.Limiter
+	accepted atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// NewRegistry returns new tenants registry, empty config means no limits.
+func NewRegistry(cfg Config) (*Registry, error) {
+	if err := cfg.Defaults.validate(); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	r := &Registry{
+		defaults:   cfg.Defaults.model(),
+		limits:     make(map[string]models.TenantLimits, len(cfg.Tenants)),
+		namespaces: make(map[string]string),
+		ingest:     make(map[string]*ingestState),
+	}
+	for i, tenant := range cfg.Tenants {
+		if err := models.ValidateTenant(tenant.Name); err != nil {
+			return nil, fmt.Errorf("tenant %d: %w", i, err)
+		}
+		if _, ok := r.limits[tenant.Name]; ok {
+			return nil, fmt.Errorf("tenant %s: duplicated tenant", tenant.Name)
+		}
+		if err := tenant.Limits.validate(); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if tenant.Namespace != "" {
+			if tenant.Name == models.DefaultTenant {
+				return nil, fmt.Errorf("tenant %s: namespace of default tenant is set by STORAGE_NAMESPACE", tenant.Name)
+			}
+			r.namespaces[tenant.Name] = tenant.Namespace
+		}
+		r.limits[tenant.Name] = tenant.Limits.model()
+	}
+	return r, nil
+}
+
+// Limits returns limits of the tenant, defaults are returned for tenants that are not listed.
+func (r *Registry) Limits(tenant string) models.TenantLimits {
+	if limits, ok := r.limits[tenant]; ok {
+		return limits
+	}
+	return r.defaults
+}
+
+// Namespaces returns aerospike namespaces of tenants that don't use storage namespace.
+func (r *Registry) Namespaces() map[string]string {
+	return r.namespaces
+}
+
+// AllowIngest takes points from ingest rate quota of the tenant, it returns models.ErrRateLimited if quota is
+// exhausted.
+func (r *Registry) AllowIngest(tenant string, points int) error {
+	state := r.ingestState(tenant)
+	allowed, wait := state.limiter.Allow(points)
+	if !allowed {
+		state.rejected.Add(uint64(points))
+		return models.NewRetryError(wait,
+			models.NewDetailError(models.ErrRateLimited, "ingest rate of tenant %s is exceeded", tenant))
+	}
+	state.accepted.Add(uint64(points))
+	return nil
+}
+
+// RefundIngest returns points taken by AllowIngest to ingest rate quota of the tenant, so points which failed to
+// be saved don't count toward the quota.
+func (r *Registry) RefundIngest(tenant string, points int) {
+	state := r.ingestState(tenant)
+	state.limiter.Return(points)
+	state.accepted.Add(^uint64(points - 1))
+}
+
+// IngestUsage returns number of points accepted and rejected by ingest quota of the tenant since start.
+func (r *Registry) IngestUsage(tenant string) models.IngestUsage {
+	state := r.ingestState(tenant)
+	return models.IngestUsage{
+		Accepted: state.accepted.Load(),
+		Rejected: state.rejected.Load(),
+	}
+}
+
+func (r *Registry) ingestState(tenant string) *ingestState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.ingest[tenant]
+	if !ok {
+		limits := r.Limits(tenant)
+		state = &ingestState{limiter: resilience.NewLimiter(limits.IngestRate, limits.IngestBurst)}
+		r.ingest[tenant] = state
+	}
+	return state
+}
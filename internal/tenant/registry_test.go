@@ -0,0 +1,78 @@
+package tenant
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+const testConfig = `
+defaults:
+  max_series: 10
+tenants:
+  - name: acme
+    namespace: acme
+    limits:
+      capacity: 5000
+      ingest_rate: 2
+      ingest_burst: 3
+  - name: default
+`
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "tenants.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig), 0o600))
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	r, err := NewRegistry(cfg)
+	require.NoError(t, err)
+
+	require.Equal(t, models.TenantLimits{Capacity: 5000, IngestRate: 2, IngestBurst: 3}, r.Limits("acme"))
+	// Listed tenant without limits has no limits.
+	require.Equal(t, models.TenantLimits{}, r.Limits(models.DefaultTenant))
+	require.Equal(t, models.TenantLimits{MaxSeries: 10}, r.Limits("globex"))
+	require.Equal(t, map[string]string{"acme": "acme"}, r.Namespaces())
+
+	require.NoError(t, r.AllowIngest("acme", 3))
+	err = r.AllowIngest("acme", 1)
+	require.ErrorIs(t, err, models.ErrRateLimited)
+	var retryErr *models.RetryError
+	require.ErrorAs(t, err, &retryErr)
+	require.Positive(t, retryErr.After)
+	require.NoError(t, r.AllowIngest("globex", 1000))
+	require.Equal(t, models.IngestUsage{Accepted: 3, Rejected: 1}, r.IngestUsage("acme"))
+	// Refunded points are available again and are not accepted.
+	r.RefundIngest("acme", 1)
+	require.NoError(t, r.AllowIngest("acme", 1))
+	require.ErrorIs(t, r.AllowIngest("acme", 1), models.ErrRateLimited)
+	require.Equal(t, models.IngestUsage{Accepted: 3, Rejected: 2}, r.IngestUsage("acme"))
+	require.Equal(t, models.IngestUsage{Accepted: 1000}, r.IngestUsage("globex"))
+}
+
+func TestNewRegistry_Invalid(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		cfg Config
+		err string
+	}{
+		{Config{Tenants: []Tenant{{Name: "Acme"}}}, "tenant 0"},
+		{Config{Tenants: []Tenant{{Name: "acme"}, {Name: "acme"}}}, "duplicated tenant"},
+		{Config{Tenants: []Tenant{{Name: "acme", Limits: Limits{IngestRate: -1}}}}, "must not be negative"},
+		{Config{Tenants: []Tenant{{Name: models.DefaultTenant, Namespace: "other"}}}, "STORAGE_NAMESPACE"},
+		{Config{Defaults: Limits{MaxSeries: -1}}, "defaults"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			_, err := NewRegistry(tc.cfg)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package timeexpr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidExpression is returned when time expression can't be parsed.
+var ErrInvalidExpression = errors.New("invalid time expression")
+
+// Anchors that can be used as a base for relative offsets.
+const (
+	anchorNow          = "now"
+	anchorToday        = "today"
+	anchorYesterday    = "yesterday"
+	anchorTomorrow     = "tomorrow"
+	anchorStartOfDay   = "startofday"
+	anchorStartOfWeek  = "startofweek"
+	anchorStartOfMonth = "startofmonth"
+	anchorStartOfYear  = "startofyear"
+)
+
+// Results of expressions are limited to the range of unix microseconds.
+var (
+	minTime = time.UnixMicro(math.MinInt64)
+	maxTime = time.UnixMicro(math.MaxInt64)
+)
+
+// Layouts for ISO8601 dates without time zone, they are parsed in configured location.
+var localLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Parser parses time expressions to unix microseconds.
+// Supported formats:
+//   - raw integer: epoch in microseconds (legacy format), e.g. `1717745157997559`;
+//   - integer with unit suffix: epoch in s, ms, us or ns, e.g. `1717745157s`;
+//   - RFC3339 and ISO8601, e.g. `2024-06-07T10:00:00Z`, `2024-06-07T10:00`, `2024-06-07`;
+//   - anchors: now, today, yesterday, tomorrow, startofday, startofweek, startofmonth, startofyear;
+//   - anchors with offsets, e.g. `now-6h`, `today+8h30m`, `startofweek-1w`;
+//   - offsets relative to now, e.g. `-1d`, `+15m`.
+//
+// Offset units: us, ms, s, m, h, d, w. Days and weeks are calendar days in configured location.
+type Parser struct {
+	location *time.Location
+}
+
+// NewParser returns new time expression parser.
+// Dates without zone and calendar anchors are resolved in the given location.
+func NewParser(location *time.Location) *Parser {
+	if location == nil {
+		location = time.UTC
+	}
+	return &Parser{
+		location: location,
+	}
+}
+
+// Parse converts time expression to unix microseconds. `now` is used as a base for relative expressions,
+// so several expressions from one request can be resolved against the same moment.
+func (p *Parser) Parse(expr string, now time.Time) (int64, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return 0, fmt.Errorf("%w: empty expression", ErrInvalidExpression)
+	}
+
+	if ts, ok, err := parseEpoch(expr); ok {
+		return ts, err
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, expr); err == nil {
+		return t.UnixMicro(), nil
+	}
+
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, expr, p.location); err == nil {
+			return t.UnixMicro(), nil
+		}
+	}
+
+	t, err := p.parseRelative(strings.ToLower(expr), now.In(p.location))
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMicro(), nil
+}
+
+// parseEpoch parses epoch integer with optional unit suffix.
+// Second returned value reports whether expression looks like epoch at all.
+func parseEpoch(expr string) (int64, bool, error) {
+	digits := strings.TrimRightFunc(expr, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if digits == "" || !isDigits(digits) {
+		return 0, false, nil
+	}
+
+	value, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, true, fmt.Errorf("%w: %q: %w", ErrInvalidExpression, expr, err)
+	}
+
+	var multiplier, divider int64 = 1, 1
+	switch unit := strings.ToLower(expr[len(digits):]); unit {
+	case "", "us":
+	case "s":
+		multiplier = int64(time.Second / time.Microsecond)
+	case "ms":
+		multiplier = int64(time.Millisecond / time.Microsecond)
+	case "ns":
+		divider = int64(time.Microsecond)
+	default:
+		return 0, false, nil
+	}
+
+	if value > (1<<63-1)/multiplier {
+		return 0, true, fmt.Errorf("%w: %q: value out of range", ErrInvalidExpression, expr)
+	}
+
+	return value * multiplier / divider, true, nil
+}
+
+// parseRelative parses anchor with optional offsets, or offsets relative to now.
+func (p *Parser) parseRelative(expr string, now time.Time) (time.Time, error) {
+	rest := strings.TrimLeft(expr, "abcdefghijklmnopqrstuvwxyz")
+	anchor := expr[:len(expr)-len(rest)]
+	if anchor == "" {
+		anchor = anchorNow
+		if rest == "" || (rest[0] != '-' && rest[0] != '+') {
+			return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidExpression, expr)
+		}
+	}
+
+	base, err := resolveAnchor(anchor, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q: %w", ErrInvalidExpression, expr, err)
+	}
+
+	result, err := applyOffsets(base, rest)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q: %w", ErrInvalidExpression, expr, err)
+	}
+
+	return result, nil
+}
+
+// resolveAnchor returns anchor time, calendar anchors are aligned to midnight of `now` location.
+func resolveAnchor(anchor string, now time.Time) (time.Time, error) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch anchor {
+	case anchorNow:
+		return now, nil
+	case anchorToday, anchorStartOfDay:
+		return midnight, nil
+	case anchorYesterday:
+		return midnight.AddDate(0, 0, -1), nil
+	case anchorTomorrow:
+		return midnight.AddDate(0, 0, 1), nil
+	case anchorStartOfWeek:
+		// Weeks start on Monday (ISO8601).
+		daysSinceMonday := (int(now.Weekday()) + 6) % 7
+		return midnight.AddDate(0, 0, -daysSinceMonday), nil
+	case anchorStartOfMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), nil
+	case anchorStartOfYear:
+		return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location()), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown anchor %q", anchor)
+	}
+}
+
+// applyOffsets applies sequence of signed offsets like `-1d+6h` or `-1h30m` to base time.
+// A sign applies to all following components until the next sign.
+func applyOffsets(base time.Time, offsets string) (time.Time, error) {
+	if strings.HasSuffix(offsets, "-") || strings.HasSuffix(offsets, "+") {
+		return time.Time{}, fmt.Errorf("dangling offset sign")
+	}
+
+	result := base
+	sign := 0
+	for offsets != "" {
+		switch offsets[0] {
+		case '-':
+			sign = -1
+			offsets = offsets[1:]
+			continue
+		case '+':
+			sign = 1
+			offsets = offsets[1:]
+			continue
+		}
+		if sign == 0 {
+			return time.Time{}, fmt.Errorf("offset must start with sign")
+		}
+
+		digits := offsets[:len(offsets)-len(strings.TrimLeft(offsets, "0123456789"))]
+		if digits == "" {
+			return time.Time{}, fmt.Errorf("missing offset value")
+		}
+		offsets = offsets[len(digits):]
+		unit := offsets[:len(offsets)-len(strings.TrimLeft(offsets, "abcdefghijklmnopqrstuvwxyz"))]
+		if unit == "" {
+			return time.Time{}, fmt.Errorf("missing offset unit after %q", digits)
+		}
+		offsets = offsets[len(unit):]
+
+		value, err := strconv.Atoi(digits)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid offset value: %w", err)
+		}
+		if int64(value) > maxOffset(unit) {
+			return time.Time{}, fmt.Errorf("offset %s%s is out of range", digits, unit)
+		}
+		value *= sign
+
+		switch unit {
+		case "w":
+			result = result.AddDate(0, 0, 7*value)
+		case "d":
+			result = result.AddDate(0, 0, value)
+		case "h":
+			result = result.Add(time.Duration(value) * time.Hour)
+		case "m":
+			result = result.Add(time.Duration(value) * time.Minute)
+		case "s":
+			result = result.Add(time.Duration(value) * time.Second)
+		case "ms":
+			result = result.Add(time.Duration(value) * time.Millisecond)
+		case "us":
+			result = result.Add(time.Duration(value) * time.Microsecond)
+		default:
+			return time.Time{}, fmt.Errorf("unknown offset unit %q", unit)
+		}
+	}
+
+	if result.Before(minTime) || result.After(maxTime) {
+		return time.Time{}, fmt.Errorf("time is out of range")
+	}
+	return result, nil
+}
+
+// maxOffset returns max value of an offset in the unit. Days and weeks are limited by the range of unix
+// microseconds, other units by the range of time.Duration.
+func maxOffset(unit string) int64 {
+	const maxDays = math.MaxInt64 / int64(24*time.Hour/time.Microsecond)
+	switch unit {
+	case "w":
+		return maxDays / 7
+	case "d":
+		return maxDays
+	case "h":
+		return math.MaxInt64 / int64(time.Hour)
+	case "m":
+		return math.MaxInt64 / int64(time.Minute)
+	case "s":
+		return math.MaxInt64 / int64(time.Second)
+	case "ms":
+		return math.MaxInt64 / int64(time.Millisecond)
+	case "us":
+		return math.MaxInt64 / int64(time.Microsecond)
+	default:
+		// Unknown units are rejected when the offset is applied.
+		return math.MaxInt64
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
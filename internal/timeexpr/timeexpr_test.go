@@ -0,0 +1,85 @@
+package timeexpr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_Parse(t *testing.T) {
+	t.Parallel()
+	location, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	p := NewParser(location)
+	// Wednesday.
+	now := time.Date(2024, time.June, 12, 15, 30, 0, 0, location)
+	midnight := time.Date(2024, time.June, 12, 0, 0, 0, 0, location)
+
+	testCases := []struct {
+		expr   string
+		result int64
+		err    error
+	}{
+		{"1717745157997559", 1717745157997559, nil},
+		{"0", 0, nil},
+		{"1717745157s", 1717745157000000, nil},
+		{"1717745157997ms", 1717745157997000, nil},
+		{"1717745157997559us", 1717745157997559, nil},
+		{"1717745157997559123ns", 1717745157997559, nil},
+		{"2024-06-07T07:25:57Z", time.Date(2024, time.June, 7, 7, 25, 57, 0, time.UTC).UnixMicro(), nil},
+		{"2024-06-07T07:25:57.5+02:00", time.Date(2024, time.June, 7, 5, 25, 57, 5e8, time.UTC).UnixMicro(), nil},
+		{"2024-06-07T07:25", time.Date(2024, time.June, 7, 7, 25, 0, 0, location).UnixMicro(), nil},
+		{"2024-06-07", time.Date(2024, time.June, 7, 0, 0, 0, 0, location).UnixMicro(), nil},
+		{"now", now.UnixMicro(), nil},
+		{"NOW", now.UnixMicro(), nil},
+		{"now-6h", now.Add(-6 * time.Hour).UnixMicro(), nil},
+		{"now-1h30m", now.Add(-90 * time.Minute).UnixMicro(), nil},
+		{"now-1d+1h", now.AddDate(0, 0, -1).Add(time.Hour).UnixMicro(), nil},
+		{"-1d", now.AddDate(0, 0, -1).UnixMicro(), nil},
+		{"+15m", now.Add(15 * time.Minute).UnixMicro(), nil},
+		{"-500ms", now.Add(-500 * time.Millisecond).UnixMicro(), nil},
+		{"today", midnight.UnixMicro(), nil},
+		{"startofday", midnight.UnixMicro(), nil},
+		{"today+8h", midnight.Add(8 * time.Hour).UnixMicro(), nil},
+		{"yesterday", midnight.AddDate(0, 0, -1).UnixMicro(), nil},
+		{"tomorrow", midnight.AddDate(0, 0, 1).UnixMicro(), nil},
+		{"startofweek", midnight.AddDate(0, 0, -2).UnixMicro(), nil},
+		{"startofweek-1w", midnight.AddDate(0, 0, -9).UnixMicro(), nil},
+		{"startofmonth", time.Date(2024, time.June, 1, 0, 0, 0, 0, location).UnixMicro(), nil},
+		{"startofyear", time.Date(2024, time.January, 1, 0, 0, 0, 0, location).UnixMicro(), nil},
+		{"", 0, ErrInvalidExpression},
+		{"-1", 0, ErrInvalidExpression},
+		{"1h", 0, ErrInvalidExpression},
+		{"now-", 0, ErrInvalidExpression},
+		{"now-6x", 0, ErrInvalidExpression},
+		{"now6h", 0, ErrInvalidExpression},
+		{"later", 0, ErrInvalidExpression},
+		{"abc", 0, ErrInvalidExpression},
+		{"99999999999999999999", 0, ErrInvalidExpression},
+		{"9999999999999999s", 0, ErrInvalidExpression},
+		// Offsets beyond the range of unix microseconds.
+		{"now-300000000d", 0, ErrInvalidExpression},
+		{"now-3000000h", 0, ErrInvalidExpression},
+		{"now-9223372036854775807w", 0, ErrInvalidExpression},
+		{"now-100000000d-100000000d", 0, ErrInvalidExpression},
+		{"2024-13-01", 0, ErrInvalidExpression},
+	}
+
+	for i, tt := range testCases {
+		result, err := p.Parse(tt.expr, now)
+		require.ErrorIs(t, err, tt.err, fmt.Sprintf("case %d: %s", i, tt.expr))
+		require.Equal(t, tt.result, result, fmt.Sprintf("case %d: %s", i, tt.expr))
+	}
+}
+
+func TestParser_StartOfWeekOnSunday(t *testing.T) {
+	t.Parallel()
+	p := NewParser(time.UTC)
+	sunday := time.Date(2024, time.June, 16, 23, 0, 0, 0, time.UTC)
+
+	result, err := p.Parse("startofweek", sunday)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC).UnixMicro(), result)
+}
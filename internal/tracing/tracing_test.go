@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// Test is not parallel, as tracer provider is global.
+func TestSetup(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Options{
+		Exporter:    ExporterStdout,
+		SampleRatio: 1,
+		ServiceName: "rrd-test",
+		Version:     "v1.0.0",
+		Writer:      &buf,
+	})
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "operation")
+	span.End()
+	// Spans are exported in batches, shutdown flushes them.
+	require.NoError(t, shutdown(context.Background()))
+	require.Contains(t, buf.String(), `"Name":"operation"`)
+	require.Contains(t, buf.String(), `"Value":"rrd-test"`)
+	require.Contains(t, buf.String(), `"Value":"v1.0.0"`)
+
+	_, err = Setup(context.Background(), Options{Exporter: "jaeger"})
+	require.ErrorContains(t, err, `unknown exporter "jaeger"`)
+}
+
+func TestEnd(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		err    error
+		status codes.Code
+		events int
+	}{
+		{nil, codes.Unset, 0},
+		{fmt.Errorf("failed to get series: %w", models.ErrNotFound), codes.Unset, 1},
+		{fmt.Errorf("failed to put bins: %w", models.ErrUnavailable), codes.Error, 1},
+		{errors.New("failed to cast counter to int"), codes.Error, 1},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			t.Parallel()
+			recorder := tracetest.NewSpanRecorder()
+			_, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).
+				Tracer("test").Start(context.Background(), "operation")
+			End(span, tc.err)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			require.Equal(t, tc.status, spans[0].Status().Code)
+			require.Len(t, spans[0].Events(), tc.events)
+		})
+	}
+}
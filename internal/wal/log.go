@@ -0,0 +1,241 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// ErrFull is returned when entry doesn't fit into max size of the log.
+var ErrFull = errors.New("write-ahead log is full")
+
+// offsetSuffix is a suffix of a file that keeps offset of the first not replayed entry.
+const offsetSuffix = ".offset"
+
+// Entry is a buffered write.
+type Entry struct {
+	Record models.Record `json:"record"`
+	// ValueType restores go type of the metric value, as json doesn't keep it.
+	ValueType models.ValueType `json:"value_type"`
+	Retention models.Retention `json:"retention"`
+}
+
+// Log is an append only file of entries in json lines format. Entries are replayed in order,
+// and the file is truncated when all of them are replayed.
+// Offset of replayed entries is saved after each replay, so entries may be replayed twice after crash.
+type Log struct {
+	path    string
+	maxSize int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// offset is a position of the first not replayed entry.
+	offset  int64
+	pending int
+}
+
+// Open opens or creates log file, zero max size means unlimited log.
+// Incomplete entry at the end of the file, that is left after crash, is dropped.
+func Open(path string, maxSize int64) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	l := &Log{
+		path:    path,
+		maxSize: maxSize,
+		file:    file,
+	}
+	if err = l.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// load reads offset and counts pending entries.
+func (l *Log) load() error {
+	data, err := os.ReadFile(l.path + offsetSuffix)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read write-ahead log offset: %w", err)
+	default:
+		if l.offset, err = strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64); err != nil {
+			return fmt.Errorf("failed to parse write-ahead log offset: %w", err)
+		}
+	}
+
+	entries := 0
+	reader := bufio.NewReader(l.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read write-ahead log: %w", err)
+		}
+		if l.size >= l.offset {
+			l.pending++
+		}
+		entries++
+		l.size += int64(len(line))
+	}
+	// Offset covers only complete entries, so offset beyond the size is left by crash during truncation
+	// after all entries were replayed.
+	if l.offset > l.size {
+		l.offset = 0
+		l.pending = entries
+	}
+
+	if err := l.file.Truncate(l.size); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+	if _, err := l.file.Seek(l.size, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// Append writes entry to the end of the log and syncs the file.
+func (l *Log) Append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize > 0 && l.size+int64(len(line)) > l.maxSize {
+		return ErrFull
+	}
+	if _, err := l.file.Write(line); err != nil {
+		// Partial entry is dropped on the next open.
+		return fmt.Errorf("failed to write entry: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync write-ahead log: %w", err)
+	}
+	l.size += int64(len(line))
+	l.pending++
+	return nil
+}
+
+// Pending returns number of not replayed entries.
+func (l *Log) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pending
+}
+
+// Replay passes pending entries to apply in order, until all of them are applied or apply fails.
+// Entries appended during replay are replayed too. It returns number of applied entries.
+func (l *Log) Replay(ctx context.Context, apply func(entry Entry) error) (int, error) {
+	reader, err := os.Open(l.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	defer reader.Close()
+
+	l.mu.Lock()
+	offset := l.offset
+	l.mu.Unlock()
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek write-ahead log: %w", err)
+	}
+
+	applied := 0
+	buffered := bufio.NewReader(reader)
+	for {
+		if err := ctx.Err(); err != nil {
+			return applied, l.commit(offset, applied, fmt.Errorf("context error: %w", err))
+		}
+		// Entries are read up to size, so partially written entry is never read.
+		l.mu.Lock()
+		size := l.size
+		l.mu.Unlock()
+		if offset >= size {
+			break
+		}
+
+		line, err := buffered.ReadBytes('\n')
+		if err != nil {
+			return applied, l.commit(offset, applied, fmt.Errorf("failed to read write-ahead log: %w", err))
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return applied, l.commit(offset, applied, fmt.Errorf("failed to decode entry at %d: %w", offset, err))
+		}
+		if err := apply(entry); err != nil {
+			return applied, l.commit(offset, applied, err)
+		}
+		offset += int64(len(line))
+		applied++
+	}
+
+	return applied, l.commit(offset, applied, nil)
+}
+
+// commit saves offset of replayed entries, log is truncated if all entries are replayed.
+func (l *Log) commit(offset int64, applied int, replayErr error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending -= applied
+	l.offset = offset
+
+	var err error
+	if l.offset == l.size {
+		err = l.truncate()
+	} else {
+		err = l.saveOffset()
+	}
+	return errors.Join(replayErr, err)
+}
+
+// truncate removes offset before the file is truncated, so crash between them leaves replayed entries
+// that are replayed again instead of offset beyond the size.
+func (l *Log) truncate() error {
+	if err := os.Remove(l.path + offsetSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove write-ahead log offset: %w", err)
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek write-ahead log: %w", err)
+	}
+	l.size = 0
+	l.offset = 0
+	return nil
+}
+
+// saveOffset replaces offset file atomically.
+func (l *Log) saveOffset() error {
+	tmp := l.path + offsetSuffix + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(l.offset, 10)), 0o600); err != nil {
+		return fmt.Errorf("failed to write write-ahead log offset: %w", err)
+	}
+	if err := os.Rename(tmp, l.path+offsetSuffix); err != nil {
+		return fmt.Errorf("failed to save write-ahead log offset: %w", err)
+	}
+	return nil
+}
+
+// Close closes log file, pending entries are kept for the next open.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
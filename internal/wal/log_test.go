@@ -0,0 +1,134 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+var errTest = errors.New("test error")
+
+func newEntry(timestamp int64) Entry {
+	return Entry{
+		Record:    models.Record{Series: "cpu", Timestamp: timestamp, MetricValue: 1.5},
+		ValueType: models.ValueTypeFloat,
+		Retention: models.Retention{MaxPoints: 10},
+	}
+}
+
+// replayAll returns timestamps of replayed entries, apply fails on failAt timestamp.
+func replayAll(t *testing.T, l *Log, failAt int64) ([]int64, error) {
+	t.Helper()
+	var timestamps []int64
+	_, err := l.Replay(context.Background(), func(entry Entry) error {
+		if entry.Record.Timestamp == failAt {
+			return errTest
+		}
+		timestamps = append(timestamps, entry.Record.Timestamp)
+		return nil
+	})
+	return timestamps, err
+}
+
+func TestLog_AppendReplay(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "wal")
+	l, err := Open(path, 0)
+	require.NoError(t, err)
+	defer l.Close()
+
+	for ts := int64(1); ts <= 3; ts++ {
+		require.NoError(t, l.Append(newEntry(ts)))
+	}
+	require.Equal(t, 3, l.Pending())
+
+	// Replay stops on failed entry, it is replayed again with the rest.
+	timestamps, err := replayAll(t, l, 2)
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, []int64{1}, timestamps)
+	require.Equal(t, 2, l.Pending())
+
+	require.NoError(t, l.Append(newEntry(4)))
+	timestamps, err = replayAll(t, l, 0)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2, 3, 4}, timestamps)
+	require.Equal(t, 0, l.Pending())
+
+	// Log is truncated when drained.
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+}
+
+func TestLog_Reopen(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "wal")
+	l, err := Open(path, 0)
+	require.NoError(t, err)
+	for ts := int64(1); ts <= 3; ts++ {
+		require.NoError(t, l.Append(newEntry(ts)))
+	}
+	_, err = replayAll(t, l, 2)
+	require.ErrorIs(t, err, errTest)
+	require.NoError(t, l.Close())
+
+	// Incomplete entry left after crash is dropped.
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"record":{"series":"cpu","timest`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	l, err = Open(path, 0)
+	require.NoError(t, err)
+	defer l.Close()
+	require.Equal(t, 2, l.Pending())
+	require.NoError(t, l.Append(newEntry(4)))
+
+	timestamps, err := replayAll(t, l, 0)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2, 3, 4}, timestamps)
+}
+
+func TestLog_Full(t *testing.T) {
+	t.Parallel()
+	l, err := Open(filepath.Join(t.TempDir(), "wal"), 200)
+	require.NoError(t, err)
+	defer l.Close()
+
+	require.NoError(t, l.Append(newEntry(1)))
+	require.ErrorIs(t, l.Append(newEntry(2)), ErrFull)
+	require.Equal(t, 1, l.Pending())
+}
+
+func TestLog_ReopenTruncated(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "wal")
+	l, err := Open(path, 0)
+	require.NoError(t, err)
+	for ts := int64(1); ts <= 3; ts++ {
+		require.NoError(t, l.Append(newEntry(ts)))
+	}
+	_, err = replayAll(t, l, 3)
+	require.ErrorIs(t, err, errTest)
+	require.NoError(t, l.Close())
+
+	// Crash after the log is truncated leaves offset of the old log.
+	require.NoError(t, os.Truncate(path, 0))
+
+	l, err = Open(path, 0)
+	require.NoError(t, err)
+	defer l.Close()
+	require.Zero(t, l.Pending())
+	require.NoError(t, l.Append(newEntry(4)))
+
+	timestamps, err := replayAll(t, l, 0)
+	require.NoError(t, err)
+	require.Equal(t, []int64{4}, timestamps)
+}
@@ -0,0 +1,152 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+type storageSetter interface {
+	Set(ctx context.Context, record models.Record, retention models.Retention) error
+	SetBatch(ctx context.Context, writes []models.Write) []error
+}
+
+// Writer accepts writes into the log while storage is unavailable, and replays them when storage recovers.
+// Writes go to the log while it has pending entries, so records of a series are saved in order.
+type Writer struct {
+	storage  storageSetter
+	log      *Log
+	interval time.Duration
+	logger   *slog.Logger
+
+	// replayMu prevents concurrent replays.
+	replayMu sync.Mutex
+}
+
+// NewWriter returns new writer that buffers writes to storage in the log.
+func NewWriter(storage storageSetter, log *Log, interval time.Duration, logger *slog.Logger) *Writer {
+	return &Writer{
+		storage:  storage,
+		log:      log,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Set saves record to storage, or appends it to the log if storage is unavailable.
+// It returns models.ErrUnavailable if storage is unavailable and the log is full.
+func (w *Writer) Set(ctx context.Context, record models.Record, retention models.Retention) error {
+	if w.log.Pending() == 0 {
+		err := w.storage.Set(ctx, record, retention)
+		if !errors.Is(err, models.ErrUnavailable) {
+			return err
+		}
+		w.logger.Warn("storage is unavailable, writes are buffered in write-ahead log", slog.Any("error", err))
+	}
+
+	return w.buffer(record, retention)
+}
+
+// SetBatch saves records to storage like Set, writes failed because storage is unavailable are appended to the log.
+func (w *Writer) SetBatch(ctx context.Context, writes []models.Write) []error {
+	if w.log.Pending() > 0 {
+		errs := make([]error, len(writes))
+		for i, write := range writes {
+			errs[i] = w.buffer(write.Record, write.Retention)
+		}
+		return errs
+	}
+
+	errs := w.storage.SetBatch(ctx, writes)
+	buffered := 0
+	for i, err := range errs {
+		if errors.Is(err, models.ErrUnavailable) {
+			errs[i] = w.buffer(writes[i].Record, writes[i].Retention)
+			buffered++
+		}
+	}
+	if buffered > 0 {
+		w.logger.Warn("storage is unavailable, writes are buffered in write-ahead log", slog.Int("writes", buffered))
+	}
+	return errs
+}
+
+// buffer appends write to the log.
+func (w *Writer) buffer(record models.Record, retention models.Retention) error {
+	valueType, _ := models.ValueTypeOf(record.MetricValue)
+	entry := Entry{Record: record, ValueType: valueType, Retention: retention}
+	if err := w.log.Append(entry); err != nil {
+		return fmt.Errorf("%w: %w", models.NewDetailError(models.ErrUnavailable, "failed to buffer write"), err)
+	}
+	return nil
+}
+
+// PendingWrites returns number of writes in the log.
+func (w *Writer) PendingWrites() int {
+	return w.log.Pending()
+}
+
+// Run replays the log at interval until context is canceled, pending entries are replayed at once.
+func (w *Writer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if w.log.Pending() > 0 {
+			w.Flush(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush replays pending entries until storage fails, entries that are not replayed stay in the log.
+func (w *Writer) Flush(ctx context.Context) {
+	w.replayMu.Lock()
+	defer w.replayMu.Unlock()
+
+	applied, err := w.log.Replay(ctx, func(entry Entry) error {
+		return w.apply(ctx, entry)
+	})
+	if applied > 0 {
+		w.logger.Info("write-ahead log replayed",
+			slog.Int("applied", applied),
+			slog.Int("pending", w.log.Pending()),
+		)
+	}
+	if err != nil {
+		w.logger.Warn("failed to replay write-ahead log", slog.Any("error", err))
+	}
+}
+
+// apply saves entry to storage. Entries rejected by storage are dropped, as they would be rejected on every replay.
+func (w *Writer) apply(ctx context.Context, entry Entry) error {
+	value, err := models.ValueSpec{Type: entry.ValueType}.Normalize(entry.Record.MetricValue)
+	if err != nil {
+		w.logger.Error("dropping invalid write-ahead log entry", slog.Any("error", err))
+		return nil
+	}
+	entry.Record.MetricValue = value
+
+	err = w.storage.Set(ctx, entry.Record, entry.Retention)
+	if errors.Is(err, models.ErrUnavailable) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		w.logger.Error("dropping write-ahead log entry rejected by storage",
+			slog.String("series", entry.Record.Series),
+			slog.Int64("timestamp", entry.Record.Timestamp),
+			slog.Any("error", err),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aerospike.com/rrd/internal/models"
+)
+
+// storageMock fails writes with err, records are saved otherwise.
+type storageMock struct {
+	err     error
+	records []models.Record
+}
+
+func (mock *storageMock) Set(_ context.Context, record models.Record, _ models.Retention) error {
+	if mock.err != nil {
+		return mock.err
+	}
+	if record.Timestamp < 0 {
+		return models.NewValidationError("timestamp", "must be positive")
+	}
+	mock.records = append(mock.records, record)
+	return nil
+}
+
+func (mock *storageMock) SetBatch(ctx context.Context, writes []models.Write) []error {
+	errs := make([]error, len(writes))
+	for i, write := range writes {
+		errs[i] = mock.Set(ctx, write.Record, write.Retention)
+	}
+	return errs
+}
+
+func newTestWriter(t *testing.T, storage storageSetter, maxSize int64) *Writer {
+	t.Helper()
+	l, err := Open(filepath.Join(t.TempDir(), "wal"), maxSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	return NewWriter(storage, l, time.Minute, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+}
+
+func TestWriter_BuffersWhileUnavailable(t *testing.T) {
+	t.Parallel()
+	storage := &storageMock{}
+	writer := newTestWriter(t, storage, 0)
+	ctx := context.Background()
+	histogram := models.Histogram{Count: 1, Sum: 2, Buckets: []models.Bucket{{UpperBound: 5, Count: 1}}}
+
+	require.NoError(t, writer.Set(ctx, models.Record{Series: "a", Timestamp: 1, MetricValue: 1.5}, models.Retention{}))
+
+	storage.err = fmt.Errorf("%w: test", models.ErrUnavailable)
+	require.NoError(t, writer.Set(ctx, models.Record{Series: "a", Timestamp: 2, MetricValue: int64(2)}, models.Retention{}))
+	// Writes go to the log while it has pending entries, even if storage recovered.
+	storage.err = nil
+	require.NoError(t, writer.Set(ctx, models.Record{Series: "a", Timestamp: -1, MetricValue: true}, models.Retention{}))
+	require.NoError(t, writer.Set(ctx, models.Record{Series: "a", Timestamp: 3, MetricValue: histogram}, models.Retention{}))
+	require.Len(t, storage.records, 1)
+	require.Equal(t, 3, writer.log.Pending())
+
+	// Records are replayed in order with their value types, rejected record is dropped.
+	writer.Flush(ctx)
+	require.Equal(t, 0, writer.log.Pending())
+	require.Equal(t, []models.Record{
+		{Series: "a", Timestamp: 1, MetricValue: 1.5},
+		{Series: "a", Timestamp: 2, MetricValue: int64(2)},
+		{Series: "a", Timestamp: 3, MetricValue: histogram},
+	}, storage.records)
+}
+
+func TestWriter_SetBatch(t *testing.T) {
+	t.Parallel()
+	storage := &storageMock{err: fmt.Errorf("%w: test", models.ErrUnavailable)}
+	writer := newTestWriter(t, storage, 0)
+	ctx := context.Background()
+	writes := []models.Write{
+		{Record: models.Record{Series: "a", Timestamp: 1, MetricValue: 1.5}},
+		{Record: models.Record{Series: "a", Timestamp: 2, MetricValue: 2.5}},
+	}
+
+	require.Equal(t, []error{nil, nil}, writer.SetBatch(ctx, writes))
+	require.Equal(t, 2, writer.PendingWrites())
+
+	// Batch goes to the log while it has pending entries.
+	storage.err = nil
+	require.Equal(t, []error{nil}, writer.SetBatch(ctx, writes[:1]))
+	require.Empty(t, storage.records)
+	writer.Flush(ctx)
+	require.Equal(t, 0, writer.PendingWrites())
+	require.Len(t, storage.records, 3)
+
+	errs := writer.SetBatch(ctx, []models.Write{{Record: models.Record{Series: "a", Timestamp: -1, MetricValue: 1.5}}})
+	require.ErrorIs(t, errs[0], models.ErrInvalidArgument)
+	require.Equal(t, 0, writer.PendingWrites())
+}
+
+func TestWriter_FlushKeepsEntriesWhileUnavailable(t *testing.T) {
+	t.Parallel()
+	storage := &storageMock{err: fmt.Errorf("%w: test", models.ErrUnavailable)}
+	writer := newTestWriter(t, storage, 0)
+	ctx := context.Background()
+
+	require.NoError(t, writer.Set(ctx, models.Record{Series: "a", Timestamp: 1, MetricValue: 1.5}, models.Retention{}))
+	writer.Flush(ctx)
+	require.Equal(t, 1, writer.log.Pending())
+	require.Empty(t, storage.records)
+}
+
+func TestWriter_Full(t *testing.T) {
+	t.Parallel()
+	storage := &storageMock{err: fmt.Errorf("%w: test", models.ErrUnavailable)}
+	writer := newTestWriter(t, storage, 10)
+
+	err := writer.Set(context.Background(), models.Record{Series: "a", Timestamp: 1, MetricValue: 1.5}, models.Retention{})
+	require.ErrorIs(t, err, models.ErrUnavailable)
+	require.ErrorIs(t, err, ErrFull)
+}
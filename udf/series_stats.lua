@@ -0,0 +1,18 @@
+local function map_stats(record)
+    return map {points = 1, oldest = record.timestamp, newest = record.timestamp}
+end
+
+local function reduce_stats(a, b)
+    local result = map {points = a.points + b.points, oldest = a.oldest, newest = a.newest}
+    if b.oldest < result.oldest then
+        result.oldest = b.oldest
+    end
+    if b.newest > result.newest then
+        result.newest = b.newest
+    end
+    return result
+end
+
+function series_stats(stream)
+    return stream : map(map_stats) : reduce(reduce_stats)
+end